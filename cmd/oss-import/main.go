@@ -0,0 +1,227 @@
+// oss-import 把已有的文件批量导入 OSS，在 OSS 服务的工作目录（包含 data/）下运行
+//
+// 遍历目录时对象键为 -prefix 加上文件相对目录的路径：
+//
+//	oss-import -dir /mnt/legacy/media -bucket media -prefix legacy/ -user 1
+//
+// 或读取清单 CSV，首行为列名：path 必填，object_key、bucket、user_id、business_id 可选，
+// meta-<key> 列作为自定义元数据；相对路径相对于清单所在目录：
+//
+//	oss-import -manifest legacy.csv
+//
+// 每个源文件的结果追加到 -journal 日志中，中断后重新运行会跳过已完成的源文件
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	sqlite "github.com/ormasia/swiftstream/internal/common/db"
+	"github.com/ormasia/swiftstream/internal/oss/ingest"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	ossrepo "github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/trash"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	var (
+		dbPath      = flag.String("db", "data/swift.db", "SQLite 数据库路径")
+		dir         = flag.String("dir", "", "要导入的目录")
+		manifest    = flag.String("manifest", "", "清单 CSV，与 -dir 二选一")
+		bucket      = flag.String("bucket", model.DefaultBucket, "默认存储桶")
+		prefix      = flag.String("prefix", "", "遍历目录时对象键的前缀")
+		userID      = flag.Uint("user", 0, "默认对象所有者")
+		businessID  = flag.String("business", "", "默认业务关联ID")
+		journalPath = flag.String("journal", "data/import.journal", "导入日志，用于中断后续传")
+		workers     = flag.Int("workers", 4, "并发计算校验和的数量")
+		overwrite   = flag.Bool("overwrite", false, "对象键已存在时写入新版本")
+		dryRun      = flag.Bool("dry-run", false, "只报告导入结果，不复制文件也不创建对象")
+	)
+	flag.Parse()
+	if (*dir == "") == (*manifest == "") {
+		log.Fatal("Exactly one of -dir and -manifest is required")
+	}
+
+	db, err := sqlite.ConnectDB(sqlite.SQLiteCfg{
+		Path:         *dbPath,
+		MaxOpenConns: 4,
+		MaxIdleConns: 2,
+		ConnMaxLife:  2 * time.Hour,
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	// 导入大量文件时不逐条打印 SQL
+	db.Logger = logger.New(log.New(os.Stderr, "", log.LstdFlags), logger.Config{
+		SlowThreshold:             time.Second,
+		LogLevel:                  logger.Warn,
+		IgnoreRecordNotFoundError: true,
+	})
+	if err := ossrepo.CreateTable(db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	journal, err := ingest.OpenJournal(*journalPath)
+	if err != nil {
+		log.Fatalf("Failed to open journal: %v", err)
+	}
+	defer journal.Close()
+
+	cfg := ingest.DefaultConfig()
+	cfg.Workers = *workers
+	cfg.Overwrite = *overwrite
+	cfg.DryRun = *dryRun
+	// 回收站服务只用于把被覆盖的旧版本移入回收站，清理任务由 OSS 服务执行
+	importer := ingest.NewImporter(db, cfg, journal, trash.NewService(db, trash.DefaultConfig()))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	defaults := ingest.Item{Bucket: *bucket, UserID: uint(*userID), BusinessID: *businessID}
+	items := make(chan ingest.Item)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(items)
+		if *dir != "" {
+			errc <- walkDir(ctx, *dir, *prefix, defaults, items)
+		} else {
+			errc <- readManifest(ctx, *manifest, defaults, items)
+		}
+	}()
+
+	start := time.Now()
+	summary := importer.Run(ctx, items)
+	if err := <-errc; err != nil {
+		log.Printf("Stopped reading sources: %v\n", err)
+	}
+	log.Printf("Import finished in %s: %d files, %v, %d bytes copied\n",
+		time.Since(start).Round(time.Second), summary.Total(), summary.Results, summary.Bytes)
+	if summary.Results[ingest.ResultFailed] > 0 {
+		os.Exit(1)
+	}
+}
+
+// walkDir 按路径顺序遍历目录下的普通文件，对象键为 prefix 加上相对路径
+func walkDir(ctx context.Context, root, prefix string, defaults ingest.Item, items chan<- ingest.Item) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		item := defaults
+		item.Source = p
+		item.ObjectKey = prefix + filepath.ToSlash(rel)
+		select {
+		case items <- item:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// readManifest 逐行读取清单 CSV
+func readManifest(ctx context.Context, name string, defaults ingest.Item, items chan<- ingest.Item) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	base, err := filepath.Abs(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("read manifest header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, col := range header {
+		columns[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+	if _, ok := columns["path"]; !ok {
+		return errors.New("manifest has no path column")
+	}
+	get := func(row []string, col string) string {
+		if i, ok := columns[col]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	for line := 2; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		item := defaults
+		item.Source = get(row, "path")
+		if item.Source == "" {
+			log.Printf("Skipping manifest line %d: path is empty\n", line)
+			continue
+		}
+		if !filepath.IsAbs(item.Source) {
+			item.Source = filepath.Join(base, item.Source)
+		}
+		item.ObjectKey = get(row, "object_key")
+		if item.ObjectKey == "" {
+			item.ObjectKey = path.Base(filepath.ToSlash(item.Source))
+		}
+		if v := get(row, "bucket"); v != "" {
+			item.Bucket = v
+		}
+		if v := get(row, "user_id"); v != "" {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				log.Printf("Skipping manifest line %d: invalid user_id %q\n", line, v)
+				continue
+			}
+			item.UserID = uint(id)
+		}
+		if v := get(row, "business_id"); v != "" {
+			item.BusinessID = v
+		}
+		for col, i := range columns {
+			if key, ok := strings.CutPrefix(col, "meta-"); ok && key != "" && i < len(row) && row[i] != "" {
+				if item.Metadata == nil {
+					item.Metadata = make(map[string]string)
+				}
+				item.Metadata[key] = row[i]
+			}
+		}
+		select {
+		case items <- item:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -1,13 +1,28 @@
 package main
 
 import (
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	sqlite "github.com/ormasia/swiftstream/internal/common/db"
+	"github.com/ormasia/swiftstream/internal/oss/fetch"
 	osshandlers "github.com/ormasia/swiftstream/internal/oss/handlers"
+	"github.com/ormasia/swiftstream/internal/oss/keystore"
+	"github.com/ormasia/swiftstream/internal/oss/lifecycle"
+	"github.com/ormasia/swiftstream/internal/oss/live"
 	ossrepo "github.com/ormasia/swiftstream/internal/oss/repo"
 	ossrouters "github.com/ormasia/swiftstream/internal/oss/router"
+	"github.com/ormasia/swiftstream/internal/oss/scan"
+	"github.com/ormasia/swiftstream/internal/oss/similar"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+	"github.com/ormasia/swiftstream/internal/oss/transcode"
+	"github.com/ormasia/swiftstream/internal/oss/trash"
 )
 
 func main() {
@@ -34,7 +49,120 @@ func main() {
 		panic("Failed to migrate database: " + err.Error())
 	}
 
-	handlers := osshandlers.NewHandlers(db)
+	handlerCfg := osshandlers.DefaultConfig()
+
+	// HLS 加密：主密钥与内部令牌不写入代码，从环境变量读取，未配置主密钥时不支持加密
+	var keys *keystore.Keystore
+	if hexKey := os.Getenv("HLS_MASTER_KEY"); hexKey != "" {
+		masterKey, err := keystore.ParseMasterKey(hexKey)
+		if err != nil {
+			panic("Invalid HLS_MASTER_KEY: " + err.Error())
+		}
+		if keys, err = keystore.New(db, masterKey); err != nil {
+			panic("Failed to create keystore: " + err.Error())
+		}
+		handlerCfg.Keys = keys
+		handlerCfg.KeyServiceToken = os.Getenv("KEY_SERVICE_TOKEN")
+	}
+
+	// 启动转码任务池，未安装 ffmpeg 时只把 H.264/AAC 的 MP4 源文件打包为 HLS
+	transcodeCfg := transcode.DefaultConfig()
+	transcodeCfg.Keys = keys
+	var executor transcode.Executor
+	if ffmpeg, err := exec.LookPath("ffmpeg"); err == nil {
+		executor = transcode.NewFFmpegExecutor(ffmpeg)
+	} else {
+		log.Println("ffmpeg not found, video transcoding disabled")
+		transcodeCfg.Ladder = nil
+	}
+	pool := transcode.NewPool(db, transcodeCfg, executor)
+	if err := pool.Start(context.Background()); err != nil {
+		panic("Failed to start transcoder: " + err.Error())
+	}
+	handlerCfg.Transcoder = pool
+
+	// 启动直播会话服务，继续重启前未完成的转点播
+	liveSvc := live.NewService(db, live.DefaultConfig())
+	if err := liveSvc.Start(context.Background()); err != nil {
+		panic("Failed to start live service: " + err.Error())
+	}
+	handlerCfg.Live = liveSvc
+
+	// 上传扫描：配置了 clamd 地址或哈希黑名单时启用，扫描通过前对象处于待审核状态
+	var scanners []scan.Scanner
+	if address := os.Getenv("CLAMD_ADDRESS"); address != "" {
+		scanners = append(scanners, scan.NewClamd(address))
+	}
+	if path := os.Getenv("HASH_BLOCKLIST"); path != "" {
+		blocklist, err := scan.LoadBlocklist(path)
+		if err != nil {
+			panic("Failed to load hash blocklist: " + err.Error())
+		}
+		scanners = append(scanners, blocklist)
+	}
+	if len(scanners) > 0 {
+		handlerCfg.Scanner = scan.NewPipeline(scan.DefaultConfig(), scanners...)
+	}
+	handlerCfg.AdminToken = os.Getenv("ADMIN_TOKEN")
+
+	// 从数据库重建感知哈希索引
+	similarIndex, err := similar.NewIndex(db)
+	if err != nil {
+		panic("Failed to load perceptual hash index: " + err.Error())
+	}
+	handlerCfg.Similar = similarIndex
+
+	// 冷存储：生命周期规则把对象转移到该目录，可通过 COLD_STORAGE_DIR 指定挂载的低成本存储
+	coldDir := os.Getenv("COLD_STORAGE_DIR")
+	if coldDir == "" {
+		coldDir = filepath.Join("data", "cold")
+	}
+	cold := storage.NewLocalDriver(coldDir)
+	handlerCfg.Cold = cold
+
+	// 回收站：保留期可通过 TRASH_RETENTION 配置（如 720h），过期对象由后台任务物理删除
+	trashCfg := trash.DefaultConfig()
+	trashCfg.Cold = cold
+	if v := os.Getenv("TRASH_RETENTION"); v != "" {
+		retention, err := time.ParseDuration(v)
+		if err != nil || retention <= 0 {
+			panic("Invalid TRASH_RETENTION: " + v)
+		}
+		trashCfg.Retention = retention
+		trashCfg.PurgeInterval = min(trashCfg.PurgeInterval, retention)
+	}
+	trashSvc := trash.NewService(db, trashCfg)
+	trashSvc.Start(context.Background())
+	handlerCfg.Trash = trashSvc
+
+	// 生命周期规则：LIFECYCLE_DRY_RUN=true 时定时任务只记录将要执行的动作
+	lifecycleCfg := lifecycle.DefaultConfig()
+	lifecycleCfg.DryRun = os.Getenv("LIFECYCLE_DRY_RUN") == "true"
+	lifecycleSvc := lifecycle.NewService(db, lifecycleCfg, trashSvc, cold)
+	lifecycleSvc.Start(context.Background())
+	handlerCfg.Lifecycle = lifecycleSvc
+
+	// 从 URL 下载：默认拒绝内网地址，FETCH_ALLOW 以逗号分隔允许访问的网段，FETCH_MAX_BYTES 限制资源大小
+	fetchCfg := fetch.DefaultConfig()
+	if v := os.Getenv("FETCH_ALLOW"); v != "" {
+		allow, err := fetch.ParseAllow(v)
+		if err != nil {
+			panic("Invalid FETCH_ALLOW: " + err.Error())
+		}
+		fetchCfg.Allow = allow
+	}
+	if v := os.Getenv("FETCH_MAX_BYTES"); v != "" {
+		maxBytes, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || maxBytes <= 0 {
+			panic("Invalid FETCH_MAX_BYTES: " + v)
+		}
+		fetchCfg.MaxBytes = maxBytes
+	}
+	handlerCfg.Fetcher = fetch.New(fetchCfg)
+
+	handlers := osshandlers.NewHandlers(db, handlerCfg)
+	// 继续重启前未完成的 URL 下载
+	handlers.ResumeFetches()
 	// 注册OSS路由
 	ossrouters.RegisterRoutes(app, *handlers)
 
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"os"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/handlers"
 	"github.com/ormasia/swiftstream/internal/router"
 )
 
@@ -10,7 +13,26 @@ func main() {
 		AppName: "SwiftStream",
 	})
 
-	router.RegisterRoutes(app, router.Deps{})
+	imageCfg := handlers.DefaultImageConfig()
+	if url := os.Getenv("OSS_BASE_URL"); url != "" {
+		imageCfg.OSSBaseURL = url
+	}
+	// 签名密钥不写入代码，从环境变量读取
+	imageCfg.SigningKey = os.Getenv("IMAGE_SIGNING_KEY")
+
+	streamCfg := handlers.DefaultStreamConfig()
+	streamCfg.OSSBaseURL = imageCfg.OSSBaseURL
+	streamCfg.PlaybackSigningKey = os.Getenv("PLAYBACK_SIGNING_KEY")
+	streamCfg.KeyServiceToken = os.Getenv("KEY_SERVICE_TOKEN")
+
+	feedCfg := handlers.DefaultFeedConfig()
+	feedCfg.OSSBaseURL = imageCfg.OSSBaseURL
+
+	router.RegisterRoutes(app, router.Deps{
+		Feed:    handlers.NewFeedHandler(feedCfg),
+		Images:  handlers.NewImageHandler(imageCfg),
+		Streams: handlers.NewStreamHandler(streamCfg),
+	})
 
 	app.Listen(":3000")
 }
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Entry 是缓存中的一个条目
+type Entry struct {
+	ContentType string
+	Body        []byte
+}
+
+// LRU 是按字节数限制容量的并发安全 LRU 缓存
+type LRU struct {
+	mu       sync.Mutex
+	maxBytes int64
+	used     int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry Entry
+}
+
+// NewLRU 创建容量为 maxBytes 字节的缓存
+func NewLRU(maxBytes int64) *LRU {
+	return &LRU{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get 获取缓存条目，并将其标记为最近使用
+func (c *LRU) Get(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		return el.Value.(*lruItem).entry, true
+	}
+	return Entry{}, false
+}
+
+// Add 写入缓存条目，超出容量时淘汰最久未使用的条目
+// 单个条目超过总容量时不缓存
+func (c *LRU) Add(key string, entry Entry) {
+	size := int64(len(entry.Body))
+	if size > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.used += size - int64(len(el.Value.(*lruItem).entry.Body))
+		el.Value.(*lruItem).entry = entry
+		c.ll.MoveToFront(el)
+	} else {
+		c.items[key] = c.ll.PushFront(&lruItem{key: key, entry: entry})
+		c.used += size
+	}
+	for c.used > c.maxBytes {
+		oldest := c.ll.Back()
+		item := oldest.Value.(*lruItem)
+		c.ll.Remove(oldest)
+		delete(c.items, item.key)
+		c.used -= int64(len(item.entry.Body))
+	}
+}
+
+// Remove 删除缓存条目
+func (c *LRU) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		c.used -= int64(len(el.Value.(*lruItem).entry.Body))
+	}
+}
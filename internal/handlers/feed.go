@@ -1,8 +1,176 @@
 package handlers
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
-func GetFeed(c *fiber.Ctx) error {
-	// Logic to get feed
-	return c.SendString("Get Feed")
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/dash"
+	"github.com/ormasia/swiftstream/internal/oss/hls"
+)
+
+// FeedConfig 是内容流的配置
+type FeedConfig struct {
+	OSSBaseURL   string        // OSS 服务地址，如 http://localhost:8080
+	PageSize     int           // 默认每页条目数
+	MaxPageSize  int           // 每页条目数上限
+	CoverWidth   int           // 封面图宽度，需在图片处理的白名单尺寸内
+	FetchTimeout time.Duration // 回源超时
+}
+
+// DefaultFeedConfig 返回默认配置
+func DefaultFeedConfig() FeedConfig {
+	return FeedConfig{
+		OSSBaseURL:   "http://localhost:8080",
+		PageSize:     10,
+		MaxPageSize:  50,
+		CoverWidth:   720,
+		FetchTimeout: 10 * time.Second,
+	}
+}
+
+// FeedHandler 从 OSS 读取已上传的视频与图片，组装为客户端内容流
+type FeedHandler struct {
+	cfg    FeedConfig
+	client *http.Client
+}
+
+func NewFeedHandler(cfg FeedConfig) *FeedHandler {
+	return &FeedHandler{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.FetchTimeout},
+	}
+}
+
+// FeedItem 是内容流中的一个条目
+// BlurHash 与主色在封面加载前用于渲染占位图
+type FeedItem struct {
+	ID            uint     `json:"id"`
+	Type          string   `json:"type"` // video/image
+	Title         string   `json:"title"`
+	Width         int      `json:"width"`
+	Height        int      `json:"height"`
+	Duration      int      `json:"duration,omitempty"`
+	URL           string   `json:"url"`                 // 视频为播放清单，图片为原图
+	CoverURL      string   `json:"cover_url,omitempty"` // 视频未设置封面时为空
+	BlurHash      string   `json:"blur_hash,omitempty"`
+	DominantColor string   `json:"dominant_color,omitempty"`
+	Palette       []string `json:"palette,omitempty"`
+}
+
+// FeedResp 是内容流的响应，cursor 传给下一次请求获取下一页
+type FeedResp struct {
+	Items  []FeedItem `json:"items"`
+	Cursor string     `json:"cursor,omitempty"`
+}
+
+// feedObject 是 OSS 对象列表中内容流用到的字段
+type feedObject struct {
+	ID            uint     `json:"id"`
+	FileName      string   `json:"file_name"`
+	FileType      string   `json:"file_type"`
+	Variant       string   `json:"variant"`
+	Width         int      `json:"width"`
+	Height        int      `json:"height"`
+	Duration      int      `json:"duration"`
+	BlurHash      string   `json:"blur_hash"`
+	DominantColor string   `json:"dominant_color"`
+	Palette       []string `json:"palette"`
+
+	Variants []feedObject `json:"variants"`
+}
+
+// GetFeed 处理 GET /feed?cursor=&limit=，按上传时间倒序返回视频与图片
+// 尚未完成打包的视频不出现在内容流中
+func (h *FeedHandler) GetFeed(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", h.cfg.PageSize)
+	cursor := c.QueryInt("cursor")
+	if limit <= 0 || limit > h.cfg.MaxPageSize || cursor < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid pagination parameters",
+		})
+	}
+
+	query := url.Values{}
+	query.Set("type", "video,image")
+	query.Set("limit", strconv.Itoa(limit))
+	if cursor > 0 {
+		query.Set("before", strconv.Itoa(cursor))
+	}
+	resp, err := h.client.Get(h.cfg.OSSBaseURL + "/api/oss/objects?" + query.Encode())
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to fetch feed",
+		})
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Objects    []feedObject `json:"objects"`
+		NextBefore uint         `json:"next_before"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&list) != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to fetch feed",
+		})
+	}
+
+	feed := FeedResp{Items: []FeedItem{}}
+	for _, object := range list.Objects {
+		if item, ok := h.item(object); ok {
+			feed.Items = append(feed.Items, item)
+		}
+	}
+	if list.NextBefore > 0 {
+		feed.Cursor = strconv.FormatUint(uint64(list.NextBefore), 10)
+	}
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	return c.JSON(feed)
+}
+
+// item 把 OSS 对象转为内容流条目，URL 均指向边缘的分发接口
+func (h *FeedHandler) item(object feedObject) (FeedItem, bool) {
+	item := FeedItem{
+		ID:            object.ID,
+		Type:          object.FileType,
+		Title:         object.FileName,
+		Width:         object.Width,
+		Height:        object.Height,
+		Duration:      object.Duration,
+		BlurHash:      object.BlurHash,
+		DominantColor: object.DominantColor,
+		Palette:       object.Palette,
+	}
+	id := strconv.FormatUint(uint64(object.ID), 10)
+	switch object.FileType {
+	case "image":
+		item.URL = "/v1/images/" + id
+		item.CoverURL = h.coverURL(object.ID)
+	case "video":
+		for _, v := range object.Variants {
+			switch v.Variant {
+			case "hls":
+				item.URL = "/v1/media/" + id + "/hls/" + hls.MasterPlaylist
+			case "dash":
+				if item.URL == "" {
+					item.URL = "/v1/media/" + id + "/dash/" + dash.Manifest
+				}
+			case "cover":
+				// 封面衍生对象有自己的 ID，直接交给图片处理接口缩放
+				item.CoverURL = h.coverURL(v.ID)
+			}
+		}
+		if item.URL == "" {
+			return FeedItem{}, false
+		}
+	default:
+		return FeedItem{}, false
+	}
+	return item, true
+}
+
+func (h *FeedHandler) coverURL(objectID uint) string {
+	return "/v1/images/" + strconv.FormatUint(uint64(objectID), 10) + "?w=" + strconv.Itoa(h.cfg.CoverWidth)
 }
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/cache"
+	"github.com/ormasia/swiftstream/internal/oss/imaging"
+)
+
+// ImageConfig 是边缘图片处理的配置
+type ImageConfig struct {
+	OSSBaseURL       string        // OSS 服务地址，如 http://localhost:8080
+	SigningKey       string        // 签名密钥，为空时只允许白名单尺寸
+	AllowedSizes     []int         // 无需签名即可请求的宽/高
+	AllowedQualities []int         // 无需签名即可请求的 JPEG 质量
+	MaxDimension     int           // 宽高上限
+	MaxSourceBytes   int64         // 原图大小上限
+	MaxSourcePixels  int           // 原图像素数上限，防止解压炸弹
+	CacheBytes       int64         // 处理结果缓存容量
+	MaxAge           time.Duration // 处理结果的客户端与 CDN 缓存时间，原图改为私有或删除后下游缓存最多再返回这么久
+	FetchTimeout     time.Duration // 回源超时
+}
+
+// DefaultImageConfig 返回默认配置
+func DefaultImageConfig() ImageConfig {
+	return ImageConfig{
+		OSSBaseURL:       "http://localhost:8080",
+		AllowedSizes:     []int{64, 128, 240, 360, 480, 720, 1080},
+		AllowedQualities: []int{60, 75, imaging.DefaultQuality},
+		MaxDimension:     4096,
+		MaxSourceBytes:   50 * 1024 * 1024,
+		MaxSourcePixels:  imaging.DefaultMaxPixels,
+		CacheBytes:       256 * 1024 * 1024,
+		MaxAge:           5 * time.Minute,
+		FetchTimeout:     10 * time.Second,
+	}
+}
+
+// ImageHandler 按 URL 参数实时缩放 OSS 中的图片
+type ImageHandler struct {
+	cfg    ImageConfig
+	client *http.Client
+	cache  *cache.LRU
+}
+
+func NewImageHandler(cfg ImageConfig) *ImageHandler {
+	return &ImageHandler{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.FetchTimeout},
+		cache:  cache.NewLRU(cfg.CacheBytes),
+	}
+}
+
+// CanonicalImageParams 把处理参数规范化为固定顺序的字符串，用作缓存键和签名内容
+func CanonicalImageParams(objectID int, o imaging.Options) string {
+	return fmt.Sprintf("%d?w=%d&h=%d&fit=%s&q=%d&fmt=%s", objectID, o.Width, o.Height, o.Fit, o.Quality, o.Format)
+}
+
+// SignImageParams 计算处理参数的签名，供生成图片 URL 的服务使用
+func SignImageParams(key string, objectID int, o imaging.Options) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(CanonicalImageParams(objectID, o)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Transform 处理 GET /images/:id?w=&h=&fit=&q=&fmt=&sig=
+func (h *ImageHandler) Transform(c *fiber.Ctx) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	opts, err := imaging.Options{
+		Width:   c.QueryInt("w"),
+		Height:  c.QueryInt("h"),
+		Fit:     c.Query("fit"),
+		Format:  c.Query("fmt"),
+		Quality: c.QueryInt("q"),
+	}.Normalize()
+	if err != nil || opts.Width > h.cfg.MaxDimension || opts.Height > h.cfg.MaxDimension {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid transform parameters",
+		})
+	}
+	if !h.authorized(objectID, opts, c.Query("sig")) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Transform parameters are not allowed",
+		})
+	}
+
+	// 缓存命中前也要回源确认原图仍可公开访问，缓存键包含原图的 ETag，原图内容变化后不再命中旧结果
+	etag, status, err := h.originETag(objectID)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if entry, ok := h.cache.Get(imageCacheKey(objectID, opts, etag)); ok {
+		return h.sendImage(c, entry, "HIT")
+	}
+
+	src, etag, status, err := h.fetchOriginal(objectID)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, imaging.Transform(src, opts), opts.Format, opts.Quality); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to encode image",
+		})
+	}
+	entry := cache.Entry{ContentType: imaging.MimeType(opts.Format), Body: buf.Bytes()}
+	h.cache.Add(imageCacheKey(objectID, opts, etag), entry)
+	return h.sendImage(c, entry, "MISS")
+}
+
+func imageCacheKey(objectID int, o imaging.Options, etag string) string {
+	return CanonicalImageParams(objectID, o) + "@" + etag
+}
+
+// authorized 白名单内的尺寸与质量可直接访问，其他参数组合必须携带有效签名
+func (h *ImageHandler) authorized(objectID int, o imaging.Options, sig string) bool {
+	if sig != "" && h.cfg.SigningKey != "" {
+		expected := SignImageParams(h.cfg.SigningKey, objectID, o)
+		return hmac.Equal([]byte(sig), []byte(expected))
+	}
+	sizeAllowed := func(n int) bool {
+		return n == 0 || slices.Contains(h.cfg.AllowedSizes, n)
+	}
+	qualityAllowed := o.Format != imaging.FormatJPEG || slices.Contains(h.cfg.AllowedQualities, o.Quality)
+	return sizeAllowed(o.Width) && sizeAllowed(o.Height) && qualityAllowed
+}
+
+func (h *ImageHandler) originURL(objectID int) string {
+	return h.cfg.OSSBaseURL + "/api/oss/objects/" + strconv.Itoa(objectID) + "/download"
+}
+
+// originStatus 把回源响应的状态码转换为返回给客户端的错误，原图删除、改为私有或不可下载时不再提供处理结果
+func originStatus(code int) (int, error) {
+	switch code {
+	case http.StatusOK:
+		return fiber.StatusOK, nil
+	case http.StatusNotFound:
+		return fiber.StatusNotFound, errors.New("Image not found")
+	case http.StatusForbidden:
+		return fiber.StatusForbidden, errors.New("Image is not available")
+	}
+	return fiber.StatusBadGateway, errors.New("Failed to fetch original image")
+}
+
+// originETag 以 HEAD 请求查询原图当前的 ETag，返回值中的 int 为出错时应返回的状态码
+func (h *ImageHandler) originETag(objectID int) (string, int, error) {
+	resp, err := h.client.Head(h.originURL(objectID))
+	if err != nil {
+		return "", fiber.StatusBadGateway, errors.New("Failed to fetch original image")
+	}
+	resp.Body.Close()
+	if status, err := originStatus(resp.StatusCode); err != nil {
+		return "", status, err
+	}
+	return resp.Header.Get(fiber.HeaderETag), fiber.StatusOK, nil
+}
+
+// fetchOriginal 从 OSS 服务下载原图并解码，同时返回原图的 ETag，返回值中的 int 为出错时应返回的状态码
+func (h *ImageHandler) fetchOriginal(objectID int) (image.Image, string, int, error) {
+	resp, err := h.client.Get(h.originURL(objectID))
+	if err != nil {
+		return nil, "", fiber.StatusBadGateway, errors.New("Failed to fetch original image")
+	}
+	defer resp.Body.Close()
+	if status, err := originStatus(resp.StatusCode); err != nil {
+		return nil, "", status, err
+	}
+	etag := resp.Header.Get(fiber.HeaderETag)
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, h.cfg.MaxSourceBytes+1))
+	if err != nil {
+		return nil, "", fiber.StatusBadGateway, errors.New("Failed to fetch original image")
+	}
+	if int64(len(data)) > h.cfg.MaxSourceBytes {
+		return nil, "", fiber.StatusRequestEntityTooLarge, errors.New("Original image is too large")
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fiber.StatusUnsupportedMediaType, errors.New("Unsupported image format")
+	}
+	if cfg.Width*cfg.Height > h.cfg.MaxSourcePixels {
+		return nil, "", fiber.StatusRequestEntityTooLarge, errors.New("Original image is too large")
+	}
+	src, _, err := imaging.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fiber.StatusUnsupportedMediaType, errors.New("Unsupported image format")
+	}
+	return src, etag, fiber.StatusOK, nil
+}
+
+// sendImage 发送处理结果，下游缓存时间较短，原图改为私有或删除后尽快失效
+func (h *ImageHandler) sendImage(c *fiber.Ctx, entry cache.Entry, cacheStatus string) error {
+	c.Set(fiber.HeaderContentType, entry.ContentType)
+	c.Set(fiber.HeaderCacheControl, "public, max-age="+strconv.Itoa(int(h.cfg.MaxAge.Seconds())))
+	c.Set("X-Cache", cacheStatus)
+	return c.Send(entry.Body)
+}
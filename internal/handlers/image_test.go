@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/imaging"
+)
+
+// fakeOrigin 模拟 OSS 的下载接口，可以随时修改原图与状态码
+type fakeOrigin struct {
+	mu     sync.Mutex
+	status int
+	etag   string
+	body   []byte
+	gets   int
+}
+
+func (o *fakeOrigin) set(status int, etag string, body []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.status, o.etag, o.body = status, etag, body
+}
+
+func (o *fakeOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if r.URL.Path != "/api/oss/objects/7/download" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if o.status != http.StatusOK {
+		w.WriteHeader(o.status)
+		return
+	}
+	w.Header().Set("ETag", o.etag)
+	if r.Method == http.MethodGet {
+		o.gets++
+		w.Write(o.body)
+	}
+}
+
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestTransformRevalidatesOrigin(t *testing.T) {
+	origin := &fakeOrigin{}
+	origin.set(http.StatusOK, `"v1"`, pngImage(t, 200, 100))
+	server := httptest.NewServer(origin)
+	defer server.Close()
+
+	cfg := DefaultImageConfig()
+	cfg.OSSBaseURL = server.URL
+	app := fiber.New()
+	app.Get("/images/:id", NewImageHandler(cfg).Transform)
+
+	tests := []struct {
+		name   string
+		setup  func()
+		status int
+		cache  string
+		width  int
+		gets   int
+	}{
+		{"miss", nil, fiber.StatusOK, "MISS", 64, 1},
+		{"hit", nil, fiber.StatusOK, "HIT", 64, 1},
+		// 原图改为私有或删除后，缓存中的结果不再返回
+		{"origin forbidden", func() { origin.set(http.StatusForbidden, "", nil) }, fiber.StatusForbidden, "", 0, 1},
+		{"origin deleted", func() { origin.set(http.StatusNotFound, "", nil) }, fiber.StatusNotFound, "", 0, 1},
+		{"origin restored", func() { origin.set(http.StatusOK, `"v1"`, pngImage(t, 200, 100)) }, fiber.StatusOK, "HIT", 64, 1},
+		// 原图内容变化后 ETag 不同，重新处理
+		{"origin replaced", func() { origin.set(http.StatusOK, `"v2"`, pngImage(t, 100, 200)) }, fiber.StatusOK, "MISS", 64, 2},
+		{"origin unavailable", func() { origin.set(http.StatusInternalServerError, "", nil) }, fiber.StatusBadGateway, "", 0, 2},
+	}
+	for _, tt := range tests {
+		if tt.setup != nil {
+			tt.setup()
+		}
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/images/7?w=64&fmt=png", nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body bytes.Buffer
+		body.ReadFrom(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+			continue
+		}
+		origin.mu.Lock()
+		gets := origin.gets
+		origin.mu.Unlock()
+		if gets != tt.gets {
+			t.Errorf("%s: %d origin downloads, want %d", tt.name, gets, tt.gets)
+		}
+		if tt.status != fiber.StatusOK {
+			continue
+		}
+		if got := resp.Header.Get("X-Cache"); got != tt.cache {
+			t.Errorf("%s: X-Cache %s, want %s", tt.name, got, tt.cache)
+		}
+		// 下游只短时间缓存，不能标记为 immutable
+		if got := resp.Header.Get(fiber.HeaderCacheControl); got != "public, max-age=300" {
+			t.Errorf("%s: Cache-Control %q", tt.name, got)
+		}
+		img, err := png.Decode(&body)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if img.Bounds().Dx() != tt.width {
+			t.Errorf("%s: width %d, want %d", tt.name, img.Bounds().Dx(), tt.width)
+		}
+	}
+}
+
+func TestTransformRejectsLargeSources(t *testing.T) {
+	origin := &fakeOrigin{}
+	origin.set(http.StatusOK, `"v1"`, pngImage(t, 200, 100))
+	server := httptest.NewServer(origin)
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		config func(cfg *ImageConfig)
+		status int
+	}{
+		{"too many pixels", func(cfg *ImageConfig) { cfg.MaxSourcePixels = 200*100 - 1 }, fiber.StatusRequestEntityTooLarge},
+		{"too many bytes", func(cfg *ImageConfig) { cfg.MaxSourceBytes = 10 }, fiber.StatusRequestEntityTooLarge},
+		{"unsigned size", func(cfg *ImageConfig) { cfg.AllowedSizes = []int{128} }, fiber.StatusForbidden},
+		{"within limits", func(cfg *ImageConfig) { cfg.MaxSourcePixels = imaging.DefaultMaxPixels }, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		cfg := DefaultImageConfig()
+		cfg.OSSBaseURL = server.URL
+		tt.config(&cfg)
+		app := fiber.New()
+		app.Get("/images/:id", NewImageHandler(cfg).Transform)
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/images/7?w=64&fmt=png", nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
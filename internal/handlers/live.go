@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/hls"
+)
+
+// liveSession 是 OSS 直播分片列表接口返回的会话字段
+type liveSession struct {
+	Format         string `json:"format"`
+	TargetDuration int    `json:"target_duration"`
+	HasInit        bool   `json:"has_init"`
+	Status         string `json:"status"`
+	LastSequence   int64  `json:"last_sequence"`
+}
+
+type liveSegment struct {
+	Sequence int64   `json:"sequence"`
+	Duration float64 `json:"duration"`
+}
+
+type liveSegments struct {
+	Session  liveSession   `json:"session"`
+	Segments []liveSegment `json:"segments"`
+}
+
+// active 与 OSS 的 live.IsActive 一致：idle/live 表示仍在推流
+func (s liveSession) active() bool {
+	return s.Status == "idle" || s.Status == "live"
+}
+
+// LivePlaylist 处理 GET /live/:id/index.m3u8，按最新分片生成滑动窗口播放列表
+// 支持 _HLS_msn 阻塞刷新：请求的分片尚未到达时等待，超时仍未到达返回 503
+func (h *StreamHandler) LivePlaylist(c *fiber.Ctx) error {
+	sessionID, err := c.ParamsInt("id")
+	if err != nil || sessionID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid session id",
+		})
+	}
+
+	query := "?limit=" + strconv.Itoa(h.cfg.LiveWindow)
+	msn := int64(-1)
+	if v := c.Query("_HLS_msn"); v != "" {
+		if msn, err = strconv.ParseInt(v, 10, 64); err != nil || msn < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid _HLS_msn",
+			})
+		}
+		query += "&msn=" + v + "&timeout=" + strconv.FormatInt(h.cfg.LiveBlockTimeout.Milliseconds(), 10)
+	}
+
+	entry, status, err := h.fetch("live/" + strconv.Itoa(sessionID) + "/segments" + query)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	var resp liveSegments
+	if err := json.Unmarshal(entry.Body, &resp); err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to fetch stream",
+		})
+	}
+	session := resp.Session
+	if msn >= 0 && session.active() && session.LastSequence < msn {
+		c.Set(fiber.HeaderRetryAfter, "1")
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Segment not available yet",
+		})
+	}
+
+	pl := &hls.Playlist{
+		Version:        6,
+		TargetDuration: session.TargetDuration,
+		CanBlockReload: session.active(),
+		Ended:          !session.active(),
+	}
+	if session.Format == "fmp4" {
+		pl.Version = 7
+		pl.InitURI = "init.mp4"
+	}
+	ext := ".ts"
+	if session.Format == "fmp4" {
+		ext = ".m4s"
+	}
+	for i, seg := range resp.Segments {
+		if i == 0 {
+			pl.MediaSequence = seg.Sequence
+		}
+		pl.Segments = append(pl.Segments, hls.PlaylistSegment{
+			URI:      "seg_" + strconv.FormatInt(seg.Sequence, 10) + ext,
+			Duration: seg.Duration,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := hls.WritePlaylist(&buf, pl); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to write playlist",
+		})
+	}
+	c.Set(fiber.HeaderContentType, "application/vnd.apple.mpegurl")
+	if session.active() {
+		// 直播播放列表随新分片变化，只允许极短的缓存
+		c.Set(fiber.HeaderCacheControl, "public, max-age=1")
+	} else {
+		c.Set(fiber.HeaderCacheControl, "public, max-age="+strconv.Itoa(int(h.cfg.PlaylistMaxAge.Seconds())))
+	}
+	return c.Send(buf.Bytes())
+}
+
+// LiveFile 处理 GET /live/:id/:file，回源读取直播的初始化分片和媒体分片
+// 媒体分片写入后不再变化，进入缓存；初始化分片可能被编码器重新推送，每次回源
+func (h *StreamHandler) LiveFile(c *fiber.Ctx) error {
+	sessionID, err := c.ParamsInt("id")
+	if err != nil || sessionID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid session id",
+		})
+	}
+	name := c.Params("file")
+	if name == "" || strings.ContainsAny(name, "/\\") || strings.HasPrefix(name, ".") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid file path",
+		})
+	}
+
+	key := "live/" + strconv.Itoa(sessionID) + "/files/" + name
+	segment := strings.HasPrefix(name, "seg_")
+	if segment {
+		if entry, ok := h.cache.Get(key); ok {
+			return h.send(c, entry, false, "HIT")
+		}
+	}
+	entry, status, err := h.fetch(key)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if segment && int64(len(entry.Body)) <= h.cfg.MaxSegmentBytes {
+		h.cache.Add(key, entry)
+	}
+	if !segment {
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderContentType, entry.ContentType)
+		c.Set("X-Cache", "MISS")
+		return c.Send(entry.Body)
+	}
+	return h.send(c, entry, false, "MISS")
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// keyServiceTokenHeader 与 OSS 服务约定的内部令牌请求头
+const keyServiceTokenHeader = "X-Key-Service-Token"
+
+// SignPlaybackToken 生成对象的播放令牌，格式为 <过期时间戳>.<签名>，供业务服务下发给播放器
+func SignPlaybackToken(key string, objectID int, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + playbackSignature(key, objectID, exp)
+}
+
+// VerifyPlaybackToken 校验播放令牌的签名与有效期
+func VerifyPlaybackToken(key string, objectID int, token string, now time.Time) bool {
+	if key == "" {
+		return false
+	}
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || now.Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(playbackSignature(key, objectID, exp)))
+}
+
+func playbackSignature(key string, objectID int, exp string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strconv.Itoa(objectID) + ":" + exp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// playbackToken 从 ?token= 或 Authorization: Bearer 中读取播放令牌
+func playbackToken(c *fiber.Ctx) string {
+	if token := c.Query("token"); token != "" {
+		return token
+	}
+	if auth, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); ok {
+		return strings.TrimSpace(auth)
+	}
+	return ""
+}
+
+var playlistURIAttr = regexp.MustCompile(`URI="([^"]*)"`)
+
+// appendPlaylistToken 给播放列表中的 URI 行和 EXT-X-KEY/EXT-X-MEDIA 的 URI 属性追加 token 参数
+// 播放器不会把令牌自动带到子请求中，改写后密钥请求才能通过校验
+func appendPlaylistToken(playlist []byte, token string) []byte {
+	withToken := func(uri string) string {
+		sep := "?"
+		if strings.Contains(uri, "?") {
+			sep = "&"
+		}
+		return uri + sep + "token=" + url.QueryEscape(token)
+	}
+
+	lines := bytes.Split(playlist, []byte("\n"))
+	for i, line := range lines {
+		text := strings.TrimRight(string(line), "\r")
+		switch {
+		case text == "":
+		case strings.HasPrefix(text, "#EXT-X-KEY:"), strings.HasPrefix(text, "#EXT-X-MEDIA:"):
+			lines[i] = []byte(playlistURIAttr.ReplaceAllStringFunc(text, func(attr string) string {
+				uri := playlistURIAttr.FindStringSubmatch(attr)[1]
+				return `URI="` + withToken(uri) + `"`
+			}))
+		case !strings.HasPrefix(text, "#") && strings.HasSuffix(text, ".m3u8"):
+			lines[i] = []byte(withToken(text))
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// Key 处理 GET /media/:id/keys/:kid，校验播放令牌后从 OSS 读取内容密钥
+func (h *StreamHandler) Key(c *fiber.Ctx) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	if !VerifyPlaybackToken(h.cfg.PlaybackSigningKey, objectID, playbackToken(c), time.Now()) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid playback token",
+		})
+	}
+
+	req, err := http.NewRequest(http.MethodGet,
+		h.cfg.OSSBaseURL+"/api/oss/objects/"+strconv.Itoa(objectID)+"/keys/"+url.PathEscape(c.Params("kid")), nil)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid key id",
+		})
+	}
+	req.Header.Set(keyServiceTokenHeader, h.cfg.KeyServiceToken)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to fetch key",
+		})
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Key not found",
+		})
+	}
+	if resp.StatusCode != http.StatusOK {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to fetch key",
+		})
+	}
+	key, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to fetch key",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEOctetStream)
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.Send(key)
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const testSigningKey = "signing-key"
+
+func TestVerifyPlaybackToken(t *testing.T) {
+	now := time.Now()
+	valid := SignPlaybackToken(testSigningKey, 7, now.Add(time.Minute))
+	tests := []struct {
+		name     string
+		key      string
+		objectID int
+		token    string
+		want     bool
+	}{
+		{"valid", testSigningKey, 7, valid, true},
+		{"other object", testSigningKey, 8, valid, false},
+		{"other key", "other-key", 7, valid, false},
+		{"no signing key", "", 7, valid, false},
+		{"expired", testSigningKey, 7, SignPlaybackToken(testSigningKey, 7, now.Add(-time.Second)), false},
+		{"extended expiry", testSigningKey, 7, strconv.FormatInt(now.Add(time.Hour).Unix(), 10) + valid[len(strconv.FormatInt(now.Add(time.Minute).Unix(), 10)):], false},
+		{"no signature", testSigningKey, 7, strconv.FormatInt(now.Add(time.Minute).Unix(), 10), false},
+		{"empty", testSigningKey, 7, "", false},
+	}
+	for _, tt := range tests {
+		if got := VerifyPlaybackToken(tt.key, tt.objectID, tt.token, now); got != tt.want {
+			t.Errorf("%s: VerifyPlaybackToken = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAppendPlaylistToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		playlist string
+		want     string
+	}{
+		{
+			"master",
+			"#EXTM3U\n#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aud\",URI=\"audio/index.m3u8\"\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n720p/index.m3u8\n",
+			"#EXTM3U\n#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aud\",URI=\"audio/index.m3u8?token=a.b%2Fc\"\n#EXT-X-STREAM-INF:BANDWIDTH=800000\n720p/index.m3u8?token=a.b%2Fc\n",
+		},
+		{
+			// 分片不需要令牌，只改写密钥地址
+			"media",
+			"#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"/media/7/keys/k1?v=2\",IV=0x01\nseg_0.ts\n",
+			"#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"/media/7/keys/k1?v=2&token=a.b%2Fc\",IV=0x01\nseg_0.ts\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := string(appendPlaylistToken([]byte(tt.playlist), "a.b/c")); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(keyServiceTokenHeader) != "service-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/oss/objects/7/keys/k1":
+			w.Write([]byte("0123456789abcdef"))
+		case "/api/oss/objects/7/keys/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer origin.Close()
+
+	cfg := DefaultStreamConfig()
+	cfg.OSSBaseURL = origin.URL
+	cfg.PlaybackSigningKey = testSigningKey
+	cfg.KeyServiceToken = "service-token"
+	app := fiber.New()
+	app.Get("/media/:id/keys/:kid", NewStreamHandler(cfg).Key)
+
+	token := SignPlaybackToken(testSigningKey, 7, time.Now().Add(time.Minute))
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		status int
+	}{
+		{"query token", "/media/7/keys/k1?token=" + token, "", fiber.StatusOK},
+		{"bearer token", "/media/7/keys/k1", "Bearer " + token, fiber.StatusOK},
+		{"no token", "/media/7/keys/k1", "", fiber.StatusForbidden},
+		{"token of another object", "/media/8/keys/k1?token=" + token, "", fiber.StatusForbidden},
+		{"invalid object id", "/media/x/keys/k1?token=" + token, "", fiber.StatusBadRequest},
+		{"unknown key", "/media/7/keys/k2?token=" + token, "", fiber.StatusNotFound},
+		{"origin error", "/media/7/keys/broken?token=" + token, "", fiber.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set(fiber.HeaderAuthorization, tt.header)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.status != fiber.StatusOK {
+			continue
+		}
+		// 内容密钥不能被任何缓存保存
+		if string(body) != "0123456789abcdef" || resp.Header.Get(fiber.HeaderCacheControl) != "private, no-store" {
+			t.Errorf("%s: body %q, Cache-Control %q", tt.name, body, resp.Header.Get(fiber.HeaderCacheControl))
+		}
+	}
+
+	// 未配置签名密钥时不下发内容密钥
+	cfg.PlaybackSigningKey = ""
+	app = fiber.New()
+	app.Get("/media/:id/keys/:kid", NewStreamHandler(cfg).Key)
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/media/7/keys/k1?token="+token, nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("without a signing key: status %d, want 403", resp.StatusCode)
+	}
+}
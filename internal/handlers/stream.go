@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/cache"
+)
+
+// StreamConfig 是边缘流媒体分发的配置
+type StreamConfig struct {
+	OSSBaseURL      string        // OSS 服务地址，如 http://localhost:8080
+	CacheBytes      int64         // 分片缓存容量
+	MaxSegmentBytes int64         // 单个可缓存文件的大小上限
+	PlaylistMaxAge  time.Duration // 清单的客户端缓存时间，重新打包后需尽快生效
+	FetchTimeout    time.Duration // 回源超时
+
+	LiveWindow       int           // 直播播放列表保留的分片数
+	LiveBlockTimeout time.Duration // _HLS_msn 阻塞刷新的最长等待时间，需小于回源超时
+
+	PlaybackSigningKey string // 播放令牌签名密钥，为空时不下发内容密钥
+	KeyServiceToken    string // 向 OSS 读取内容密钥时携带的内部令牌
+}
+
+// DefaultStreamConfig 返回默认配置
+func DefaultStreamConfig() StreamConfig {
+	return StreamConfig{
+		OSSBaseURL:      "http://localhost:8080",
+		CacheBytes:      512 * 1024 * 1024,
+		MaxSegmentBytes: 32 * 1024 * 1024,
+		PlaylistMaxAge:  10 * time.Second,
+		FetchTimeout:    30 * time.Second,
+
+		LiveWindow:       6,
+		LiveBlockTimeout: 15 * time.Second,
+	}
+}
+
+// StreamHandler 把 OSS 中打包好的 HLS/DASH 清单和分片分发给播放器
+// 初始化分片和媒体分片缓存在内存中，清单每次回源
+type StreamHandler struct {
+	cfg    StreamConfig
+	client *http.Client
+	cache  *cache.LRU
+}
+
+func NewStreamHandler(cfg StreamConfig) *StreamHandler {
+	return &StreamHandler{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.FetchTimeout},
+		cache:  cache.NewLRU(cfg.CacheBytes),
+	}
+}
+
+// HLS 处理 GET /media/:id/hls/*
+func (h *StreamHandler) HLS(c *fiber.Ctx) error {
+	return h.proxy(c, "hls", ".m3u8")
+}
+
+// DASH 处理 GET /media/:id/dash/*
+func (h *StreamHandler) DASH(c *fiber.Ctx) error {
+	return h.proxy(c, "dash", ".mpd")
+}
+
+// proxy 回源读取打包输出中的文件，manifestExt 为清单文件的扩展名，清单不进入缓存
+func (h *StreamHandler) proxy(c *fiber.Ctx, format, manifestExt string) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	name := c.Params("*")
+	if name == "" || path.Clean("/" + name)[1:] != name {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid file path",
+		})
+	}
+
+	key := strconv.Itoa(objectID) + "/" + format + "/" + name
+	manifest := strings.HasSuffix(name, manifestExt)
+	if !manifest {
+		if entry, ok := h.cache.Get(key); ok {
+			return h.send(c, entry, manifest, "HIT")
+		}
+	}
+
+	entry, status, err := h.fetch("objects/" + key)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if !manifest && int64(len(entry.Body)) <= h.cfg.MaxSegmentBytes {
+		h.cache.Add(key, entry)
+	}
+	if manifest && format == "hls" {
+		// 播放令牌随播放列表传递给子播放列表和密钥请求
+		if token := playbackToken(c); token != "" {
+			entry.Body = appendPlaylistToken(entry.Body, token)
+		}
+	}
+	return h.send(c, entry, manifest, "MISS")
+}
+
+// fetch 从 OSS 服务读取 /api/oss/ 下的资源，返回值中的 int 为出错时应返回的状态码
+func (h *StreamHandler) fetch(path string) (cache.Entry, int, error) {
+	resp, err := h.client.Get(h.cfg.OSSBaseURL + "/api/oss/" + path)
+	if err != nil {
+		return cache.Entry{}, fiber.StatusBadGateway, errors.New("Failed to fetch stream")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return cache.Entry{}, fiber.StatusNotFound, errors.New("Stream not found")
+	}
+	if resp.StatusCode == http.StatusBadRequest {
+		return cache.Entry{}, fiber.StatusBadRequest, errors.New("Invalid stream request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return cache.Entry{}, fiber.StatusBadGateway, errors.New("Failed to fetch stream")
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cache.Entry{}, fiber.StatusBadGateway, errors.New("Failed to fetch stream")
+	}
+	return cache.Entry{ContentType: resp.Header.Get(fiber.HeaderContentType), Body: body}, fiber.StatusOK, nil
+}
+
+func (h *StreamHandler) send(c *fiber.Ctx, entry cache.Entry, manifest bool, cacheStatus string) error {
+	c.Set(fiber.HeaderContentType, entry.ContentType)
+	if manifest {
+		c.Set(fiber.HeaderCacheControl, "public, max-age="+strconv.Itoa(int(h.cfg.PlaylistMaxAge.Seconds())))
+	} else {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	}
+	c.Set("X-Cache", cacheStatus)
+	return c.Send(entry.Body)
+}
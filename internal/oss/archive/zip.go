@@ -0,0 +1,283 @@
+package archive
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"path"
+	"strings"
+	"time"
+)
+
+var ErrSizeMismatch = errors.New("archive: entry size does not match")
+
+// Entry 归档中的一个文件
+type Entry struct {
+	Name     string
+	Size     int64
+	Modified time.Time
+	Open     func(ctx context.Context) (io.ReadCloser, error)
+}
+
+// ZIP 格式常量，所有条目都使用 ZIP64 扩展与 STORE 方式，布局只取决于条目的名称、大小与时间
+const (
+	localHeaderLen   = 30
+	localExtraLen    = 20 // ZIP64 扩展：id、长度、原始大小、压缩大小
+	descriptorLen    = 24 // 签名、CRC32、两个 64 位大小
+	centralHeaderLen = 46
+	centralExtraLen  = 28 // ZIP64 扩展：id、长度、原始大小、压缩大小、本地头偏移
+	zip64EndLen      = 56
+	zip64LocatorLen  = 20
+	endLen           = 22
+
+	zipVersion = 45     // 4.5，支持 ZIP64
+	zipFlags   = 0x0808 // bit 3 数据描述符，bit 11 UTF-8 文件名
+)
+
+// Zip 是布局确定的 ZIP64 归档：写入前即可算出总大小与每个条目的偏移，
+// 同样的条目总是生成相同的字节，因此可以按 Range 从任意位置续传
+// 文件内容不压缩，CRC32 在写入数据时计算并写在数据描述符与中央目录中
+type Zip struct {
+	entries   []Entry
+	offsets   []int64 // 每个条目本地头的偏移
+	cdOffset  int64   // 中央目录的偏移
+	cdSize    int64
+	totalSize int64
+}
+
+// SafeName 把对象键转换为归档内的路径，去掉开头的 / 与 .. 段，避免解压到目标目录之外
+func SafeName(key string) string {
+	name := path.Clean("/" + key)
+	return strings.TrimPrefix(name, "/")
+}
+
+// NewZip 计算归档布局，条目按传入顺序写入
+func NewZip(entries []Entry) (*Zip, error) {
+	z := &Zip{entries: entries, offsets: make([]int64, len(entries))}
+	var offset int64
+	for i, e := range entries {
+		if e.Name == "" || len(e.Name) > math.MaxUint16 {
+			return nil, fmt.Errorf("archive: invalid entry name %q", e.Name)
+		}
+		if e.Size < 0 {
+			return nil, fmt.Errorf("archive: invalid size of %q", e.Name)
+		}
+		z.offsets[i] = offset
+		offset += localHeaderLen + int64(len(e.Name)) + localExtraLen + e.Size + descriptorLen
+		z.cdSize += centralHeaderLen + int64(len(e.Name)) + centralExtraLen
+	}
+	z.cdOffset = offset
+	z.totalSize = offset + z.cdSize + zip64EndLen + zip64LocatorLen + endLen
+	return z, nil
+}
+
+// Size 返回归档的总字节数
+func (z *Zip) Size() int64 {
+	return z.totalSize
+}
+
+// rangeWriter 只写出 [start, end) 范围内的字节，pos 记录归档中的当前位置
+type rangeWriter struct {
+	w          io.Writer
+	pos        int64
+	start, end int64
+}
+
+func (rw *rangeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	from, to := rw.pos, rw.pos+int64(n)
+	rw.pos = to
+	if to <= rw.start || from >= rw.end {
+		return n, nil
+	}
+	lo := max(rw.start, from) - from
+	hi := min(rw.end, to) - from
+	if _, err := rw.w.Write(p[lo:hi]); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// overlaps 返回 [from, to) 是否与输出范围相交
+func (rw *rangeWriter) overlaps(from, to int64) bool {
+	return from < rw.end && to > rw.start
+}
+
+// WriteRange 写出归档中 [start, end) 范围内的字节
+// 范围之前的条目只有在其数据描述符或中央目录需要输出时才读取内容以计算 CRC32
+func (z *Zip) WriteRange(ctx context.Context, w io.Writer, start, end int64) error {
+	if start < 0 || end > z.totalSize || start > end {
+		return fmt.Errorf("archive: invalid range %d-%d", start, end)
+	}
+	rw := &rangeWriter{w: w, start: start, end: end}
+	needCentral := rw.overlaps(z.cdOffset, z.totalSize)
+	crcs := make([]uint32, len(z.entries))
+
+	for i, e := range z.entries {
+		if !needCentral && rw.pos >= end {
+			return nil
+		}
+		dataStart := z.offsets[i] + localHeaderLen + int64(len(e.Name)) + localExtraLen
+		dataEnd := dataStart + e.Size
+		if !rw.overlaps(z.offsets[i], dataEnd+descriptorLen) && !needCentral {
+			rw.pos = dataEnd + descriptorLen
+			continue
+		}
+
+		if _, err := rw.Write(localHeader(e)); err != nil {
+			return err
+		}
+		crc, err := z.writeData(ctx, rw, e, rw.overlaps(dataStart, dataEnd))
+		if err != nil {
+			return err
+		}
+		crcs[i] = crc
+		if _, err := rw.Write(descriptor(e, crc)); err != nil {
+			return err
+		}
+	}
+
+	if !needCentral {
+		return nil
+	}
+	for i, e := range z.entries {
+		if _, err := rw.Write(centralHeader(e, crcs[i], z.offsets[i])); err != nil {
+			return err
+		}
+	}
+	_, err := rw.Write(z.end())
+	return err
+}
+
+// writeData 读取条目内容并计算 CRC32，output 为 false 时只计算不输出
+func (z *Zip) writeData(ctx context.Context, rw *rangeWriter, e Entry, output bool) (uint32, error) {
+	r, err := e.Open(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	hash := crc32.NewIEEE()
+	var dst io.Writer = hash
+	if output {
+		dst = io.MultiWriter(rw, hash)
+	} else {
+		rw.pos += e.Size
+	}
+	n, err := io.Copy(dst, io.LimitReader(r, e.Size+1))
+	if err != nil {
+		return 0, err
+	}
+	if n != e.Size {
+		return 0, fmt.Errorf("%w: %q is %d bytes, expected %d", ErrSizeMismatch, e.Name, n, e.Size)
+	}
+	return hash.Sum32(), nil
+}
+
+// dosTime 把时间转换为 ZIP 使用的 MS-DOS 日期与时间，早于 1980 年的时间取 1980-01-01
+func dosTime(t time.Time) (uint16, uint16) {
+	t = t.UTC()
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	date := uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	clock := uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return clock, date
+}
+
+// localHeader 本地文件头，CRC32 与大小写在数据描述符中，这里按规范置 0
+func localHeader(e Entry) []byte {
+	b := make([]byte, 0, localHeaderLen+len(e.Name)+localExtraLen)
+	clock, date := dosTime(e.Modified)
+	b = binary.LittleEndian.AppendUint32(b, 0x04034b50)
+	b = binary.LittleEndian.AppendUint16(b, zipVersion)
+	b = binary.LittleEndian.AppendUint16(b, zipFlags)
+	b = binary.LittleEndian.AppendUint16(b, 0) // STORE
+	b = binary.LittleEndian.AppendUint16(b, clock)
+	b = binary.LittleEndian.AppendUint16(b, date)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, math.MaxUint32)
+	b = binary.LittleEndian.AppendUint32(b, math.MaxUint32)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(e.Name)))
+	b = binary.LittleEndian.AppendUint16(b, localExtraLen)
+	b = append(b, e.Name...)
+	b = binary.LittleEndian.AppendUint16(b, 0x0001)
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = binary.LittleEndian.AppendUint64(b, 0)
+	b = binary.LittleEndian.AppendUint64(b, 0)
+	return b
+}
+
+// descriptor ZIP64 数据描述符
+func descriptor(e Entry, crc uint32) []byte {
+	b := make([]byte, 0, descriptorLen)
+	b = binary.LittleEndian.AppendUint32(b, 0x08074b50)
+	b = binary.LittleEndian.AppendUint32(b, crc)
+	b = binary.LittleEndian.AppendUint64(b, uint64(e.Size))
+	b = binary.LittleEndian.AppendUint64(b, uint64(e.Size))
+	return b
+}
+
+// centralHeader 中央目录中的文件头
+func centralHeader(e Entry, crc uint32, offset int64) []byte {
+	b := make([]byte, 0, centralHeaderLen+len(e.Name)+centralExtraLen)
+	clock, date := dosTime(e.Modified)
+	b = binary.LittleEndian.AppendUint32(b, 0x02014b50)
+	b = binary.LittleEndian.AppendUint16(b, zipVersion)
+	b = binary.LittleEndian.AppendUint16(b, zipVersion)
+	b = binary.LittleEndian.AppendUint16(b, zipFlags)
+	b = binary.LittleEndian.AppendUint16(b, 0)
+	b = binary.LittleEndian.AppendUint16(b, clock)
+	b = binary.LittleEndian.AppendUint16(b, date)
+	b = binary.LittleEndian.AppendUint32(b, crc)
+	b = binary.LittleEndian.AppendUint32(b, math.MaxUint32)
+	b = binary.LittleEndian.AppendUint32(b, math.MaxUint32)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(e.Name)))
+	b = binary.LittleEndian.AppendUint16(b, centralExtraLen)
+	b = binary.LittleEndian.AppendUint16(b, 0) // 注释长度
+	b = binary.LittleEndian.AppendUint16(b, 0) // 磁盘号
+	b = binary.LittleEndian.AppendUint16(b, 0) // 内部属性
+	b = binary.LittleEndian.AppendUint32(b, 0) // 外部属性
+	b = binary.LittleEndian.AppendUint32(b, math.MaxUint32)
+	b = append(b, e.Name...)
+	b = binary.LittleEndian.AppendUint16(b, 0x0001)
+	b = binary.LittleEndian.AppendUint16(b, 24)
+	b = binary.LittleEndian.AppendUint64(b, uint64(e.Size))
+	b = binary.LittleEndian.AppendUint64(b, uint64(e.Size))
+	b = binary.LittleEndian.AppendUint64(b, uint64(offset))
+	return b
+}
+
+// end ZIP64 中央目录结束记录、定位符与中央目录结束记录
+func (z *Zip) end() []byte {
+	n := uint64(len(z.entries))
+	b := make([]byte, 0, zip64EndLen+zip64LocatorLen+endLen)
+	b = binary.LittleEndian.AppendUint32(b, 0x06064b50)
+	b = binary.LittleEndian.AppendUint64(b, zip64EndLen-12)
+	b = binary.LittleEndian.AppendUint16(b, zipVersion)
+	b = binary.LittleEndian.AppendUint16(b, zipVersion)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint64(b, n)
+	b = binary.LittleEndian.AppendUint64(b, n)
+	b = binary.LittleEndian.AppendUint64(b, uint64(z.cdSize))
+	b = binary.LittleEndian.AppendUint64(b, uint64(z.cdOffset))
+
+	b = binary.LittleEndian.AppendUint32(b, 0x07064b50)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint64(b, uint64(z.cdOffset+z.cdSize))
+	b = binary.LittleEndian.AppendUint32(b, 1)
+
+	b = binary.LittleEndian.AppendUint32(b, 0x06054b50)
+	b = binary.LittleEndian.AppendUint16(b, 0)
+	b = binary.LittleEndian.AppendUint16(b, 0)
+	b = binary.LittleEndian.AppendUint16(b, math.MaxUint16)
+	b = binary.LittleEndian.AppendUint16(b, math.MaxUint16)
+	b = binary.LittleEndian.AppendUint32(b, math.MaxUint32)
+	b = binary.LittleEndian.AppendUint32(b, math.MaxUint32)
+	b = binary.LittleEndian.AppendUint16(b, 0)
+	return b
+}
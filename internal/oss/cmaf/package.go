@@ -0,0 +1,351 @@
+package cmaf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ormasia/swiftstream/internal/oss/mp4"
+)
+
+// ManifestFile 是打包结果描述文件名，HLS/DASH 清单都由它生成
+const ManifestFile = "package.json"
+
+// 流类型
+const (
+	StreamVideo = "video"
+	StreamAudio = "audio"
+)
+
+var ErrNothingToPackage = errors.New("cmaf: no H.264/AAC track to package")
+
+// Input 是一个待打包的 MP4 文件
+type Input struct {
+	Name  string // 档位名，如 source/720p，用作视频流 ID 的一部分
+	Path  string // 文件路径
+	Audio bool   // 是否从该文件提取音轨（只提取第一个可用的音轨）
+}
+
+// Options 打包参数
+type Options struct {
+	SegmentDuration time.Duration // 目标分片时长，实际在关键帧处切分
+}
+
+// DefaultOptions 返回默认打包参数
+func DefaultOptions() Options {
+	return Options{SegmentDuration: 6 * time.Second}
+}
+
+// Segment 描述一个媒体分片，时间以流的 Timescale 为单位
+type Segment struct {
+	Start    uint64 `json:"start"`
+	Duration uint64 `json:"duration"`
+	Size     int64  `json:"size"`
+}
+
+// Stream 是打包后的一条单轨 CMAF 流
+type Stream struct {
+	ID               string    `json:"id"`   // 子目录名，如 video_720p、audio
+	Type             string    `json:"type"` // video/audio
+	Codecs           string    `json:"codecs"`
+	Timescale        uint32    `json:"timescale"`
+	Bandwidth        int64     `json:"bandwidth"`         // 峰值码率(bit/s)
+	AverageBandwidth int64     `json:"average_bandwidth"` // 平均码率(bit/s)
+	Width            int       `json:"width,omitempty"`
+	Height           int       `json:"height,omitempty"`
+	FrameRate        float64   `json:"frame_rate,omitempty"`
+	SampleRate       int       `json:"sample_rate,omitempty"`
+	Channels         int       `json:"channels,omitempty"`
+	InitURI          string    `json:"init_uri"`         // 初始化分片文件名
+	SegmentTemplate  string    `json:"segment_template"` // 分片文件名模板，$Number$ 从 1 开始
+	Segments         []Segment `json:"segments"`
+}
+
+// SegmentName 返回第 n 个分片（从 1 开始）的文件名
+func (s *Stream) SegmentName(n int) string {
+	return strings.ReplaceAll(s.SegmentTemplate, "$Number$", strconv.Itoa(n))
+}
+
+// Seconds 返回流的总时长（秒）
+func (s *Stream) Seconds() float64 {
+	var total uint64
+	for _, seg := range s.Segments {
+		total += seg.Duration
+	}
+	return float64(total) / float64(s.Timescale)
+}
+
+// MaxSegmentSeconds 返回最长分片的时长（秒）
+func (s *Stream) MaxSegmentSeconds() float64 {
+	var longest uint64
+	for _, seg := range s.Segments {
+		longest = max(longest, seg.Duration)
+	}
+	return float64(longest) / float64(s.Timescale)
+}
+
+// Presentation 是一次打包的全部输出
+type Presentation struct {
+	Duration        float64  `json:"duration"`         // 总时长（秒）
+	SegmentDuration float64  `json:"segment_duration"` // 目标分片时长（秒）
+	Streams         []Stream `json:"streams"`
+}
+
+// Package 把输入文件切分为 CMAF 分片写入 dir，并写出 package.json
+// 所有视频流独立切分；音轨按第一个视频流的分片边界对齐
+func Package(dir string, inputs []Input, opts Options) (*Presentation, error) {
+	pres := &Presentation{SegmentDuration: opts.SegmentDuration.Seconds()}
+	var boundaries []float64 // 参考视频流的分片起始时间（秒）
+	var audioSource *Input
+
+	for i := range inputs {
+		input := &inputs[i]
+		stream, cuts, err := packageVideo(dir, input, opts)
+		if err != nil {
+			return nil, fmt.Errorf("cmaf: package %s: %w", input.Name, err)
+		}
+		if stream != nil {
+			pres.Streams = append(pres.Streams, *stream)
+			if boundaries == nil {
+				boundaries = cuts
+			}
+		}
+		if input.Audio && audioSource == nil {
+			audioSource = input
+		}
+	}
+	if audioSource != nil {
+		stream, err := packageAudio(dir, audioSource, boundaries, opts)
+		if err != nil {
+			return nil, fmt.Errorf("cmaf: package audio of %s: %w", audioSource.Name, err)
+		}
+		if stream != nil {
+			pres.Streams = append(pres.Streams, *stream)
+		}
+	}
+	if len(pres.Streams) == 0 {
+		return nil, ErrNothingToPackage
+	}
+	for _, s := range pres.Streams {
+		pres.Duration = math.Max(pres.Duration, s.Seconds())
+	}
+
+	data, err := json.MarshalIndent(pres, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), data, 0644); err != nil {
+		return nil, err
+	}
+	return pres, nil
+}
+
+// ReadPresentation 读取 dir 下的 package.json
+func ReadPresentation(dir string) (*Presentation, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var pres Presentation
+	if err := json.Unmarshal(data, &pres); err != nil {
+		return nil, err
+	}
+	return &pres, nil
+}
+
+// openTrack 打开输入文件并解析指定类型轨道的样本表，轨道不存在或编码不支持时返回 nil
+func openTrack(path, handler string, codecPrefixes ...string) (*os.File, *mp4.Track, *mp4.SampleTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, nil, err
+	}
+	movie, err := mp4.ReadMovie(f, stat.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, nil, err
+	}
+	track := movie.Track(handler)
+	if track == nil || !hasPrefix(track.Codec, codecPrefixes) {
+		f.Close()
+		return nil, nil, nil, nil
+	}
+	table, err := mp4.ReadSamples(f, stat.Size(), track)
+	if err != nil {
+		f.Close()
+		return nil, nil, nil, err
+	}
+	return f, track, table, nil
+}
+
+func hasPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func packageVideo(dir string, input *Input, opts Options) (*Stream, []float64, error) {
+	f, track, table, err := openTrack(input.Path, mp4.HandlerVideo, "avc1", "avc3")
+	if err != nil || f == nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	cuts := videoCuts(table.Samples, track.Timescale, opts.SegmentDuration)
+	stream := &Stream{
+		ID:        "video_" + input.Name,
+		Type:      StreamVideo,
+		Width:     track.Width,
+		Height:    track.Height,
+		FrameRate: track.FrameRate(),
+	}
+	if err := writeStream(dir, stream, f, track, table, cuts); err != nil {
+		return nil, nil, err
+	}
+	boundaries := make([]float64, len(cuts))
+	for i, c := range cuts {
+		boundaries[i] = float64(table.Samples[c].DTS) / float64(track.Timescale)
+	}
+	return stream, boundaries, nil
+}
+
+func packageAudio(dir string, input *Input, boundaries []float64, opts Options) (*Stream, error) {
+	f, track, table, err := openTrack(input.Path, mp4.HandlerAudio, "mp4a")
+	if err != nil || f == nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if boundaries == nil {
+		// 纯音频文件按固定时长切分
+		for t := 0.0; t < track.Seconds(); t += opts.SegmentDuration.Seconds() {
+			boundaries = append(boundaries, t)
+		}
+	}
+	stream := &Stream{
+		ID:         StreamAudio,
+		Type:       StreamAudio,
+		SampleRate: track.SampleRate,
+		Channels:   track.Channels,
+	}
+	cuts := audioCuts(table.Samples, track.Timescale, boundaries)
+	if err := writeStream(dir, stream, f, track, table, cuts); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// videoCuts 在达到目标时长后的第一个关键帧处切分，返回每个分片首个样本的下标
+func videoCuts(samples []mp4.Sample, timescale uint32, target time.Duration) []int {
+	cuts := []int{0}
+	limit := uint64(target.Seconds() * float64(timescale))
+	segStart := samples[0].DTS
+	for i := 1; i < len(samples); i++ {
+		if samples[i].Sync && samples[i].DTS-segStart >= limit {
+			cuts = append(cuts, i)
+			segStart = samples[i].DTS
+		}
+	}
+	return cuts
+}
+
+// audioCuts 在每个边界时间之后的第一个样本处切分
+func audioCuts(samples []mp4.Sample, timescale uint32, boundaries []float64) []int {
+	cuts := []int{0}
+	i := 0
+	for _, t := range boundaries[min(1, len(boundaries)):] {
+		limit := uint64(t * float64(timescale))
+		for i < len(samples) && samples[i].DTS < limit {
+			i++
+		}
+		if i >= len(samples) {
+			break
+		}
+		if i > cuts[len(cuts)-1] {
+			cuts = append(cuts, i)
+		}
+	}
+	return cuts
+}
+
+// writeStream 写出初始化分片和所有媒体分片，并填充流的码率与分片信息
+func writeStream(dir string, stream *Stream, r io.ReaderAt, track *mp4.Track, table *mp4.SampleTable, cuts []int) error {
+	streamDir := filepath.Join(dir, stream.ID)
+	if err := os.MkdirAll(streamDir, 0755); err != nil {
+		return err
+	}
+	stream.Codecs = mp4.CodecString(table.SampleEntry)
+	stream.Timescale = track.Timescale
+	stream.InitURI = "init.mp4"
+	stream.SegmentTemplate = "seg_$Number$.m4s"
+
+	init, err := mp4.InitSegment(r, track, table)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(streamDir, stream.InitURI), init, 0644); err != nil {
+		return err
+	}
+
+	var totalBytes int64
+	var totalDuration uint64
+	for k, start := range cuts {
+		end := len(table.Samples)
+		if k+1 < len(cuts) {
+			end = cuts[k+1]
+		}
+		frag := mp4.Fragment{
+			Sequence:         uint32(k + 1),
+			BaseDecodeTime:   table.Samples[start].DTS,
+			Samples:          table.Samples[start:end],
+			CompositionShift: table.MediaTime,
+		}
+		size, err := writeSegment(filepath.Join(streamDir, stream.SegmentName(k+1)), r, frag)
+		if err != nil {
+			return err
+		}
+
+		var duration uint64
+		for _, s := range frag.Samples {
+			duration += uint64(s.Duration)
+		}
+		stream.Segments = append(stream.Segments, Segment{Start: frag.BaseDecodeTime, Duration: duration, Size: size})
+		if duration > 0 {
+			bps := int64(float64(size*8) * float64(track.Timescale) / float64(duration))
+			stream.Bandwidth = max(stream.Bandwidth, bps)
+		}
+		totalBytes += size
+		totalDuration += duration
+	}
+	if totalDuration > 0 {
+		stream.AverageBandwidth = int64(float64(totalBytes*8) * float64(track.Timescale) / float64(totalDuration))
+	}
+	return nil
+}
+
+func writeSegment(path string, r io.ReaderAt, frag mp4.Fragment) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := mp4.WriteFragment(f, r, frag)
+	if err != nil {
+		f.Close()
+		return n, err
+	}
+	return n, f.Close()
+}
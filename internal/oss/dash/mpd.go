@@ -0,0 +1,379 @@
+package dash
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ormasia/swiftstream/internal/oss/cmaf"
+)
+
+// Manifest 是 MPD 文件名，与 HLS 主播放列表放在同一目录
+const Manifest = "manifest.mpd"
+
+const (
+	namespace         = "urn:mpeg:dash:schema:mpd:2011"
+	profileLive       = "urn:mpeg:dash:profile:isoff-live:2011"
+	channelConfScheme = "urn:mpeg:dash:23003:3:audio_channel_configuration:2011"
+)
+
+// MPD 描述点播 MPD 中用到的元素子集
+type MPD struct {
+	XMLName                   xml.Name `xml:"MPD"`
+	Xmlns                     string   `xml:"xmlns,attr"`
+	Profiles                  string   `xml:"profiles,attr"`
+	Type                      string   `xml:"type,attr"`
+	MediaPresentationDuration string   `xml:"mediaPresentationDuration,attr"`
+	MinBufferTime             string   `xml:"minBufferTime,attr"`
+	Periods                   []Period `xml:"Period"`
+}
+
+type Period struct {
+	ID             string          `xml:"id,attr"`
+	Start          string          `xml:"start,attr"`
+	AdaptationSets []AdaptationSet `xml:"AdaptationSet"`
+}
+
+type AdaptationSet struct {
+	ID               int              `xml:"id,attr"`
+	ContentType      string           `xml:"contentType,attr"`
+	MimeType         string           `xml:"mimeType,attr"`
+	Lang             string           `xml:"lang,attr,omitempty"`
+	SegmentAlignment bool             `xml:"segmentAlignment,attr"`
+	StartWithSAP     int              `xml:"startWithSAP,attr"`
+	MaxWidth         int              `xml:"maxWidth,attr,omitempty"`
+	MaxHeight        int              `xml:"maxHeight,attr,omitempty"`
+	Representations  []Representation `xml:"Representation"`
+}
+
+type Representation struct {
+	ID                        string                     `xml:"id,attr"`
+	Bandwidth                 int64                      `xml:"bandwidth,attr"`
+	Codecs                    string                     `xml:"codecs,attr"`
+	Width                     int                        `xml:"width,attr,omitempty"`
+	Height                    int                        `xml:"height,attr,omitempty"`
+	FrameRate                 string                     `xml:"frameRate,attr,omitempty"`
+	AudioSamplingRate         int                        `xml:"audioSamplingRate,attr,omitempty"`
+	AudioChannelConfiguration *AudioChannelConfiguration `xml:"AudioChannelConfiguration"`
+	SegmentTemplate           SegmentTemplate            `xml:"SegmentTemplate"`
+}
+
+type AudioChannelConfiguration struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr"`
+}
+
+type SegmentTemplate struct {
+	Timescale       uint32          `xml:"timescale,attr"`
+	Initialization  string          `xml:"initialization,attr"`
+	Media           string          `xml:"media,attr"`
+	StartNumber     int             `xml:"startNumber,attr"`
+	SegmentTimeline SegmentTimeline `xml:"SegmentTimeline"`
+}
+
+type SegmentTimeline struct {
+	S []S `xml:"S"`
+}
+
+// S 是时间线中的一段：起始时间 t、时长 d、额外重复次数 r
+type S struct {
+	T *uint64 `xml:"t,attr"`
+	D uint64  `xml:"d,attr"`
+	R int     `xml:"r,attr,omitempty"`
+}
+
+// Build 由打包结果构建 MPD：所有视频流放在同一个自适应集中，音轨单独一个自适应集
+func Build(pres *cmaf.Presentation) *MPD {
+	period := Period{ID: "0", Start: "PT0S"}
+
+	var videos, audios []cmaf.Stream
+	for _, s := range pres.Streams {
+		switch s.Type {
+		case cmaf.StreamVideo:
+			videos = append(videos, s)
+		case cmaf.StreamAudio:
+			audios = append(audios, s)
+		}
+	}
+	sort.SliceStable(videos, func(i, j int) bool {
+		return videos[i].Bandwidth < videos[j].Bandwidth
+	})
+
+	if len(videos) > 0 {
+		set := AdaptationSet{
+			ID:               len(period.AdaptationSets),
+			ContentType:      "video",
+			MimeType:         "video/mp4",
+			SegmentAlignment: aligned(videos),
+			StartWithSAP:     1,
+		}
+		for _, v := range videos {
+			set.MaxWidth = max(set.MaxWidth, v.Width)
+			set.MaxHeight = max(set.MaxHeight, v.Height)
+			rep := representation(&v)
+			rep.Width, rep.Height = v.Width, v.Height
+			if v.FrameRate > 0 {
+				rep.FrameRate = frameRate(v.FrameRate)
+			}
+			set.Representations = append(set.Representations, rep)
+		}
+		period.AdaptationSets = append(period.AdaptationSets, set)
+	}
+	for _, a := range audios {
+		rep := representation(&a)
+		rep.AudioSamplingRate = a.SampleRate
+		rep.AudioChannelConfiguration = &AudioChannelConfiguration{
+			SchemeIDURI: channelConfScheme,
+			Value:       strconv.Itoa(max(1, a.Channels)),
+		}
+		period.AdaptationSets = append(period.AdaptationSets, AdaptationSet{
+			ID:               len(period.AdaptationSets),
+			ContentType:      "audio",
+			MimeType:         "audio/mp4",
+			Lang:             "und",
+			SegmentAlignment: true,
+			StartWithSAP:     1,
+			Representations:  []Representation{rep},
+		})
+	}
+
+	return &MPD{
+		Xmlns:                     namespace,
+		Profiles:                  profileLive,
+		Type:                      "static",
+		MediaPresentationDuration: duration(pres.Duration),
+		MinBufferTime:             duration(math.Max(2, pres.SegmentDuration)),
+		Periods:                   []Period{period},
+	}
+}
+
+// representation 生成流的 Representation，分片路径相对 MPD 所在目录
+func representation(s *cmaf.Stream) Representation {
+	return Representation{
+		ID:        s.ID,
+		Bandwidth: s.Bandwidth,
+		Codecs:    s.Codecs,
+		SegmentTemplate: SegmentTemplate{
+			Timescale:       s.Timescale,
+			Initialization:  path.Join(s.ID, s.InitURI),
+			Media:           path.Join(s.ID, s.SegmentTemplate),
+			StartNumber:     1,
+			SegmentTimeline: timeline(s.Segments),
+		},
+	}
+}
+
+// timeline 把连续且时长相同的分片合并为带 r 的一项
+func timeline(segments []cmaf.Segment) SegmentTimeline {
+	var tl SegmentTimeline
+	var next uint64
+	for i, seg := range segments {
+		if i > 0 && seg.Start == next && seg.Duration == tl.S[len(tl.S)-1].D {
+			tl.S[len(tl.S)-1].R++
+		} else {
+			s := S{D: seg.Duration}
+			if i == 0 || seg.Start != next {
+				start := seg.Start
+				s.T = &start
+			}
+			tl.S = append(tl.S, s)
+		}
+		next = seg.Start + seg.Duration
+	}
+	return tl
+}
+
+// aligned 判断各视频流的分片起始时间是否一致
+func aligned(streams []cmaf.Stream) bool {
+	ref := streams[0]
+	for _, s := range streams[1:] {
+		if len(s.Segments) != len(ref.Segments) {
+			return false
+		}
+		for i := range s.Segments {
+			a := float64(s.Segments[i].Start) / float64(s.Timescale)
+			b := float64(ref.Segments[i].Start) / float64(ref.Timescale)
+			if math.Abs(a-b) > 0.001 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// frameRate 把帧率表示为整数或 NTSC 分数形式，如 30000/1001
+func frameRate(fps float64) string {
+	if r := math.Round(fps); math.Abs(fps-r) < 0.01 {
+		return strconv.Itoa(int(r))
+	}
+	if r := math.Round(fps * 1.001); math.Abs(fps*1.001-r) < 0.01 {
+		return fmt.Sprintf("%d/1001", int(r*1000))
+	}
+	return strconv.FormatFloat(fps, 'f', 3, 64)
+}
+
+// duration 把秒数格式化为 xs:duration，如 PT12.480S
+func duration(seconds float64) string {
+	return "PT" + strconv.FormatFloat(seconds, 'f', 3, 64) + "S"
+}
+
+// parseDuration 解析 PTnHnMnS 形式的 xs:duration
+func parseDuration(s string) (float64, error) {
+	rest, ok := strings.CutPrefix(s, "PT")
+	if !ok || rest == "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var total float64
+	for _, unit := range []struct {
+		suffix string
+		scale  float64
+	}{{"H", 3600}, {"M", 60}, {"S", 1}} {
+		value, after, found := strings.Cut(rest, unit.suffix)
+		if !found {
+			continue
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		total += n * unit.scale
+		rest = after
+	}
+	if rest != "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return total, nil
+}
+
+// Validate 检查 MPD 是否满足点播播放所需的约束
+func Validate(m *MPD) error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("dash: "+format, args...))
+	}
+
+	if m.Xmlns != namespace {
+		fail("unexpected namespace %q", m.Xmlns)
+	}
+	if m.Type != "static" {
+		fail("type must be static, got %q", m.Type)
+	}
+	total, err := parseDuration(m.MediaPresentationDuration)
+	if err != nil {
+		fail("mediaPresentationDuration: %v", err)
+	}
+	if _, err := parseDuration(m.MinBufferTime); err != nil {
+		fail("minBufferTime: %v", err)
+	}
+	if len(m.Periods) == 0 {
+		fail("no Period")
+	}
+
+	ids := make(map[string]bool)
+	for _, p := range m.Periods {
+		if len(p.AdaptationSets) == 0 {
+			fail("period %s has no AdaptationSet", p.ID)
+		}
+		for _, set := range p.AdaptationSets {
+			if set.MimeType == "" {
+				fail("adaptation set %d has no mimeType", set.ID)
+			}
+			if len(set.Representations) == 0 {
+				fail("adaptation set %d has no Representation", set.ID)
+			}
+			for _, rep := range set.Representations {
+				if rep.ID == "" || strings.ContainsAny(rep.ID, " \t\n") {
+					fail("invalid representation id %q", rep.ID)
+				}
+				if ids[rep.ID] {
+					fail("duplicate representation id %q", rep.ID)
+				}
+				ids[rep.ID] = true
+				if rep.Bandwidth <= 0 {
+					fail("representation %s: bandwidth must be positive", rep.ID)
+				}
+				if rep.Codecs == "" {
+					fail("representation %s: missing codecs", rep.ID)
+				}
+				if set.ContentType == "video" && (rep.Width <= 0 || rep.Height <= 0) {
+					fail("representation %s: missing resolution", rep.ID)
+				}
+				if err := validateTemplate(&rep.SegmentTemplate, total); err != nil {
+					fail("representation %s: %v", rep.ID, err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateTemplate 检查分片模板和时间线：分片首尾相接，总时长与 MPD 时长一致
+func validateTemplate(st *SegmentTemplate, total float64) error {
+	if st.Timescale == 0 {
+		return errors.New("timescale must be positive")
+	}
+	if st.Initialization == "" {
+		return errors.New("missing initialization")
+	}
+	if !strings.Contains(st.Media, "$Number$") && !strings.Contains(st.Media, "$Time$") {
+		return errors.New("media template has no $Number$ or $Time$")
+	}
+	if len(st.SegmentTimeline.S) == 0 {
+		return errors.New("empty SegmentTimeline")
+	}
+	if st.SegmentTimeline.S[0].T == nil {
+		return errors.New("first S element has no t")
+	}
+	var next, start uint64
+	for i, s := range st.SegmentTimeline.S {
+		if s.D == 0 {
+			return fmt.Errorf("S[%d] has zero duration", i)
+		}
+		if s.R < 0 {
+			return fmt.Errorf("S[%d] has negative repeat count", i)
+		}
+		if s.T != nil {
+			if i > 0 && *s.T < next {
+				return fmt.Errorf("S[%d] overlaps the previous segment", i)
+			}
+			if i == 0 {
+				start = *s.T
+			}
+			next = *s.T
+		}
+		next += s.D * uint64(s.R+1)
+	}
+	length := float64(next-start) / float64(st.Timescale)
+	if total > 0 && math.Abs(length-total) > 1 {
+		return fmt.Errorf("timeline covers %.3fs, presentation is %.3fs", length, total)
+	}
+	return nil
+}
+
+// Marshal 校验并序列化 MPD
+func Marshal(m *MPD) ([]byte, error) {
+	if err := Validate(m); err != nil {
+		return nil, err
+	}
+	data, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// WriteManifest 为打包结果生成 MPD 写入 dir
+func WriteManifest(dir string, pres *cmaf.Presentation) error {
+	data, err := Marshal(Build(pres))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, Manifest), data, 0644)
+}
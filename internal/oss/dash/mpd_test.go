@@ -0,0 +1,203 @@
+package dash
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ormasia/swiftstream/internal/oss/cmaf"
+)
+
+// segments 生成 n 个首尾相接、时长为 d 的分片，最后一个分片时长为 last
+func segments(n int, d, last uint64) []cmaf.Segment {
+	var out []cmaf.Segment
+	var start uint64
+	for i := range n {
+		duration := d
+		if i == n-1 {
+			duration = last
+		}
+		out = append(out, cmaf.Segment{Start: start, Duration: duration, Size: 1000})
+		start += duration
+	}
+	return out
+}
+
+// presentation 是 14.5 秒的点播：720p 与 360p 两条视频流和一条音轨
+func presentation() *cmaf.Presentation {
+	video := func(id string, height int, bandwidth int64) cmaf.Stream {
+		return cmaf.Stream{
+			ID: id, Type: cmaf.StreamVideo, Codecs: "avc1.64001f", Timescale: 90000,
+			Bandwidth: bandwidth, Width: height * 16 / 9, Height: height, FrameRate: 29.97,
+			InitURI: "init.mp4", SegmentTemplate: "seg_$Number$.m4s",
+			Segments: segments(3, 540000, 225000),
+		}
+	}
+	return &cmaf.Presentation{
+		Duration:        14.5,
+		SegmentDuration: 6,
+		Streams: []cmaf.Stream{
+			video("video_720p", 720, 3100000),
+			video("video_360p", 360, 900000),
+			{
+				ID: "audio", Type: cmaf.StreamAudio, Codecs: "mp4a.40.2", Timescale: 48000,
+				Bandwidth: 130000, SampleRate: 48000, Channels: 2,
+				InitURI: "init.mp4", SegmentTemplate: "seg_$Number$.m4s",
+				Segments: segments(3, 288000, 120000),
+			},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	m := Build(presentation())
+	if err := Validate(m); err != nil {
+		t.Fatal(err)
+	}
+	if m.MediaPresentationDuration != "PT14.500S" || m.MinBufferTime != "PT6.000S" {
+		t.Errorf("durations: %s %s", m.MediaPresentationDuration, m.MinBufferTime)
+	}
+	sets := m.Periods[0].AdaptationSets
+	if len(sets) != 2 {
+		t.Fatalf("%d adaptation sets, want 2", len(sets))
+	}
+
+	video := sets[0]
+	if video.ContentType != "video" || !video.SegmentAlignment || video.MaxWidth != 1280 || video.MaxHeight != 720 {
+		t.Errorf("video adaptation set: %+v", video)
+	}
+	// 按码率从低到高排列
+	if video.Representations[0].ID != "video_360p" || video.Representations[1].ID != "video_720p" {
+		t.Errorf("representations are not sorted by bandwidth")
+	}
+	rep := video.Representations[0]
+	if rep.FrameRate != "30000/1001" || rep.SegmentTemplate.Initialization != "video_360p/init.mp4" ||
+		rep.SegmentTemplate.Media != "video_360p/seg_$Number$.m4s" {
+		t.Errorf("representation: %+v", rep)
+	}
+	// 前两个时长相同的分片合并为带 r 的一项
+	s := rep.SegmentTemplate.SegmentTimeline.S
+	if len(s) != 2 || s[0].T == nil || *s[0].T != 0 || s[0].D != 540000 || s[0].R != 1 || s[1].T != nil || s[1].D != 225000 {
+		t.Errorf("timeline: %+v", s)
+	}
+
+	audio := sets[1]
+	if audio.ContentType != "audio" || audio.Representations[0].AudioChannelConfiguration.Value != "2" ||
+		audio.Representations[0].AudioSamplingRate != 48000 {
+		t.Errorf("audio adaptation set: %+v", audio)
+	}
+}
+
+func TestBuildUnalignedVideo(t *testing.T) {
+	pres := presentation()
+	pres.Streams[1].Segments = segments(2, 900000, 405000)
+	m := Build(pres)
+	if m.Periods[0].AdaptationSets[0].SegmentAlignment {
+		t.Error("streams with different segment boundaries are marked aligned")
+	}
+	if err := Validate(m); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteManifest(dir, presentation()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, Manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), `<?xml version="1.0" encoding="UTF-8"?>`) {
+		t.Errorf("manifest has no XML header")
+	}
+	var m MPD
+	if err := xml.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(&m); err != nil {
+		t.Errorf("parsed manifest is invalid: %v", err)
+	}
+	if m.Profiles != profileLive || len(m.Periods[0].AdaptationSets) != 2 {
+		t.Errorf("parsed manifest: %+v", m)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(m *MPD)
+		want   string
+	}{
+		{"namespace", func(m *MPD) { m.Xmlns = "urn:example" }, "unexpected namespace"},
+		{"dynamic", func(m *MPD) { m.Type = "dynamic" }, "type must be static"},
+		{"duration", func(m *MPD) { m.MediaPresentationDuration = "14.5" }, "mediaPresentationDuration"},
+		{"no period", func(m *MPD) { m.Periods = nil }, "no Period"},
+		{"duplicate id", func(m *MPD) {
+			reps := m.Periods[0].AdaptationSets[0].Representations
+			reps[1].ID = reps[0].ID
+		}, "duplicate representation id"},
+		{"bandwidth", func(m *MPD) { m.Periods[0].AdaptationSets[0].Representations[0].Bandwidth = 0 }, "bandwidth must be positive"},
+		{"codecs", func(m *MPD) { m.Periods[0].AdaptationSets[1].Representations[0].Codecs = "" }, "missing codecs"},
+		{"resolution", func(m *MPD) { m.Periods[0].AdaptationSets[0].Representations[0].Height = 0 }, "missing resolution"},
+		{"media template", func(m *MPD) {
+			m.Periods[0].AdaptationSets[0].Representations[0].SegmentTemplate.Media = "seg.m4s"
+		}, "no $Number$ or $Time$"},
+		{"overlap", func(m *MPD) {
+			s := m.Periods[0].AdaptationSets[0].Representations[0].SegmentTemplate.SegmentTimeline.S
+			start := uint64(100)
+			s[1].T = &start
+		}, "overlaps the previous segment"},
+		{"timeline length", func(m *MPD) {
+			s := m.Periods[0].AdaptationSets[1].Representations[0].SegmentTemplate.SegmentTimeline.S
+			s[0].R = 5
+		}, "timeline covers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Build(presentation())
+			tt.modify(m)
+			err := Validate(m)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.want)
+			}
+			if _, err := Marshal(m); err == nil {
+				t.Error("Marshal accepted an invalid MPD")
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"PT14.500S", 14.5, true},
+		{"PT1H2M3S", 3723, true},
+		{"PT5M", 300, true},
+		{"PT", 0, false},
+		{"P1D", 0, false},
+		{"PT-1S", 0, false},
+		{"PT1X", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestFrameRate(t *testing.T) {
+	tests := map[float64]string{25: "25", 29.97: "30000/1001", 59.94: "60000/1001", 12.5: "12.500"}
+	for fps, want := range tests {
+		if got := frameRate(fps); got != want {
+			t.Errorf("frameRate(%v) = %s, want %s", fps, got, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+)
+
+// Encode 把 EXIF 编码为 TIFF 结构，布局依次为头部、主图 IFD、Exif 子 IFD、GPS 子 IFD
+// 子 IFD 指针按实际布局重新计算
+func (x *Exif) Encode() []byte {
+	ifd0 := withoutTags(x.IFD0, tagExifIFD, tagGPSIFD)
+	if len(x.Exif) > 0 {
+		ifd0 = append(ifd0, Entry{Tag: tagExifIFD, Type: typeLong, Count: 1, Value: make([]byte, 4)})
+	}
+	if len(x.GPS) > 0 {
+		ifd0 = append(ifd0, Entry{Tag: tagGPSIFD, Type: typeLong, Count: 1, Value: make([]byte, 4)})
+	}
+	sortEntries(ifd0)
+	exif, gps := slices.Clone(x.Exif), slices.Clone(x.GPS)
+	sortEntries(exif)
+	sortEntries(gps)
+
+	// 先计算各 IFD 的偏移，再回填指针
+	ifd0Offset := uint32(8)
+	exifOffset := ifd0Offset + ifdSize(ifd0)
+	gpsOffset := exifOffset + ifdSize(exif)
+	for i := range ifd0 {
+		switch ifd0[i].Tag {
+		case tagExifIFD:
+			x.order.PutUint32(ifd0[i].Value, exifOffset)
+		case tagGPSIFD:
+			x.order.PutUint32(ifd0[i].Value, gpsOffset)
+		}
+	}
+
+	var buf bytes.Buffer
+	if x.order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	x.write(&buf, uint16(42))
+	x.write(&buf, ifd0Offset)
+	x.writeIFD(&buf, ifd0, ifd0Offset)
+	if len(exif) > 0 {
+		x.writeIFD(&buf, exif, exifOffset)
+	}
+	if len(gps) > 0 {
+		x.writeIFD(&buf, gps, gpsOffset)
+	}
+	return buf.Bytes()
+}
+
+// ifdSize 返回 IFD 及其外置数据占用的字节数，外置数据按 2 字节对齐
+func ifdSize(entries []Entry) uint32 {
+	if len(entries) == 0 {
+		return 0
+	}
+	size := uint32(2 + 12*len(entries) + 4)
+	for _, e := range entries {
+		if len(e.Value) > 4 {
+			size += uint32(len(e.Value) + len(e.Value)&1)
+		}
+	}
+	return size
+}
+
+func (x *Exif) writeIFD(buf *bytes.Buffer, entries []Entry, offset uint32) {
+	data := offset + uint32(2+12*len(entries)+4)
+	x.write(buf, uint16(len(entries)))
+	for _, e := range entries {
+		x.write(buf, e.Tag)
+		x.write(buf, e.Type)
+		x.write(buf, e.Count)
+		if len(e.Value) <= 4 {
+			var inline [4]byte
+			copy(inline[:], e.Value)
+			buf.Write(inline[:])
+		} else {
+			x.write(buf, data)
+			data += uint32(len(e.Value) + len(e.Value)&1)
+		}
+	}
+	// 不写缩略图 IFD
+	x.write(buf, uint32(0))
+	for _, e := range entries {
+		if len(e.Value) > 4 {
+			buf.Write(e.Value)
+			if len(e.Value)&1 == 1 {
+				buf.WriteByte(0)
+			}
+		}
+	}
+}
+
+func (x *Exif) write(buf *bytes.Buffer, v any) {
+	binary.Write(buf, x.order, v)
+}
+
+func withoutTags(entries []Entry, tags ...uint16) []Entry {
+	var out []Entry
+	for _, e := range entries {
+		if !slices.Contains(tags, e.Tag) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// sortEntries TIFF 要求 IFD 条目按标签升序排列
+func sortEntries(entries []Entry) {
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return int(a.Tag) - int(b.Tag)
+	})
+}
@@ -0,0 +1,229 @@
+package exif
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalid EXIF 数据不是合法的 TIFF 结构
+var ErrInvalid = errors.New("exif: invalid tiff data")
+
+// 数据类型
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
+	typeSLong     = 9
+	typeSRational = 10
+	typeFloat     = 11
+	typeDouble    = 12
+	typeIFD       = 13
+)
+
+// 用到的标签
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagXResolution        = 0x011A
+	tagYResolution        = 0x011B
+	tagResolutionUnit     = 0x0128
+	tagSoftware           = 0x0131
+	tagDateTime           = 0x0132
+	tagYCbCrPositioning   = 0x0213
+	tagExposureTime       = 0x829A
+	tagFNumber            = 0x829D
+	tagExifIFD            = 0x8769
+	tagExposureProgram    = 0x8822
+	tagGPSIFD             = 0x8825
+	tagISO                = 0x8827
+	tagExifVersion        = 0x9000
+	tagDateTimeOriginal   = 0x9003
+	tagDateTimeDigitized  = 0x9004
+	tagOffsetTime         = 0x9010
+	tagOffsetTimeOriginal = 0x9011
+	tagOffsetTimeDigit    = 0x9012
+	tagExposureBias       = 0x9204
+	tagMeteringMode       = 0x9207
+	tagFlash              = 0x9209
+	tagFocalLength        = 0x920A
+	tagSubSecTimeOriginal = 0x9291
+	tagColorSpace         = 0xA001
+	tagWhiteBalance       = 0xA403
+	tagFocalLength35mm    = 0xA405
+	tagLensMake           = 0xA433
+	tagLensModel          = 0xA434
+)
+
+// 单个 IFD 的条目数上限，防止恶意数据导致大量分配
+const maxEntries = 1024
+
+// Entry 是 IFD 中的一个条目，Value 保留原字节序的原始数据，重新编码时原样写回
+type Entry struct {
+	Tag   uint16
+	Type  uint16
+	Count uint32
+	Value []byte
+}
+
+// Exif 是解析后的 EXIF 数据，只保留主图 IFD、Exif 子 IFD 与 GPS 子 IFD，缩略图 IFD 被丢弃
+type Exif struct {
+	order binary.ByteOrder
+
+	IFD0 []Entry
+	Exif []Entry
+	GPS  []Entry
+}
+
+func typeSize(t uint16) int {
+	switch t {
+	case typeByte, typeASCII, typeUndefined:
+		return 1
+	case typeShort:
+		return 2
+	case typeLong, typeSLong, typeFloat, typeIFD:
+		return 4
+	case typeRational, typeSRational, typeDouble:
+		return 8
+	}
+	return 0
+}
+
+// Parse 解析 TIFF 结构的 EXIF 数据（JPEG APP1 段去掉 "Exif\0\0" 前缀后的部分）
+func Parse(data []byte) (*Exif, error) {
+	if len(data) < 8 {
+		return nil, ErrInvalid
+	}
+	x := &Exif{}
+	switch string(data[:2]) {
+	case "II":
+		x.order = binary.LittleEndian
+	case "MM":
+		x.order = binary.BigEndian
+	default:
+		return nil, ErrInvalid
+	}
+	if x.order.Uint16(data[2:4]) != 42 {
+		return nil, ErrInvalid
+	}
+
+	var err error
+	if x.IFD0, err = x.readIFD(data, x.order.Uint32(data[4:8])); err != nil {
+		return nil, err
+	}
+	// 子 IFD 损坏时只丢弃该子 IFD，主图信息仍然可用
+	if offset, ok := x.pointer(x.IFD0, tagExifIFD); ok {
+		x.Exif, _ = x.readIFD(data, offset)
+	}
+	if offset, ok := x.pointer(x.IFD0, tagGPSIFD); ok {
+		x.GPS, _ = x.readIFD(data, offset)
+	}
+	return x, nil
+}
+
+func (x *Exif) readIFD(data []byte, offset uint32) ([]Entry, error) {
+	if int64(offset)+2 > int64(len(data)) {
+		return nil, ErrInvalid
+	}
+	n := int(x.order.Uint16(data[offset:]))
+	if n > maxEntries || int64(offset)+2+int64(n)*12 > int64(len(data)) {
+		return nil, ErrInvalid
+	}
+	entries := make([]Entry, 0, n)
+	for i := 0; i < n; i++ {
+		raw := data[int(offset)+2+i*12:]
+		e := Entry{
+			Tag:   x.order.Uint16(raw[0:2]),
+			Type:  x.order.Uint16(raw[2:4]),
+			Count: x.order.Uint32(raw[4:8]),
+		}
+		size := int64(typeSize(e.Type)) * int64(e.Count)
+		if size == 0 {
+			continue
+		}
+		if size <= 4 {
+			e.Value = append([]byte(nil), raw[8:8+size]...)
+		} else {
+			start := int64(x.order.Uint32(raw[8:12]))
+			if start+size > int64(len(data)) {
+				continue
+			}
+			e.Value = append([]byte(nil), data[start:start+size]...)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (x *Exif) pointer(entries []Entry, tag uint16) (uint32, bool) {
+	e := find(entries, tag)
+	if e == nil || (e.Type != typeLong && e.Type != typeIFD) || len(e.Value) < 4 {
+		return 0, false
+	}
+	return x.order.Uint32(e.Value), true
+}
+
+func find(entries []Entry, tag uint16) *Entry {
+	for i := range entries {
+		if entries[i].Tag == tag {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// Orientation 返回方向标签的值（1-8），没有或非法时返回 1
+func (x *Exif) Orientation() int {
+	e := find(x.IFD0, tagOrientation)
+	if e == nil || e.Type != typeShort || len(e.Value) < 2 {
+		return 1
+	}
+	if v := int(x.order.Uint16(e.Value)); v >= 1 && v <= 8 {
+		return v
+	}
+	return 1
+}
+
+func (x *Exif) str(entries []Entry, tag uint16) string {
+	e := find(entries, tag)
+	if e == nil || e.Type != typeASCII {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(e.Value), "\x00"))
+}
+
+// Make 返回相机厂商
+func (x *Exif) Make() string { return x.str(x.IFD0, tagMake) }
+
+// Model 返回相机型号
+func (x *Exif) Model() string { return x.str(x.IFD0, tagModel) }
+
+// LensModel 返回镜头型号
+func (x *Exif) LensModel() string { return x.str(x.Exif, tagLensModel) }
+
+// CaptureTime 返回拍摄时间，优先使用 DateTimeOriginal
+// 没有时区偏移时按 UTC 解释
+func (x *Exif) CaptureTime() (time.Time, bool) {
+	value, offset := x.str(x.Exif, tagDateTimeOriginal), x.str(x.Exif, tagOffsetTimeOriginal)
+	if value == "" {
+		value, offset = x.str(x.IFD0, tagDateTime), x.str(x.Exif, tagOffsetTime)
+	}
+	if value == "" {
+		return time.Time{}, false
+	}
+	if offset != "" {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", value+offset); err == nil {
+			return t, true
+		}
+	}
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", value, time.UTC)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
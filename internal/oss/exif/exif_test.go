@@ -0,0 +1,164 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	tagArtist       = 0x013B
+	tagMakerNote    = 0x927C
+	tagSerialNumber = 0xA431
+	tagGPSLatitude  = 0x0002
+)
+
+func ascii(s string) Entry {
+	return Entry{Type: typeASCII, Count: uint32(len(s) + 1), Value: append([]byte(s), 0)}
+}
+
+func tagged(tag uint16, e Entry) Entry {
+	e.Tag = tag
+	return e
+}
+
+// testExif 含有相机、拍摄时间、序列号、作者、MakerNote 与 GPS 位置
+func testExif(order binary.ByteOrder) *Exif {
+	orientation := make([]byte, 2)
+	order.PutUint16(orientation, 6)
+	return &Exif{
+		order: order,
+		IFD0: []Entry{
+			tagged(tagMake, ascii("Camera")),
+			tagged(tagModel, ascii("X100")),
+			{Tag: tagOrientation, Type: typeShort, Count: 1, Value: orientation},
+			tagged(tagArtist, ascii("Alice")),
+		},
+		Exif: []Entry{
+			tagged(tagDateTimeOriginal, ascii("2024:05:06 07:08:09")),
+			tagged(tagOffsetTimeOriginal, ascii("+08:00")),
+			tagged(tagSerialNumber, ascii("SN123456")),
+			{Tag: tagMakerNote, Type: typeUndefined, Count: 8, Value: []byte("MAKERNOT")},
+		},
+		GPS: []Entry{
+			{Tag: tagGPSLatitude, Type: typeRational, Count: 3, Value: make([]byte, 24)},
+		},
+	}
+}
+
+func tags(entries []Entry) []uint16 {
+	var out []uint16
+	for _, e := range entries {
+		out = append(out, e.Tag)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestEncodeParse(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		x, err := Parse(testExif(order).Encode())
+		if err != nil {
+			t.Fatalf("%v: %v", order, err)
+		}
+		if x.Orientation() != 6 || x.Make() != "Camera" || x.Model() != "X100" {
+			t.Errorf("%v: orientation %d make %q model %q", order, x.Orientation(), x.Make(), x.Model())
+		}
+		want := time.Date(2024, 5, 6, 7, 8, 9, 0, time.FixedZone("", 8*3600))
+		if got, ok := x.CaptureTime(); !ok || !got.Equal(want) {
+			t.Errorf("%v: CaptureTime() = %v, %v, want %v", order, got, ok, want)
+		}
+		if len(x.GPS) != 1 {
+			t.Errorf("%v: %d GPS entries, want 1", order, len(x.GPS))
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	valid := testExif(binary.BigEndian).Encode()
+	// IFD 条目数超过上限
+	tooMany := slices.Clone(valid)
+	binary.BigEndian.PutUint16(tooMany[8:], maxEntries+1)
+	tests := map[string][]byte{
+		"short":            valid[:6],
+		"bad byte order":   append([]byte("XX"), valid[2:]...),
+		"ifd out of range": append(slices.Clone(valid[:4]), 0xFF, 0xFF, 0xFF, 0xFF),
+		"too many entries": tooMany,
+	}
+	for name, data := range tests {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("%s: Parse() succeeded", name)
+		}
+	}
+
+	// 值偏移越界的条目被跳过，其余条目仍然可用
+	outOfRange := slices.Clone(valid)
+	binary.BigEndian.PutUint32(outOfRange[8+2+8:], uint32(len(valid)))
+	x, err := Parse(outOfRange)
+	if err != nil || x.Make() != "" || x.Model() != "X100" {
+		t.Errorf("Parse() with a bad value offset = %+v, %v", x, err)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	x := testExif(binary.BigEndian)
+	tests := []struct {
+		name   string
+		policy Policy
+		ifd0   []uint16
+		exif   []uint16
+		gps    bool
+	}{
+		{"default", DefaultPolicy(), []uint16{tagMake, tagModel}, []uint16{tagDateTimeOriginal, tagOffsetTimeOriginal}, false},
+		{"keep gps", Policy{KeepGPS: true}, nil, nil, true},
+		{"capture time only", Policy{KeepCaptureTime: true}, nil, []uint16{tagDateTimeOriginal, tagOffsetTimeOriginal}, false},
+	}
+	for _, tt := range tests {
+		got := x.Sanitize(tt.policy)
+		if got == nil {
+			t.Errorf("%s: Sanitize() = nil", tt.name)
+			continue
+		}
+		// 方向、作者、序列号与 MakerNote 总是删除
+		if !slices.Equal(tags(got.IFD0), tt.ifd0) || !slices.Equal(tags(got.Exif), tt.exif) || (len(got.GPS) > 0) != tt.gps {
+			t.Errorf("%s: IFD0 %x Exif %x GPS %d", tt.name, tags(got.IFD0), tags(got.Exif), len(got.GPS))
+		}
+	}
+	if got := x.Sanitize(Policy{}); got != nil {
+		t.Errorf("Sanitize() with nothing to keep = %+v, want nil", got)
+	}
+}
+
+func TestRewriteJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	withExif, err := RewriteJPEG(buf.Bytes(), testExif(binary.LittleEndian))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := FromJPEG(withExif)
+	if err != nil || x == nil || x.Make() != "Camera" {
+		t.Fatalf("FromJPEG() = %+v, %v", x, err)
+	}
+
+	stripped, err := RewriteJPEG(withExif, x.Sanitize(Policy{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, err := FromJPEG(stripped); err != nil || x != nil {
+		t.Errorf("FromJPEG() after stripping = %+v, %v, want no EXIF", x, err)
+	}
+	// 压缩数据原样保留，仍可解码
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("stripped JPEG does not decode: %v", err)
+	}
+	if _, err := FromJPEG([]byte("not a jpeg")); err != ErrNotJPEG {
+		t.Errorf("FromJPEG() of non-JPEG data: error = %v, want ErrNotJPEG", err)
+	}
+}
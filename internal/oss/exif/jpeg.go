@@ -0,0 +1,114 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrNotJPEG 数据不是 JPEG 或段结构损坏
+var ErrNotJPEG = errors.New("exif: not a jpeg file")
+
+const (
+	markerSOI   = 0xD8
+	markerSOS   = 0xDA
+	markerAPP0  = 0xE0
+	markerAPP1  = 0xE1
+	markerAPP13 = 0xED
+	markerCOM   = 0xFE
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// segment 是 SOS 之前的一个标记段，data 包含标记与长度字段
+type segment struct {
+	marker byte
+	data   []byte
+}
+
+// payload 返回段内容（不含标记与长度）
+func (s segment) payload() []byte {
+	return s.data[4:]
+}
+
+// splitJPEG 拆分 SOS 之前的标记段，rest 为 SOS 段及其后的压缩数据
+func splitJPEG(data []byte) ([]segment, []byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, nil, ErrNotJPEG
+	}
+	var segments []segment
+	pos := 2
+	for {
+		if pos+4 > len(data) || data[pos] != 0xFF {
+			return nil, nil, ErrNotJPEG
+		}
+		// 标记前允许有填充的 0xFF
+		for pos+1 < len(data) && data[pos+1] == 0xFF {
+			pos++
+		}
+		if pos+4 > len(data) {
+			return nil, nil, ErrNotJPEG
+		}
+		marker := data[pos+1]
+		if marker == markerSOS {
+			return segments, data[pos:], nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, nil, ErrNotJPEG
+		}
+		segments = append(segments, segment{marker: marker, data: data[pos : pos+2+length]})
+		pos += 2 + length
+	}
+}
+
+// FromJPEG 读取 JPEG 中的 EXIF，没有 EXIF 时返回 nil
+func FromJPEG(data []byte) (*Exif, error) {
+	segments, _, err := splitJPEG(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range segments {
+		if s.marker == markerAPP1 && bytes.HasPrefix(s.payload(), exifHeader) {
+			return Parse(s.payload()[len(exifHeader):])
+		}
+	}
+	return nil, nil
+}
+
+// RewriteJPEG 删除 JPEG 中的 EXIF、XMP、IPTC 与注释段，并在 JFIF 段之后写入 x（可为 nil）
+// ICC 色彩配置等其他段与压缩数据原样保留
+func RewriteJPEG(data []byte, x *Exif) ([]byte, error) {
+	segments, rest, err := splitJPEG(data)
+	if err != nil {
+		return nil, err
+	}
+	var app1 []byte
+	if x != nil {
+		tiff := x.Encode()
+		// 段长度字段为 16 位，超出时放弃写入 EXIF
+		if length := 2 + len(exifHeader) + len(tiff); length <= 0xFFFF {
+			app1 = append([]byte{0xFF, markerAPP1, byte(length >> 8), byte(length)}, exifHeader...)
+			app1 = append(app1, tiff...)
+		}
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write([]byte{0xFF, markerSOI})
+	for _, s := range segments {
+		if app1 != nil && s.marker != markerAPP0 {
+			out.Write(app1)
+			app1 = nil
+		}
+		switch s.marker {
+		case markerAPP1, markerAPP13, markerCOM:
+			continue
+		}
+		out.Write(s.data)
+	}
+	if app1 != nil {
+		out.Write(app1)
+	}
+	out.Write(rest)
+	return out.Bytes(), nil
+}
@@ -0,0 +1,100 @@
+package exif
+
+import "slices"
+
+// Policy 是 EXIF 清理策略
+// 采用白名单：只有下列分组中的标签会被保留，序列号、机主姓名、作者、MakerNote、备注、缩略图等一律删除
+// 方向标签总是删除，图片像素已按方向纠正
+type Policy struct {
+	KeepGPS         bool // 保留 GPS 位置
+	KeepCamera      bool // 保留相机厂商、型号、镜头与处理软件
+	KeepCaptureTime bool // 保留拍摄时间与时区偏移
+	KeepExposure    bool // 保留光圈、快门、ISO、焦距等拍摄参数
+
+	// 保留清理前的原文件作为 original 衍生对象，原文件包含位置等敏感信息，只有所有者与管理员可以下载
+	KeepOriginal bool
+	// 纠正方向需要重新编码时的 JPEG 质量
+	Quality int
+}
+
+// DefaultPolicy 删除位置，保留相机、拍摄时间与拍摄参数
+func DefaultPolicy() Policy {
+	return Policy{
+		KeepCamera:      true,
+		KeepCaptureTime: true,
+		KeepExposure:    true,
+		Quality:         92,
+	}
+}
+
+// 无论策略如何都保留的标签，只描述图像本身
+var (
+	baseIFD0Tags = []uint16{tagXResolution, tagYResolution, tagResolutionUnit, tagYCbCrPositioning}
+	baseExifTags = []uint16{tagExifVersion, tagColorSpace}
+
+	cameraIFD0Tags = []uint16{tagMake, tagModel, tagSoftware}
+	cameraExifTags = []uint16{tagLensMake, tagLensModel}
+
+	captureIFD0Tags = []uint16{tagDateTime}
+	captureExifTags = []uint16{
+		tagDateTimeOriginal, tagDateTimeDigitized, tagOffsetTime, tagOffsetTimeOriginal,
+		tagOffsetTimeDigit, tagSubSecTimeOriginal,
+	}
+
+	exposureExifTags = []uint16{
+		tagExposureTime, tagFNumber, tagExposureProgram, tagISO, tagExposureBias,
+		tagMeteringMode, tagFlash, tagFocalLength, tagWhiteBalance, tagFocalLength35mm,
+	}
+)
+
+// Sanitize 按策略返回清理后的 EXIF，保留的条目为空时返回 nil
+func (x *Exif) Sanitize(p Policy) *Exif {
+	ifd0, exif := slices.Clone(baseIFD0Tags), slices.Clone(baseExifTags)
+	if p.KeepCamera {
+		ifd0, exif = append(ifd0, cameraIFD0Tags...), append(exif, cameraExifTags...)
+	}
+	if p.KeepCaptureTime {
+		ifd0, exif = append(ifd0, captureIFD0Tags...), append(exif, captureExifTags...)
+	}
+	if p.KeepExposure {
+		exif = append(exif, exposureExifTags...)
+	}
+
+	out := &Exif{
+		order: x.order,
+		IFD0:  filter(x.IFD0, ifd0),
+		Exif:  filter(x.Exif, exif),
+	}
+	if p.KeepGPS {
+		out.GPS = slices.Clone(x.GPS)
+	}
+	// 只剩分辨率等基础标签时没有保留的意义
+	if !out.hasAny(ifd0, exif) && len(out.GPS) == 0 {
+		return nil
+	}
+	return out
+}
+
+func (x *Exif) hasAny(ifd0, exif []uint16) bool {
+	for _, e := range x.IFD0 {
+		if !slices.Contains(baseIFD0Tags, e.Tag) && slices.Contains(ifd0, e.Tag) {
+			return true
+		}
+	}
+	for _, e := range x.Exif {
+		if !slices.Contains(baseExifTags, e.Tag) && slices.Contains(exif, e.Tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func filter(entries []Entry, tags []uint16) []Entry {
+	var out []Entry
+	for _, e := range entries {
+		if slices.Contains(tags, e.Tag) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,268 @@
+// Package fetch 服务端下载远程资源，限制大小、超时与重定向次数，拒绝访问内网地址
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrScheme    = errors.New("fetch: only http and https URLs are supported")
+	ErrForbidden = errors.New("fetch: destination address is not allowed")
+	ErrTooLarge  = errors.New("fetch: resource exceeds size limit")
+	ErrRedirects = errors.New("fetch: too many redirects")
+)
+
+// StatusError 远程服务器返回了非 2xx 状态码
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("fetch: remote server returned %d", e.Code)
+}
+
+// Config 下载限制
+type Config struct {
+	MaxBytes       int64         // 资源的最大字节数
+	ConnectTimeout time.Duration // 建立连接的超时
+	HeaderTimeout  time.Duration // 等待响应头的超时
+	Timeout        time.Duration // 整个下载的超时，包含重定向
+	MaxRedirects   int           // 最多跟随的重定向次数
+	MaxConcurrent  int           // 同时进行的下载数
+	// 允许访问的内网网段，默认拒绝环回、私有、链路本地等非公网地址
+	Allow []netip.Prefix
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		MaxBytes:       2 << 30,
+		ConnectTimeout: 10 * time.Second,
+		HeaderTimeout:  30 * time.Second,
+		Timeout:        30 * time.Minute,
+		MaxRedirects:   5,
+		MaxConcurrent:  4,
+	}
+}
+
+// ParseAllow 解析逗号分隔的网段列表，单个地址视为 /32 或 /128
+func ParseAllow(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// 默认拒绝的非公网网段，IPv4 映射地址按映射后的 IPv4 检查
+var blocked = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // 本网络
+	netip.MustParsePrefix("10.0.0.0/8"),      // 私有
+	netip.MustParsePrefix("100.64.0.0/10"),   // 运营商级 NAT
+	netip.MustParsePrefix("127.0.0.0/8"),     // 环回
+	netip.MustParsePrefix("169.254.0.0/16"),  // 链路本地，包含云厂商元数据服务
+	netip.MustParsePrefix("172.16.0.0/12"),   // 私有
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF 协议分配
+	netip.MustParsePrefix("192.0.2.0/24"),    // 文档地址
+	netip.MustParsePrefix("192.168.0.0/16"),  // 私有
+	netip.MustParsePrefix("198.18.0.0/15"),   // 基准测试
+	netip.MustParsePrefix("198.51.100.0/24"), // 文档地址
+	netip.MustParsePrefix("203.0.113.0/24"),  // 文档地址
+	netip.MustParsePrefix("224.0.0.0/3"),     // 组播、保留地址与广播
+	netip.MustParsePrefix("::/128"),          // 未指定地址
+	netip.MustParsePrefix("::1/128"),         // 环回
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64，可能映射到内网 IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // 本地 NAT64
+	netip.MustParsePrefix("100::/64"),        // 丢弃前缀
+	netip.MustParsePrefix("2001::/23"),       // IETF 协议分配，包含 Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // 文档地址
+	netip.MustParsePrefix("2002::/16"),       // 6to4，可能映射到内网 IPv4
+	netip.MustParsePrefix("fc00::/7"),        // 唯一本地地址
+	netip.MustParsePrefix("fe80::/10"),       // 链路本地
+	netip.MustParsePrefix("fec0::/10"),       // 已废弃的站点本地地址
+	netip.MustParsePrefix("ff00::/8"),        // 组播
+}
+
+// Fetcher 下载远程资源
+// 在建立连接时检查实际连接的 IP，DNS 重绑定与重定向到内网地址同样会被拒绝；不使用环境变量中的代理
+type Fetcher struct {
+	cfg    Config
+	client *http.Client
+	sem    chan struct{}
+}
+
+func New(cfg Config) *Fetcher {
+	f := &Fetcher{cfg: cfg, sem: make(chan struct{}, max(cfg.MaxConcurrent, 1))}
+	dialer := &net.Dialer{
+		Timeout: cfg.ConnectTimeout,
+		Control: f.control,
+	}
+	f.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			TLSHandshakeTimeout:   cfg.ConnectTimeout,
+			ResponseHeaderTimeout: cfg.HeaderTimeout,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConns:          16,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return ErrRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrScheme
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// Allowed 返回是否允许连接该地址
+func (f *Fetcher) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range f.cfg.Allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, prefix := range blocked {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return addr.IsGlobalUnicast()
+}
+
+// control 在连接建立前检查解析后的地址
+func (f *Fetcher) control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !f.Allowed(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbidden, addrPort.Addr())
+	}
+	return nil
+}
+
+// Result 下载结果
+type Result struct {
+	URL         string // 跟随重定向后的最终地址
+	ContentType string // 响应的媒体类型，不含参数
+	FileName    string // 来自 Content-Disposition 或 URL 路径，可能为空
+	Size        int64
+}
+
+// Fetch 下载 rawURL 写入 w，超过 MaxBytes 时返回 ErrTooLarge
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string, w io.Writer) (*Result, error) {
+	u, err := Validate(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case f.sem <- struct{}{}:
+		defer func() { <-f.sem }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		// 重定向与连接检查的错误被包装在 url.Error 中
+		for _, target := range []error{ErrForbidden, ErrRedirects, ErrScheme} {
+			if errors.Is(err, target) {
+				return nil, target
+			}
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{Code: resp.StatusCode}
+	}
+	if resp.ContentLength > f.cfg.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	n, err := io.Copy(w, io.LimitReader(resp.Body, f.cfg.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > f.cfg.MaxBytes {
+		return nil, ErrTooLarge
+	}
+	result := &Result{URL: resp.Request.URL.String(), Size: n}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		result.ContentType = mediaType
+	}
+	result.FileName = fileName(resp)
+	return result, nil
+}
+
+// Validate 检查 URL 的协议与主机，地址检查在连接时进行
+func Validate(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, ErrScheme
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("fetch: URL has no host")
+	}
+	if u.User != nil {
+		return nil, errors.New("fetch: URL must not contain credentials")
+	}
+	return u, nil
+}
+
+// fileName 从 Content-Disposition 或最终 URL 的路径中取文件名
+func fileName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := path.Base(strings.ReplaceAll(params["filename"], "\\", "/")); name != "." && name != "/" {
+			return name
+		}
+	}
+	name := path.Base(resp.Request.URL.Path)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
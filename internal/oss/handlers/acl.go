@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"gorm.io/gorm"
+)
+
+var errAccessDenied = fiber.NewError(fiber.StatusForbidden, "Access denied")
+
+// canRead 返回请求方能否读取对象：public-read、所有者、被授权的用户与管理员可读
+// 衍生对象按源对象的 ACL 判断，改写前的原文件只有所有者与管理员可读
+func (h *Handlers) canRead(c *fiber.Ctx, object *model.OssObject) (bool, error) {
+	root := object
+	if object.ParentID != nil {
+		parent, err := repo.GetObject(h.db, *object.ParentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 源对象已删除时衍生对象不再对外提供
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		root = parent
+	}
+	if h.isAdmin(c) {
+		return true, nil
+	}
+	userID := getUserID(c)
+	// 改写前的原文件可能含有 EXIF 中的位置信息等，不随 ACL 与授权开放
+	if object.Variant == VariantOriginal {
+		return userID != 0 && root.UserID == userID, nil
+	}
+	if root.ACL == "" || root.ACL == model.ACLPublicRead {
+		return true, nil
+	}
+	if userID == 0 {
+		return false, nil
+	}
+	if root.UserID == userID {
+		return true, nil
+	}
+	return repo.HasGrant(h.db, root.ID, userID)
+}
+
+// checkRead 请求方不能读取对象时返回 403
+func (h *Handlers) checkRead(c *fiber.Ctx, object *model.OssObject) error {
+	ok, err := h.canRead(c, object)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check object access")
+	}
+	if !ok {
+		return errAccessDenied
+	}
+	return nil
+}
+
+// readable 用于过滤列表结果，检查失败时视为不可读
+func (h *Handlers) readable(c *fiber.Ctx, object *model.OssObject) bool {
+	ok, err := h.canRead(c, object)
+	return err == nil && ok
+}
+
+// RequireRead 校验请求方能否读取路径参数 :id 指定的对象，对象不存在时交给后续处理返回 404
+func (h *Handlers) RequireRead(c *fiber.Ctx) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Next()
+	}
+	object, err := repo.GetObject(h.db, uint(objectID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Next()
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check object access",
+		})
+	}
+	if err := h.checkRead(c, object); err != nil {
+		return sendError(c, err)
+	}
+	return c.Next()
+}
+
+// readableScopes 返回列表查询的可读范围，管理员不受限制
+func (h *Handlers) readableScopes(c *fiber.Ctx) []func(*gorm.DB) *gorm.DB {
+	if h.isAdmin(c) {
+		return nil
+	}
+	return []func(*gorm.DB) *gorm.DB{repo.ReadableBy(getUserID(c))}
+}
+
+// validACL 校验 ACL，为空时使用 public-read
+func validACL(acl string) (string, bool) {
+	switch acl {
+	case "":
+		return model.ACLPublicRead, true
+	case model.ACLPublicRead, model.ACLPrivate:
+		return acl, true
+	}
+	return "", false
+}
+
+// objectURL 返回对象的公开访问地址，private 对象没有公开地址，通过下载接口或分享链接访问
+func objectURL(acl, fileName string) string {
+	if acl == model.ACLPrivate {
+		return ""
+	}
+	return "/files/" + fileName
+}
+
+// aclTarget 读取要修改访问控制的源对象，只有所有者与管理员可以修改
+func (h *Handlers) aclTarget(c *fiber.Ctx) (*model.OssObject, error) {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid object id")
+	}
+	object, err := repo.GetObject(h.db, uint(objectID))
+	if err != nil || object.ParentID != nil || object.DeleteMarker {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Object not found")
+	}
+	if object.UserID != getUserID(c) && !h.isAdmin(c) {
+		return nil, fiber.NewError(fiber.StatusForbidden, "Forbidden")
+	}
+	return object, nil
+}
+
+type ACLReq struct {
+	ACL string `json:"acl"` // public-read/private
+}
+
+type ACLResp struct {
+	ACL    string              `json:"acl"`
+	URL    string              `json:"url,omitempty"`
+	Grants []model.ObjectGrant `json:"grants"`
+}
+
+func (h *Handlers) aclResp(object *model.OssObject) (*ACLResp, error) {
+	grants, err := repo.ListGrants(h.db, object.ID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to list grants")
+	}
+	acl := object.ACL
+	if acl == "" {
+		acl = model.ACLPublicRead
+	}
+	return &ACLResp{ACL: acl, URL: object.URL, Grants: grants}, nil
+}
+
+// GetACL 查询对象的访问控制与授权列表
+func (h *Handlers) GetACL(c *fiber.Ctx) error {
+	object, err := h.aclTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	resp, err := h.aclResp(object)
+	if err != nil {
+		return sendError(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// PutACL 修改对象的访问控制，公开访问地址随之更新
+func (h *Handlers) PutACL(c *fiber.Ctx) error {
+	object, err := h.aclTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	var req ACLReq
+	if err := c.BodyParser(&req); err != nil || req.ACL == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	acl, ok := validACL(req.ACL)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid acl",
+		})
+	}
+	url := objectURL(acl, object.FileName)
+	if object.Encryption != "" {
+		url = ""
+	}
+	if err := repo.UpdateObjectACL(h.db, object.ID, acl, url); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update acl",
+		})
+	}
+	object.ACL, object.URL = acl, url
+	resp, err := h.aclResp(object)
+	if err != nil {
+		return sendError(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// grantUser 解析路径参数 :user
+func grantUser(c *fiber.Ctx) (uint, error) {
+	userID, err := strconv.ParseUint(c.Params("user"), 10, 64)
+	if err != nil || userID == 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Invalid user id")
+	}
+	return uint(userID), nil
+}
+
+// PutGrant 授权用户读取 private 对象
+func (h *Handlers) PutGrant(c *fiber.Ctx) error {
+	object, err := h.aclTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	userID, err := grantUser(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	grant := model.ObjectGrant{ObjectID: object.ID, UserID: userID, GrantedBy: getUserID(c)}
+	if err := repo.CreateGrant(h.db, &grant); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create grant",
+		})
+	}
+	resp, err := h.aclResp(object)
+	if err != nil {
+		return sendError(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// DeleteGrant 撤销用户的授权
+func (h *Handlers) DeleteGrant(c *fiber.Ctx) error {
+	object, err := h.aclTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	userID, err := grantUser(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	if err := repo.DeleteGrant(h.db, object.ID, userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete grant",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,183 @@
+package handlers_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/handlers"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+)
+
+func grantPath(id uint, user uint) string {
+	return objectPath(id, "/grants/"+strconv.Itoa(int(user)))
+}
+
+// createShare 以所有者身份创建分享链接
+func createShare(t *testing.T, app *fiber.App, id uint, req handlers.ShareReq) handlers.ShareResp {
+	t.Helper()
+	resp := do(t, app, asOwner, fiber.MethodPost, objectPath(id, "/shares"), req)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("create share: status %d: %s", resp.StatusCode, readBody(t, resp))
+	}
+	var share handlers.ShareResp
+	decode(t, resp, &share)
+	return share
+}
+
+func TestObjectACL(t *testing.T) {
+	app, db := newTestApp(t, nil)
+	object := createObject(t, db, model.OssObject{UserID: owner, URL: "/files/file.txt"}, "secret")
+	download := objectPath(object.ID, "/download")
+
+	if resp := do(t, app, asOther, fiber.MethodPut, objectPath(object.ID, "/acl"), handlers.ACLReq{ACL: model.ACLPrivate}); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("put acl by another user: status %d, want 403", resp.StatusCode)
+	}
+	if resp := do(t, app, asOwner, fiber.MethodPut, objectPath(object.ID, "/acl"), handlers.ACLReq{ACL: "public-write"}); resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("put invalid acl: status %d, want 400", resp.StatusCode)
+	}
+	var acl handlers.ACLResp
+	decode(t, do(t, app, asOwner, fiber.MethodPut, objectPath(object.ID, "/acl"), handlers.ACLReq{ACL: model.ACLPrivate}), &acl)
+	if acl.ACL != model.ACLPrivate || acl.URL != "" {
+		t.Errorf("put acl = %+v, want private without a public url", acl)
+	}
+
+	tests := []struct {
+		name   string
+		who    caller
+		setup  func()
+		status int
+	}{
+		{"anonymous", asAnonymous, nil, fiber.StatusForbidden},
+		{"another user", asOther, nil, fiber.StatusForbidden},
+		{"owner", asOwner, nil, fiber.StatusOK},
+		{"admin", asAdmin, nil, fiber.StatusOK},
+		{"granted user", asOther, func() {
+			do(t, app, asOwner, fiber.MethodPut, grantPath(object.ID, other), nil)
+		}, fiber.StatusOK},
+		{"revoked grant", asOther, func() {
+			do(t, app, asOwner, fiber.MethodDelete, grantPath(object.ID, other), nil)
+		}, fiber.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if tt.setup != nil {
+			tt.setup()
+		}
+		if resp := do(t, app, tt.who, fiber.MethodGet, download, nil); resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+
+	// private 对象不出现在他人的列表中
+	var list handlers.ListObjectsResp
+	decode(t, do(t, app, asOther, fiber.MethodGet, "/api/oss/objects", nil), &list)
+	if len(list.Objects) != 0 {
+		t.Errorf("list by another user: %d objects, want 0", len(list.Objects))
+	}
+}
+
+func TestEncryptedObjectACL(t *testing.T) {
+	app, db := newTestApp(t, withKeys(t))
+	video := createObject(t, db, model.OssObject{FileName: "video.mp4", UserID: owner, Encryption: model.EncryptionAES128}, "plaintext video")
+
+	// 加密视频改为 public-read 后仍没有公开地址
+	var acl handlers.ACLResp
+	decode(t, do(t, app, asOwner, fiber.MethodPut, objectPath(video.ID, "/acl"), handlers.ACLReq{ACL: model.ACLPublicRead}), &acl)
+	if acl.URL != "" {
+		t.Errorf("encrypted object url = %q, want none", acl.URL)
+	}
+	// 分享链接同样不能绕过 HLS 加密
+	share := createShare(t, app, video.ID, handlers.ShareReq{})
+	if resp := do(t, app, asAnonymous, fiber.MethodGet, share.URL, nil); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("download encrypted object via share: status %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestShare(t *testing.T) {
+	app, db := newTestApp(t, nil)
+	object := createObject(t, db, model.OssObject{UserID: owner, ACL: model.ACLPrivate}, "shared content")
+
+	if resp := do(t, app, asOther, fiber.MethodPost, objectPath(object.ID, "/shares"), handlers.ShareReq{}); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("share by another user: status %d, want 403", resp.StatusCode)
+	}
+	past := time.Now().Add(-time.Hour)
+	for _, req := range []handlers.ShareReq{
+		{ExpiresIn: -1},
+		{MaxDownloads: -1},
+		{ExpiresAt: &past},
+		{ExpiresIn: 60, ExpiresAt: &past},
+	} {
+		if resp := do(t, app, asOwner, fiber.MethodPost, objectPath(object.ID, "/shares"), req); resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("share %+v: status %d, want 400", req, resp.StatusCode)
+		}
+	}
+
+	open := createShare(t, app, object.ID, handlers.ShareReq{})
+	limited := createShare(t, app, object.ID, handlers.ShareReq{MaxDownloads: 2})
+	protected := createShare(t, app, object.ID, handlers.ShareReq{Password: "s3cret"})
+	expired := createShare(t, app, object.ID, handlers.ShareReq{ExpiresIn: 60})
+	if err := db.Model(&model.ObjectShare{}).Where("id = ?", expired.ID).Update("expires_at", past).Error; err != nil {
+		t.Fatal(err)
+	}
+	revoked := createShare(t, app, object.ID, handlers.ShareReq{})
+	if resp := do(t, app, asOther, fiber.MethodDelete, objectPath(object.ID, "/shares/"+strconv.Itoa(int(revoked.ID))), nil); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("revoke by another user: status %d, want 403", resp.StatusCode)
+	}
+	do(t, app, asOwner, fiber.MethodDelete, objectPath(object.ID, "/shares/"+strconv.Itoa(int(revoked.ID))), nil)
+
+	tests := []struct {
+		name   string
+		url    string
+		header []string
+		status int
+	}{
+		{"open", open.URL, nil, fiber.StatusOK},
+		{"open again", open.URL, nil, fiber.StatusOK},
+		{"limited 1", limited.URL, nil, fiber.StatusOK},
+		// 断点续传不计入下载次数
+		{"limited resumed", limited.URL, []string{fiber.HeaderRange, "bytes=7-"}, fiber.StatusPartialContent},
+		{"limited 2", limited.URL, nil, fiber.StatusOK},
+		{"limited exhausted", limited.URL, nil, fiber.StatusGone},
+		{"limited restarted range", limited.URL, []string{fiber.HeaderRange, "bytes=0-"}, fiber.StatusGone},
+		{"no password", protected.URL, nil, fiber.StatusUnauthorized},
+		{"wrong password", protected.URL, []string{handlers.SharePasswordHeader, "guess"}, fiber.StatusUnauthorized},
+		{"password", protected.URL, []string{handlers.SharePasswordHeader, "s3cret"}, fiber.StatusOK},
+		{"password query", protected.URL + "?password=s3cret", nil, fiber.StatusOK},
+		{"expired", expired.URL, nil, fiber.StatusGone},
+		{"revoked", revoked.URL, nil, fiber.StatusGone},
+		{"unknown token", "/api/oss/shares/unknown/download", nil, fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp := do(t, app, asAnonymous, fiber.MethodGet, tt.url, nil, tt.header...)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.status == fiber.StatusOK {
+			if body := readBody(t, resp); body != "shared content" {
+				t.Errorf("%s: body %q", tt.name, body)
+			}
+		}
+	}
+
+	// 查询分享信息不计入下载次数
+	once := createShare(t, app, object.ID, handlers.ShareReq{MaxDownloads: 1})
+	for range 2 {
+		var info handlers.ShareInfoResp
+		decode(t, do(t, app, asAnonymous, fiber.MethodGet, "/api/oss/shares/"+once.Token, nil), &info)
+		if info.DownloadsLeft == nil || *info.DownloadsLeft != 1 || info.FileSize != object.FileSize {
+			t.Errorf("share info = %+v, want one download left", info)
+		}
+	}
+	if resp := do(t, app, asAnonymous, fiber.MethodGet, once.URL, nil); resp.StatusCode != fiber.StatusOK {
+		t.Errorf("download after share info: status %d, want 200", resp.StatusCode)
+	}
+	// 对象删除后链接失效
+	if err := db.Delete(&model.OssObject{}, object.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if resp := do(t, app, asAnonymous, fiber.MethodGet, open.URL, nil); resp.StatusCode != fiber.StatusGone {
+		t.Errorf("share of a deleted object: status %d, want 410", resp.StatusCode)
+	}
+}
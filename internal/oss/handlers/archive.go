@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/archive"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+)
+
+// Archive 把当前用户在存储桶中的多个对象打包为 ZIP64 归档边生成边下载，不写临时文件
+// ?key= 指定对象键，可重复；未指定时按 ?prefix= 打包，prefix 为空时打包整个存储桶
+// 条目按对象键排序且不压缩，归档布局确定，支持 Range 与 If-Range 断点续传
+func (h *Handlers) Archive(c *fiber.Ctx) error {
+	bucket := c.Params("bucket")
+	var keys []string
+	seen := make(map[string]bool)
+	for _, raw := range c.Context().QueryArgs().PeekMulti("key") {
+		if key := string(raw); key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > h.cfg.ArchiveMaxEntries {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("At most %d objects can be archived", h.cfg.ArchiveMaxEntries),
+		})
+	}
+
+	objects, err := repo.ListCurrentObjects(h.db, bucket, getUserID(c), c.Query("prefix"), keys, h.cfg.ArchiveMaxEntries+1)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list objects",
+		})
+	}
+	if len(objects) > h.cfg.ArchiveMaxEntries {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("At most %d objects can be archived", h.cfg.ArchiveMaxEntries),
+		})
+	}
+	if len(keys) > 0 && len(objects) < len(keys) {
+		found := make(map[string]bool, len(objects))
+		for _, object := range objects {
+			found[object.ObjectKey] = true
+		}
+		for _, key := range keys {
+			if !found[key] {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": fmt.Sprintf("Object not found: %s", key),
+				})
+			}
+		}
+	}
+	if len(objects) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "No objects to archive",
+		})
+	}
+
+	var total int64
+	entries := make([]archive.Entry, 0, len(objects))
+	// ETag 由条目的名称、版本与内容决定，任一对象变化后续传请求会收到完整的新归档
+	hash := md5.New()
+	for i := range objects {
+		object := &objects[i]
+		total += object.FileSize
+		name := archive.SafeName(object.ObjectKey)
+		entries = append(entries, archive.Entry{
+			Name:     name,
+			Size:     object.FileSize,
+			Modified: object.CreatedAt,
+			Open: func(ctx context.Context) (io.ReadCloser, error) {
+				return h.openObject(ctx, object)
+			},
+		})
+		fmt.Fprintf(hash, "%s\x00%d\x00%s\x00%d\x00%d\n", name, object.ID, object.ETag, object.FileSize, object.CreatedAt.Unix())
+	}
+	if total > h.cfg.ArchiveMaxBytes {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive exceeds %d bytes", h.cfg.ArchiveMaxBytes),
+		})
+	}
+	z, err := archive.NewZip(entries)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create archive",
+		})
+	}
+
+	size := z.Size()
+	etag := fmt.Sprintf(`"zip-%x"`, hash.Sum(nil))
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.zip"`, archiveName(bucket)))
+
+	status := fiber.StatusOK
+	start, end := int64(0), size
+	ifRange := c.Get(fiber.HeaderIfRange)
+	if c.Get(fiber.HeaderRange) != "" && (ifRange == "" || ifRange == etag) {
+		r, err := c.Range(int(size))
+		if err != nil || r.Type != "bytes" {
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", size))
+			return c.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+		}
+		// 多段 Range 只返回第一段
+		start, end = int64(r.Ranges[0].Start), int64(r.Ranges[0].End)+1
+		status = fiber.StatusPartialContent
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end-1, size))
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		// 客户端断开时 fasthttp 关闭 pr，写入随之失败，goroutine 退出
+		err := z.WriteRange(context.Background(), pw, start, end)
+		if err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			log.Printf("Failed to write archive of bucket %s: %v\n", bucket, err)
+		}
+		pw.CloseWithError(err)
+	}()
+	c.Status(status)
+	c.Response().SetBodyStream(pr, int(end-start))
+	return nil
+}
+
+// archiveName 生成下载文件名，去掉文件名中不安全的字符
+func archiveName(bucket string) string {
+	name := []rune(bucket)
+	for i, r := range name {
+		if r == '"' || r == '\\' || r == '/' || r < 0x20 {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 {
+		return model.DefaultBucket
+	}
+	return string(name)
+}
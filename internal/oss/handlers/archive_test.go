@@ -0,0 +1,158 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/handlers"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"gorm.io/gorm"
+)
+
+func archivePath(query url.Values) string {
+	return "/api/oss/buckets/" + model.DefaultBucket + "/archive?" + query.Encode()
+}
+
+// readZip 解析归档，返回条目名与内容
+func readZip(t *testing.T, data string) ([]string, map[string]string) {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader([]byte(data)), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	contents := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		names = append(names, f.Name)
+		contents[f.Name] = string(content)
+	}
+	return names, contents
+}
+
+func TestArchive(t *testing.T) {
+	app, db := newTestApp(t, nil)
+	photo := createObject(t, db, model.OssObject{FileName: "b.jpg", ObjectKey: "docs/b.jpg", UserID: owner}, "photo")
+	createObject(t, db, model.OssObject{FileName: "a.txt", ObjectKey: "docs/a.txt", UserID: owner}, "alpha")
+	createObject(t, db, model.OssObject{FileName: "c.txt", ObjectKey: "logs/c.txt", UserID: owner}, "gamma")
+	createObject(t, db, model.OssObject{FileName: "d.txt", ObjectKey: "docs/d.txt", UserID: other}, "other")
+	// 衍生对象不进入归档
+	createObject(t, db, model.OssObject{FileName: "b_original.jpg", ObjectKey: "docs/b_original.jpg", UserID: owner, ParentID: &photo.ID, Variant: handlers.VariantOriginal}, "with gps")
+
+	bodies := map[string]string{"docs/a.txt": "alpha", "docs/b.jpg": "photo", "logs/c.txt": "gamma", "docs/d.txt": "other"}
+	tests := []struct {
+		name    string
+		who     caller
+		query   url.Values
+		status  int
+		entries []string
+	}{
+		{"prefix", asOwner, url.Values{"prefix": {"docs/"}}, fiber.StatusOK, []string{"docs/a.txt", "docs/b.jpg"}},
+		{"whole bucket", asOwner, nil, fiber.StatusOK, []string{"docs/a.txt", "docs/b.jpg", "logs/c.txt"}},
+		{"keys", asOwner, url.Values{"key": {"logs/c.txt", "docs/a.txt", "docs/a.txt"}}, fiber.StatusOK, []string{"docs/a.txt", "logs/c.txt"}},
+		{"missing key", asOwner, url.Values{"key": {"docs/a.txt", "docs/x.txt"}}, fiber.StatusNotFound, nil},
+		// 只打包请求方自己的对象
+		{"another user's key", asOther, url.Values{"key": {"docs/a.txt"}}, fiber.StatusNotFound, nil},
+		{"another user", asOther, nil, fiber.StatusOK, []string{"docs/d.txt"}},
+		{"anonymous", asAnonymous, nil, fiber.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		resp := do(t, app, tt.who, fiber.MethodGet, archivePath(tt.query), nil)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.status != fiber.StatusOK {
+			continue
+		}
+		names, contents := readZip(t, readBody(t, resp))
+		if !slices.Equal(names, tt.entries) {
+			t.Errorf("%s: entries %v, want %v", tt.name, names, tt.entries)
+		}
+		for _, name := range names {
+			if want := bodies[name]; contents[name] != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, name, contents[name], want)
+			}
+		}
+	}
+}
+
+func TestArchiveLimits(t *testing.T) {
+	app, db := newTestApp(t, func(db *gorm.DB, cfg *handlers.Config) {
+		cfg.ArchiveMaxEntries = 2
+		cfg.ArchiveMaxBytes = 8
+	})
+	createObject(t, db, model.OssObject{FileName: "a.txt", ObjectKey: "a.txt", UserID: owner}, "aaaa")
+	createObject(t, db, model.OssObject{FileName: "b.txt", ObjectKey: "b.txt", UserID: owner}, "bbbbb")
+	createObject(t, db, model.OssObject{FileName: "c.txt", ObjectKey: "c.txt", UserID: owner}, "c")
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		status int
+	}{
+		{"too many keys", url.Values{"key": {"a.txt", "b.txt", "c.txt"}}, fiber.StatusRequestEntityTooLarge},
+		{"too many objects", nil, fiber.StatusRequestEntityTooLarge},
+		{"too many bytes", url.Values{"key": {"a.txt", "b.txt"}}, fiber.StatusRequestEntityTooLarge},
+		{"within limits", url.Values{"key": {"a.txt", "c.txt"}}, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		if resp := do(t, app, asOwner, fiber.MethodGet, archivePath(tt.query), nil); resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestArchiveRange(t *testing.T) {
+	app, db := newTestApp(t, nil)
+	createObject(t, db, model.OssObject{FileName: "a.txt", ObjectKey: "a.txt", UserID: owner}, "alpha")
+	createObject(t, db, model.OssObject{FileName: "b.txt", ObjectKey: "b.txt", UserID: owner}, "bravo")
+
+	full := do(t, app, asOwner, fiber.MethodGet, archivePath(nil), nil)
+	etag := full.Header.Get(fiber.HeaderETag)
+	body := readBody(t, full)
+	// 布局确定，重复请求得到相同的归档
+	if again := readBody(t, do(t, app, asOwner, fiber.MethodGet, archivePath(nil), nil)); again != body {
+		t.Fatal("archive is not deterministic")
+	}
+
+	tests := []struct {
+		name   string
+		header []string
+		status int
+		want   string
+	}{
+		{"range", []string{fiber.HeaderRange, "bytes=10-99"}, fiber.StatusPartialContent, body[10:100]},
+		{"suffix", []string{fiber.HeaderRange, "bytes=-22"}, fiber.StatusPartialContent, body[len(body)-22:]},
+		{"if-range match", []string{fiber.HeaderRange, "bytes=10-", fiber.HeaderIfRange, etag}, fiber.StatusPartialContent, body[10:]},
+		// ETag 变化后返回完整的新归档
+		{"if-range mismatch", []string{fiber.HeaderRange, "bytes=10-", fiber.HeaderIfRange, `"zip-stale"`}, fiber.StatusOK, body},
+		{"unsatisfiable", []string{fiber.HeaderRange, "bytes=100000-"}, fiber.StatusRequestedRangeNotSatisfiable, ""},
+	}
+	for _, tt := range tests {
+		resp := do(t, app, asOwner, fiber.MethodGet, archivePath(nil), nil, tt.header...)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.want == "" {
+			continue
+		}
+		if got := readBody(t, resp); got != tt.want {
+			t.Errorf("%s: body of %d bytes differs from the archive", tt.name, len(got))
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"path"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/trash"
+	"gorm.io/gorm"
+)
+
+// versioned 返回存储桶是否开启了版本控制
+func (h *Handlers) versioned(bucket string) bool {
+	b, err := repo.GetBucket(h.db, bucket)
+	return err == nil && b.Versioning == model.VersioningEnabled
+}
+
+var errKeyOwned = fiber.NewError(fiber.StatusForbidden, "Object key belongs to another user")
+
+// checkKeyOwner 对象键的当前版本属于其他用户时拒绝写入，管理员不受限制
+// 覆盖写入会把他人的对象移入回收站，开启版本控制时会替换他人对象键的当前版本
+func (h *Handlers) checkKeyOwner(c *fiber.Ctx, bucket, key string, userID uint) error {
+	if h.isAdmin(c) {
+		return nil
+	}
+	return h.checkKeyWriter(bucket, key, userID)
+}
+
+// checkKeyWriter 对象键的当前版本不属于 userID 时拒绝写入，删除标记不属于任何人
+func (h *Handlers) checkKeyWriter(bucket, key string, userID uint) error {
+	if key == "" {
+		return nil
+	}
+	current, err := repo.GetCurrentVersion(h.db, bucket, key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check object owner")
+	}
+	if !current.DeleteMarker && current.UserID != userID {
+		return errKeyOwned
+	}
+	return nil
+}
+
+// commitVersion 把新写入的版本设为当前版本；存储桶未开启版本控制时，被取代的版本移入回收站
+func (h *Handlers) commitVersion(object *model.OssObject) {
+	superseded, err := repo.SetCurrentVersion(h.db, object, time.Now())
+	if err != nil {
+		log.Printf("Failed to set current version of %s/%s: %v\n", object.Bucket, object.ObjectKey, err)
+		return
+	}
+	if h.versioned(object.Bucket) || h.cfg.Trash == nil {
+		return
+	}
+	for _, previous := range superseded {
+		if previous.DeleteMarker {
+			continue
+		}
+		if err := h.cfg.Trash.Delete(&previous); err != nil {
+			log.Printf("Failed to move overwritten object %d to trash: %v\n", previous.ID, err)
+		}
+	}
+}
+
+// GetBucket 查询存储桶配置，没有记录的存储桶返回未开启版本控制
+func (h *Handlers) GetBucket(c *fiber.Ctx) error {
+	name := c.Params("bucket")
+	bucket, err := repo.GetBucket(h.db, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(model.Bucket{Name: name})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get bucket",
+		})
+	}
+	return c.JSON(bucket)
+}
+
+// PutVersioningReq 设置版本控制的请求
+type PutVersioningReq struct {
+	Status string `json:"status"` // enabled/suspended
+}
+
+// PutVersioning 开启或暂停存储桶的版本控制，开启后不能再关闭，只能暂停；需要管理员令牌
+func (h *Handlers) PutVersioning(c *fiber.Ctx) error {
+	var req PutVersioningReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if req.Status != model.VersioningEnabled && req.Status != model.VersioningSuspended {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Status must be enabled or suspended",
+		})
+	}
+	bucket, err := repo.SaveBucketVersioning(h.db, c.Params("bucket"), req.Status)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update bucket",
+		})
+	}
+	return c.JSON(bucket)
+}
+
+// VersionItem 是版本列表中的一项
+type VersionItem struct {
+	model.OssObject
+	IsLatest bool `json:"is_latest"`
+}
+
+// VersionsResp 版本列表的响应
+type VersionsResp struct {
+	Versions   []VersionItem `json:"versions"`
+	NextBefore uint          `json:"next_before,omitempty"`
+}
+
+// ListVersions 按写入时间倒序列出存储桶中请求方可读的对象版本与删除标记
+// ?prefix= 按对象键前缀过滤；?before=&limit= 分页
+func (h *Handlers) ListVersions(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultListLimit)
+	before := c.QueryInt("before")
+	if limit <= 0 || limit > maxListLimit || before < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid pagination parameters",
+		})
+	}
+	objects, err := repo.ListVersions(h.db, c.Params("bucket"), c.Query("prefix"), uint(before), limit, h.readableScopes(c)...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list versions",
+		})
+	}
+	resp := VersionsResp{Versions: make([]VersionItem, 0, len(objects))}
+	for _, object := range objects {
+		resp.Versions = append(resp.Versions, VersionItem{OssObject: object, IsLatest: object.NoncurrentAt == nil})
+	}
+	if len(objects) == limit {
+		resp.NextBefore = objects[len(objects)-1].ID
+	}
+	return c.JSON(resp)
+}
+
+// keyedObject 按 ?key= 与 ?version_id= 读取对象，未指定版本时读取当前版本
+// 当前版本是删除标记时视为不存在；指定的版本是删除标记时返回 405
+func (h *Handlers) keyedObject(c *fiber.Ctx) (*model.OssObject, error) {
+	bucket, key, versionID := c.Params("bucket"), c.Query("key"), c.Query("version_id")
+	if key == "" {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "key is required")
+	}
+	if versionID == "" {
+		object, err := repo.GetCurrentVersion(h.db, bucket, key)
+		if err != nil || object.DeleteMarker {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Object not found")
+		}
+		return object, nil
+	}
+	object, err := repo.GetVersion(h.db, bucket, key, versionID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Version not found")
+	}
+	if object.DeleteMarker {
+		return nil, fiber.NewError(fiber.StatusMethodNotAllowed, "Version is a delete marker")
+	}
+	return object, nil
+}
+
+// GetKeyedObject 按对象键查询对象元数据（含衍生对象、自定义元数据与标签），?version_id= 指定版本
+func (h *Handlers) GetKeyedObject(c *fiber.Ctx) error {
+	object, err := h.keyedObject(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	if err := h.checkRead(c, object); err != nil {
+		return sendError(c, err)
+	}
+	object, err = repo.GetObjectWithVariants(h.db, object.ID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Object not found",
+		})
+	}
+	if err := h.loadAttributes(object); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get object metadata",
+		})
+	}
+	h.hideOriginal(c, object)
+	return c.JSON(object)
+}
+
+// DownloadKeyedObject 按对象键下载对象，?version_id= 指定版本，?variant= 指定衍生规格
+func (h *Handlers) DownloadKeyedObject(c *fiber.Ctx) error {
+	object, err := h.keyedObject(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	if err := h.checkRead(c, object); err != nil {
+		return sendError(c, err)
+	}
+	return h.sendObject(c, object)
+}
+
+// PromoteVersion 把历史版本设为当前版本，?key=&version_id= 必填，只有所有者与管理员可以操作
+func (h *Handlers) PromoteVersion(c *fiber.Ctx) error {
+	if c.Query("version_id") == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "version_id is required",
+		})
+	}
+	object, err := h.keyedObject(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	if !h.owns(c, object) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden",
+		})
+	}
+	if object.Status != model.StatusActive {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Object is not available",
+		})
+	}
+	if _, err := repo.SetCurrentVersion(h.db, object, time.Now()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to promote version",
+		})
+	}
+	log.Printf("Version %s of %s/%s promoted to current\n", object.VersionID, object.Bucket, object.ObjectKey)
+	return c.JSON(VersionItem{OssObject: *object, IsLatest: true})
+}
+
+// DeleteKeyedObject 按对象键删除对象
+// 未指定版本时：开启版本控制的存储桶写入删除标记，历史版本保留；否则当前版本移入回收站
+// 指定版本时：删除标记被物理删除，普通版本移入回收站；删除的是当前版本时，剩余最新的版本成为当前版本
+// 写入删除标记需要是当前版本的所有者，删除标记只能由写入者删除，管理员不受限制
+func (h *Handlers) DeleteKeyedObject(c *fiber.Ctx) error {
+	bucket, key, versionID := c.Params("bucket"), c.Query("key"), c.Query("version_id")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "key is required",
+		})
+	}
+
+	if versionID == "" && h.versioned(bucket) {
+		current, err := repo.GetCurrentVersion(h.db, bucket, key)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Object not found",
+			})
+		}
+		if current.DeleteMarker {
+			return c.JSON(VersionItem{OssObject: *current, IsLatest: true})
+		}
+		if !h.owns(c, current) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Forbidden",
+			})
+		}
+		marker := model.OssObject{
+			FileName:     path.Base(key),
+			Bucket:       bucket,
+			ObjectKey:    key,
+			VersionID:    uuid.New().String(),
+			DeleteMarker: true,
+			UserID:       getUserID(c),
+			Status:       model.StatusActive,
+		}
+		if err := repo.CreateObject(h.db, &marker); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create delete marker",
+			})
+		}
+		if _, err := repo.SetCurrentVersion(h.db, &marker, time.Now()); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create delete marker",
+			})
+		}
+		return c.JSON(VersionItem{OssObject: marker, IsLatest: true})
+	}
+
+	var object *model.OssObject
+	var err error
+	if versionID == "" {
+		object, err = repo.GetCurrentVersion(h.db, bucket, key)
+	} else {
+		object, err = repo.GetVersion(h.db, bucket, key, versionID)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Object not found",
+		})
+	}
+	if object.DeleteMarker {
+		if versionID == "" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Object not found",
+			})
+		}
+		if !h.owns(c, object) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Forbidden",
+			})
+		}
+		if err := repo.DeleteMarkerVersion(h.db, object.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to delete marker",
+			})
+		}
+		// 删除的是当前的删除标记时，剩余最新的版本成为当前版本
+		if object.NoncurrentAt == nil {
+			if err := repo.PromoteNewestVersion(h.db, bucket, key); err != nil {
+				log.Printf("Failed to promote newest version of %s/%s: %v\n", bucket, key, err)
+			}
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	if h.cfg.Trash == nil {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": "Deletion is disabled",
+		})
+	}
+	if object.UserID != getUserID(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden",
+		})
+	}
+	if err := h.cfg.Trash.Delete(object); err != nil {
+		if errors.Is(err, trash.ErrNotDeletable) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Object cannot be deleted",
+			})
+		}
+		if errors.Is(err, trash.ErrLocked) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Object is under legal hold or retention",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete object",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,204 @@
+package handlers_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/fetch"
+	"github.com/ormasia/swiftstream/internal/oss/handlers"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/trash"
+	"gorm.io/gorm"
+)
+
+func withTrash(db *gorm.DB, cfg *handlers.Config) {
+	cfg.Trash = trash.NewService(db, trash.DefaultConfig())
+}
+
+// keyedPath 返回按对象键访问的地址，versionID 为空时访问当前版本
+func keyedPath(action, key, versionID string) string {
+	q := url.Values{"key": {key}}
+	if versionID != "" {
+		q.Set("version_id", versionID)
+	}
+	return "/api/oss/buckets/" + model.DefaultBucket + "/object" + action + "?" + q.Encode()
+}
+
+func enableVersioning(t *testing.T, app *fiber.App) {
+	t.Helper()
+	resp := do(t, app, asAdmin, fiber.MethodPut, "/api/oss/buckets/"+model.DefaultBucket+"/versioning",
+		handlers.PutVersioningReq{Status: model.VersioningEnabled})
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("enable versioning: status %d", resp.StatusCode)
+	}
+}
+
+func mustUpload(t *testing.T, app *fiber.App, who caller, key, content string) {
+	t.Helper()
+	resp := upload(t, app, who, handlers.InitReq{FileName: "a.txt", ObjectKey: key}, content)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("upload %s: status %d: %s", key, resp.StatusCode, readBody(t, resp))
+	}
+}
+
+// listVersions 返回对象键的版本，最新写入的在前
+func listVersions(t *testing.T, app *fiber.App, key string) []handlers.VersionItem {
+	t.Helper()
+	var resp handlers.VersionsResp
+	decode(t, do(t, app, asAdmin, fiber.MethodGet, "/api/oss/buckets/"+model.DefaultBucket+"/versions?prefix="+url.QueryEscape(key), nil), &resp)
+	return resp.Versions
+}
+
+// expectBody 下载对象键的当前版本，want 为空时期望 404
+func expectBody(t *testing.T, app *fiber.App, key, versionID, want string) {
+	t.Helper()
+	resp := do(t, app, asOwner, fiber.MethodGet, keyedPath("/download", key, versionID), nil)
+	if want == "" {
+		if resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("download %s@%s: status %d, want 404", key, versionID, resp.StatusCode)
+		}
+		return
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("download %s@%s: status %d, want 200", key, versionID, resp.StatusCode)
+	} else if body := readBody(t, resp); body != want {
+		t.Errorf("download %s@%s: body %q, want %q", key, versionID, body, want)
+	}
+}
+
+func TestPutVersioningRequiresAdmin(t *testing.T) {
+	app, _ := newTestApp(t, nil)
+	for _, who := range []caller{asAnonymous, asOwner} {
+		resp := do(t, app, who, fiber.MethodPut, "/api/oss/buckets/"+model.DefaultBucket+"/versioning",
+			handlers.PutVersioningReq{Status: model.VersioningEnabled})
+		if resp.StatusCode != fiber.StatusForbidden {
+			t.Errorf("as %s: status %d, want 403", who, resp.StatusCode)
+		}
+	}
+	enableVersioning(t, app)
+}
+
+func TestVersioning(t *testing.T) {
+	app, _ := newTestApp(t, withTrash)
+	enableVersioning(t, app)
+	const key = "docs/a.txt"
+	mustUpload(t, app, asOwner, key, "one")
+	mustUpload(t, app, asOwner, key, "two")
+
+	versions := listVersions(t, app, key)
+	if len(versions) != 2 || !versions[0].IsLatest || versions[1].IsLatest {
+		t.Fatalf("versions = %+v, want 2 with the newest current", versions)
+	}
+	v1 := versions[1].VersionID
+	expectBody(t, app, key, "", "two")
+	expectBody(t, app, key, v1, "one")
+
+	// 他人不能写入、提升或删除所有者的对象键
+	if resp := upload(t, app, asOther, handlers.InitReq{FileName: "a.txt", ObjectKey: key}, "evil"); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("upload by another user: status %d, want 403", resp.StatusCode)
+	}
+	tests := []struct {
+		name   string
+		who    caller
+		method string
+		path   string
+		status int
+	}{
+		{"promote by another user", asOther, fiber.MethodPost, keyedPath("/promote", key, v1), fiber.StatusForbidden},
+		{"delete by another user", asOther, fiber.MethodDelete, keyedPath("", key, ""), fiber.StatusForbidden},
+		{"delete version by another user", asOther, fiber.MethodDelete, keyedPath("", key, v1), fiber.StatusForbidden},
+		{"promote without version", asOwner, fiber.MethodPost, keyedPath("/promote", key, ""), fiber.StatusBadRequest},
+		{"promote", asOwner, fiber.MethodPost, keyedPath("/promote", key, v1), fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		if resp := do(t, app, tt.who, tt.method, tt.path, nil); resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+	expectBody(t, app, key, "", "one")
+}
+
+func TestDeleteMarker(t *testing.T) {
+	app, _ := newTestApp(t, withTrash)
+	enableVersioning(t, app)
+	const key = "docs/a.txt"
+	mustUpload(t, app, asOwner, key, "one")
+	mustUpload(t, app, asOwner, key, "two")
+
+	resp := do(t, app, asOwner, fiber.MethodDelete, keyedPath("", key, ""), nil)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	var marker handlers.VersionItem
+	decode(t, resp, &marker)
+	if !marker.DeleteMarker || !marker.IsLatest {
+		t.Fatalf("delete returned %+v, want the current delete marker", marker)
+	}
+	// 删除标记成为当前版本，历史版本仍可按版本 ID 下载
+	versions := listVersions(t, app, key)
+	if len(versions) != 3 {
+		t.Fatalf("%d versions, want 3", len(versions))
+	}
+	expectBody(t, app, key, "", "")
+	expectBody(t, app, key, versions[1].VersionID, "two")
+	if resp := do(t, app, asOwner, fiber.MethodGet, keyedPath("/download", key, marker.VersionID), nil); resp.StatusCode != fiber.StatusMethodNotAllowed {
+		t.Errorf("download delete marker: status %d, want 405", resp.StatusCode)
+	}
+
+	// 删除标记不属于当前版本的所有者以外的用户；删除标记后上传的新版本不受限制
+	if resp := do(t, app, asOther, fiber.MethodDelete, keyedPath("", key, marker.VersionID), nil); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("delete marker by another user: status %d, want 403", resp.StatusCode)
+	}
+	if resp := do(t, app, asOwner, fiber.MethodDelete, keyedPath("", key, marker.VersionID), nil); resp.StatusCode != fiber.StatusNoContent {
+		t.Fatalf("delete marker: status %d, want 204", resp.StatusCode)
+	}
+	expectBody(t, app, key, "", "two")
+
+	do(t, app, asOwner, fiber.MethodDelete, keyedPath("", key, ""), nil)
+	mustUpload(t, app, asOther, key, "three")
+	if v := listVersions(t, app, key); len(v) != 4 || v[0].UserID != other {
+		t.Errorf("versions after writing over a delete marker = %+v", v)
+	}
+}
+
+func TestOverwriteUnversioned(t *testing.T) {
+	app, db := newTestApp(t, withTrash)
+	const key = "docs/a.txt"
+	mustUpload(t, app, asOwner, key, "one")
+	mustUpload(t, app, asOwner, key, "two")
+	expectBody(t, app, key, "", "two")
+
+	// 被覆盖的版本移入回收站
+	var deleted []model.OssObject
+	if err := db.Unscoped().Where("object_key = ? AND status = ?", key, model.StatusDeleted).Find(&deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 {
+		t.Errorf("%d overwritten versions in trash, want 1", len(deleted))
+	}
+
+	if resp := upload(t, app, asOther, handlers.InitReq{FileName: "a.txt", ObjectKey: key}, "evil"); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("overwrite by another user: status %d, want 403", resp.StatusCode)
+	}
+	mustUpload(t, app, asAdmin, key, "admin")
+	expectBody(t, app, key, "", "admin")
+}
+
+func TestFetchKeyOwner(t *testing.T) {
+	app, _ := newTestApp(t, func(db *gorm.DB, cfg *handlers.Config) {
+		cfg.Fetcher = fetch.New(fetch.DefaultConfig())
+	})
+	const key = "docs/a.txt"
+	mustUpload(t, app, asOwner, key, "one")
+
+	// 后台下载没有请求上下文，管理员同样不能通过下载覆盖他人的对象键
+	for _, who := range []caller{asOther, asAnonymous, asAdmin} {
+		resp := do(t, app, who, fiber.MethodPost, "/api/oss/upload/fetch",
+			handlers.FetchReq{URL: "http://example.com/a.txt", ObjectKey: key})
+		if resp.StatusCode != fiber.StatusForbidden {
+			t.Errorf("fetch as %s: status %d, want 403", who, resp.StatusCode)
+		}
+	}
+	expectBody(t, app, key, "", "one")
+}
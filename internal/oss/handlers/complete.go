@@ -21,6 +21,8 @@ type CompleteResp struct {
 	ObjectKey string `json:"objectKey"`
 	ETag      string `json:"etag"`
 	ObjectID  uint   `json:"objectId"`
+	// 对象状态，配置了扫描时为 pending_review，扫描通过后变为 active
+	ObjectStatus string `json:"objectStatus,omitempty"`
 }
 
 // Complete处理分片文件的合并，生成最终文件
@@ -76,8 +78,19 @@ func (h *Handlers) Complete(c *fiber.Ctx) error {
 		})
 	}
 
-	// 合并分片文件
+	// 上传期间对象键可能被他人写入或对象被锁定，合并前再检查一次
+	if err := h.checkKeyOwner(c, uploadTask.Bucket, uploadTask.ObjectKey, uploadTask.UserID); err != nil {
+		return sendError(c, err)
+	}
+	if err := h.checkOverwrite(uploadTask.Bucket, uploadTask.ObjectKey, uploadTask.FileName); err != nil {
+		return sendError(c, err)
+	}
+
+	// 合并分片文件；指定对象键的上传每个版本使用独立目录，覆盖写入不会破坏历史版本的文件
 	finalFilePath := filepath.Join("data", "files", uploadTask.FileName)
+	if uploadTask.ObjectKey != "" {
+		finalFilePath = filepath.Join("data", "files", "versions", uploadID, uploadTask.FileName)
+	}
 
 	// 确保目录存在
 	if err := os.MkdirAll(filepath.Dir(finalFilePath), 0755); err != nil {
@@ -118,33 +131,94 @@ func (h *Handlers) Complete(c *fiber.Ctx) error {
 		// 删除分片文件
 		os.Remove(chunk.FilePath)
 	}
+	if err := finalFile.Close(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to write final file",
+		})
+	}
 	// 计算文件的 ETag (MD5)
 	etag := fmt.Sprintf("%x", hash.Sum(nil))
 
+	ossObject, err := h.completeUpload(uploadTask, finalFilePath, etag)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(CompleteResp{
+		Status:       "completed",
+		FileURL:      ossObject.URL,
+		FileSize:     ossObject.FileSize,
+		FileName:     uploadTask.FileName,
+		ObjectKey:    ossObject.ObjectKey,
+		ETag:         ossObject.ETag,
+		ObjectID:     ossObject.ID,
+		ObjectStatus: ossObject.Status,
+	})
+}
+
+// completeUpload 由合并或下载完成的文件创建对象，更新上传任务并启动扫描或后续处理
+// etag 为 finalFilePath 的 MD5；返回的错误为 *fiber.Error
+func (h *Handlers) completeUpload(uploadTask *model.UploadTask, finalFilePath, etag string) (*model.OssObject, error) {
+	uploadID := uploadTask.UploadID
 	// 生成文件URL和对象键（使用uploadID确保唯一性）
 	objectKey := fmt.Sprintf("uploads/%s/%s_%s", time.Now().Format("2006/01/02"), uploadID, uploadTask.FileName)
-	fileURL := fmt.Sprintf("/files/%s", uploadTask.FileName)
+	acl, _ := validACL(uploadTask.ACL)
+	fileURL := objectURL(acl, uploadTask.FileName)
+	// 加密视频的明文只提供给所有者，不生成公开地址
+	if uploadTask.Encryption != "" {
+		fileURL = ""
+	}
+	bucket := uploadTask.Bucket
+	if bucket == "" {
+		bucket = model.DefaultBucket
+	}
+	// 指定对象键时以 uploadID 作为版本 ID
+	var versionID string
+	if uploadTask.ObjectKey != "" {
+		objectKey = uploadTask.ObjectKey
+		versionID = uploadID
+	}
 
 	// 创建 OssObject 记录
 	ossObject := model.OssObject{
-		FileName:   uploadTask.FileName,
-		FileSize:   uploadTask.FileSize,
-		FileType:   uploadTask.FileType,
-		MimeType:   uploadTask.FileType, // TODO: 需要根据文件扩展名确定正确的 MIME 类型
-		Bucket:     "default",           // TODO: 从配置中获取
-		ObjectKey:  objectKey,
-		ETag:       etag,
-		URL:        fileURL,
-		UserID:     uploadTask.UserID,
-		BusinessID: uploadTask.BusinessID,
-		Status:     "active",
+		FileName:    uploadTask.FileName,
+		FileSize:    uploadTask.FileSize,
+		FileType:    uploadTask.FileType,
+		MimeType:    uploadTask.FileType, // TODO: 需要根据文件扩展名确定正确的 MIME 类型
+		Bucket:      bucket,
+		ObjectKey:   objectKey,
+		VersionID:   versionID,
+		ETag:        etag,
+		StoragePath: finalFilePath,
+		URL:         fileURL,
+		Encryption:  uploadTask.Encryption,
+		ACL:         acl,
+		UserID:      uploadTask.UserID,
+		BusinessID:  uploadTask.BusinessID,
+		Status:      model.StatusActive,
+	}
+	// 配置了扫描时，扫描通过前对象不可下载
+	if h.cfg.Scanner != nil {
+		ossObject.Status = model.StatusPendingReview
 	}
+	// moov 在末尾的 MP4 改写为 faststart，ETag 与大小随之更新
+	faststarted := h.faststart(&ossObject, finalFilePath, uploadID)
+	// JPEG 照片纠正方向并清理 EXIF，对外提供清理后的版本
+	sanitized := h.sanitizeImage(&ossObject, finalFilePath, uploadID)
+	etag = ossObject.ETag
+	// 解析容器头部，填充宽高、时长与编码信息
+	h.applyMediaInfo(&ossObject, finalFilePath)
 
 	// 保存 OSS 对象记录
 	if err := repo.CreateObject(h.db, &ossObject); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create OSS object record",
-		})
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to create OSS object record")
+	}
+	if err := repo.CreateObjectMetadata(h.db, ossObject.ID, uploadTask.Metadata); err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to save object metadata")
+	}
+	// 新版本成为对象键的当前版本
+	if versionID != "" {
+		h.commitVersion(&ossObject)
 	}
 	// 更新上传任务状态
 	uploadTask.Status = "completed"
@@ -154,9 +228,7 @@ func (h *Handlers) Complete(c *fiber.Ctx) error {
 	uploadTask.Progress = 100
 
 	if err := repo.SaveUploadTask(h.db, uploadTask); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update upload task",
-		})
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to update upload task")
 	}
 
 	// 清理分片记录和临时目录
@@ -167,13 +239,14 @@ func (h *Handlers) Complete(c *fiber.Ctx) error {
 	uploadDir := filepath.Join("data", "uploads", uploadID)
 	os.RemoveAll(uploadDir)
 
-	return c.Status(fiber.StatusOK).JSON(CompleteResp{
-		Status:    "completed",
-		FileURL:   fileURL,
-		FileSize:  uploadTask.FileSize,
-		FileName:  uploadTask.FileName,
-		ObjectKey: objectKey,
-		ETag:      etag,
-		ObjectID:  ossObject.ID,
-	})
+	h.saveOriginal(&ossObject, faststarted)
+	h.saveOriginal(&ossObject, sanitized)
+	if h.cfg.Scanner != nil {
+		// 扫描通过后再生成衍生对象
+		go h.scanObject(ossObject)
+	} else {
+		h.process(ossObject)
+	}
+
+	return &ossObject, nil
 }
@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+)
+
+// 组合的源对象数上限
+const maxComposeSources = 32
+
+// 复制时元数据的处理方式
+const (
+	MetadataCopy    = "copy"    // 沿用源对象的元数据与标签
+	MetadataReplace = "replace" // 使用请求中的元数据，不带标签
+)
+
+// ObjectDest 复制、移动与组合的目标对象键
+type ObjectDest struct {
+	Bucket    string `json:"bucket"` // 默认 default
+	ObjectKey string `json:"object_key"`
+}
+
+// checkDest 校验目标对象键，拒绝覆盖他人或锁定的对象
+func (h *Handlers) checkDest(c *fiber.Ctx, dest *ObjectDest) error {
+	if dest.Bucket == "" {
+		dest.Bucket = model.DefaultBucket
+	}
+	if dest.ObjectKey == "" || len(dest.ObjectKey) > maxObjectKeyLength || strings.HasPrefix(dest.ObjectKey, "/") {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid object key")
+	}
+	if err := h.checkKeyOwner(c, dest.Bucket, dest.ObjectKey, getUserID(c)); err != nil {
+		return err
+	}
+	return h.checkOverwrite(dest.Bucket, dest.ObjectKey, "")
+}
+
+// sourceObject 读取可复制的源对象，只有请求方可读且 active 的源对象可以复制
+func (h *Handlers) sourceObject(c *fiber.Ctx, objectID uint) (*model.OssObject, error) {
+	object, err := repo.GetObject(h.db, objectID)
+	if err != nil || object.ParentID != nil || object.DeleteMarker {
+		return nil, fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("Object %d not found", objectID))
+	}
+	if err := h.checkRead(c, object); err != nil {
+		return nil, err
+	}
+	// 复制得到的对象属于请求方，加密视频的明文不能借此绕过 HLS 加密
+	if err := h.checkPlaintext(c, object); err != nil {
+		return nil, err
+	}
+	if object.Status != model.StatusActive {
+		return nil, fiber.NewError(fiber.StatusConflict, fmt.Sprintf("Object %d is not available", objectID))
+	}
+	return object, nil
+}
+
+// copyObject 在目标对象键下创建源对象的新版本
+// 本地文件直接共用，不复制内容，清理时按引用计数删除；冷存储中的文件复制回本地并校验 ETag
+func (h *Handlers) copyObject(ctx context.Context, source *model.OssObject, dest ObjectDest, userID uint) (*model.OssObject, error) {
+	object := *source
+	object.ID = 0
+	object.CreatedAt = time.Time{}
+	object.UpdatedAt = time.Time{}
+	object.CDNUrl = ""
+	object.Bucket = dest.Bucket
+	object.ObjectKey = dest.ObjectKey
+	object.VersionID = uuid.New().String()
+	object.NoncurrentAt = nil
+	object.StoragePath = source.FilePath()
+	object.StorageClass = ""
+	object.Variants = nil
+	object.LegalHold = false
+	object.RetainUntil = nil
+	object.UserID = userID
+	object.Status = model.StatusActive
+	object.Metadata = nil
+	object.Tags = nil
+
+	if source.StorageClass == model.StorageClassCold {
+		object.StoragePath = filepath.Join("data", "files", "versions", object.VersionID, filepath.Base(source.FileName))
+		if _, _, err := h.writeSources(ctx, object.StoragePath, []*model.OssObject{source}); err != nil {
+			return nil, err
+		}
+	}
+	if err := repo.CreateObject(h.db, &object); err != nil {
+		return nil, err
+	}
+	h.commitVersion(&object)
+	return &object, nil
+}
+
+// writeSources 把源对象的内容依次写入 path，逐个校验源对象的 ETag，返回写入内容的 ETag 与大小
+func (h *Handlers) writeSources(ctx context.Context, path string, sources []*model.OssObject) (string, int64, error) {
+	etag, size, err := storage.WriteFile(path, func(w io.Writer) error {
+		for _, source := range sources {
+			r, err := h.openObject(ctx, source)
+			if err != nil {
+				return err
+			}
+			hash := md5.New()
+			_, err = io.Copy(io.MultiWriter(w, hash), r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+			// 早期记录可能没有 ETag
+			if source.ETag != "" && fmt.Sprintf("%x", hash.Sum(nil)) != source.ETag {
+				return fmt.Errorf("%w: object %d", errChecksumMismatch, source.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		os.Remove(filepath.Dir(path))
+		return "", 0, err
+	}
+	return etag, size, nil
+}
+
+var errChecksumMismatch = errors.New("source checksum mismatch")
+
+// copyAttributes 复制源对象的元数据与标签
+func (h *Handlers) copyAttributes(source, object *model.OssObject) error {
+	if err := h.loadAttributes(source); err != nil {
+		return err
+	}
+	if err := repo.CreateObjectMetadata(h.db, object.ID, source.Metadata); err != nil {
+		return err
+	}
+	if len(source.Tags) > 0 {
+		if err := repo.ReplaceObjectTags(h.db, object.ID, source.Tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyError 把复制过程中的错误转换为响应
+func copyError(c *fiber.Ctx, err error) error {
+	var e *fiber.Error
+	switch {
+	case errors.As(err, &e):
+		return sendError(c, err)
+	case errors.Is(err, errChecksumMismatch):
+		log.Printf("Failed to copy object: %v\n", err)
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, errColdUnavailable):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Cold storage is not available",
+		})
+	}
+	log.Printf("Failed to copy object: %v\n", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to copy object",
+	})
+}
+
+// CopyReq 复制对象的请求
+type CopyReq struct {
+	ObjectDest
+	MetadataDirective string            `json:"metadata_directive"` // copy(默认)/replace
+	Metadata          map[string]string `json:"metadata"`           // replace 时使用，也可以通过 X-Oss-Meta-<key> 请求头指定
+}
+
+// CopyObject 在服务端把对象复制到新的对象键，衍生对象重新生成
+func (h *Handlers) CopyObject(c *fiber.Ctx) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	var req CopyReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if req.MetadataDirective == "" {
+		req.MetadataDirective = MetadataCopy
+	}
+	if req.MetadataDirective != MetadataCopy && req.MetadataDirective != MetadataReplace {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "metadata_directive must be copy or replace",
+		})
+	}
+	var metadata map[string]string
+	if req.MetadataDirective == MetadataReplace {
+		if metadata, err = parseMetadata(c, req.Metadata); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+	source, err := h.sourceObject(c, uint(objectID))
+	if err != nil {
+		return sendError(c, err)
+	}
+	if err := h.checkDest(c, &req.ObjectDest); err != nil {
+		return sendError(c, err)
+	}
+
+	object, err := h.copyObject(c.Context(), source, req.ObjectDest, getUserID(c))
+	if err != nil {
+		return copyError(c, err)
+	}
+	if req.MetadataDirective == MetadataCopy {
+		err = h.copyAttributes(source, object)
+	} else {
+		err = repo.CreateObjectMetadata(h.db, object.ID, metadata)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save object metadata",
+		})
+	}
+	log.Printf("Object %d copied to %s/%s as object %d\n", source.ID, object.Bucket, object.ObjectKey, object.ID)
+	h.process(*object)
+	return c.Status(fiber.StatusCreated).JSON(object)
+}
+
+// MoveObject 把对象移动到新的对象键：复制到目标后源对象移入回收站，元数据与标签随之移动
+func (h *Handlers) MoveObject(c *fiber.Ctx) error {
+	if h.cfg.Trash == nil {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": "Deletion is disabled",
+		})
+	}
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	var req ObjectDest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	source, err := h.sourceObject(c, uint(objectID))
+	if err != nil {
+		return sendError(c, err)
+	}
+	if source.UserID != getUserID(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden",
+		})
+	}
+	if source.Locked(time.Now()) {
+		return sendError(c, errObjectLocked)
+	}
+	if err := h.checkDest(c, &req); err != nil {
+		return sendError(c, err)
+	}
+	if req.Bucket == source.Bucket && req.ObjectKey == source.ObjectKey {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Destination is the same as the source",
+		})
+	}
+
+	object, err := h.copyObject(c.Context(), source, req, source.UserID)
+	if err != nil {
+		return copyError(c, err)
+	}
+	if err := h.copyAttributes(source, object); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save object metadata",
+		})
+	}
+	if err := h.cfg.Trash.Delete(source); err != nil {
+		log.Printf("Failed to move source object %d to trash after moving to object %d: %v\n", source.ID, object.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete source object",
+		})
+	}
+	log.Printf("Object %d moved to %s/%s as object %d\n", source.ID, object.Bucket, object.ObjectKey, object.ID)
+	h.process(*object)
+	return c.Status(fiber.StatusCreated).JSON(object)
+}
+
+// ComposeSource 组合的一个源对象，ETag 不为空时必须与源对象一致
+type ComposeSource struct {
+	ObjectID uint   `json:"object_id"`
+	ETag     string `json:"etag"`
+}
+
+// ComposeReq 组合对象的请求
+type ComposeReq struct {
+	ObjectDest
+	FileName string            `json:"file_name"` // 默认取对象键的最后一段
+	Sources  []ComposeSource   `json:"sources"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// ComposeObject 按顺序拼接多个源对象生成新对象
+// 拼接时重新计算每个源对象的 MD5 并与其 ETag 比对，内容不一致时放弃写入
+func (h *Handlers) ComposeObject(c *fiber.Ctx) error {
+	var req ComposeReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if len(req.Sources) == 0 || len(req.Sources) > maxComposeSources {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Between 1 and %d sources are required", maxComposeSources),
+		})
+	}
+	metadata, err := parseMetadata(c, req.Metadata)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := h.checkDest(c, &req.ObjectDest); err != nil {
+		return sendError(c, err)
+	}
+	if req.FileName == "" {
+		req.FileName = path.Base(req.ObjectKey)
+	}
+	if req.FileName != filepath.Base(req.FileName) || req.FileName == "." || req.FileName == ".." {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid file name",
+		})
+	}
+
+	acl := model.ACLPublicRead
+	sources := make([]*model.OssObject, 0, len(req.Sources))
+	for _, s := range req.Sources {
+		source, err := h.sourceObject(c, s.ObjectID)
+		if err != nil {
+			return sendError(c, err)
+		}
+		if s.ETag != "" && s.ETag != source.ETag {
+			return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+				"error": fmt.Sprintf("ETag of object %d does not match", s.ObjectID),
+			})
+		}
+		sources = append(sources, source)
+		// 任一源对象为 private 时拼接结果同样为 private
+		if source.ACL == model.ACLPrivate {
+			acl = model.ACLPrivate
+		}
+	}
+
+	versionID := uuid.New().String()
+	finalFilePath := filepath.Join("data", "files", "versions", versionID, req.FileName)
+	etag, size, err := h.writeSources(c.Context(), finalFilePath, sources)
+	if err != nil {
+		return copyError(c, err)
+	}
+	object := model.OssObject{
+		FileName:    req.FileName,
+		FileSize:    size,
+		FileType:    sources[0].FileType,
+		MimeType:    sources[0].MimeType,
+		Bucket:      req.Bucket,
+		ObjectKey:   req.ObjectKey,
+		VersionID:   versionID,
+		ETag:        etag,
+		StoragePath: finalFilePath,
+		URL:         objectURL(acl, req.FileName),
+		ACL:         acl,
+		UserID:      getUserID(c),
+		BusinessID:  sources[0].BusinessID,
+		Status:      model.StatusActive,
+	}
+	// 拼接后的内容是新文件，配置了扫描时同样需要扫描通过
+	if h.cfg.Scanner != nil {
+		object.Status = model.StatusPendingReview
+	}
+	h.applyMediaInfo(&object, finalFilePath)
+	if err := repo.CreateObject(h.db, &object); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create OSS object record",
+		})
+	}
+	if err := repo.CreateObjectMetadata(h.db, object.ID, metadata); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save object metadata",
+		})
+	}
+	h.commitVersion(&object)
+	log.Printf("Object %d composed from %d sources\n", object.ID, len(sources))
+	if h.cfg.Scanner != nil {
+		go h.scanObject(object)
+	} else {
+		h.process(object)
+	}
+	return c.Status(fiber.StatusCreated).JSON(object)
+}
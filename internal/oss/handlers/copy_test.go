@@ -0,0 +1,133 @@
+package handlers_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/handlers"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+)
+
+func TestCopyObject(t *testing.T) {
+	app, db := newTestApp(t, withTrash)
+	source := createObject(t, db, model.OssObject{FileName: "a.txt", UserID: owner}, "content")
+	createObject(t, db, model.OssObject{FileName: "taken.txt", ObjectKey: "others/taken.txt", UserID: other}, "taken")
+
+	tests := []struct {
+		name   string
+		who    caller
+		key    string
+		status int
+	}{
+		{"invalid key", asOther, "/abs", fiber.StatusBadRequest},
+		{"over another user's key", asOwner, "others/taken.txt", fiber.StatusForbidden},
+		{"by owner", asOwner, "copies/a.txt", fiber.StatusCreated},
+		// 可读的对象任何人都可以复制到自己的对象键
+		{"by another user", asOther, "others/a.txt", fiber.StatusCreated},
+	}
+	for _, tt := range tests {
+		resp := do(t, app, tt.who, fiber.MethodPost, objectPath(source.ID, "/copy"),
+			handlers.CopyReq{ObjectDest: handlers.ObjectDest{ObjectKey: tt.key}})
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.status != fiber.StatusCreated {
+			continue
+		}
+		var created model.OssObject
+		decode(t, resp, &created)
+		// 复制共用源对象的文件，对象属于请求方
+		object, err := repo.GetObject(db, created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if object.StoragePath != source.StoragePath || object.ETag != source.ETag || object.UserID != tt.who.user {
+			t.Errorf("%s: copy = %+v", tt.name, object)
+		}
+		expectBody(t, app, tt.key, "", "content")
+	}
+}
+
+func TestMoveObject(t *testing.T) {
+	app, db := newTestApp(t, withTrash)
+	source := createObject(t, db, model.OssObject{FileName: "a.txt", UserID: owner}, "content")
+
+	if resp := do(t, app, asOther, fiber.MethodPost, objectPath(source.ID, "/move"), handlers.ObjectDest{ObjectKey: "moved/a.txt"}); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("move by another user: status %d, want 403", resp.StatusCode)
+	}
+	if resp := do(t, app, asOwner, fiber.MethodPost, objectPath(source.ID, "/move"), handlers.ObjectDest{ObjectKey: source.ObjectKey}); resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("move onto itself: status %d, want 400", resp.StatusCode)
+	}
+	if resp := do(t, app, asOwner, fiber.MethodPost, objectPath(source.ID, "/move"), handlers.ObjectDest{ObjectKey: "moved/a.txt"}); resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("move: status %d", resp.StatusCode)
+	}
+	expectBody(t, app, "moved/a.txt", "", "content")
+	if _, err := repo.GetObject(db, source.ID); err == nil {
+		t.Error("source object is still available after the move")
+	}
+	// 源对象在回收站中，文件仍被新对象引用
+	if _, err := os.Stat(source.StoragePath); err != nil {
+		t.Errorf("shared file was removed: %v", err)
+	}
+}
+
+func TestComposeObject(t *testing.T) {
+	app, db := newTestApp(t, withTrash)
+	a := createObject(t, db, model.OssObject{FileName: "a.txt", UserID: owner}, "hello, ")
+	b := createObject(t, db, model.OssObject{FileName: "b.txt", UserID: owner}, "world")
+	corrupted := createObject(t, db, model.OssObject{FileName: "c.txt", UserID: owner}, "original")
+	if err := os.WriteFile(corrupted.StoragePath, []byte("modified"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	createObject(t, db, model.OssObject{FileName: "taken.txt", ObjectKey: "others/taken.txt", UserID: other}, "taken")
+
+	tests := []struct {
+		name    string
+		key     string
+		sources []handlers.ComposeSource
+		status  int
+	}{
+		{"no sources", "out.txt", nil, fiber.StatusBadRequest},
+		{"missing source", "out.txt", []handlers.ComposeSource{{ObjectID: 999}}, fiber.StatusNotFound},
+		{"etag mismatch", "out.txt", []handlers.ComposeSource{{ObjectID: a.ID, ETag: b.ETag}}, fiber.StatusPreconditionFailed},
+		{"checksum mismatch", "out.txt", []handlers.ComposeSource{{ObjectID: a.ID}, {ObjectID: corrupted.ID}}, fiber.StatusConflict},
+		{"over another user's key", "others/taken.txt", []handlers.ComposeSource{{ObjectID: a.ID}}, fiber.StatusForbidden},
+		{"compose", "out.txt", []handlers.ComposeSource{{ObjectID: a.ID, ETag: a.ETag}, {ObjectID: b.ID}}, fiber.StatusCreated},
+	}
+	for _, tt := range tests {
+		resp := do(t, app, asOwner, fiber.MethodPost, "/api/oss/objects/compose",
+			handlers.ComposeReq{ObjectDest: handlers.ObjectDest{ObjectKey: tt.key}, Sources: tt.sources})
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+	expectBody(t, app, "out.txt", "", "hello, world")
+}
+
+func TestCopyEncryptedObject(t *testing.T) {
+	app, db := newTestApp(t, withKeys(t))
+	video := createObject(t, db, model.OssObject{FileName: "video.mp4", UserID: owner, Encryption: model.EncryptionAES128}, "plaintext video")
+
+	tests := []struct {
+		who    caller
+		status int
+	}{
+		{asOther, fiber.StatusForbidden},
+		{asOwner, fiber.StatusCreated},
+	}
+	for _, tt := range tests {
+		resp := do(t, app, tt.who, fiber.MethodPost, objectPath(video.ID, "/copy"),
+			handlers.CopyReq{ObjectDest: handlers.ObjectDest{ObjectKey: tt.who.String() + "/video.mp4"}})
+		if resp.StatusCode != tt.status {
+			t.Errorf("copy as %s: status %d, want %d", tt.who, resp.StatusCode, tt.status)
+		}
+		resp = do(t, app, tt.who, fiber.MethodPost, "/api/oss/objects/compose",
+			handlers.ComposeReq{ObjectDest: handlers.ObjectDest{ObjectKey: tt.who.String() + "/composed.mp4"}, Sources: []handlers.ComposeSource{{ObjectID: video.ID}}})
+		if resp.StatusCode != tt.status {
+			t.Errorf("compose as %s: status %d, want %d", tt.who, resp.StatusCode, tt.status)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/imaging"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/probe"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"gorm.io/gorm"
+)
+
+// VariantCover 视频封面的衍生对象规格名，与封面图片共用同一个文件
+const VariantCover = "cover"
+
+// placeholder 计算图片的 BlurHash 与主色
+func placeholder(img image.Image) (string, string, []string) {
+	var palette []string
+	for _, c := range imaging.Palette(img, imaging.DefaultPaletteSize) {
+		palette = append(palette, imaging.Hex(c))
+	}
+	dominant := ""
+	if len(palette) > 0 {
+		dominant = palette[0]
+	}
+	return imaging.BlurHash(img), dominant, palette
+}
+
+// SetCoverReq 设置视频封面的请求
+type SetCoverReq struct {
+	ImageID uint `json:"image_id"` // 已上传的图片对象
+}
+
+// SetCover 使用已上传的图片作为视频封面，登记为 cover 衍生对象，并把图片的占位信息复制到视频
+// 只有视频的所有者与管理员可以设置
+func (h *Handlers) SetCover(c *fiber.Ctx) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	var req SetCoverReq
+	if err := c.BodyParser(&req); err != nil || req.ImageID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	video, err := repo.GetObject(h.db, uint(objectID))
+	if err != nil || video.ParentID != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Object not found",
+		})
+	}
+	if video.FileType != probe.KindVideo {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cover is only supported for videos",
+		})
+	}
+	if !h.owns(c, video) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden",
+		})
+	}
+	cover, err := repo.GetObject(h.db, req.ImageID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Image not found",
+		})
+	}
+	if err := h.checkRead(c, cover); err != nil {
+		return sendError(c, err)
+	}
+	if cover.Status != model.StatusActive {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Image is not available",
+		})
+	}
+	if cover.FileType != probe.KindImage {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cover must be an image",
+		})
+	}
+
+	img, _, err := imaging.Open(cover.FilePath(), h.cfg.MaxImagePixels)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to decode cover image",
+		})
+	}
+	// 图片的占位信息在上传完成后异步计算，尚未完成时在这里同步计算
+	if cover.BlurHash == "" {
+		cover.BlurHash, cover.DominantColor, cover.Palette = placeholder(img)
+		if err := repo.UpdateObjectPlaceholder(h.db, cover.ID, cover.BlurHash, cover.DominantColor, cover.Palette); err != nil {
+			log.Printf("Failed to save placeholder of object %d: %v\n", cover.ID, err)
+		}
+	}
+
+	parentID := video.ID
+	child := &model.OssObject{
+		ParentID:  &parentID,
+		Variant:   VariantCover,
+		Bucket:    video.Bucket,
+		ObjectKey: fmt.Sprintf("%s@%s", video.ObjectKey, VariantCover),
+		VersionID: video.VersionID,
+		URL:       fmt.Sprintf("/api/oss/objects/%d/download?variant=%s", video.ID, VariantCover),
+	}
+	if existing, err := repo.GetVariant(h.db, video.ID, VariantCover); err == nil {
+		child = existing
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get cover",
+		})
+	}
+	child.FileName = fmt.Sprintf("%s_%s%s", trimExt(video.FileName), VariantCover, filepath.Ext(cover.FileName))
+	child.FileSize = cover.FileSize
+	child.FileType = probe.KindImage
+	child.MimeType = cover.MimeType
+	child.ETag = cover.ETag
+	child.StoragePath = cover.FilePath()
+	child.Width = cover.Width
+	child.Height = cover.Height
+	child.BlurHash = cover.BlurHash
+	child.DominantColor = cover.DominantColor
+	child.Palette = cover.Palette
+	child.UserID = video.UserID
+	child.BusinessID = video.BusinessID
+	child.Status = "active"
+	if err := repo.SaveObject(h.db, child); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save cover",
+		})
+	}
+	if err := repo.UpdateObjectPlaceholder(h.db, video.ID, cover.BlurHash, cover.DominantColor, cover.Palette); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save cover",
+		})
+	}
+
+	// 封面的感知哈希记在视频上，近似查询可以找到使用相同画面的视频
+	h.indexImage(*video, img, model.HashSourceCover)
+
+	object, err := repo.GetObjectWithVariants(h.db, video.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get object",
+		})
+	}
+	return c.JSON(object)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"bytes"
+	"image/jpeg"
+	"io"
+	"log"
+	"os"
+
+	"github.com/ormasia/swiftstream/internal/oss/exif"
+	"github.com/ormasia/swiftstream/internal/oss/imaging"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/probe"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+)
+
+// sanitizeImage 清理 JPEG 的 EXIF：按方向标签纠正像素，按策略删除位置等敏感标签，并改写 filePath
+// 保留的相机与拍摄时间写入 object，返回 nil 表示无需改写；改写失败时保留原文件，不影响上传完成
+func (h *Handlers) sanitizeImage(object *model.OssObject, filePath, uploadID string) *rewriteResult {
+	if h.cfg.Exif == nil {
+		return nil
+	}
+	info, err := probe.File(filePath)
+	if err != nil || info.Format != "jpeg" {
+		return nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		log.Printf("Failed to read %s for exif: %v\n", filePath, err)
+		return nil
+	}
+	x, err := exif.FromJPEG(data)
+	if err != nil {
+		// EXIF 损坏时按没有 EXIF 处理，仍然删除其他元数据段
+		log.Printf("Failed to parse exif of %s: %v\n", filePath, err)
+	}
+
+	policy := *h.cfg.Exif
+	var kept *exif.Exif
+	orientation := 1
+	if x != nil {
+		kept = x.Sanitize(policy)
+		orientation = x.Orientation()
+		applyExif(object, kept)
+	}
+
+	src := data
+	// 纠正方向需要解码整张图片，像素数超过上限时只清理元数据，不纠正方向
+	if orientation != 1 && info.Width*info.Height <= h.cfg.MaxImagePixels {
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			log.Printf("Failed to decode %s for orientation: %v\n", filePath, err)
+			return nil
+		}
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, imaging.Orient(img, orientation), imaging.FormatJPEG, policy.Quality); err != nil {
+			log.Printf("Failed to encode %s for orientation: %v\n", filePath, err)
+			return nil
+		}
+		src = buf.Bytes()
+	}
+	cleaned, err := exif.RewriteJPEG(src, kept)
+	if err != nil {
+		log.Printf("Failed to strip metadata of %s: %v\n", filePath, err)
+		return nil
+	}
+	if bytes.Equal(cleaned, data) {
+		return nil
+	}
+
+	result := &rewriteResult{OriginalETag: object.ETag, OriginalSize: int64(len(data))}
+	if policy.KeepOriginal {
+		if result.OriginalPath, err = keepOriginal(filePath, uploadID); err != nil {
+			log.Printf("Failed to keep original of %s: %v\n", filePath, err)
+			return nil
+		}
+	}
+	etag, size, err := storage.WriteFile(filePath, func(w io.Writer) error {
+		_, err := w.Write(cleaned)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to write sanitized %s: %v\n", filePath, err)
+		if result.OriginalPath != "" {
+			os.Rename(result.OriginalPath, filePath)
+		}
+		return nil
+	}
+	object.ETag = etag
+	object.FileSize = size
+	return result
+}
+
+// applyExif 把清理后保留的拍摄信息写入对象
+func applyExif(object *model.OssObject, x *exif.Exif) {
+	if x == nil {
+		return
+	}
+	object.CameraMake = x.Make()
+	object.CameraModel = x.Model()
+	object.LensModel = x.LensModel()
+	if t, ok := x.CaptureTime(); ok {
+		object.CapturedAt = &t
+	}
+}
@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/handlers"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+)
+
+func TestOriginalVariantRestricted(t *testing.T) {
+	app, db := newTestApp(t, nil)
+	photo := createObject(t, db, model.OssObject{FileName: "photo.jpg", UserID: owner}, "sanitized")
+	original := createObject(t, db, model.OssObject{FileName: "photo_original.jpg", UserID: owner, ParentID: &photo.ID, Variant: handlers.VariantOriginal}, "with gps")
+	createObject(t, db, model.OssObject{FileName: "photo_thumb.jpg", UserID: owner, ParentID: &photo.ID, Variant: "thumb"}, "thumb")
+
+	byID := "/api/oss/objects/" + strconv.Itoa(int(original.ID)) + "/download"
+	byParam := "/api/oss/objects/" + strconv.Itoa(int(photo.ID)) + "/download?variant=" + handlers.VariantOriginal
+	tests := []struct {
+		path   string
+		who    caller
+		status int
+	}{
+		{byID, asAnonymous, fiber.StatusForbidden},
+		{byID, asOther, fiber.StatusForbidden},
+		{byID, asOwner, fiber.StatusOK},
+		{byID, asAdmin, fiber.StatusOK},
+		{byParam, asAnonymous, fiber.StatusForbidden},
+		{byParam, asOther, fiber.StatusForbidden},
+		{byParam, asOwner, fiber.StatusOK},
+		{byParam, asAdmin, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		resp := do(t, app, tt.who, fiber.MethodGet, tt.path, nil)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s as %s: status %d, want %d", tt.path, tt.who, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.status == fiber.StatusOK {
+			if body := readBody(t, resp); body != "with gps" {
+				t.Errorf("%s as %s: body %q", tt.path, tt.who, body)
+			}
+		}
+	}
+}
+
+func TestOriginalVariantHidden(t *testing.T) {
+	app, db := newTestApp(t, nil)
+	photo := createObject(t, db, model.OssObject{FileName: "photo.jpg", UserID: owner}, "sanitized")
+	createObject(t, db, model.OssObject{FileName: "photo_original.jpg", UserID: owner, ParentID: &photo.ID, Variant: handlers.VariantOriginal}, "with gps")
+	createObject(t, db, model.OssObject{FileName: "photo_thumb.jpg", UserID: owner, ParentID: &photo.ID, Variant: "thumb"}, "thumb")
+
+	tests := []struct {
+		who      caller
+		variants []string
+	}{
+		{asAnonymous, []string{"thumb"}},
+		{asOther, []string{"thumb"}},
+		{asOwner, []string{handlers.VariantOriginal, "thumb"}},
+		{asAdmin, []string{handlers.VariantOriginal, "thumb"}},
+	}
+	variantNames := func(object model.OssObject) []string {
+		var names []string
+		for _, v := range object.Variants {
+			names = append(names, v.Variant)
+		}
+		return names
+	}
+	for _, tt := range tests {
+		var object model.OssObject
+		decode(t, do(t, app, tt.who, fiber.MethodGet, "/api/oss/objects/"+strconv.Itoa(int(photo.ID)), nil), &object)
+		if got := variantNames(object); !slices.Equal(got, tt.variants) {
+			t.Errorf("get as %s: variants %v, want %v", tt.who, got, tt.variants)
+		}
+
+		var list handlers.ListObjectsResp
+		decode(t, do(t, app, tt.who, fiber.MethodGet, "/api/oss/objects", nil), &list)
+		if len(list.Objects) != 1 {
+			t.Errorf("list as %s: %d objects, want 1", tt.who, len(list.Objects))
+		} else if got := variantNames(list.Objects[0]); !slices.Equal(got, tt.variants) {
+			t.Errorf("list as %s: variants %v, want %v", tt.who, got, tt.variants)
+		}
+	}
+}
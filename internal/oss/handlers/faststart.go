@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/mp4"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+)
+
+// VariantOriginal 是 faststart、EXIF 清理等改写前原文件作为衍生对象时的规格名
+const VariantOriginal = "original"
+
+// rewriteResult 记录一次对上传文件的原地改写，原文件未保留时 OriginalPath 为空
+type rewriteResult struct {
+	OriginalPath string
+	OriginalETag string
+	OriginalSize int64
+}
+
+// faststart 检测 moov 位于末尾的 MP4 并改写 filePath，返回 nil 表示无需改写
+// 改写失败时保留原文件，不影响上传完成
+func (h *Handlers) faststart(object *model.OssObject, filePath, uploadID string) *rewriteResult {
+	if !h.cfg.Faststart {
+		return nil
+	}
+	src, err := os.Open(filePath)
+	if err != nil {
+		log.Printf("Failed to open %s for faststart: %v\n", filePath, err)
+		return nil
+	}
+	defer src.Close()
+	stat, err := src.Stat()
+	if err != nil || !mp4.NeedsFaststart(src, stat.Size()) {
+		return nil
+	}
+	layout, err := mp4.PlanFaststart(src, stat.Size())
+	if err != nil {
+		log.Printf("Failed to plan faststart for %s: %v\n", filePath, err)
+		return nil
+	}
+
+	result := &rewriteResult{OriginalETag: object.ETag, OriginalSize: stat.Size()}
+	if h.cfg.KeepOriginal {
+		// 已打开的 src 在重命名后仍可读取
+		if result.OriginalPath, err = keepOriginal(filePath, uploadID); err != nil {
+			log.Printf("Failed to keep original of %s: %v\n", filePath, err)
+			return nil
+		}
+	}
+
+	etag, size, err := storage.WriteFile(filePath, func(w io.Writer) error {
+		_, err := mp4.Faststart(w, src, layout)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to rewrite %s with faststart: %v\n", filePath, err)
+		if result.OriginalPath != "" {
+			os.Rename(result.OriginalPath, filePath)
+		}
+		return nil
+	}
+	object.ETag = etag
+	object.FileSize = size
+	return result
+}
+
+// keepOriginal 把改写前的原文件移到 originals 目录，返回新路径
+func keepOriginal(filePath, uploadID string) (string, error) {
+	originalPath := filepath.Join("data", "files", "originals", uploadID, filepath.Base(filePath))
+	if err := os.MkdirAll(filepath.Dir(originalPath), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(filePath, originalPath); err != nil {
+		return "", err
+	}
+	return originalPath, nil
+}
+
+// saveOriginal 把保留的原文件登记为对象的衍生对象
+func (h *Handlers) saveOriginal(parent *model.OssObject, result *rewriteResult) {
+	if result == nil || result.OriginalPath == "" {
+		return
+	}
+	parentID := parent.ID
+	original := model.OssObject{
+		FileName:    parent.FileName,
+		FileSize:    result.OriginalSize,
+		FileType:    parent.FileType,
+		MimeType:    parent.MimeType,
+		Bucket:      parent.Bucket,
+		ObjectKey:   fmt.Sprintf("%s@%s", parent.ObjectKey, VariantOriginal),
+		VersionID:   parent.VersionID,
+		ETag:        result.OriginalETag,
+		StoragePath: result.OriginalPath,
+		ParentID:    &parentID,
+		Variant:     VariantOriginal,
+		URL:         fmt.Sprintf("/api/oss/objects/%d/download?variant=%s", parent.ID, VariantOriginal),
+		Width:       parent.Width,
+		Height:      parent.Height,
+		Duration:    parent.Duration,
+		VideoCodec:  parent.VideoCodec,
+		AudioCodec:  parent.AudioCodec,
+		Bitrate:     parent.Bitrate,
+		FrameRate:   parent.FrameRate,
+		UserID:      parent.UserID,
+		BusinessID:  parent.BusinessID,
+		Status:      "active",
+	}
+	if err := repo.CreateObject(h.db, &original); err != nil {
+		log.Printf("Failed to create original variant of object %d: %v\n", parent.ID, err)
+	}
+}
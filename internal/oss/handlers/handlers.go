@@ -1,13 +1,132 @@
 package handlers
 
-import "gorm.io/gorm"
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/exif"
+	"github.com/ormasia/swiftstream/internal/oss/fetch"
+	"github.com/ormasia/swiftstream/internal/oss/imaging"
+	"github.com/ormasia/swiftstream/internal/oss/keystore"
+	"github.com/ormasia/swiftstream/internal/oss/lifecycle"
+	"github.com/ormasia/swiftstream/internal/oss/live"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/scan"
+	"github.com/ormasia/swiftstream/internal/oss/similar"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+	"github.com/ormasia/swiftstream/internal/oss/transcode"
+	"github.com/ormasia/swiftstream/internal/oss/trash"
+	"github.com/ormasia/swiftstream/internal/oss/waveform"
+	"gorm.io/gorm"
+)
+
+// Config OSS 服务的可配置项
+type Config struct {
+	ImageVariants  []imaging.Variant // 图片上传完成后生成的衍生图规格
+	MaxImagePixels int               // 解码图片的像素数上限，防止解压炸弹；超过时不生成衍生图、不纠正方向
+	Transcoder     *transcode.Pool   // 视频转码任务池，为 nil 时不转码
+	Faststart      bool              // 上传完成时把 MP4 末尾的 moov 移到文件头部，便于边下边播
+	KeepOriginal   bool              // faststart 改写后保留原文件，作为 original 衍生对象
+	Exif           *exif.Policy      // JPEG 照片的 EXIF 清理策略，为 nil 时不处理
+	// 音频波形峰值的各级分辨率（每像素采样数），为空时不生成
+	WaveformLevels []int
+
+	// HLS 内容密钥存储，为 nil 时不接受加密上传
+	Keys *keystore.Keystore
+	// 播放边缘读取内容密钥时携带的内部令牌，为空时不提供密钥
+	KeyServiceToken string
+
+	// 直播会话服务，为 nil 时不提供直播接口
+	Live *live.Service
+
+	// 上传完成后的扫描流水线，为 nil 时对象直接生效
+	Scanner *scan.Pipeline
+	// 审核接口的管理员令牌，为空时不开放审核接口
+	AdminToken string
+
+	// 图片与视频封面的感知哈希索引，为 nil 时不计算哈希
+	Similar *similar.Index
+	// 新上传图片与已有内容的 pHash 距离不超过 DuplicateDistance 时标记为重复上传
+	FlagDuplicates    bool
+	DuplicateDistance int
+
+	// 回收站服务，为 nil 时不提供删除接口
+	Trash *trash.Service
+
+	// 生命周期规则任务，为 nil 时只能配置规则，不能生成报告
+	Lifecycle *lifecycle.Service
+	// 冷存储，读取已转移到冷存储的对象
+	Cold storage.Driver
+
+	// 从 URL 下载创建对象，为 nil 时不接受 URL 上传
+	Fetcher *fetch.Fetcher
+
+	// 打包下载的限制：单次请求的文件数与文件总字节数
+	ArchiveMaxEntries int
+	ArchiveMaxBytes   int64
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	exifPolicy := exif.DefaultPolicy()
+	return Config{
+		ImageVariants:  imaging.DefaultVariants,
+		MaxImagePixels: imaging.DefaultMaxPixels,
+		Faststart:      true,
+		Exif:           &exifPolicy,
+
+		WaveformLevels: waveform.DefaultLevels,
+
+		FlagDuplicates:    true,
+		DuplicateDistance: 6,
+
+		ArchiveMaxEntries: 10000,
+		ArchiveMaxBytes:   20 << 30,
+	}
+}
 
 type Handlers struct {
-	db *gorm.DB
+	db  *gorm.DB
+	cfg Config
 }
 
-func NewHandlers(db *gorm.DB) *Handlers {
+func NewHandlers(db *gorm.DB, cfg Config) *Handlers {
 	return &Handlers{
-		db: db,
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// UserIDHeader 网关完成认证后传入的用户 ID
+const UserIDHeader = "X-User-ID"
+
+// getUserID 从请求头读取用户 ID，未登录时为 0
+func getUserID(c *fiber.Ctx) uint {
+	id, err := strconv.ParseUint(c.Get(UserIDHeader), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// owns 返回请求方是否是对象的所有者或管理员，修改对象的接口据此鉴权；未登录的请求不拥有任何对象
+func (h *Handlers) owns(c *fiber.Ctx, object *model.OssObject) bool {
+	if h.isAdmin(c) {
+		return true
+	}
+	userID := getUserID(c)
+	return userID != 0 && object.UserID == userID
+}
+
+// sendError 以 JSON 返回 *fiber.Error，其他错误按 500 处理
+func sendError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	var e *fiber.Error
+	if errors.As(err, &e) {
+		status = e.Code
 	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
 }
@@ -0,0 +1,218 @@
+package handlers_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/handlers"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/router"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const adminToken = "admin-token"
+
+// 测试中的用户
+const (
+	anonymous uint = 0
+	owner     uint = 1
+	other     uint = 2
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := repo.CreateTable(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func testConfig() handlers.Config {
+	cfg := handlers.DefaultConfig()
+	cfg.AdminToken = adminToken
+	return cfg
+}
+
+// newTestApp 注册 OSS 路由，文件写入以临时目录为工作目录的 data/ 下；configure 不为 nil 时用于调整配置
+func newTestApp(t *testing.T, configure func(db *gorm.DB, cfg *handlers.Config)) (*fiber.App, *gorm.DB) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	db := openTestDB(t)
+	cfg := testConfig()
+	if configure != nil {
+		configure(db, &cfg)
+	}
+	app := fiber.New()
+	router.RegisterRoutes(app, *handlers.NewHandlers(db, cfg))
+	return app, db
+}
+
+// caller 发起请求的身份
+type caller struct {
+	user  uint
+	admin bool
+}
+
+var (
+	asAnonymous = caller{}
+	asOwner     = caller{user: owner}
+	asOther     = caller{user: other}
+	asAdmin     = caller{admin: true}
+)
+
+func (c caller) String() string {
+	if c.admin {
+		return "admin"
+	}
+	return fmt.Sprintf("user %d", c.user)
+}
+
+// do 发起请求，body 为 nil 以外的非 io.Reader 值时编码为 JSON
+func do(t *testing.T, app *fiber.App, who caller, method, target string, body any, header ...string) *http.Response {
+	t.Helper()
+	var r io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		r = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+		contentType = fiber.MIMEApplicationJSON
+	}
+	req := httptest.NewRequest(method, target, r)
+	if contentType != "" {
+		req.Header.Set(fiber.HeaderContentType, contentType)
+	}
+	if who.user != 0 {
+		req.Header.Set(handlers.UserIDHeader, strconv.FormatUint(uint64(who.user), 10))
+	}
+	if who.admin {
+		req.Header.Set(handlers.AdminTokenHeader, adminToken)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// decode 解码 JSON 响应
+func decode(t *testing.T, resp *http.Response, v any) {
+	t.Helper()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// createObject 直接写入文件与对象记录，未指定的字段使用 data/files 下的默认值
+func createObject(t *testing.T, db *gorm.DB, object model.OssObject, content string) *model.OssObject {
+	t.Helper()
+	if object.FileName == "" {
+		object.FileName = "file.txt"
+	}
+	if object.StoragePath == "" {
+		object.StoragePath = filepath.Join("data", "files", strconv.Itoa(len(content))+"_"+object.FileName)
+	}
+	if object.ObjectKey == "" {
+		object.ObjectKey = object.StoragePath
+	}
+	if object.Bucket == "" {
+		object.Bucket = model.DefaultBucket
+	}
+	if object.Status == "" {
+		object.Status = model.StatusActive
+	}
+	if object.MimeType == "" {
+		object.MimeType = "text/plain"
+		object.FileType = "text/plain"
+	}
+	if object.ETag == "" {
+		object.ETag = fmt.Sprintf("%x", md5.Sum([]byte(content)))
+	}
+	object.FileSize = int64(len(content))
+	if err := os.MkdirAll(filepath.Dir(object.StoragePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(object.StoragePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateObject(db, &object); err != nil {
+		t.Fatal(err)
+	}
+	return &object
+}
+
+// upload 以 who 的身份分片上传 content，返回完成接口的响应；初始化失败时返回初始化的响应
+func upload(t *testing.T, app *fiber.App, who caller, req handlers.InitReq, content string) *http.Response {
+	t.Helper()
+	req.FileSize = int64(len(content))
+	req.ChunkSize = int64(len(content))
+	if req.FileType == "" {
+		req.FileType = "text/plain"
+	}
+	resp := do(t, app, who, fiber.MethodPost, "/api/oss/upload/init", req)
+	if resp.StatusCode != fiber.StatusCreated && resp.StatusCode != fiber.StatusOK {
+		return resp
+	}
+	var initResp handlers.InitResp
+	decode(t, resp, &initResp)
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("chunk", "chunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	w.Close()
+	resp = do(t, app, who, fiber.MethodPost, "/api/oss/upload/"+initResp.UploadID+"/chunk/0", &body,
+		fiber.HeaderContentType, w.FormDataContentType())
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("chunk upload: status %d: %s", resp.StatusCode, readBody(t, resp))
+	}
+	return do(t, app, who, fiber.MethodPost, "/api/oss/upload/"+initResp.UploadID+"/complete", nil)
+}
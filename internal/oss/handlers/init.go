@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"strings"
+
 	"github.com/ormasia/swiftstream/internal/oss/model"
 	"github.com/ormasia/swiftstream/internal/oss/repo"
 
@@ -14,8 +16,20 @@ type InitReq struct {
 	FileType  string `json:"file_type"`
 	ChunkSize int64  `json:"chunk_size"`
 	FileMD5   string `json:"file_md5"` // 文件的MD5值，用于秒传 对应 model.OssObject.ETag
+	// HLS 分片加密方式，空表示不加密，目前只支持 aes-128
+	Encryption string `json:"encryption"`
+	// 存储桶，默认 default；指定对象键时写入该键的新版本，否则生成唯一的对象键
+	Bucket    string `json:"bucket"`
+	ObjectKey string `json:"object_key"`
+	// 自定义元数据，也可以通过 X-Oss-Meta-<key> 请求头指定，完成上传后随对象保存
+	Metadata map[string]string `json:"metadata"`
+	// 访问控制：public-read（默认）或 private
+	ACL string `json:"acl"`
 }
 
+// 对象键的最大长度
+const maxObjectKeyLength = 1024
+
 type InitResp struct {
 	UploadID   string `json:"uploadId"`
 	ChunkCount int    `json:"chunkCount"` // 分片总数
@@ -37,13 +51,50 @@ func (h *Handlers) Init(c *fiber.Ctx) error {
 		})
 	}
 
+	if req.Encryption != "" {
+		if req.Encryption != model.EncryptionAES128 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unsupported encryption",
+			})
+		}
+		if h.cfg.Keys == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Encryption is not enabled",
+			})
+		}
+	}
+
+	acl, ok := validACL(req.ACL)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid acl",
+		})
+	}
+
+	metadata, err := parseMetadata(c, req.Metadata)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if req.Bucket == "" {
+		req.Bucket = model.DefaultBucket
+	}
+	if len(req.ObjectKey) > maxObjectKeyLength || strings.HasPrefix(req.ObjectKey, "/") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object key",
+		})
+	}
+
 	// 生成唯一上传UploadID
 	uploadID := uuid.New().String()
 
-	// 检查文件是否已存在（秒传）
-	if req.FileMD5 != "" {
+	// 检查文件是否已存在（秒传）；指定对象键的上传需要创建新版本，不走秒传
+	// 秒传返回已有对象的地址，只对 public-read 的对象生效，private 对象不因 MD5 相同而泄露
+	if req.FileMD5 != "" && req.ObjectKey == "" && acl == model.ACLPublicRead {
 		existingObject, err := repo.GetObjectByEtag(h.db, req.FileMD5)
-		if err == nil && existingObject != nil {
+		if err == nil && existingObject != nil && existingObject.ACL != model.ACLPrivate {
 			// 文件已存在，创建上传任务并直接标记完成
 			uploadTask := model.UploadTask{
 				UploadID:   uploadID,
@@ -76,6 +127,14 @@ func (h *Handlers) Init(c *fiber.Ctx) error {
 		}
 	}
 
+	// 覆盖他人或锁定的对象时在开始上传前拒绝
+	if err := h.checkKeyOwner(c, req.Bucket, req.ObjectKey, getUserID(c)); err != nil {
+		return sendError(c, err)
+	}
+	if err := h.checkOverwrite(req.Bucket, req.ObjectKey, req.FileName); err != nil {
+		return sendError(c, err)
+	}
+
 	// 计算分片总数
 	chunkCount := int(req.FileSize / req.ChunkSize)
 	if req.FileSize%req.ChunkSize != 0 {
@@ -91,8 +150,12 @@ func (h *Handlers) Init(c *fiber.Ctx) error {
 		ChunkSize:  req.ChunkSize,
 		ChunkCount: chunkCount,
 		Status:     "uploading",
-		// TODO: 从context中获取用户ID
-		// UserID: getUserID(c),
+		Encryption: req.Encryption,
+		Bucket:     req.Bucket,
+		ObjectKey:  req.ObjectKey,
+		UserID:     getUserID(c),
+		Metadata:   metadata,
+		ACL:        acl,
 	}
 
 	// 保存上传任务到数据库
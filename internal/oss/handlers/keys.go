@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+)
+
+// KeyServiceTokenHeader 播放边缘读取内容密钥时携带内部令牌的请求头
+const KeyServiceTokenHeader = "X-Key-Service-Token"
+
+// Key 返回 HLS 内容密钥，只供播放边缘调用，播放器经边缘校验播放令牌后获取
+func (h *Handlers) Key(c *fiber.Ctx) error {
+	token := c.Get(KeyServiceTokenHeader)
+	if h.cfg.Keys == nil || h.cfg.KeyServiceToken == "" ||
+		subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.KeyServiceToken)) != 1 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden",
+		})
+	}
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+
+	key, err := h.cfg.Keys.Get(uint(objectID), c.Params("kid"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Key not found",
+		})
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEOctetStream)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Send(key.Value)
+}
+
+var errEncrypted = fiber.NewError(fiber.StatusForbidden, "Encrypted video can only be played through HLS")
+
+// checkPlaintext 加密视频的源文件与转码输出是明文，只提供给所有者与管理员，其他人只能播放加密的 HLS
+// 衍生对象按源对象的加密方式判断，视频封面不受限制
+func (h *Handlers) checkPlaintext(c *fiber.Ctx, object *model.OssObject) error {
+	if object.Variant == VariantCover {
+		return nil
+	}
+	root := object
+	if object.ParentID != nil {
+		parent, err := repo.GetObject(h.db, *object.ParentID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Object not found")
+		}
+		root = parent
+	}
+	if root.Encryption == "" || h.owns(c, root) {
+		return nil
+	}
+	return errEncrypted
+}
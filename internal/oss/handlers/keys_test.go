@@ -0,0 +1,129 @@
+package handlers_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/handlers"
+	"github.com/ormasia/swiftstream/internal/oss/keystore"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"gorm.io/gorm"
+)
+
+const keyServiceToken = "key-service-token"
+
+var masterKey = bytes.Repeat([]byte{7}, 32)
+
+func newKeystore(t *testing.T, db *gorm.DB) *keystore.Keystore {
+	t.Helper()
+	keys, err := keystore.New(db, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// withKeys 启用加密上传与内容密钥接口
+func withKeys(t *testing.T) func(db *gorm.DB, cfg *handlers.Config) {
+	return func(db *gorm.DB, cfg *handlers.Config) {
+		cfg.Keys = newKeystore(t, db)
+		cfg.KeyServiceToken = keyServiceToken
+	}
+}
+
+func TestKey(t *testing.T) {
+	app, db := newTestApp(t, withKeys(t))
+	video := createObject(t, db, model.OssObject{FileName: "video.mp4", UserID: owner, Encryption: model.EncryptionAES128}, "video")
+	generated, err := newKeystore(t, db).Generate(video.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/oss/objects/" + strconv.Itoa(int(video.ID)) + "/keys/"
+
+	tests := []struct {
+		name   string
+		who    caller
+		token  string
+		kid    string
+		status int
+	}{
+		{"no token", asOwner, "", generated[0].ID, fiber.StatusForbidden},
+		{"wrong token", asAdmin, "wrong", generated[0].ID, fiber.StatusForbidden},
+		{"service token", asAnonymous, keyServiceToken, generated[0].ID, fiber.StatusOK},
+		{"unknown key", asAnonymous, keyServiceToken, "0000", fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp := do(t, app, tt.who, fiber.MethodGet, path+tt.kid, nil, handlers.KeyServiceTokenHeader, tt.token)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.status == fiber.StatusOK {
+			if body := readBody(t, resp); body != string(generated[0].Value) {
+				t.Errorf("%s: key %x, want %x", tt.name, body, generated[0].Value)
+			}
+			if cc := resp.Header.Get(fiber.HeaderCacheControl); cc != "no-store" {
+				t.Errorf("%s: Cache-Control %q, want no-store", tt.name, cc)
+			}
+		}
+	}
+}
+
+func TestKeyNotConfigured(t *testing.T) {
+	app, db := newTestApp(t, nil)
+	video := createObject(t, db, model.OssObject{FileName: "video.mp4"}, "video")
+	resp := do(t, app, asAnonymous, fiber.MethodGet, "/api/oss/objects/"+strconv.Itoa(int(video.ID))+"/keys/1", nil,
+		handlers.KeyServiceTokenHeader, "")
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("status %d, want 403 without a configured service token", resp.StatusCode)
+	}
+}
+
+func TestEncryptedUploadRequiresKeystore(t *testing.T) {
+	app, _ := newTestApp(t, nil)
+	resp := upload(t, app, asOwner, handlers.InitReq{FileName: "video.mp4", Encryption: model.EncryptionAES128}, "video")
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("status %d, want 400 when encryption is not enabled", resp.StatusCode)
+	}
+}
+
+func TestEncryptedObjectDownload(t *testing.T) {
+	app, db := newTestApp(t, withKeys(t))
+	video := createObject(t, db, model.OssObject{FileName: "video.mp4", UserID: owner, Encryption: model.EncryptionAES128}, "plaintext video")
+	rendition := createObject(t, db, model.OssObject{FileName: "video_720p.mp4", UserID: owner, ParentID: &video.ID, Variant: "720p"}, "plaintext rendition")
+	createObject(t, db, model.OssObject{FileName: "video_cover.jpg", UserID: owner, ParentID: &video.ID, Variant: handlers.VariantCover}, "cover")
+	plain := createObject(t, db, model.OssObject{FileName: "plain.mp4", UserID: owner}, "plain video")
+
+	id := func(o *model.OssObject) string { return strconv.Itoa(int(o.ID)) }
+	paths := map[string]string{
+		"source":             "/api/oss/objects/" + id(video) + "/download",
+		"rendition by id":    "/api/oss/objects/" + id(rendition) + "/download",
+		"rendition by param": "/api/oss/objects/" + id(video) + "/download?variant=720p",
+		"cover":              "/api/oss/objects/" + id(video) + "/download?variant=" + handlers.VariantCover,
+		"unencrypted":        "/api/oss/objects/" + id(plain) + "/download",
+	}
+	tests := []struct {
+		path   string
+		who    caller
+		status int
+	}{
+		{"source", asAnonymous, fiber.StatusForbidden},
+		{"source", asOther, fiber.StatusForbidden},
+		{"source", asOwner, fiber.StatusOK},
+		{"source", asAdmin, fiber.StatusOK},
+		{"rendition by id", asOther, fiber.StatusForbidden},
+		{"rendition by id", asOwner, fiber.StatusOK},
+		{"rendition by param", asOther, fiber.StatusForbidden},
+		{"rendition by param", asAdmin, fiber.StatusOK},
+		{"cover", asOther, fiber.StatusOK},
+		{"unencrypted", asOther, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		resp := do(t, app, tt.who, fiber.MethodGet, paths[tt.path], nil)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s as %s: status %d, want %d", tt.path, tt.who, resp.StatusCode, tt.status)
+		}
+	}
+}
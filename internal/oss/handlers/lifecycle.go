@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+)
+
+// 每个存储桶的生命周期规则数上限
+const maxLifecycleRules = 100
+
+// LifecycleReq 生命周期规则的请求与响应
+type LifecycleReq struct {
+	Rules []model.LifecycleRule `json:"rules"`
+}
+
+// validateRule 检查规则的状态、条件与动作
+func validateRule(rule *model.LifecycleRule) error {
+	if rule.Status == "" {
+		rule.Status = model.RuleEnabled
+	}
+	if rule.Status != model.RuleEnabled && rule.Status != model.RuleDisabled {
+		return fmt.Errorf("rule %q: status must be enabled or disabled", rule.Name)
+	}
+	if rule.ExpirationDays < 0 || rule.TransitionDays < 0 || rule.AbortIncompleteUploadDays < 0 {
+		return fmt.Errorf("rule %q: days must not be negative", rule.Name)
+	}
+	if rule.ExpirationDays == 0 && rule.TransitionDays == 0 && rule.AbortIncompleteUploadDays == 0 {
+		return fmt.Errorf("rule %q: at least one action is required", rule.Name)
+	}
+	if rule.ExpirationDays > 0 && rule.TransitionDays > 0 && rule.TransitionDays >= rule.ExpirationDays {
+		return fmt.Errorf("rule %q: transition_days must be less than expiration_days", rule.Name)
+	}
+	if err := validateTags(rule.Tags); err != nil {
+		return fmt.Errorf("rule %q: %w", rule.Name, err)
+	}
+	return nil
+}
+
+// GetLifecycle 查询存储桶的生命周期规则
+func (h *Handlers) GetLifecycle(c *fiber.Ctx) error {
+	rules, err := repo.ListLifecycleRules(h.db, c.Params("bucket"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get lifecycle rules",
+		})
+	}
+	return c.JSON(LifecycleReq{Rules: rules})
+}
+
+// PutLifecycle 替换存储桶的全部生命周期规则，规则为空时清空
+func (h *Handlers) PutLifecycle(c *fiber.Ctx) error {
+	var req LifecycleReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if len(req.Rules) > maxLifecycleRules {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("At most %d rules are allowed", maxLifecycleRules),
+		})
+	}
+	for i := range req.Rules {
+		if err := validateRule(&req.Rules[i]); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+	bucket := c.Params("bucket")
+	if err := repo.ReplaceLifecycleRules(h.db, bucket, req.Rules); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save lifecycle rules",
+		})
+	}
+	rules, err := repo.ListLifecycleRules(h.db, bucket)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get lifecycle rules",
+		})
+	}
+	return c.JSON(LifecycleReq{Rules: rules})
+}
+
+// LifecycleReport 试运行生命周期规则，返回将要执行的动作，不修改任何对象；?bucket= 只执行该存储桶的规则
+func (h *Handlers) LifecycleReport(c *fiber.Ctx) error {
+	return h.runLifecycle(c, true)
+}
+
+// LifecycleRun 立即执行生命周期规则并返回执行结果；?bucket= 只执行该存储桶的规则
+func (h *Handlers) LifecycleRun(c *fiber.Ctx) error {
+	return h.runLifecycle(c, false)
+}
+
+func (h *Handlers) runLifecycle(c *fiber.Ctx, dryRun bool) error {
+	if h.cfg.Lifecycle == nil {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": "Lifecycle is disabled",
+		})
+	}
+	report, err := h.cfg.Lifecycle.Run(c.Context(), c.Query("bucket"), dryRun)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to run lifecycle rules",
+		})
+	}
+	return c.JSON(report)
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/live"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"gorm.io/gorm"
+)
+
+// StreamKeyHeader 推流时携带推流密钥的请求头，也可以使用 ?key= 参数
+const StreamKeyHeader = "X-Stream-Key"
+
+// liveContentTypes 直播分片的文件类型
+var liveContentTypes = map[string]string{
+	model.LiveFormatFMP4: "video/iso.segment",
+	model.LiveFormatTS:   "video/mp2t",
+}
+
+type LiveCreateReq struct {
+	Title          string `json:"title"`
+	Format         string `json:"format"`          // fmp4(默认)/ts
+	TargetDuration int    `json:"target_duration"` // 分片目标时长(秒)
+	BusinessID     string `json:"business_id"`
+}
+
+type LiveCreateResp struct {
+	Session   *model.LiveSession `json:"session"`
+	StreamKey string             `json:"stream_key"`
+	InitURL   string             `json:"init_url,omitempty"` // fmp4 初始化分片推送地址
+	PushURL   string             `json:"push_url"`           // 媒体分片推送地址，{seq} 替换为序号
+	EndURL    string             `json:"end_url"`
+}
+
+type LiveSegmentsResp struct {
+	Session  *model.LiveSession  `json:"session"`
+	Segments []model.LiveSegment `json:"segments"`
+}
+
+// LiveCreate 创建属于请求方的直播会话，返回推流密钥和推送地址
+func (h *Handlers) LiveCreate(c *fiber.Ctx) error {
+	if h.cfg.Live == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Live streaming is not enabled",
+		})
+	}
+	var req LiveCreateReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	session, err := h.cfg.Live.Create(live.CreateRequest{
+		Title:          req.Title,
+		Format:         req.Format,
+		TargetDuration: req.TargetDuration,
+		UserID:         getUserID(c),
+		BusinessID:     req.BusinessID,
+	})
+	if err != nil {
+		return liveError(c, err)
+	}
+
+	base := fmt.Sprintf("/api/oss/live/%d", session.ID)
+	resp := LiveCreateResp{
+		Session:   session,
+		StreamKey: session.StreamKey,
+		PushURL:   base + "/segments/{seq}?duration={duration}",
+		EndURL:    base + "/end",
+	}
+	if session.Format == model.LiveFormatFMP4 {
+		resp.InitURL = base + "/" + live.InitName
+	}
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+// LiveGet 查询直播会话状态
+func (h *Handlers) LiveGet(c *fiber.Ctx) error {
+	session, err := h.liveSession(c)
+	if err != nil {
+		return liveError(c, err)
+	}
+	return c.JSON(session)
+}
+
+// LivePutInit 推送 fMP4 初始化分片
+func (h *Handlers) LivePutInit(c *fiber.Ctx) error {
+	session, err := h.authorizeLive(c)
+	if err != nil {
+		return liveError(c, err)
+	}
+	if err := h.cfg.Live.PutInit(session.ID, c.Body()); err != nil {
+		return liveError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// LivePutSegment 推送媒体分片，?duration= 为分片时长(秒)
+func (h *Handlers) LivePutSegment(c *fiber.Ctx) error {
+	session, err := h.authorizeLive(c)
+	if err != nil {
+		return liveError(c, err)
+	}
+	sequence, err := strconv.ParseInt(c.Params("seq"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid segment sequence",
+		})
+	}
+	duration, err := strconv.ParseFloat(c.Query("duration"), 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid segment duration",
+		})
+	}
+	segment, err := h.cfg.Live.PutSegment(session.ID, sequence, duration, c.Body())
+	if err != nil {
+		return liveError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(segment)
+}
+
+// LiveEnd 结束直播，会话在后台转为点播对象
+func (h *Handlers) LiveEnd(c *fiber.Ctx) error {
+	session, err := h.authorizeLive(c)
+	if err != nil {
+		return liveError(c, err)
+	}
+	session, err = h.cfg.Live.End(session.ID)
+	if err != nil {
+		return liveError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(session)
+}
+
+// LiveSegments 返回最新的分片列表，供播放边缘生成滑动窗口播放列表
+// ?msn= 指定期望的媒体序号，分片尚未到达时最多阻塞 ?timeout= 毫秒
+func (h *Handlers) LiveSegments(c *fiber.Ctx) error {
+	session, err := h.liveSession(c)
+	if err != nil {
+		return liveError(c, err)
+	}
+
+	if msn := c.Query("msn"); msn != "" {
+		sequence, err := strconv.ParseInt(msn, 10, 64)
+		if err != nil || sequence < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid msn",
+			})
+		}
+		// 只允许等待紧接着的分片，避免请求长时间挂起
+		if live.IsActive(session) && sequence > session.LastSequence+2 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "msn is too far ahead",
+			})
+		}
+		maxWait := 3 * time.Duration(session.TargetDuration) * time.Second
+		timeout := min(time.Duration(c.QueryInt("timeout", int(maxWait.Milliseconds())))*time.Millisecond, maxWait)
+		if session, err = h.cfg.Live.WaitSegment(c.Context(), session.ID, sequence, timeout); err != nil {
+			return liveError(c, err)
+		}
+	}
+
+	segments, err := repo.ListLiveSegments(h.db, session.ID, c.QueryInt("limit", 0))
+	if err != nil {
+		return liveError(c, err)
+	}
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	return c.JSON(LiveSegmentsResp{Session: session, Segments: segments})
+}
+
+// LiveFile 返回直播的初始化分片或媒体分片
+func (h *Handlers) LiveFile(c *fiber.Ctx) error {
+	session, err := h.liveSession(c)
+	if err != nil {
+		return liveError(c, err)
+	}
+
+	name := c.Params("name")
+	if name == live.InitName {
+		if !session.HasInit {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "File not found",
+			})
+		}
+		return sendFileAs(c, h.cfg.Live.InitPath(session), "video/mp4")
+	}
+
+	var sequence int64
+	var ext string
+	if _, err := fmt.Sscanf(name, "seg_%d%s", &sequence, &ext); err != nil || live.SegmentName(session, sequence) != name {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+	segment, err := repo.GetLiveSegment(h.db, session.ID, sequence)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+	return sendFileAs(c, segment.StoragePath, liveContentTypes[session.Format])
+}
+
+func (h *Handlers) liveSession(c *fiber.Ctx) (*model.LiveSession, error) {
+	if h.cfg.Live == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return repo.GetLiveSession(h.db, uint(id))
+}
+
+// authorizeLive 校验请求中的推流密钥
+func (h *Handlers) authorizeLive(c *fiber.Ctx) (*model.LiveSession, error) {
+	session, err := h.liveSession(c)
+	if err != nil {
+		return nil, err
+	}
+	key := c.Get(StreamKeyHeader)
+	if key == "" {
+		key = c.Query("key")
+	}
+	return h.cfg.Live.Authorize(session.ID, key)
+}
+
+// liveError 把直播服务的错误映射为 HTTP 状态码
+func liveError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status = fiber.StatusNotFound
+		err = errors.New("Live session not found")
+	case errors.Is(err, live.ErrInvalidStreamKey):
+		status = fiber.StatusForbidden
+	case errors.Is(err, live.ErrSessionEnded), errors.Is(err, live.ErrOutOfOrder):
+		status = fiber.StatusConflict
+	case errors.Is(err, live.ErrInvalidFormat), errors.Is(err, live.ErrInitRequired),
+		errors.Is(err, live.ErrInitNotAllowed), errors.Is(err, live.ErrInvalidDuration):
+		status = fiber.StatusBadRequest
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// sendFileAs 发送文件并在成功时设置文件类型
+func sendFileAs(c *fiber.Ctx, path, contentType string) error {
+	if err := c.SendFile(path); err != nil {
+		return err
+	}
+	if c.Response().StatusCode() < fiber.StatusBadRequest {
+		c.Set(fiber.HeaderContentType, contentType)
+	}
+	return nil
+}
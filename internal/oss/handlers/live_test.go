@@ -0,0 +1,56 @@
+package handlers_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/handlers"
+	"github.com/ormasia/swiftstream/internal/oss/live"
+	"gorm.io/gorm"
+)
+
+func withLive(db *gorm.DB, cfg *handlers.Config) {
+	cfg.Live = live.NewService(db, live.DefaultConfig())
+}
+
+func TestLiveCreateOwner(t *testing.T) {
+	app, _ := newTestApp(t, withLive)
+	// 会话属于请求方，请求体中的 user_id 被忽略
+	resp := do(t, app, asOwner, fiber.MethodPost, "/api/oss/live", map[string]any{
+		"title":   "lesson",
+		"format":  "ts",
+		"user_id": other,
+	})
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("create: status %d: %s", resp.StatusCode, readBody(t, resp))
+	}
+	var created handlers.LiveCreateResp
+	decode(t, resp, &created)
+	if created.Session.UserID != owner {
+		t.Errorf("session user %d, want %d", created.Session.UserID, owner)
+	}
+
+	base := "/api/oss/live/" + strconv.Itoa(int(created.Session.ID))
+	tests := []struct {
+		name   string
+		path   string
+		header []string
+		status int
+	}{
+		{"no stream key", base + "/segments/0?duration=2", nil, fiber.StatusForbidden},
+		{"wrong stream key", base + "/segments/0?duration=2", []string{handlers.StreamKeyHeader, strings.Repeat("0", 32)}, fiber.StatusForbidden},
+		{"invalid duration", base + "/segments/0?duration=x", []string{handlers.StreamKeyHeader, created.StreamKey}, fiber.StatusBadRequest},
+		{"push", base + "/segments/0?duration=2", []string{handlers.StreamKeyHeader, created.StreamKey}, fiber.StatusCreated},
+		{"key query", base + "/segments/1?duration=2&key=" + created.StreamKey, nil, fiber.StatusCreated},
+		{"out of order", base + "/segments/5?duration=2&key=" + created.StreamKey, nil, fiber.StatusConflict},
+		{"unknown session", "/api/oss/live/999/segments/0?duration=2", []string{handlers.StreamKeyHeader, created.StreamKey}, fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp := do(t, app, asAnonymous, fiber.MethodPut, tt.path, strings.NewReader("segment"), tt.header...)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"gorm.io/gorm"
+)
+
+var errObjectLocked = fiber.NewError(fiber.StatusConflict, "Object is under legal hold or retention")
+
+// checkOverwrite 拒绝覆盖锁定对象的上传
+// 指定对象键时，未开启版本控制的存储桶覆盖写入会把当前版本移入回收站；未指定时同名上传共用 data/files/<FileName>
+func (h *Handlers) checkOverwrite(bucket, key, fileName string) error {
+	now := time.Now()
+	if key != "" {
+		if h.versioned(bucket) {
+			return nil
+		}
+		current, err := repo.GetCurrentVersion(h.db, bucket, key)
+		if err == nil && !current.DeleteMarker && current.Locked(now) {
+			return errObjectLocked
+		}
+		return nil
+	}
+	_, err := repo.GetLockedObjectByPath(h.db, filepath.Join("data", "files", fileName), now)
+	if err == nil {
+		return errObjectLocked
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check object lock")
+	}
+	return nil
+}
+
+// lockTarget 读取要修改锁定状态的源对象，回收站中的对象不能锁定
+func (h *Handlers) lockTarget(c *fiber.Ctx) (*model.OssObject, error) {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid object id")
+	}
+	object, err := repo.GetObject(h.db, uint(objectID))
+	if err != nil || object.ParentID != nil || object.DeleteMarker {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Object not found")
+	}
+	return object, nil
+}
+
+// LockResp 对象锁定状态
+type LockResp struct {
+	LegalHold   bool       `json:"legal_hold"`
+	RetainUntil *time.Time `json:"retain_until"`
+}
+
+// PutRetentionReq 设置保留期的请求，retain_until 为空表示清除
+type PutRetentionReq struct {
+	RetainUntil *time.Time `json:"retain_until"`
+	Reason      string     `json:"reason"`
+}
+
+// PutRetention 设置对象的保留期
+// 对象所有者可以设置或延长保留期；缩短或清除尚未到期的保留期需要管理员令牌
+func (h *Handlers) PutRetention(c *fiber.Ctx) error {
+	object, err := h.lockTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	admin := h.isAdmin(c)
+	if !admin && object.UserID != getUserID(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden",
+		})
+	}
+	var req PutRetentionReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	now := time.Now()
+	if req.RetainUntil != nil && !req.RetainUntil.After(now) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "retain_until must be in the future",
+		})
+	}
+	previous := object.RetainUntil
+	shortened := previous != nil && previous.After(now) && (req.RetainUntil == nil || req.RetainUntil.Before(*previous))
+	if shortened && !admin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only admins can shorten or clear an active retention",
+		})
+	}
+
+	action := model.LockRetentionSet
+	if req.RetainUntil == nil {
+		action = model.LockRetentionCleared
+	}
+	audit := model.LockAudit{
+		ObjectID:            object.ID,
+		Action:              action,
+		UserID:              getUserID(c),
+		Admin:               admin,
+		Reason:              req.Reason,
+		RetainUntil:         req.RetainUntil,
+		PreviousRetainUntil: previous,
+	}
+	if err := repo.UpdateObjectLock(h.db, object.ID, object.LegalHold, req.RetainUntil, &audit); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update retention",
+		})
+	}
+	log.Printf("Retention of object %d changed by user %d (admin: %v): %s\n", object.ID, audit.UserID, admin, action)
+	return c.JSON(LockResp{LegalHold: object.LegalHold, RetainUntil: req.RetainUntil})
+}
+
+// PutLegalHoldReq 设置法律保留的请求
+type PutLegalHoldReq struct {
+	LegalHold bool   `json:"legal_hold"`
+	Reason    string `json:"reason"`
+}
+
+// PutLegalHold 设置或解除对象的法律保留，只有管理员可以调用
+// 法律保留没有期限，解除前对象不能删除、覆盖或被生命周期规则处理
+func (h *Handlers) PutLegalHold(c *fiber.Ctx) error {
+	object, err := h.lockTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	var req PutLegalHoldReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "reason is required",
+		})
+	}
+
+	action := model.LockLegalHoldOff
+	if req.LegalHold {
+		action = model.LockLegalHoldOn
+	}
+	audit := model.LockAudit{
+		ObjectID:            object.ID,
+		Action:              action,
+		UserID:              getUserID(c),
+		Admin:               true,
+		Reason:              req.Reason,
+		RetainUntil:         object.RetainUntil,
+		PreviousRetainUntil: object.RetainUntil,
+	}
+	if err := repo.UpdateObjectLock(h.db, object.ID, req.LegalHold, object.RetainUntil, &audit); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update legal hold",
+		})
+	}
+	log.Printf("Legal hold of object %d changed by admin: %s\n", object.ID, action)
+	return c.JSON(LockResp{LegalHold: req.LegalHold, RetainUntil: object.RetainUntil})
+}
+
+// ListLockAudits 按时间倒序列出对象的锁定变更记录，对象被物理删除后仍可查询
+func (h *Handlers) ListLockAudits(c *fiber.Ctx) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	audits, err := repo.ListLockAudits(h.db, uint(objectID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list lock audits",
+		})
+	}
+	return c.JSON(audits)
+}
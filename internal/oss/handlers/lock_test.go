@@ -0,0 +1,130 @@
+package handlers_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/handlers"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"gorm.io/gorm"
+)
+
+func objectPath(id uint, action string) string {
+	return "/api/oss/objects/" + strconv.Itoa(int(id)) + action
+}
+
+func adminPath(id uint, action string) string {
+	return "/api/oss/admin/objects/" + strconv.Itoa(int(id)) + action
+}
+
+func currentVersion(t *testing.T, db *gorm.DB, key string) *model.OssObject {
+	t.Helper()
+	object, err := repo.GetCurrentVersion(db, model.DefaultBucket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return object
+}
+
+func TestLegalHoldBlocksOverwriteAndDelete(t *testing.T) {
+	app, db := newTestApp(t, withTrash)
+	const key = "evidence/a.txt"
+	mustUpload(t, app, asOwner, key, "one")
+	object := currentVersion(t, db, key)
+
+	if resp := do(t, app, asOwner, fiber.MethodPut, adminPath(object.ID, "/legal-hold"),
+		handlers.PutLegalHoldReq{LegalHold: true}); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("legal hold by owner: status %d, want 403", resp.StatusCode)
+	}
+	if resp := do(t, app, asAdmin, fiber.MethodPut, adminPath(object.ID, "/legal-hold"),
+		handlers.PutLegalHoldReq{LegalHold: true, Reason: "case 1"}); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("legal hold by admin: status %d", resp.StatusCode)
+	}
+
+	tests := []struct {
+		name string
+		resp func() int
+	}{
+		{"overwrite key", func() int {
+			return upload(t, app, asOwner, handlers.InitReq{FileName: "a.txt", ObjectKey: key}, "two").StatusCode
+		}},
+		{"delete by id", func() int {
+			return do(t, app, asOwner, fiber.MethodDelete, objectPath(object.ID, ""), nil).StatusCode
+		}},
+		{"delete by key", func() int {
+			return do(t, app, asOwner, fiber.MethodDelete, keyedPath("", key, ""), nil).StatusCode
+		}},
+	}
+	for _, tt := range tests {
+		if status := tt.resp(); status != fiber.StatusConflict {
+			t.Errorf("%s: status %d, want 409", tt.name, status)
+		}
+	}
+	expectBody(t, app, key, "", "one")
+
+	// 解除法律保留后可以覆盖
+	if resp := do(t, app, asAdmin, fiber.MethodPut, adminPath(object.ID, "/legal-hold"), handlers.PutLegalHoldReq{}); resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("release without a reason: status %d, want 400", resp.StatusCode)
+	}
+	do(t, app, asAdmin, fiber.MethodPut, adminPath(object.ID, "/legal-hold"), handlers.PutLegalHoldReq{Reason: "case closed"})
+	mustUpload(t, app, asOwner, key, "two")
+	expectBody(t, app, key, "", "two")
+}
+
+func TestLockedFileNameBlocksUpload(t *testing.T) {
+	app, db := newTestApp(t, withTrash)
+	resp := upload(t, app, asOwner, handlers.InitReq{FileName: "report.txt"}, "one")
+	var complete handlers.CompleteResp
+	decode(t, resp, &complete)
+	future := time.Now().Add(time.Hour)
+	if resp := do(t, app, asOwner, fiber.MethodPut, objectPath(complete.ObjectID, "/retention"),
+		handlers.PutRetentionReq{RetainUntil: &future}); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("set retention: status %d", resp.StatusCode)
+	}
+	// 未指定对象键的同名上传写入同一个文件
+	if resp := upload(t, app, asOther, handlers.InitReq{FileName: "report.txt"}, "two"); resp.StatusCode != fiber.StatusConflict {
+		t.Errorf("upload over a retained file: status %d, want 409", resp.StatusCode)
+	}
+	if object, _ := repo.GetObject(db, complete.ObjectID); object == nil || object.Status != model.StatusActive {
+		t.Errorf("retained object = %+v", object)
+	}
+}
+
+func TestPutRetention(t *testing.T) {
+	app, db := newTestApp(t, nil)
+	object := createObject(t, db, model.OssObject{UserID: owner}, "data")
+	later := time.Now().Add(2 * time.Hour)
+	sooner := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		who    caller
+		until  *time.Time
+		status int
+	}{
+		{"another user", asOther, &later, fiber.StatusForbidden},
+		{"in the past", asOwner, &past, fiber.StatusBadRequest},
+		{"set by owner", asOwner, &sooner, fiber.StatusOK},
+		{"extend by owner", asOwner, &later, fiber.StatusOK},
+		{"shorten by owner", asOwner, &sooner, fiber.StatusForbidden},
+		{"clear by owner", asOwner, nil, fiber.StatusForbidden},
+		{"shorten by admin", asAdmin, &sooner, fiber.StatusOK},
+		{"clear by admin", asAdmin, nil, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		resp := do(t, app, tt.who, fiber.MethodPut, objectPath(object.ID, "/retention"), handlers.PutRetentionReq{RetainUntil: tt.until})
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+
+	var audits []model.LockAudit
+	decode(t, do(t, app, asAdmin, fiber.MethodGet, adminPath(object.ID, "/lock-audits"), nil), &audits)
+	if len(audits) != 4 {
+		t.Errorf("%d lock audits, want 4", len(audits))
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"log"
+	"math"
+
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/probe"
+)
+
+// applyMediaInfo 探测合并后的文件，把媒体元数据写入对象记录
+// 探测失败（如非媒体文件）不影响上传完成，只保留客户端提供的类型
+func (h *Handlers) applyMediaInfo(object *model.OssObject, filePath string) {
+	info, err := probe.File(filePath)
+	if err != nil {
+		if err != probe.ErrUnknownFormat {
+			log.Printf("Failed to probe media info for %s: %v\n", filePath, err)
+		}
+		return
+	}
+
+	object.FileType = info.Kind
+	object.MimeType = info.MimeType
+	object.Width = info.Width
+	object.Height = info.Height
+	object.Duration = int(math.Round(info.Duration))
+	object.VideoCodec = info.VideoCodec
+	object.AudioCodec = info.AudioCodec
+	object.Bitrate = info.Bitrate
+	object.FrameRate = info.FrameRate
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+)
+
+// MetaHeaderPrefix 自定义元数据的请求头与响应头前缀
+const MetaHeaderPrefix = "X-Oss-Meta-"
+
+// 自定义元数据与标签的限制
+const (
+	maxMetadataSize = 2048 // 所有元数据键与值的总字节数
+	maxMetaKeyLen   = 128
+	maxTags         = 10
+	maxTagKeyLen    = 128
+	maxTagValueLen  = 256
+)
+
+// validMetaKey 元数据键只允许小写字母、数字、- 与 _，以便作为响应头原样返回
+func validMetaKey(key string) bool {
+	if key == "" || len(key) > maxMetaKeyLen {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseMetadata 合并 X-Oss-Meta-* 请求头与请求体中的元数据，键统一为小写，请求头优先
+func parseMetadata(c *fiber.Ctx, body map[string]string) (map[string]string, error) {
+	metadata := make(map[string]string, len(body))
+	for k, v := range body {
+		metadata[strings.ToLower(k)] = v
+	}
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		if k := string(key); len(k) > len(MetaHeaderPrefix) && strings.EqualFold(k[:len(MetaHeaderPrefix)], MetaHeaderPrefix) {
+			metadata[strings.ToLower(k[len(MetaHeaderPrefix):])] = string(value)
+		}
+	})
+	size := 0
+	for k, v := range metadata {
+		if !validMetaKey(k) {
+			return nil, fmt.Errorf("invalid metadata key %q", k)
+		}
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("invalid metadata value for %q", k)
+		}
+		size += len(k) + len(v)
+	}
+	if size > maxMetadataSize {
+		return nil, fmt.Errorf("metadata exceeds %d bytes", maxMetadataSize)
+	}
+	return metadata, nil
+}
+
+// validateTags 检查标签数量与键值长度
+func validateTags(tags map[string]string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("at most %d tags are allowed", maxTags)
+	}
+	for k, v := range tags {
+		if k == "" || len(k) > maxTagKeyLen || strings.Contains(k, ":") {
+			return fmt.Errorf("invalid tag key %q", k)
+		}
+		if len(v) > maxTagValueLen {
+			return fmt.Errorf("tag value for %q exceeds %d bytes", k, maxTagValueLen)
+		}
+	}
+	return nil
+}
+
+// parseTagFilters 解析列表接口的 ?tag=key:value 或 ?tag=key 条件，可重复
+func parseTagFilters(c *fiber.Ctx) ([]repo.TagFilter, error) {
+	var filters []repo.TagFilter
+	for _, raw := range c.Context().QueryArgs().PeekMulti("tag") {
+		key, value, hasValue := strings.Cut(string(raw), ":")
+		if key == "" {
+			return nil, fmt.Errorf("invalid tag filter %q", raw)
+		}
+		filters = append(filters, repo.TagFilter{Key: key, Value: value, HasValue: hasValue})
+	}
+	return filters, nil
+}
+
+// loadAttributes 填充对象的自定义元数据与标签
+func (h *Handlers) loadAttributes(object *model.OssObject) error {
+	metadata, err := repo.ListObjectMetadata(h.db, object.ID)
+	if err != nil {
+		return err
+	}
+	tags, err := repo.ListObjectTags(h.db, object.ID)
+	if err != nil {
+		return err
+	}
+	object.Metadata = make(map[string]string, len(metadata))
+	for _, m := range metadata {
+		object.Metadata[m.Key] = m.Value
+	}
+	object.Tags = make(map[string]string, len(tags))
+	for _, t := range tags {
+		object.Tags[t.Key] = t.Value
+	}
+	return nil
+}
+
+// setAttributeHeaders 下载源对象时以 X-Oss-Meta-* 返回自定义元数据，X-Oss-Tagging-Count 返回标签数
+func (h *Handlers) setAttributeHeaders(c *fiber.Ctx, object *model.OssObject) error {
+	if err := h.loadAttributes(object); err != nil {
+		return err
+	}
+	for k, v := range object.Metadata {
+		c.Set(MetaHeaderPrefix+k, v)
+	}
+	if len(object.Tags) > 0 {
+		c.Set("X-Oss-Tagging-Count", fmt.Sprint(len(object.Tags)))
+	}
+	return nil
+}
+
+// tagTarget 读取要查询或修改标签的源对象
+func (h *Handlers) tagTarget(c *fiber.Ctx) (*model.OssObject, error) {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid object id")
+	}
+	object, err := repo.GetObject(h.db, uint(objectID))
+	if err != nil || object.ParentID != nil || object.DeleteMarker {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Object not found")
+	}
+	return object, nil
+}
+
+// TagsResp 标签接口的响应
+type TagsResp struct {
+	Tags map[string]string `json:"tags"`
+}
+
+// GetTags 查询对象的标签
+func (h *Handlers) GetTags(c *fiber.Ctx) error {
+	object, err := h.tagTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	if err := h.loadAttributes(object); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get tags",
+		})
+	}
+	return c.JSON(TagsResp{Tags: object.Tags})
+}
+
+// PutTags 替换对象的全部标签
+func (h *Handlers) PutTags(c *fiber.Ctx) error {
+	object, err := h.tagTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	if object.UserID != getUserID(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden",
+		})
+	}
+	var req TagsResp
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := validateTags(req.Tags); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := repo.ReplaceObjectTags(h.db, object.ID, req.Tags); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save tags",
+		})
+	}
+	if req.Tags == nil {
+		req.Tags = map[string]string{}
+	}
+	return c.JSON(req)
+}
+
+// DeleteTags 清空对象的标签
+func (h *Handlers) DeleteTags(c *fiber.Ctx) error {
+	object, err := h.tagTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	if object.UserID != getUserID(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden",
+		})
+	}
+	if err := repo.ReplaceObjectTags(h.db, object.ID, nil); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete tags",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
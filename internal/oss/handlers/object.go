@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+)
+
+// GetObject 查询对象元数据，包含衍生对象列表、自定义元数据与标签
+func (h *Handlers) GetObject(c *fiber.Ctx) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+
+	object, err := repo.GetObjectWithVariants(h.db, uint(objectID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Object not found",
+		})
+	}
+	if err := h.loadAttributes(object); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get object metadata",
+		})
+	}
+	h.hideOriginal(c, object)
+	return c.JSON(object)
+}
+
+// hideOriginal 从衍生对象列表中去掉改写前的原文件，只有所有者与管理员可以看到
+func (h *Handlers) hideOriginal(c *fiber.Ctx, object *model.OssObject) {
+	if h.owns(c, object) {
+		return
+	}
+	object.Variants = slices.DeleteFunc(object.Variants, func(v model.OssObject) bool {
+		return v.Variant == VariantOriginal
+	})
+}
+
+// 对象列表的分页大小
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// ListObjectsResp 对象列表的响应
+type ListObjectsResp struct {
+	Objects    []model.OssObject `json:"objects"`
+	NextBefore uint              `json:"next_before,omitempty"` // 下一页的 before 参数，没有更多数据时为空
+}
+
+// ListObjects 按上传时间倒序列出请求方可读的源对象，含衍生对象列表
+// ?type= 按文件类型过滤，多个类型用逗号分隔；?tag=key:value 或 ?tag=key 按标签过滤，可重复
+// ?before= 返回 ID 小于该值的对象；?limit= 分页大小
+func (h *Handlers) ListObjects(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultListLimit)
+	before := c.QueryInt("before")
+	if limit <= 0 || limit > maxListLimit || before < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid pagination parameters",
+		})
+	}
+	var fileTypes []string
+	if t := c.Query("type"); t != "" {
+		fileTypes = strings.Split(t, ",")
+	}
+	tags, err := parseTagFilters(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	objects, err := repo.ListObjects(h.db, fileTypes, tags, uint(before), limit, h.readableScopes(c)...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list objects",
+		})
+	}
+	for i := range objects {
+		h.hideOriginal(c, &objects[i])
+	}
+	resp := ListObjectsResp{Objects: objects}
+	if len(objects) == limit {
+		resp.NextBefore = objects[len(objects)-1].ID
+	}
+	return c.JSON(resp)
+}
+
+// Download 下载对象文件，?variant= 指定衍生规格时返回对应的衍生对象
+func (h *Handlers) Download(c *fiber.Ctx) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+
+	object, err := repo.GetObject(h.db, uint(objectID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Object not found",
+		})
+	}
+	return h.sendObject(c, object)
+}
+
+// sendObject 发送对象文件并在响应头中返回自定义元数据，?variant= 指定衍生规格时发送对应的衍生对象
+func (h *Handlers) sendObject(c *fiber.Ctx, object *model.OssObject) error {
+	if object.DeleteMarker {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Object not found",
+		})
+	}
+	// 待审核、已隔离与已拒绝的对象不可下载
+	if object.Status != model.StatusActive {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Object is not available",
+		})
+	}
+	variant := c.Query("variant")
+	if variant == "" {
+		if err := h.setAttributeHeaders(c, object); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to get object metadata",
+			})
+		}
+	} else {
+		var err error
+		object, err = repo.GetVariant(h.db, object.ID, variant)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Variant not found",
+			})
+		}
+	}
+	// 改写前的原文件可能含有 EXIF 中的位置信息等，只提供给所有者与管理员，也包括按衍生对象 ID 直接下载
+	if object.Variant == VariantOriginal && !h.owns(c, object) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden",
+		})
+	}
+	if err := h.checkPlaintext(c, object); err != nil {
+		return sendError(c, err)
+	}
+
+	c.Set(fiber.HeaderETag, `"`+object.ETag+`"`)
+	// 已转移到冷存储的对象从冷存储读取，不支持 Range
+	if object.StorageClass == model.StorageClassCold {
+		r, err := h.openObject(c.Context(), object)
+		if errors.Is(err, errColdUnavailable) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "Cold storage is not available",
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to read object from cold storage",
+			})
+		}
+		if object.MimeType != "" {
+			c.Set(fiber.HeaderContentType, object.MimeType)
+		}
+		return c.SendStream(r, int(object.FileSize))
+	}
+	if err := c.SendFile(object.FilePath()); err != nil {
+		return err
+	}
+	if object.MimeType != "" && c.Response().StatusCode() < fiber.StatusBadRequest {
+		c.Set(fiber.HeaderContentType, object.MimeType)
+	}
+	return nil
+}
+
+var errColdUnavailable = errors.New("cold storage is not configured")
+
+// openObject 打开对象文件，已转移到冷存储的对象从冷存储读取
+func (h *Handlers) openObject(ctx context.Context, object *model.OssObject) (io.ReadCloser, error) {
+	if object.StorageClass != model.StorageClassCold {
+		return os.Open(object.FilePath())
+	}
+	if h.cfg.Cold == nil {
+		return nil, errColdUnavailable
+	}
+	return h.cfg.Cold.Open(ctx, object.ColdKey())
+}
+
+// Transcodes 查询对象的转码任务及进度
+func (h *Handlers) Transcodes(c *fiber.Ctx) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+
+	jobs, err := repo.ListTranscodeJobs(h.db, uint(objectID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get transcode jobs",
+		})
+	}
+	return c.JSON(jobs)
+}
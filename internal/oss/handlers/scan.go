@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/scan"
+)
+
+// AdminTokenHeader 调用审核接口时携带管理员令牌的请求头
+const AdminTokenHeader = "X-Admin-Token"
+
+// process 对象生效后的后续处理：生成衍生图、波形并提交转码任务
+func (h *Handlers) process(object model.OssObject) {
+	// 异步生成衍生图
+	go h.generateVariants(object)
+	// 异步生成音频波形峰值
+	go h.generateWaveform(object)
+	// 视频提交转码任务
+	if h.cfg.Transcoder != nil {
+		if _, err := h.cfg.Transcoder.Enqueue(&object); err != nil {
+			log.Printf("Failed to enqueue transcode jobs for object %d: %v\n", object.ID, err)
+		}
+	}
+}
+
+// scanObject 扫描待审核的对象：通过则生效，命中则隔离，扫描器出错或要求人工审核时保持待审核
+func (h *Handlers) scanObject(object model.OssObject) {
+	report := h.cfg.Scanner.Scan(context.Background(), object.FilePath())
+	results := make([]model.ScanResult, 0, len(report.Findings))
+	for _, f := range report.Findings {
+		results = append(results, model.ScanResult{
+			ObjectID: object.ID,
+			Scanner:  f.Scanner,
+			Verdict:  f.Verdict,
+			Reason:   f.Reason,
+			Error:    f.Error,
+		})
+	}
+	if err := repo.CreateScanResults(h.db, results); err != nil {
+		log.Printf("Failed to save scan results of object %d: %v\n", object.ID, err)
+	}
+
+	switch report.Verdict {
+	case scan.VerdictClean:
+		ok, err := repo.UpdateObjectStatus(h.db, object.ID, model.StatusActive, model.StatusPendingReview)
+		if err != nil {
+			log.Printf("Failed to activate object %d: %v\n", object.ID, err)
+			return
+		}
+		if ok {
+			object.Status = model.StatusActive
+			h.process(object)
+		}
+	case scan.VerdictInfected:
+		if _, err := repo.UpdateObjectStatus(h.db, object.ID, model.StatusQuarantined, model.StatusPendingReview); err != nil {
+			log.Printf("Failed to quarantine object %d: %v\n", object.ID, err)
+			return
+		}
+		log.Printf("Object %d quarantined by scan\n", object.ID)
+	default:
+		log.Printf("Object %d needs manual review\n", object.ID)
+	}
+}
+
+// isAdmin 校验管理员令牌，未配置令牌时总是返回 false
+func (h *Handlers) isAdmin(c *fiber.Ctx) bool {
+	token := c.Get(AdminTokenHeader)
+	return h.cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.AdminToken)) == 1
+}
+
+// RequireAdmin 校验管理员令牌，未配置令牌时拒绝所有请求
+func (h *Handlers) RequireAdmin(c *fiber.Ctx) error {
+	if !h.isAdmin(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden",
+		})
+	}
+	return c.Next()
+}
+
+// ReviewItem 是审核列表中的一项
+type ReviewItem struct {
+	Object      model.OssObject    `json:"object"`
+	ScanResults []model.ScanResult `json:"scan_results"`
+}
+
+// ReviewList 列出待审核或已隔离的对象及其扫描结果
+// ?status=quarantined|pending_review，默认 quarantined；?before=&limit= 分页
+func (h *Handlers) ReviewList(c *fiber.Ctx) error {
+	status := c.Query("status", model.StatusQuarantined)
+	limit := c.QueryInt("limit", defaultListLimit)
+	before := c.QueryInt("before")
+	if status != model.StatusQuarantined && status != model.StatusPendingReview {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid status",
+		})
+	}
+	if limit <= 0 || limit > maxListLimit || before < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid pagination parameters",
+		})
+	}
+
+	objects, err := repo.ListObjectsByStatus(h.db, status, uint(before), limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list objects",
+		})
+	}
+	items := make([]ReviewItem, 0, len(objects))
+	for _, object := range objects {
+		results, err := repo.ListScanResults(h.db, object.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to get scan results",
+			})
+		}
+		items = append(items, ReviewItem{Object: object, ScanResults: results})
+	}
+	return c.JSON(items)
+}
+
+// reviewTarget 读取待审核或已隔离的对象
+func (h *Handlers) reviewTarget(c *fiber.Ctx) (*model.OssObject, error) {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid object id")
+	}
+	object, err := repo.GetObject(h.db, uint(objectID))
+	if err != nil || object.ParentID != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Object not found")
+	}
+	if object.Status != model.StatusPendingReview && object.Status != model.StatusQuarantined {
+		return nil, fiber.NewError(fiber.StatusConflict, "Object is not under review")
+	}
+	return object, nil
+}
+
+// Approve 审核通过，对象生效并开始生成衍生对象
+func (h *Handlers) Approve(c *fiber.Ctx) error {
+	object, err := h.reviewTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	ok, err := repo.UpdateObjectStatus(h.db, object.ID, model.StatusActive, model.StatusPendingReview, model.StatusQuarantined)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update object status",
+		})
+	}
+	if !ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Object is not under review",
+		})
+	}
+	log.Printf("Object %d approved by admin\n", object.ID)
+	object.Status = model.StatusActive
+	h.process(*object)
+	return c.JSON(object)
+}
+
+// Reject 确认违规，删除对象文件及改写前保留的原文件，记录保留以便追溯；锁定的对象保留文件
+func (h *Handlers) Reject(c *fiber.Ctx) error {
+	object, err := h.reviewTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	ok, err := repo.UpdateObjectStatus(h.db, object.ID, model.StatusRejected, model.StatusPendingReview, model.StatusQuarantined)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update object status",
+		})
+	}
+	if !ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Object is not under review",
+		})
+	}
+
+	// 锁定的对象作为证据保留文件，状态为 rejected 后不能再下载
+	if object.Locked(time.Now()) {
+		log.Printf("Object %d rejected by admin, files kept under legal hold or retention\n", object.ID)
+		object.Status = model.StatusRejected
+		return c.JSON(object)
+	}
+
+	// 审核前只会生成 original 衍生对象
+	variants, err := repo.ListVariants(h.db, object.ID)
+	if err != nil {
+		log.Printf("Failed to list variants of rejected object %d: %v\n", object.ID, err)
+	}
+	// 复制、去重、封面与版本可能与其他对象共用文件，只删除没有其他记录引用的文件
+	ids := []uint{object.ID}
+	for _, v := range variants {
+		ids = append(ids, v.ID)
+	}
+	for _, v := range variants {
+		if err := h.removeUnshared(v.FilePath(), ids); err != nil {
+			log.Printf("Failed to remove variant %q of rejected object %d: %v\n", v.Variant, object.ID, err)
+		}
+		if err := repo.DeleteVariant(h.db, object.ID, v.Variant); err != nil {
+			log.Printf("Failed to delete variant %q of rejected object %d: %v\n", v.Variant, object.ID, err)
+		}
+	}
+	if err := h.removeUnshared(object.FilePath(), ids); err != nil {
+		log.Printf("Failed to remove file of rejected object %d: %v\n", object.ID, err)
+	}
+	log.Printf("Object %d rejected by admin\n", object.ID)
+	object.Status = model.StatusRejected
+	return c.JSON(object)
+}
+
+// removeUnshared 删除 exclude 以外没有对象引用的文件
+func (h *Handlers) removeUnshared(path string, exclude []uint) error {
+	n, err := repo.CountStorageReferences(h.db, path, exclude)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Rescan 重新扫描待审核或已隔离的对象，例如更新病毒库或扫描器恢复后
+func (h *Handlers) Rescan(c *fiber.Ctx) error {
+	if h.cfg.Scanner == nil {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": "Scanning is disabled",
+		})
+	}
+	object, err := h.reviewTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	// 已隔离的对象先回到待审核，扫描通过后才会生效
+	if _, err := repo.UpdateObjectStatus(h.db, object.ID, model.StatusPendingReview, model.StatusQuarantined); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update object status",
+		})
+	}
+	object.Status = model.StatusPendingReview
+	go h.scanObject(*object)
+	return c.Status(fiber.StatusAccepted).JSON(object)
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"gorm.io/gorm"
+)
+
+// SharePasswordHeader 访问受密码保护的分享链接时携带的密码，也可以使用 ?password=
+const SharePasswordHeader = "X-Share-Password"
+
+// 分享链接密码的 PBKDF2 参数
+const (
+	sharePasswordIterations = 100000
+	maxSharePasswordLength  = 128
+)
+
+var errShareUnavailable = fiber.NewError(fiber.StatusGone, "Share link is no longer available")
+
+type ShareReq struct {
+	// 有效期，二者都为空时不过期
+	ExpiresIn int        `json:"expires_in"` // 秒
+	ExpiresAt *time.Time `json:"expires_at"`
+	Password  string     `json:"password"`
+	// 最大下载次数，0 表示不限制
+	MaxDownloads int `json:"max_downloads"`
+}
+
+type ShareResp struct {
+	model.ObjectShare
+	URL              string `json:"url"`
+	PasswordRequired bool   `json:"password_required"`
+}
+
+func newShareResp(share model.ObjectShare) ShareResp {
+	return ShareResp{
+		ObjectShare:      share,
+		URL:              "/api/oss/shares/" + share.Token + "/download",
+		PasswordRequired: share.PasswordHash != "",
+	}
+}
+
+// CreateShare 为对象创建分享链接，只有所有者与管理员可以创建
+// 持有链接的人无需登录即可下载，private 对象也可以通过链接分享
+func (h *Handlers) CreateShare(c *fiber.Ctx) error {
+	object, err := h.aclTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	if object.Status != model.StatusActive {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Object is not available",
+		})
+	}
+	var req ShareReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	now := time.Now()
+	if req.ExpiresIn < 0 || req.MaxDownloads < 0 || len(req.Password) > maxSharePasswordLength ||
+		req.ExpiresIn > 0 && req.ExpiresAt != nil || req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid share parameters",
+		})
+	}
+
+	share := model.ObjectShare{
+		ObjectID:     object.ID,
+		UserID:       getUserID(c),
+		ExpiresAt:    req.ExpiresAt,
+		MaxDownloads: req.MaxDownloads,
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := now.Add(time.Duration(req.ExpiresIn) * time.Second)
+		share.ExpiresAt = &expiresAt
+	}
+	if req.Password != "" {
+		if share.PasswordHash, err = hashSharePassword(req.Password); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to hash password",
+			})
+		}
+	}
+	if share.Token, err = shareToken(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate share token",
+		})
+	}
+	if err := repo.CreateShare(h.db, &share); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create share",
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(newShareResp(share))
+}
+
+// ListShares 列出对象的分享链接，包括已撤销与已过期的链接
+func (h *Handlers) ListShares(c *fiber.Ctx) error {
+	object, err := h.aclTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	shares, err := repo.ListShares(h.db, object.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list shares",
+		})
+	}
+	resp := make([]ShareResp, 0, len(shares))
+	for _, share := range shares {
+		resp = append(resp, newShareResp(share))
+	}
+	return c.JSON(resp)
+}
+
+// RevokeShare 撤销分享链接，撤销后链接立即失效
+func (h *Handlers) RevokeShare(c *fiber.Ctx) error {
+	object, err := h.aclTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	shareID, err := strconv.ParseUint(c.Params("share"), 10, 64)
+	if err != nil || shareID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid share id",
+		})
+	}
+	err = repo.RevokeShare(h.db, object.ID, uint(shareID), time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Share not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke share",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// sharedObject 校验分享链接的令牌、有效期、下载次数与密码，返回分享链接与对象
+func (h *Handlers) sharedObject(c *fiber.Ctx) (*model.ObjectShare, *model.OssObject, error) {
+	share, err := repo.GetShareByToken(h.db, c.Params("token"))
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, "Share not found")
+	}
+	if !share.Usable(time.Now()) {
+		return nil, nil, errShareUnavailable
+	}
+	if share.PasswordHash != "" {
+		password := c.Get(SharePasswordHeader)
+		if password == "" {
+			password = c.Query("password")
+		}
+		if password == "" {
+			return nil, nil, fiber.NewError(fiber.StatusUnauthorized, "Password required")
+		}
+		if !checkSharePassword(share.PasswordHash, password) {
+			return nil, nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid password")
+		}
+	}
+	// 对象被删除后链接随之失效
+	object, err := repo.GetObject(h.db, share.ObjectID)
+	if err != nil || object.DeleteMarker {
+		return nil, nil, errShareUnavailable
+	}
+	return share, object, nil
+}
+
+// ShareInfoResp 分享链接指向的文件信息
+type ShareInfoResp struct {
+	FileName      string     `json:"file_name"`
+	FileSize      int64      `json:"file_size"`
+	MimeType      string     `json:"mime_type"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	DownloadsLeft *int       `json:"downloads_left,omitempty"` // 不限制次数时为空
+}
+
+// GetShare 查询分享链接指向的文件信息，不计入下载次数
+func (h *Handlers) GetShare(c *fiber.Ctx) error {
+	share, object, err := h.sharedObject(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	resp := ShareInfoResp{
+		FileName:  object.FileName,
+		FileSize:  object.FileSize,
+		MimeType:  object.MimeType,
+		ExpiresAt: share.ExpiresAt,
+	}
+	if share.MaxDownloads > 0 {
+		left := share.MaxDownloads - share.Downloads
+		resp.DownloadsLeft = &left
+	}
+	return c.JSON(resp)
+}
+
+// DownloadShare 通过分享链接下载对象，?variant= 指定衍生规格
+// 每次下载计入次数，Range 请求中从非零位置开始的续传不重复计数
+func (h *Handlers) DownloadShare(c *fiber.Ctx) error {
+	share, object, err := h.sharedObject(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	if object.Status != model.StatusActive {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Object is not available",
+		})
+	}
+	if !resumedRange(c.Get(fiber.HeaderRange)) {
+		ok, err := repo.ConsumeShareDownload(h.db, share.ID, time.Now())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to record download",
+			})
+		}
+		// 并发下载时次数可能在校验后用完
+		if !ok {
+			return sendError(c, errShareUnavailable)
+		}
+	}
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, archiveName(object.FileName)))
+	return h.sendObject(c, object)
+}
+
+// resumedRange 返回 Range 请求是否从非零位置开始
+func resumedRange(header string) bool {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	return ok && !strings.HasPrefix(strings.TrimSpace(spec), "0-")
+}
+
+// shareToken 生成 192 位随机令牌
+func shareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSharePassword 以 PBKDF2-SHA256 加盐哈希密码，格式为 pbkdf2-sha256$迭代次数$盐$哈希
+func hashSharePassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, sharePasswordIterations, sha256.Size)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", sharePasswordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkSharePassword 校验密码与哈希是否匹配
+func checkSharePassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"image"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/similar"
+)
+
+// 近似查询的距离参数，64 位哈希距离超过 20 基本已无相似性，同时限制 BK 树的搜索范围
+const (
+	defaultSimilarDistance = 10
+	maxSimilarDistance     = 20
+	defaultSimilarLimit    = 20
+)
+
+// indexImage 计算图片的感知哈希并加入索引，source 为 image 时按配置标记重复上传
+// 在后台执行，失败只记录日志
+func (h *Handlers) indexImage(object model.OssObject, img image.Image, source string) {
+	if h.cfg.Similar == nil {
+		return
+	}
+	hashes := similar.Compute(img)
+	if err := h.cfg.Similar.Add(object.ID, source, hashes); err != nil {
+		log.Printf("Failed to index perceptual hash of object %d: %v\n", object.ID, err)
+		return
+	}
+	if source != model.HashSourceImage || !h.cfg.FlagDuplicates {
+		return
+	}
+
+	results, err := h.cfg.Similar.Search(hashes, h.cfg.DuplicateDistance, object.ID, 1)
+	if err != nil || len(results) == 0 {
+		return
+	}
+	original, err := repo.GetObject(h.db, results[0].ObjectID)
+	if err != nil {
+		return
+	}
+	// 命中的对象本身是重复上传时，指向它的源对象
+	duplicateOf := original.ID
+	if original.DuplicateOf != nil {
+		duplicateOf = *original.DuplicateOf
+	}
+	if err := repo.MarkDuplicate(h.db, object.ID, duplicateOf); err != nil {
+		log.Printf("Failed to mark object %d as duplicate: %v\n", object.ID, err)
+		return
+	}
+	log.Printf("Object %d looks like a re-upload of object %d (distance %d)\n", object.ID, duplicateOf, results[0].Distance)
+}
+
+// SimilarItem 是近似查询结果中的一项
+type SimilarItem struct {
+	similar.Result
+	Object model.OssObject `json:"object"`
+}
+
+// Similar 查找与对象感知哈希接近的图片与视频封面
+// ?distance= pHash 汉明距离上限，默认 10；?limit= 结果数上限
+func (h *Handlers) Similar(c *fiber.Ctx) error {
+	if h.cfg.Similar == nil {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": "Similarity search is disabled",
+		})
+	}
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	distance := c.QueryInt("distance", defaultSimilarDistance)
+	limit := c.QueryInt("limit", defaultSimilarLimit)
+	if distance < 0 || distance > maxSimilarDistance || limit <= 0 || limit > maxListLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid distance or limit",
+		})
+	}
+
+	hash, err := repo.GetImageHash(h.db, uint(objectID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Perceptual hash not found",
+		})
+	}
+	results, err := h.cfg.Similar.Search(similar.Hashes{PHash: uint64(hash.PHash), DHash: uint64(hash.DHash)}, distance, hash.ObjectID, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to search similar objects",
+		})
+	}
+
+	ids := make([]uint, len(results))
+	for i, r := range results {
+		ids[i] = r.ObjectID
+	}
+	objects, err := repo.GetObjects(h.db, ids)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get objects",
+		})
+	}
+	byID := make(map[uint]model.OssObject, len(objects))
+	for _, o := range objects {
+		byID[o.ID] = o
+	}
+	items := make([]SimilarItem, 0, len(results))
+	for _, r := range results {
+		// 已删除与请求方不可读的对象不再返回
+		if o, ok := byID[r.ObjectID]; ok && h.readable(c, &o) {
+			items = append(items, SimilarItem{Result: r, Object: o})
+		}
+	}
+	return c.JSON(items)
+}
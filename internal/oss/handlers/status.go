@@ -13,6 +13,12 @@ type StatusResp struct {
 	UploadedChunks int                 `json:"uploadedChunks"`
 	TotalChunks    int                 `json:"totalChunks"`
 	ChunkStatus    []ChunkStatusDetail `json:"chunkStatus"`
+	// 从 URL 下载的上传任务的源地址与失败原因
+	SourceURL string `json:"sourceUrl,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// 完成后的对象键与 ETag
+	ObjectKey string `json:"objectKey,omitempty"`
+	ETag      string `json:"etag,omitempty"`
 }
 
 type ChunkStatusDetail struct {
@@ -62,5 +68,9 @@ func (h *Handlers) Status(c *fiber.Ctx) error {
 		UploadedChunks: uploadTask.UploadedChunks,
 		TotalChunks:    uploadTask.ChunkCount,
 		ChunkStatus:    chunkStatus,
+		SourceURL:      uploadTask.SourceURL,
+		Error:          uploadTask.Error,
+		ObjectKey:      uploadTask.ObjectKey,
+		ETag:           uploadTask.ETag,
 	})
 }
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/hls"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/transcode"
+)
+
+// hlsContentTypes HLS 播放列表与分片的文件类型
+var hlsContentTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".m4s":  "video/iso.segment",
+	".mp4":  "video/mp4",
+}
+
+// dashContentTypes DASH MPD 与分片的文件类型，分片与 HLS 共用
+var dashContentTypes = map[string]string{
+	".mpd": "application/dash+xml",
+	".m4s": "video/iso.segment",
+	".mp4": "video/mp4",
+}
+
+// HLS 返回视频打包输出中的播放列表和分片，路径相对于主播放列表所在目录
+// 主播放列表在返回时加入字幕渲染，subtitles/ 下的字幕播放列表和 WebVTT 按字幕轨道生成
+func (h *Handlers) HLS(c *fiber.Ctx) error {
+	name := c.Params("*")
+	switch {
+	case name == hls.MasterPlaylist:
+		return h.sendMasterPlaylist(c)
+	case strings.HasPrefix(name, subtitleDir+"/"):
+		return h.sendSubtitleRendition(c, strings.TrimPrefix(name, subtitleDir+"/"))
+	}
+	return h.sendPackageFile(c, transcode.VariantHLS, hlsContentTypes)
+}
+
+// DASH 返回视频打包输出中的 MPD 和分片，路径相对于 MPD 所在目录
+func (h *Handlers) DASH(c *fiber.Ctx) error {
+	return h.sendPackageFile(c, transcode.VariantDASH, dashContentTypes)
+}
+
+// sendPackageFile 以清单衍生对象所在目录为根返回打包输出中的文件
+func (h *Handlers) sendPackageFile(c *fiber.Ctx, variant string, contentTypes map[string]string) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	name, ok := packageFile(c.Params("*"))
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid file path",
+		})
+	}
+	contentType, ok := contentTypes[path.Ext(name)]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+
+	manifest, err := repo.GetVariant(h.db, uint(objectID), variant)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stream package not found",
+		})
+	}
+
+	dir := filepath.Dir(manifest.FilePath())
+	if err := c.SendFile(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+		return err
+	}
+	if c.Response().StatusCode() < fiber.StatusBadRequest {
+		c.Set(fiber.HeaderContentType, contentType)
+	}
+	return nil
+}
+
+// packageFile 校验打包输出内的相对路径，拒绝绝对路径和 ..
+func packageFile(name string) (string, bool) {
+	if name == "" || strings.Contains(name, "\\") {
+		return "", false
+	}
+	cleaned := path.Clean("/" + name)[1:]
+	if cleaned == "" || cleaned != name {
+		return "", false
+	}
+	return cleaned, true
+}
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/hls"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/probe"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+	"github.com/ormasia/swiftstream/internal/oss/subtitle"
+	"github.com/ormasia/swiftstream/internal/oss/transcode"
+	"gorm.io/gorm"
+)
+
+const (
+	// subtitleDir HLS 输出中字幕渲染的虚拟目录，字幕不写入打包输出，重新打包不会丢失
+	subtitleDir = "subtitles"
+	// maxSubtitleSize 字幕文件大小上限
+	maxSubtitleSize = 5 * 1024 * 1024
+	// maxSearchResults 字幕检索返回条数上限
+	maxSearchResults = 100
+)
+
+const vttContentType = "text/vtt; charset=utf-8"
+
+// PutSubtitle 上传视频的字幕，multipart 的 file 字段或请求体为 SRT/WebVTT 文本，统一转为 WebVTT 保存
+// 同一语言重复上传时替换；?label= 为显示名称，?default=true 设为默认字幕
+func (h *Handlers) PutSubtitle(c *fiber.Ctx) error {
+	object, language, err := h.subtitleTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	body, err := subtitleBody(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if len(body) == 0 || len(body) > maxSubtitleSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Subtitle file must be between 1 byte and 5MB",
+		})
+	}
+	cues, format, err := subtitle.Parse(body)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	track, err := repo.GetSubtitleTrack(h.db, object.ID, language)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to get subtitle track",
+			})
+		}
+		track = &model.SubtitleTrack{ObjectID: object.ID, Language: language}
+	}
+
+	path := filepath.Join("data", "files", "subtitles", strconv.FormatUint(uint64(object.ID), 10), language+".vtt")
+	etag, size, err := storage.WriteFile(path, func(w io.Writer) error {
+		return subtitle.WriteVTT(w, cues)
+	})
+	if err != nil {
+		log.Printf("Failed to write subtitle %s of object %d: %v\n", language, object.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save subtitle",
+		})
+	}
+
+	track.Label = c.Query("label", track.Label)
+	track.IsDefault = c.QueryBool("default", track.IsDefault)
+	track.SourceFormat = format
+	track.CueCount = len(cues)
+	track.Duration = 0
+	track.FileSize = size
+	track.ETag = etag
+	track.StoragePath = path
+
+	records := make([]model.SubtitleCue, len(cues))
+	for i, cue := range cues {
+		track.Duration = max(track.Duration, cue.End.Seconds())
+		records[i] = model.SubtitleCue{
+			ObjectID: object.ID,
+			Language: language,
+			Sequence: i + 1,
+			Start:    cue.Start.Seconds(),
+			End:      cue.End.Seconds(),
+			Text:     subtitle.PlainText(cue),
+		}
+	}
+	if err := repo.SaveSubtitleTrack(h.db, track, records); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save subtitle track",
+		})
+	}
+	return c.JSON(track)
+}
+
+// ListSubtitles 查询视频的字幕轨道
+func (h *Handlers) ListSubtitles(c *fiber.Ctx) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	tracks, err := repo.ListSubtitleTracks(h.db, uint(objectID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get subtitle tracks",
+		})
+	}
+	return c.JSON(tracks)
+}
+
+// GetSubtitle 下载规范化后的 WebVTT 字幕
+func (h *Handlers) GetSubtitle(c *fiber.Ctx) error {
+	track, err := h.subtitleTrack(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	c.Set(fiber.HeaderETag, `"`+track.ETag+`"`)
+	return sendFileAs(c, track.StoragePath, vttContentType)
+}
+
+// DeleteSubtitle 删除视频指定语言的字幕
+func (h *Handlers) DeleteSubtitle(c *fiber.Ctx) error {
+	object, language, err := h.subtitleTarget(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+	track, err := repo.GetSubtitleTrack(h.db, object.ID, language)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Subtitle not found",
+		})
+	}
+	if err := repo.DeleteSubtitleTrack(h.db, track); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete subtitle track",
+		})
+	}
+	if err := os.Remove(track.StoragePath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove subtitle file %s: %v\n", track.StoragePath, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SearchSubtitles 按字幕文本检索视频片段，?q= 为关键词，可用 ?language= 和 ?object_id= 过滤
+func (h *Handlers) SearchSubtitles(c *fiber.Ctx) error {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "q is required",
+		})
+	}
+	language := c.Query("language")
+	if language != "" {
+		var ok bool
+		if language, ok = subtitle.NormalizeLanguage(language); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid language",
+			})
+		}
+	}
+	limit := min(max(c.QueryInt("limit", 20), 1), maxSearchResults)
+
+	// 只返回请求方可读的视频的字幕
+	cues, err := repo.SearchSubtitleCues(h.db, q, language, uint(max(c.QueryInt("object_id"), 0)), limit, h.readableScopes(c)...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to search subtitles",
+		})
+	}
+	return c.JSON(cues)
+}
+
+// subtitleBody 读取上传的字幕内容，超过上限时多读一个字节以便调用方判断
+func subtitleBody(c *fiber.Ctx) ([]byte, error) {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		return c.Body(), nil
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, maxSubtitleSize+1))
+}
+
+// subtitleTarget 读取要修改字幕的视频对象和规范化的语言标签，只有所有者与管理员可以修改
+// 失败时返回带状态码的 *fiber.Error
+func (h *Handlers) subtitleTarget(c *fiber.Ctx) (*model.OssObject, string, error) {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return nil, "", fiber.NewError(fiber.StatusBadRequest, "Invalid object id")
+	}
+	language, ok := subtitle.NormalizeLanguage(c.Params("lang"))
+	if !ok {
+		return nil, "", fiber.NewError(fiber.StatusBadRequest, "Invalid language")
+	}
+	object, err := repo.GetObject(h.db, uint(objectID))
+	if err != nil {
+		return nil, "", fiber.NewError(fiber.StatusNotFound, "Object not found")
+	}
+	if object.ParentID != nil || object.FileType != probe.KindVideo {
+		return nil, "", fiber.NewError(fiber.StatusBadRequest, "Subtitles can only be added to videos")
+	}
+	if !h.owns(c, object) {
+		return nil, "", fiber.NewError(fiber.StatusForbidden, "Forbidden")
+	}
+	return object, language, nil
+}
+
+// subtitleTrack 读取路径参数指定的字幕轨道，失败时返回带状态码的 *fiber.Error
+func (h *Handlers) subtitleTrack(c *fiber.Ctx) (*model.SubtitleTrack, error) {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid object id")
+	}
+	language, ok := subtitle.NormalizeLanguage(c.Params("lang"))
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid language")
+	}
+	track, err := repo.GetSubtitleTrack(h.db, uint(objectID), language)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Subtitle not found")
+	}
+	return track, nil
+}
+
+// sendMasterPlaylist 返回加入字幕渲染的 HLS 主播放列表
+func (h *Handlers) sendMasterPlaylist(c *fiber.Ctx) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	manifest, err := repo.GetVariant(h.db, uint(objectID), transcode.VariantHLS)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stream package not found",
+		})
+	}
+	master, err := os.ReadFile(manifest.FilePath())
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+	tracks, err := repo.ListSubtitleTracks(h.db, uint(objectID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get subtitle tracks",
+		})
+	}
+	subs := make([]hls.Subtitle, len(tracks))
+	for i, t := range tracks {
+		subs[i] = hls.Subtitle{
+			Language: t.Language,
+			Name:     t.Label,
+			Default:  t.IsDefault,
+			URI:      subtitleDir + "/" + t.Language + "/" + hls.MediaPlaylist,
+		}
+	}
+	c.Set(fiber.HeaderContentType, hlsContentTypes[".m3u8"])
+	return c.Send(hls.AddSubtitles(master, subs))
+}
+
+// sendSubtitleRendition 返回字幕渲染的媒体播放列表 <语言>/index.m3u8 或 WebVTT 文件 <语言>/<etag>.vtt
+// WebVTT 的文件名带 ETag，字幕替换后地址随之变化，边缘缓存不会返回旧内容
+func (h *Handlers) sendSubtitleRendition(c *fiber.Ctx, name string) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	lang, file, ok := strings.Cut(name, "/")
+	language, valid := subtitle.NormalizeLanguage(lang)
+	if !ok || !valid || language != lang {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+	track, err := repo.GetSubtitleTrack(h.db, uint(objectID), language)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Subtitle not found",
+		})
+	}
+
+	switch file {
+	case hls.MediaPlaylist:
+		duration := track.Duration
+		if object, err := repo.GetObject(h.db, uint(objectID)); err == nil {
+			duration = max(duration, float64(object.Duration))
+		}
+		var buf bytes.Buffer
+		if err := hls.WriteSubtitlePlaylist(&buf, track.ETag+".vtt", duration); err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, hlsContentTypes[".m3u8"])
+		return c.Send(buf.Bytes())
+	case track.ETag + ".vtt":
+		return sendFileAs(c, track.StoragePath, vttContentType)
+	}
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"error": "File not found",
+	})
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/trash"
+)
+
+// DeleteObject 删除对象，默认移入回收站；?permanent=true 时立即物理删除
+func (h *Handlers) DeleteObject(c *fiber.Ctx) error {
+	if h.cfg.Trash == nil {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": "Deletion is disabled",
+		})
+	}
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	object, err := repo.GetObject(h.db, uint(objectID))
+	if err != nil || object.ParentID != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Object not found",
+		})
+	}
+	if object.UserID != getUserID(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden",
+		})
+	}
+
+	if err := h.cfg.Trash.Delete(object); err != nil {
+		if errors.Is(err, trash.ErrNotDeletable) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Object cannot be deleted",
+			})
+		}
+		if errors.Is(err, trash.ErrLocked) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Object is under legal hold or retention",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete object",
+		})
+	}
+	if c.QueryBool("permanent") {
+		if err := h.cfg.Trash.Purge(object.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to purge object",
+			})
+		}
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// TrashItem 是回收站列表中的一项
+type TrashItem struct {
+	Object  model.OssObject `json:"object"`
+	PurgeAt time.Time       `json:"purge_at"` // 超过该时间后对象被物理删除，不能再恢复
+}
+
+// TrashResp 回收站列表的响应
+type TrashResp struct {
+	Items      []TrashItem `json:"items"`
+	NextBefore uint        `json:"next_before,omitempty"`
+}
+
+// ListTrash 列出当前用户回收站中仍可恢复的对象，?before=&limit= 分页
+func (h *Handlers) ListTrash(c *fiber.Ctx) error {
+	if h.cfg.Trash == nil {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": "Deletion is disabled",
+		})
+	}
+	limit := c.QueryInt("limit", defaultListLimit)
+	before := c.QueryInt("before")
+	if limit <= 0 || limit > maxListLimit || before < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid pagination parameters",
+		})
+	}
+
+	since := time.Now().Add(-h.cfg.Trash.Retention())
+	objects, err := repo.ListTrash(h.db, getUserID(c), since, uint(before), limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list trash",
+		})
+	}
+	resp := TrashResp{Items: make([]TrashItem, 0, len(objects))}
+	for _, object := range objects {
+		resp.Items = append(resp.Items, TrashItem{Object: object, PurgeAt: h.cfg.Trash.PurgeAt(&object)})
+	}
+	if len(objects) == limit {
+		resp.NextBefore = objects[len(objects)-1].ID
+	}
+	return c.JSON(resp)
+}
+
+// RestoreObject 在保留期内从回收站恢复对象及其衍生对象
+func (h *Handlers) RestoreObject(c *fiber.Ctx) error {
+	if h.cfg.Trash == nil {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": "Deletion is disabled",
+		})
+	}
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	object, err := repo.GetDeletedObject(h.db, uint(objectID))
+	if err != nil || object.UserID != getUserID(c) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Object not found in trash",
+		})
+	}
+
+	if err := h.cfg.Trash.Restore(object); err != nil {
+		switch {
+		case errors.Is(err, trash.ErrExpired):
+			return c.Status(fiber.StatusGone).JSON(fiber.Map{
+				"error": "Retention period has expired",
+			})
+		case errors.Is(err, trash.ErrNotInTrash):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Object not found in trash",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to restore object",
+		})
+	}
+
+	restored, err := repo.GetObjectWithVariants(h.db, object.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get object",
+		})
+	}
+	return c.JSON(restored)
+}
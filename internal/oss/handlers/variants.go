@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ormasia/swiftstream/internal/oss/imaging"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/probe"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+)
+
+// generateVariants 计算图片对象的占位信息，并生成配置的衍生图作为子对象保存
+// 在后台执行，单个规格失败只记录日志，不影响其他规格
+func (h *Handlers) generateVariants(parent model.OssObject) {
+	if parent.FileType != probe.KindImage {
+		return
+	}
+	src, _, err := imaging.Open(parent.FilePath(), h.cfg.MaxImagePixels)
+	if err != nil {
+		log.Printf("Failed to decode image %d for variants: %v\n", parent.ID, err)
+		return
+	}
+	// 衍生图与原图的占位信息相同，一并写入
+	blurHash, dominant, palette := placeholder(src)
+	if err := repo.UpdateObjectPlaceholder(h.db, parent.ID, blurHash, dominant, palette); err != nil {
+		log.Printf("Failed to save placeholder of object %d: %v\n", parent.ID, err)
+	}
+	// 感知哈希用于近似查询与重复上传检测
+	h.indexImage(parent, src, model.HashSourceImage)
+
+	for _, variant := range h.cfg.ImageVariants {
+		opts, err := variant.Normalize()
+		if err != nil {
+			log.Printf("Invalid image variant %q: %v\n", variant.Name, err)
+			continue
+		}
+		img := imaging.Transform(src, opts)
+
+		ext := imaging.Ext(opts.Format)
+		variantPath := filepath.Join("data", "files", "variants", strconv.FormatUint(uint64(parent.ID), 10), variant.Name+"."+ext)
+		etag, size, err := storage.WriteFile(variantPath, func(w io.Writer) error {
+			return imaging.Encode(w, img, opts.Format, opts.Quality)
+		})
+		if err != nil {
+			log.Printf("Failed to write variant %q of object %d: %v\n", variant.Name, parent.ID, err)
+			continue
+		}
+
+		parentID := parent.ID
+		child := model.OssObject{
+			FileName:      fmt.Sprintf("%s_%s.%s", trimExt(parent.FileName), variant.Name, ext),
+			FileSize:      size,
+			FileType:      probe.KindImage,
+			MimeType:      imaging.MimeType(opts.Format),
+			Bucket:        parent.Bucket,
+			ObjectKey:     fmt.Sprintf("%s@%s.%s", parent.ObjectKey, variant.Name, ext),
+			VersionID:     parent.VersionID,
+			ETag:          etag,
+			StoragePath:   variantPath,
+			ParentID:      &parentID,
+			Variant:       variant.Name,
+			URL:           fmt.Sprintf("/api/oss/objects/%d/download?variant=%s", parent.ID, variant.Name),
+			Width:         img.Bounds().Dx(),
+			Height:        img.Bounds().Dy(),
+			BlurHash:      blurHash,
+			DominantColor: dominant,
+			Palette:       palette,
+			UserID:        parent.UserID,
+			BusinessID:    parent.BusinessID,
+			Status:        "active",
+		}
+		if err := repo.CreateObject(h.db, &child); err != nil {
+			log.Printf("Failed to create variant %q of object %d: %v\n", variant.Name, parent.ID, err)
+		}
+	}
+}
+
+func trimExt(name string) string {
+	return name[:len(name)-len(filepath.Ext(name))]
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/probe"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+	"github.com/ormasia/swiftstream/internal/oss/waveform"
+	"gorm.io/gorm"
+)
+
+// VariantWaveform 波形峰值的衍生对象规格名，指向最高分辨率的数据，其他分辨率位于同一目录
+const VariantWaveform = "waveform"
+
+// generateWaveform 为 WAV/MP3 音频计算各级分辨率的波形峰值，并登记为衍生对象
+// 在后台执行，失败只记录日志
+func (h *Handlers) generateWaveform(object model.OssObject) {
+	if object.FileType != probe.KindAudio || len(h.cfg.WaveformLevels) == 0 {
+		return
+	}
+	info, err := probe.File(object.FilePath())
+	if err != nil || (info.Format != "wav" && info.Format != "mp3") {
+		return
+	}
+	dec, closer, err := waveform.OpenFile(object.FilePath(), info.Format)
+	if err != nil {
+		log.Printf("Failed to decode audio %d for waveform: %v\n", object.ID, err)
+		return
+	}
+	defer closer.Close()
+	waveforms, err := waveform.Generate(dec, h.cfg.WaveformLevels)
+	if err != nil {
+		log.Printf("Failed to generate waveform of object %d: %v\n", object.ID, err)
+		return
+	}
+
+	dir := filepath.Join("data", "files", "waveforms", strconv.FormatUint(uint64(object.ID), 10))
+	var etag string
+	var size int64
+	for i, w := range waveforms {
+		e, n, err := storage.WriteFile(waveformPath(dir, w.SamplesPerPixel), func(out io.Writer) error {
+			return w.WriteBinary(out)
+		})
+		if err != nil {
+			log.Printf("Failed to write waveform of object %d: %v\n", object.ID, err)
+			return
+		}
+		if i == 0 {
+			etag, size = e, n
+		}
+	}
+
+	parentID := object.ID
+	base := waveforms[0]
+	child := &model.OssObject{
+		ParentID:  &parentID,
+		Variant:   VariantWaveform,
+		Bucket:    object.Bucket,
+		ObjectKey: fmt.Sprintf("%s@%s.dat", object.ObjectKey, VariantWaveform),
+		VersionID: object.VersionID,
+		URL:       fmt.Sprintf("/api/oss/objects/%d/waveform", object.ID),
+	}
+	if existing, err := repo.GetVariant(h.db, object.ID, VariantWaveform); err == nil {
+		child = existing
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Failed to get waveform of object %d: %v\n", object.ID, err)
+		return
+	}
+	child.FileName = fmt.Sprintf("%s_%s.dat", trimExt(object.FileName), VariantWaveform)
+	child.FileSize = size
+	child.FileType = object.FileType
+	child.MimeType = fiber.MIMEOctetStream
+	child.ETag = etag
+	child.StoragePath = waveformPath(dir, base.SamplesPerPixel)
+	child.Duration = object.Duration
+	child.UserID = object.UserID
+	child.BusinessID = object.BusinessID
+	child.Status = "active"
+	if err := repo.SaveObject(h.db, child); err != nil {
+		log.Printf("Failed to save waveform of object %d: %v\n", object.ID, err)
+	}
+}
+
+func waveformPath(dir string, samplesPerPixel int) string {
+	return filepath.Join(dir, strconv.Itoa(samplesPerPixel)+".dat")
+}
+
+// Waveform 返回音频的波形峰值
+// ?samples_per_pixel= 指定分辨率；?pixels= 按显示宽度选择不超过该像素数的最高分辨率；都不指定时返回最高分辨率
+// ?format=binary 返回 audiowaveform 二进制格式，默认 JSON
+func (h *Handlers) Waveform(c *fiber.Ctx) error {
+	objectID, err := c.ParamsInt("id")
+	if err != nil || objectID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid object id",
+		})
+	}
+	variant, err := repo.GetVariant(h.db, uint(objectID), VariantWaveform)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Waveform not found",
+		})
+	}
+	dir := filepath.Dir(variant.FilePath())
+	levels, err := waveformLevels(dir)
+	if err != nil || len(levels) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Waveform not found",
+		})
+	}
+
+	spp := levels[0]
+	switch {
+	case c.Query("samples_per_pixel") != "":
+		spp = c.QueryInt("samples_per_pixel")
+		if !slices.Contains(levels, spp) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":  "Unsupported samples_per_pixel",
+				"levels": levels,
+			})
+		}
+	case c.Query("pixels") != "":
+		pixels := c.QueryInt("pixels")
+		if pixels <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid pixels",
+			})
+		}
+		// 各级像素数与分辨率成反比，估算最高分辨率的像素数后选出第一个不超过 pixels 的级别
+		base, err := waveformLength(waveformPath(dir, levels[0]))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Waveform not found",
+			})
+		}
+		spp = levels[len(levels)-1]
+		for _, level := range levels {
+			if base*levels[0]/level <= pixels {
+				spp = level
+				break
+			}
+		}
+	}
+
+	path := waveformPath(dir, spp)
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	c.Set("X-Samples-Per-Pixel", strconv.Itoa(spp))
+	if c.Query("format") == "binary" {
+		return sendFileAs(c, path, fiber.MIMEOctetStream)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Waveform not found",
+		})
+	}
+	w, err := waveform.ReadBinary(bytes.NewReader(data))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to read waveform",
+		})
+	}
+	return c.JSON(w)
+}
+
+// waveformLevels 列出目录中已生成的分辨率，升序排列
+func waveformLevels(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var levels []int
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".dat")
+		if !ok {
+			continue
+		}
+		if spp, err := strconv.Atoi(name); err == nil && spp > 0 {
+			levels = append(levels, spp)
+		}
+	}
+	slices.Sort(levels)
+	return levels, nil
+}
+
+// waveformLength 读取二进制头部中的像素数
+func waveformLength(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	header, err := waveform.ReadHeader(f)
+	if err != nil {
+		return 0, err
+	}
+	return header.Length, nil
+}
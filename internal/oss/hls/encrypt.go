@@ -0,0 +1,79 @@
+package hls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+
+	"github.com/ormasia/swiftstream/internal/oss/cmaf"
+)
+
+// Key 是 EXT-X-KEY 引用的一个 AES-128 内容密钥
+type Key struct {
+	URI   string // 密钥地址，相对媒体播放列表
+	Value []byte // 16 字节密钥
+}
+
+// Encryption 描述分片加密方式：每 Rotation 个分片换一个密钥，Rotation 为 0 时整个视频使用同一个密钥
+// 初始化分片不加密，EXT-X-MAP 写在第一个 EXT-X-KEY 之前
+type Encryption struct {
+	Keys     []Key
+	Rotation int
+}
+
+// KeyCount 返回 n 个分片需要的密钥数
+func KeyCount(segments, rotation int) int {
+	if rotation <= 0 || segments <= rotation {
+		return 1
+	}
+	return (segments + rotation - 1) / rotation
+}
+
+// keyIndex 返回第 i 个分片（从 0 开始）使用的密钥下标
+func (e *Encryption) keyIndex(i int) int {
+	if e.Rotation <= 0 {
+		return 0
+	}
+	return min(i/e.Rotation, len(e.Keys)-1)
+}
+
+// EncryptSegments 用 AES-128-CBC（PKCS7 填充）原地加密所有媒体分片
+// 播放列表不写 IV，按规范使用分片的媒体序号作为 IV
+func EncryptSegments(dir string, pres *cmaf.Presentation, enc *Encryption) error {
+	for i := range pres.Streams {
+		stream := &pres.Streams[i]
+		for n := range stream.Segments {
+			path := filepath.Join(dir, stream.ID, stream.SegmentName(n+1))
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			sealed, err := encryptSegment(data, enc.Keys[enc.keyIndex(n)].Value, uint64(n+1))
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, sealed, 0644); err != nil {
+				return err
+			}
+			stream.Segments[n].Size = int64(len(sealed))
+		}
+	}
+	return nil
+}
+
+func encryptSegment(data, key []byte, sequence uint64) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], sequence)
+
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	padded := append(data, bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(padded, padded)
+	return padded, nil
+}
@@ -0,0 +1,84 @@
+package hls
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Playlist 是不依赖 CMAF 打包结果的通用媒体播放列表，用于直播和直播转点播
+type Playlist struct {
+	Version        int
+	TargetDuration int
+	MediaSequence  int64
+	Type           string // 空表示滑动窗口直播，VOD/EVENT 写入 EXT-X-PLAYLIST-TYPE
+	InitURI        string // fMP4 初始化分片，TS 为空
+	CanBlockReload bool   // 是否支持 _HLS_msn 阻塞刷新
+	Segments       []PlaylistSegment
+	Ended          bool // 写入 EXT-X-ENDLIST
+}
+
+// PlaylistSegment 是播放列表中的一个分片
+type PlaylistSegment struct {
+	URI      string
+	Duration float64 // 秒
+}
+
+// Variant 是主播放列表中的一个变体流
+type Variant struct {
+	URI       string
+	Bandwidth int64
+	Codecs    string
+	Width     int
+	Height    int
+}
+
+// WritePlaylist 写出媒体播放列表，TARGETDURATION 不小于最长分片四舍五入后的时长
+func WritePlaylist(w io.Writer, pl *Playlist) error {
+	target := pl.TargetDuration
+	for _, seg := range pl.Segments {
+		target = max(target, int(math.Round(seg.Duration)))
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	fmt.Fprintf(bw, "#EXT-X-VERSION:%d\n", max(3, pl.Version))
+	fmt.Fprintf(bw, "#EXT-X-TARGETDURATION:%d\n", max(1, target))
+	if pl.CanBlockReload {
+		fmt.Fprintln(bw, "#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES")
+	}
+	fmt.Fprintf(bw, "#EXT-X-MEDIA-SEQUENCE:%d\n", pl.MediaSequence)
+	if pl.Type != "" {
+		fmt.Fprintf(bw, "#EXT-X-PLAYLIST-TYPE:%s\n", pl.Type)
+	}
+	fmt.Fprintln(bw, "#EXT-X-INDEPENDENT-SEGMENTS")
+	if pl.InitURI != "" {
+		fmt.Fprintf(bw, "#EXT-X-MAP:URI=%q\n", pl.InitURI)
+	}
+	for _, seg := range pl.Segments {
+		fmt.Fprintf(bw, "#EXTINF:%.6f,\n", seg.Duration)
+		fmt.Fprintln(bw, seg.URI)
+	}
+	if pl.Ended {
+		fmt.Fprintln(bw, "#EXT-X-ENDLIST")
+	}
+	return bw.Flush()
+}
+
+// WriteVariantPlaylist 写出只有一个变体流的主播放列表
+func WriteVariantPlaylist(w io.Writer, v Variant) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	fmt.Fprintln(bw, "#EXT-X-INDEPENDENT-SEGMENTS")
+	fmt.Fprintf(bw, "#EXT-X-STREAM-INF:BANDWIDTH=%d", max(1, v.Bandwidth))
+	if v.Codecs != "" {
+		fmt.Fprintf(bw, ",CODECS=%q", v.Codecs)
+	}
+	if v.Width > 0 && v.Height > 0 {
+		fmt.Fprintf(bw, ",RESOLUTION=%dx%d", v.Width, v.Height)
+	}
+	fmt.Fprintln(bw)
+	fmt.Fprintln(bw, v.URI)
+	return bw.Flush()
+}
@@ -0,0 +1,131 @@
+package hls
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/ormasia/swiftstream/internal/oss/cmaf"
+)
+
+// 播放列表文件名
+const (
+	MasterPlaylist = "master.m3u8"
+	MediaPlaylist  = "index.m3u8"
+)
+
+// audioGroupID 是主播放列表中音频渲染组的 GROUP-ID
+const audioGroupID = "audio"
+
+// WritePlaylists 为打包结果写出每条流的媒体播放列表和主播放列表，enc 为 nil 时不加密
+func WritePlaylists(dir string, pres *cmaf.Presentation, enc *Encryption) error {
+	for i := range pres.Streams {
+		stream := &pres.Streams[i]
+		err := writeFile(filepath.Join(dir, stream.ID, MediaPlaylist), func(w io.Writer) error {
+			return WriteMediaPlaylist(w, stream, enc)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return writeFile(filepath.Join(dir, MasterPlaylist), func(w io.Writer) error {
+		return WriteMasterPlaylist(w, pres)
+	})
+}
+
+// WriteMediaPlaylist 写出单条流的 VOD 媒体播放列表
+func WriteMediaPlaylist(w io.Writer, s *cmaf.Stream, enc *Encryption) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	fmt.Fprintln(bw, "#EXT-X-VERSION:7")
+	fmt.Fprintf(bw, "#EXT-X-TARGETDURATION:%d\n", TargetDuration(s))
+	fmt.Fprintln(bw, "#EXT-X-MEDIA-SEQUENCE:1")
+	fmt.Fprintln(bw, "#EXT-X-PLAYLIST-TYPE:VOD")
+	fmt.Fprintln(bw, "#EXT-X-INDEPENDENT-SEGMENTS")
+	fmt.Fprintf(bw, "#EXT-X-MAP:URI=%q\n", s.InitURI)
+	for i, seg := range s.Segments {
+		if enc != nil && (i == 0 || enc.keyIndex(i) != enc.keyIndex(i-1)) {
+			fmt.Fprintf(bw, "#EXT-X-KEY:METHOD=AES-128,URI=%q\n", enc.Keys[enc.keyIndex(i)].URI)
+		}
+		fmt.Fprintf(bw, "#EXTINF:%.6f,\n", float64(seg.Duration)/float64(s.Timescale))
+		fmt.Fprintln(bw, s.SegmentName(i+1))
+	}
+	fmt.Fprintln(bw, "#EXT-X-ENDLIST")
+	return bw.Flush()
+}
+
+// TargetDuration 返回 EXT-X-TARGETDURATION：四舍五入后的最长分片时长
+func TargetDuration(s *cmaf.Stream) int {
+	return max(1, int(math.Round(s.MaxSegmentSeconds())))
+}
+
+// WriteMasterPlaylist 写出主播放列表，视频流按码率从低到高排列，音轨作为独立的音频渲染
+func WriteMasterPlaylist(w io.Writer, pres *cmaf.Presentation) error {
+	var videos []cmaf.Stream
+	var audio *cmaf.Stream
+	for i := range pres.Streams {
+		switch pres.Streams[i].Type {
+		case cmaf.StreamVideo:
+			videos = append(videos, pres.Streams[i])
+		case cmaf.StreamAudio:
+			if audio == nil {
+				audio = &pres.Streams[i]
+			}
+		}
+	}
+	sort.SliceStable(videos, func(i, j int) bool {
+		return videos[i].Bandwidth < videos[j].Bandwidth
+	})
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	fmt.Fprintln(bw, "#EXT-X-VERSION:7")
+	fmt.Fprintln(bw, "#EXT-X-INDEPENDENT-SEGMENTS")
+
+	if audio != nil && len(videos) > 0 {
+		fmt.Fprintf(bw, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=%q,NAME=\"default\",DEFAULT=YES,AUTOSELECT=YES,CHANNELS=\"%d\",URI=%q\n",
+			audioGroupID, max(1, audio.Channels), path.Join(audio.ID, MediaPlaylist))
+	}
+	for _, v := range videos {
+		bandwidth, average, codecs := v.Bandwidth, v.AverageBandwidth, v.Codecs
+		if audio != nil {
+			bandwidth += audio.Bandwidth
+			average += audio.AverageBandwidth
+			codecs += "," + audio.Codecs
+		}
+		fmt.Fprintf(bw, "#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,CODECS=%q,RESOLUTION=%dx%d",
+			bandwidth, average, codecs, v.Width, v.Height)
+		if v.FrameRate > 0 {
+			fmt.Fprintf(bw, ",FRAME-RATE=%.3f", v.FrameRate)
+		}
+		if audio != nil {
+			fmt.Fprintf(bw, ",AUDIO=%q", audioGroupID)
+		}
+		fmt.Fprintln(bw)
+		fmt.Fprintln(bw, path.Join(v.ID, MediaPlaylist))
+	}
+	if len(videos) == 0 && audio != nil {
+		// 纯音频：音轨直接作为唯一的变体流
+		fmt.Fprintf(bw, "#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,CODECS=%q\n",
+			audio.Bandwidth, audio.AverageBandwidth, audio.Codecs)
+		fmt.Fprintln(bw, path.Join(audio.ID, MediaPlaylist))
+	}
+	return bw.Flush()
+}
+
+func writeFile(name string, write func(w io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,208 @@
+package hls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ormasia/swiftstream/internal/oss/cmaf"
+)
+
+// readFixture 读取 testdata/package.json：720p、360p 两条视频流与一条音轨，各 3 个分片
+func readFixture(t *testing.T) *cmaf.Presentation {
+	t.Helper()
+	pres, err := cmaf.ReadPresentation("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pres
+}
+
+func stream(t *testing.T, pres *cmaf.Presentation, id string) *cmaf.Stream {
+	t.Helper()
+	for i := range pres.Streams {
+		if pres.Streams[i].ID == id {
+			return &pres.Streams[i]
+		}
+	}
+	t.Fatalf("stream %s not found", id)
+	return nil
+}
+
+func TestWriteMediaPlaylist(t *testing.T) {
+	pres := readFixture(t)
+	var buf bytes.Buffer
+	if err := WriteMediaPlaylist(&buf, stream(t, pres, "video_720p"), nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:1
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:6.000000,
+seg_1.m4s
+#EXTINF:6.000000,
+seg_2.m4s
+#EXTINF:2.500000,
+seg_3.m4s
+#EXT-X-ENDLIST
+`
+	if got := buf.String(); got != want {
+		t.Errorf("media playlist:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteMasterPlaylist(t *testing.T) {
+	pres := readFixture(t)
+	var buf bytes.Buffer
+	if err := WriteMasterPlaylist(&buf, pres); err != nil {
+		t.Fatal(err)
+	}
+	// 视频流按码率从低到高排列，码率与编码包含音轨
+	want := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="default",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="audio/index.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1030000,AVERAGE-BANDWIDTH=928000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360,FRAME-RATE=29.970,AUDIO="audio"
+video_360p/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=3230000,AVERAGE-BANDWIDTH=2928000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720,FRAME-RATE=29.970,AUDIO="audio"
+video_720p/index.m3u8
+`
+	if got := buf.String(); got != want {
+		t.Errorf("master playlist:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteMasterPlaylistAudioOnly(t *testing.T) {
+	pres := readFixture(t)
+	pres.Streams = []cmaf.Stream{*stream(t, pres, "audio")}
+	var buf bytes.Buffer
+	if err := WriteMasterPlaylist(&buf, pres); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	if strings.Contains(got, "#EXT-X-MEDIA:") {
+		t.Errorf("audio-only master playlist has an audio rendition:\n%s", got)
+	}
+	if !strings.Contains(got, "#EXT-X-STREAM-INF:BANDWIDTH=130000,AVERAGE-BANDWIDTH=128000,CODECS=\"mp4a.40.2\"\naudio/index.m3u8\n") {
+		t.Errorf("audio-only master playlist:\n%s", got)
+	}
+}
+
+func TestWritePlaylists(t *testing.T) {
+	pres := readFixture(t)
+	dir := t.TempDir()
+	for _, s := range pres.Streams {
+		if err := os.MkdirAll(filepath.Join(dir, s.ID), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WritePlaylists(dir, pres, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		MasterPlaylist,
+		filepath.Join("video_720p", MediaPlaylist),
+		filepath.Join("video_360p", MediaPlaylist),
+		filepath.Join("audio", MediaPlaylist),
+	} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+}
+
+func TestKeyCount(t *testing.T) {
+	tests := []struct{ segments, rotation, want int }{
+		{10, 0, 1},
+		{3, 5, 1},
+		{10, 5, 2},
+		{11, 5, 3},
+	}
+	for _, tt := range tests {
+		if got := KeyCount(tt.segments, tt.rotation); got != tt.want {
+			t.Errorf("KeyCount(%d, %d) = %d, want %d", tt.segments, tt.rotation, got, tt.want)
+		}
+	}
+}
+
+func TestEncryptedMediaPlaylistRotatesKeys(t *testing.T) {
+	pres := readFixture(t)
+	enc := &Encryption{
+		Rotation: 2,
+		Keys:     []Key{{URI: "../../keys/a"}, {URI: "../../keys/b"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteMediaPlaylist(&buf, stream(t, pres, "video_360p"), enc); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	// 初始化分片不加密，EXT-X-MAP 在第一个 EXT-X-KEY 之前；第 3 个分片换用第二个密钥
+	want := `#EXT-X-MAP:URI="init.mp4"
+#EXT-X-KEY:METHOD=AES-128,URI="../../keys/a"
+#EXTINF:6.000000,
+seg_1.m4s
+#EXTINF:6.000000,
+seg_2.m4s
+#EXT-X-KEY:METHOD=AES-128,URI="../../keys/b"
+#EXTINF:2.500000,
+seg_3.m4s
+`
+	if !strings.Contains(got, want) {
+		t.Errorf("encrypted media playlist:\n%s\nwant it to contain:\n%s", got, want)
+	}
+}
+
+func TestEncryptSegments(t *testing.T) {
+	pres := readFixture(t)
+	pres.Streams = []cmaf.Stream{*stream(t, pres, "audio")}
+	s := &pres.Streams[0]
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, s.ID), 0755); err != nil {
+		t.Fatal(err)
+	}
+	plain := make([][]byte, len(s.Segments))
+	for n := range s.Segments {
+		plain[n] = bytes.Repeat([]byte{byte(n + 1)}, 100+n)
+		if err := os.WriteFile(filepath.Join(dir, s.ID, s.SegmentName(n+1)), plain[n], 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enc := &Encryption{
+		Rotation: 2,
+		Keys:     []Key{{Value: bytes.Repeat([]byte{1}, 16)}, {Value: bytes.Repeat([]byte{2}, 16)}},
+	}
+	if err := EncryptSegments(dir, pres, enc); err != nil {
+		t.Fatal(err)
+	}
+
+	for n := range s.Segments {
+		sealed, err := os.ReadFile(filepath.Join(dir, s.ID, s.SegmentName(n+1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Segments[n].Size != int64(len(sealed)) {
+			t.Errorf("segment %d: size %d, file has %d bytes", n+1, s.Segments[n].Size, len(sealed))
+		}
+		// 播放器按 EXT-X-KEY 选择密钥，以媒体序号为 IV 解密
+		block, err := aes.NewCipher(enc.Keys[enc.keyIndex(n)].Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		iv := make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint64(iv[8:], uint64(n+1))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(sealed, sealed)
+		pad := int(sealed[len(sealed)-1])
+		if got := sealed[:len(sealed)-pad]; !bytes.Equal(got, plain[n]) {
+			t.Errorf("segment %d does not decrypt to the original data", n+1)
+		}
+	}
+}
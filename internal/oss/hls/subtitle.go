@@ -0,0 +1,78 @@
+package hls
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// SubtitleGroup 是主播放列表中字幕渲染组的 GROUP-ID
+const SubtitleGroup = "subs"
+
+// Subtitle 是主播放列表中的一个字幕渲染
+type Subtitle struct {
+	Language string // BCP 47 语言标签
+	Name     string // 播放器中显示的名称
+	Default  bool
+	URI      string // 字幕媒体播放列表地址
+}
+
+// AddSubtitles 在主播放列表中加入字幕渲染：在第一个 EXT-X-STREAM-INF 前写入 EXT-X-MEDIA，
+// 并给每个变体流加上 SUBTITLES 属性；没有字幕时原样返回
+func AddSubtitles(master []byte, subs []Subtitle) []byte {
+	if len(subs) == 0 {
+		return master
+	}
+	var media bytes.Buffer
+	for _, s := range subs {
+		name := s.Name
+		if name == "" {
+			name = s.Language
+		}
+		fmt.Fprintf(&media, "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"%s\",LANGUAGE=\"%s\",NAME=\"%s\",DEFAULT=%s,AUTOSELECT=YES,URI=\"%s\"\n",
+			SubtitleGroup, s.Language, quotedString(name), yesNo(s.Default), s.URI)
+	}
+
+	var out bytes.Buffer
+	inserted := false
+	for _, line := range strings.SplitAfter(string(master), "\n") {
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			if !inserted {
+				out.Write(media.Bytes())
+				inserted = true
+			}
+			body, newline := strings.CutSuffix(line, "\n")
+			line = body + fmt.Sprintf(",SUBTITLES=%q", SubtitleGroup)
+			if newline {
+				line += "\n"
+			}
+		}
+		out.WriteString(line)
+	}
+	return out.Bytes()
+}
+
+// WriteSubtitlePlaylist 写出字幕媒体播放列表：整个 WebVTT 文件作为一个分片
+func WriteSubtitlePlaylist(w io.Writer, uri string, duration float64) error {
+	return WritePlaylist(w, &Playlist{
+		Version:        3,
+		TargetDuration: int(math.Ceil(duration)),
+		Type:           "VOD",
+		Segments:       []PlaylistSegment{{URI: uri, Duration: duration}},
+		Ended:          true,
+	})
+}
+
+// quotedString 去掉属性值中不允许出现的双引号和换行
+func quotedString(s string) string {
+	return strings.NewReplacer(`"`, "'", "\n", " ", "\r", " ").Replace(s)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "YES"
+	}
+	return "NO"
+}
@@ -0,0 +1,201 @@
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // 注册 WebP 解码器
+)
+
+// 缩放适配方式
+const (
+	FitCover   = "cover"   // 等比缩放填满目标尺寸，居中裁剪多余部分
+	FitContain = "contain" // 等比缩放完整放入目标尺寸内
+	FitFill    = "fill"    // 拉伸到目标尺寸，不保持宽高比
+)
+
+// 输出编码格式
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// DefaultQuality 是未指定时的 JPEG 编码质量
+const DefaultQuality = 85
+
+var ErrUnsupportedFormat = errors.New("imaging: unsupported output format")
+
+// Options 描述一次缩放/转码操作
+type Options struct {
+	Width   int    `json:"width"`   // 目标宽度，0 表示按高度等比计算
+	Height  int    `json:"height"`  // 目标高度，0 表示按宽度等比计算
+	Fit     string `json:"fit"`     // cover/contain/fill，默认 contain
+	Format  string `json:"format"`  // jpeg/png，默认 jpeg
+	Quality int    `json:"quality"` // JPEG 质量 1-100
+}
+
+// Variant 是一个命名的衍生图规格
+type Variant struct {
+	Name string `json:"name"`
+	Options
+}
+
+// DefaultVariants 是默认生成的衍生图规格
+var DefaultVariants = []Variant{
+	{Name: "128", Options: Options{Width: 128, Height: 128, Fit: FitCover, Format: FormatJPEG, Quality: 80}},
+	{Name: "360", Options: Options{Width: 360, Fit: FitContain, Format: FormatJPEG, Quality: DefaultQuality}},
+	{Name: "720", Options: Options{Width: 720, Fit: FitContain, Format: FormatJPEG, Quality: DefaultQuality}},
+}
+
+// Normalize 补全默认值并校验参数
+func (o Options) Normalize() (Options, error) {
+	if o.Width < 0 || o.Height < 0 || (o.Width == 0 && o.Height == 0) {
+		return o, fmt.Errorf("imaging: invalid size %dx%d", o.Width, o.Height)
+	}
+	switch o.Fit {
+	case "":
+		o.Fit = FitContain
+	case FitCover, FitContain, FitFill:
+	default:
+		return o, fmt.Errorf("imaging: invalid fit %q", o.Fit)
+	}
+	switch o.Format {
+	case "", "jpg":
+		o.Format = FormatJPEG
+	case FormatJPEG, FormatPNG:
+	default:
+		return o, ErrUnsupportedFormat
+	}
+	if o.Quality <= 0 || o.Quality > 100 {
+		o.Quality = DefaultQuality
+	}
+	if o.Format != FormatJPEG {
+		o.Quality = 0
+	}
+	return o, nil
+}
+
+// DefaultMaxPixels 是解码图片默认的像素数上限
+const DefaultMaxPixels = 50_000_000
+
+// ErrTooManyPixels 图片尺寸超过像素数上限，很小的文件也可能声明巨大的尺寸（解压炸弹）
+var ErrTooManyPixels = errors.New("imaging: image exceeds the pixel limit")
+
+// Open 解码 path 指向的 JPEG/PNG/GIF/WebP 图片，先读取头部中的尺寸，像素数超过 maxPixels 时不解码
+func Open(path string, maxPixels int) (image.Image, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, "", ErrTooManyPixels
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	return Decode(f)
+}
+
+// Decode 从 r 解码 JPEG/PNG/GIF/WebP 图片，不限制尺寸，调用方需要先用 image.DecodeConfig 校验像素数
+func Decode(r io.Reader) (image.Image, string, error) {
+	return image.Decode(r)
+}
+
+// Transform 按 Options 缩放（必要时裁剪）图片，不会放大原图
+func Transform(src image.Image, o Options) image.Image {
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	if sw == 0 || sh == 0 {
+		return src
+	}
+
+	// 只指定一边时，另一边按原图比例计算
+	tw, th := o.Width, o.Height
+	if tw == 0 {
+		tw = max(1, sw*th/sh)
+	}
+	if th == 0 {
+		th = max(1, sh*tw/sw)
+	}
+
+	crop := sb
+	switch o.Fit {
+	case FitFill:
+	case FitCover:
+		// 先按目标比例居中裁剪原图，再缩放
+		if sw*th > sh*tw {
+			cw := sh * tw / th
+			crop = image.Rect(sb.Min.X+(sw-cw)/2, sb.Min.Y, sb.Min.X+(sw-cw)/2+cw, sb.Max.Y)
+		} else {
+			ch := sw * th / tw
+			crop = image.Rect(sb.Min.X, sb.Min.Y+(sh-ch)/2, sb.Max.X, sb.Min.Y+(sh-ch)/2+ch)
+		}
+		if tw > crop.Dx() || th > crop.Dy() {
+			tw, th = crop.Dx(), crop.Dy()
+		}
+	default: // FitContain
+		if sw*th > sh*tw {
+			th = max(1, sh*tw/sw)
+		} else {
+			tw = max(1, sw*th/sh)
+		}
+		if tw > sw || th > sh {
+			tw, th = sw, sh
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+	return dst
+}
+
+// Encode 按格式编码图片；JPEG 不支持透明通道，先铺白底
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case FormatJPEG:
+		if quality <= 0 {
+			quality = DefaultQuality
+		}
+		return jpeg.Encode(w, flatten(img), &jpeg.Options{Quality: quality})
+	case FormatPNG:
+		return png.Encode(w, img)
+	default:
+		return ErrUnsupportedFormat
+	}
+}
+
+// Ext 返回格式对应的文件扩展名
+func Ext(format string) string {
+	if format == FormatJPEG {
+		return "jpg"
+	}
+	return format
+}
+
+// MimeType 返回格式对应的 MIME 类型
+func MimeType(format string) string {
+	return "image/" + format
+}
+
+func flatten(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
@@ -0,0 +1,44 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenPixelLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	tests := []struct {
+		name      string
+		maxPixels int
+		err       error
+	}{
+		{"within limit", 1200, nil},
+		{"over limit", 1199, ErrTooManyPixels},
+	}
+	for _, tt := range tests {
+		img, format, err := Open(path, tt.maxPixels)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && (format != "png" || img.Bounds().Dx() != 40 || img.Bounds().Dy() != 30) {
+			t.Errorf("%s: decoded %s %v", tt.name, format, img.Bounds())
+		}
+	}
+	if _, _, err := Open(filepath.Join(t.TempDir(), "missing.png"), DefaultMaxPixels); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: err = %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+)
+
+// 计算占位信息前先把图片缩小，结果只取决于整体色彩分布，缩小后计算量与原图尺寸无关
+const (
+	blurHashSampleSize = 32
+	paletteSampleSize  = 64
+)
+
+// DefaultPaletteSize 默认提取的主色数量
+const DefaultPaletteSize = 5
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// BlurHash 计算图片的 BlurHash，横图使用 4x3 个分量，竖图使用 3x4 个分量
+func BlurHash(img image.Image) string {
+	small := flatten(Transform(img, Options{Width: blurHashSampleSize, Height: blurHashSampleSize, Fit: FitContain}))
+	b := small.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return ""
+	}
+	xComp, yComp := 4, 3
+	if h > w {
+		xComp, yComp = 3, 4
+	}
+
+	// 先把像素转为线性 RGB，避免对每个分量重复换算
+	linear := make([][3]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBAModel.Convert(small.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			linear[y*w+x] = [3]float64{srgbToLinear(c.R), srgbToLinear(c.G), srgbToLinear(c.B)}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComp*yComp)
+	for j := 0; j < yComp; j++ {
+		for i := 0; i < xComp; i++ {
+			var f [3]float64
+			norm := 2.0
+			if i == 0 && j == 0 {
+				norm = 1
+			}
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := norm * math.Cos(math.Pi*float64(i*x)/float64(w)) * math.Cos(math.Pi*float64(j*y)/float64(h))
+					p := linear[y*w+x]
+					f[0] += basis * p[0]
+					f[1] += basis * p[1]
+					f[2] += basis * p[2]
+				}
+			}
+			scale := 1 / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var sb strings.Builder
+	encodeBase83(&sb, (xComp-1)+(yComp-1)*9, 1)
+
+	dc, ac := factors[0], factors[1:]
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = max(actualMax, math.Abs(f[0]), math.Abs(f[1]), math.Abs(f[2]))
+		}
+		quantised := int(max(0, min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantised+1) / 166
+		encodeBase83(&sb, quantised, 1)
+	} else {
+		encodeBase83(&sb, 0, 1)
+	}
+
+	encodeBase83(&sb, linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4)
+	for _, f := range ac {
+		q := func(v float64) int {
+			return int(max(0, min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		encodeBase83(&sb, q(f[0])*19*19+q(f[1])*19+q(f[2]), 2)
+	}
+	return sb.String()
+}
+
+func encodeBase83(sb *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		sb.WriteByte(base83Chars[digit])
+	}
+}
+
+func srgbToLinear(c uint8) float64 {
+	v := float64(c) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = max(0, min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+// Palette 用中位切分法提取图片的主色，按覆盖像素数从多到少排列，第一个即主色调
+// 透明度低于一半的像素不参与统计
+func Palette(img image.Image, n int) []color.RGBA {
+	small := Transform(img, Options{Width: paletteSampleSize, Height: paletteSampleSize, Fit: FitContain})
+	b := small.Bounds()
+	pixels := make([][3]uint8, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(small.At(x, y)).(color.NRGBA)
+			if c.A >= 128 {
+				pixels = append(pixels, [3]uint8{c.R, c.G, c.B})
+			}
+		}
+	}
+	if len(pixels) == 0 || n <= 0 {
+		return nil
+	}
+
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < n {
+		// 选择通道跨度最大的盒子沿该通道的中位数切开
+		best, bestChannel, bestRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			channel, r := widestChannel(box)
+			if r > bestRange {
+				best, bestChannel, bestRange = i, channel, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+		box := boxes[best]
+		sort.Slice(box, func(i, j int) bool {
+			return box[i][bestChannel] < box[j][bestChannel]
+		})
+		mid := splitIndex(box, bestChannel)
+		boxes[best] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	sort.SliceStable(boxes, func(i, j int) bool {
+		return len(boxes[i]) > len(boxes[j])
+	})
+	colors := make([]color.RGBA, 0, len(boxes))
+	for _, box := range boxes {
+		var sum [3]int
+		for _, p := range box {
+			sum[0] += int(p[0])
+			sum[1] += int(p[1])
+			sum[2] += int(p[2])
+		}
+		colors = append(colors, color.RGBA{
+			R: uint8(sum[0] / len(box)),
+			G: uint8(sum[1] / len(box)),
+			B: uint8(sum[2] / len(box)),
+			A: 255,
+		})
+	}
+	return colors
+}
+
+// splitIndex 返回已排序盒子中离中位数最近的取值变化位置，避免把同一种颜色切到两个盒子里
+// 调用方保证该通道跨度大于 0，因此一定存在变化位置
+func splitIndex(box [][3]uint8, channel int) int {
+	mid := len(box) / 2
+	for d := 0; ; d++ {
+		if i := mid - d; i > 0 && box[i][channel] != box[i-1][channel] {
+			return i
+		}
+		if i := mid + d; i < len(box) && box[i][channel] != box[i-1][channel] {
+			return i
+		}
+	}
+}
+
+// widestChannel 返回盒子中取值跨度最大的通道及其跨度
+func widestChannel(box [][3]uint8) (int, int) {
+	lo := [3]uint8{255, 255, 255}
+	var hi [3]uint8
+	for _, p := range box {
+		for c := 0; c < 3; c++ {
+			lo[c], hi[c] = min(lo[c], p[c]), max(hi[c], p[c])
+		}
+	}
+	channel, r := 0, 0
+	for c := 0; c < 3; c++ {
+		if d := int(hi[c]) - int(lo[c]); d > r {
+			channel, r = c, d
+		}
+	}
+	return channel, r
+}
+
+// Hex 把颜色格式化为 #rrggbb
+func Hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,318 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/probe"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+	"github.com/ormasia/swiftstream/internal/oss/trash"
+	"gorm.io/gorm"
+)
+
+// 导入结果
+const (
+	ResultImported = "imported" // 复制文件并创建对象
+	ResultLinked   = "linked"   // 内容与已有对象相同，新对象共用已有文件
+	ResultSkipped  = "skipped"  // 对象键的当前版本内容相同，不重复导入
+	ResultExists   = "exists"   // 对象键已存在且未开启覆盖
+	ResultFailed   = "failed"
+)
+
+// 对象键的最大长度，与上传接口一致
+const maxObjectKeyLength = 1024
+
+var ErrChanged = errors.New("ingest: source file changed during import")
+
+// Item 要导入的一个源文件
+type Item struct {
+	Source     string            // 源文件路径
+	Bucket     string            // 为空时使用 default
+	ObjectKey  string            // 对象键
+	UserID     uint              // 对象所有者
+	BusinessID string            // 业务关联ID
+	Metadata   map[string]string // 自定义元数据
+}
+
+// Record 是日志中的一条导入结果
+type Record struct {
+	Time      time.Time `json:"time"`
+	Source    string    `json:"source"`
+	Bucket    string    `json:"bucket"`
+	ObjectKey string    `json:"object_key"`
+	Result    string    `json:"result"`
+	ObjectID  uint      `json:"object_id,omitempty"`
+	ETag      string    `json:"etag,omitempty"`
+	Size      int64     `json:"size"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Summary 统计一次导入的结果
+type Summary struct {
+	Results map[string]int
+	Bytes   int64 // 复制的字节数，不含共用已有文件的对象
+}
+
+// Config 导入配置
+type Config struct {
+	Workers   int  // 并发计算校验和的数量，写入数据库与复制文件串行执行
+	Overwrite bool // 对象键已存在时写入新版本，未开启版本控制的存储桶中旧版本移入回收站
+	DryRun    bool // 只计算校验和并报告结果，不复制文件也不创建对象
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{Workers: 4}
+}
+
+// Importer 把已有文件导入 OSS：计算 MD5，按 ETag 与 oss_objects 去重，
+// 复制到 data/files/versions/<versionID>/ 下并创建对象记录，结果写入日志以便中断后续传
+// 导入的对象直接生效，不经过扫描，也不生成衍生对象
+type Importer struct {
+	db      *gorm.DB
+	cfg     Config
+	journal *Journal
+	trash   *trash.Service
+}
+
+func NewImporter(db *gorm.DB, cfg Config, journal *Journal, trash *trash.Service) *Importer {
+	return &Importer{db: db, cfg: cfg, journal: journal, trash: trash}
+}
+
+// hashed 是计算过校验和的源文件
+type hashed struct {
+	item Item
+	etag string
+	size int64
+	err  error
+}
+
+// Run 导入 items 中的源文件，日志中已完成的源文件被跳过，items 关闭后返回统计结果
+func (im *Importer) Run(ctx context.Context, items <-chan Item) Summary {
+	summary := Summary{Results: make(map[string]int)}
+	results := make(chan hashed)
+	var wg sync.WaitGroup
+	for range max(im.cfg.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				if im.journal != nil && im.journal.Done(item.Source) {
+					continue
+				}
+				etag, size, err := storage.Checksum(item.Source)
+				select {
+				case results <- hashed{item: item, etag: etag, size: size, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for h := range results {
+		record := im.commit(ctx, h)
+		summary.Results[record.Result]++
+		if record.Result == ResultImported {
+			summary.Bytes += record.Size
+		}
+		if record.Result == ResultFailed {
+			log.Printf("Failed to import %s: %s\n", record.Source, record.Error)
+		}
+		if im.cfg.DryRun {
+			log.Printf("[dry run] %s -> %s/%s: %s\n", record.Source, record.Bucket, record.ObjectKey, record.Result)
+			continue
+		}
+		if im.journal != nil {
+			if err := im.journal.Append(record); err != nil {
+				log.Printf("Failed to write journal: %v\n", err)
+			}
+		}
+		if n := summary.Total(); n%1000 == 0 {
+			log.Printf("Import progress: %d files, %v\n", n, summary.Results)
+		}
+	}
+	return summary
+}
+
+// Total 返回处理的源文件数
+func (s Summary) Total() int {
+	n := 0
+	for _, v := range s.Results {
+		n += v
+	}
+	return n
+}
+
+// commit 去重、复制文件并创建对象，只在一个 goroutine 中执行，避免同一内容被并发复制
+func (im *Importer) commit(ctx context.Context, h hashed) Record {
+	item := h.item
+	if item.Bucket == "" {
+		item.Bucket = model.DefaultBucket
+	}
+	record := Record{Time: time.Now(), Source: item.Source, Bucket: item.Bucket, ObjectKey: item.ObjectKey, ETag: h.etag, Size: h.size}
+	fail := func(err error) Record {
+		record.Result = ResultFailed
+		record.Error = err.Error()
+		return record
+	}
+	if h.err != nil {
+		return fail(h.err)
+	}
+	if item.ObjectKey == "" || len(item.ObjectKey) > maxObjectKeyLength || strings.HasPrefix(item.ObjectKey, "/") {
+		return fail(fmt.Errorf("invalid object key %q", item.ObjectKey))
+	}
+
+	now := time.Now()
+	current, err := repo.GetCurrentVersion(im.db, item.Bucket, item.ObjectKey)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fail(err)
+	}
+	versioned := false
+	if bucket, err := repo.GetBucket(im.db, item.Bucket); err == nil {
+		versioned = bucket.Versioning == model.VersioningEnabled
+	}
+	if current != nil && !current.DeleteMarker {
+		if current.ETag == h.etag {
+			record.Result = ResultSkipped
+			record.ObjectID = current.ID
+			return record
+		}
+		if !im.cfg.Overwrite {
+			record.Result = ResultExists
+			record.ObjectID = current.ID
+			return record
+		}
+		if !versioned && current.Locked(now) {
+			return fail(trash.ErrLocked)
+		}
+	}
+
+	object := model.OssObject{
+		FileName:   path.Base(item.ObjectKey),
+		FileSize:   h.size,
+		Bucket:     item.Bucket,
+		ObjectKey:  item.ObjectKey,
+		VersionID:  uuid.New().String(),
+		ETag:       h.etag,
+		UserID:     item.UserID,
+		BusinessID: item.BusinessID,
+		Status:     model.StatusActive,
+	}
+	object.URL = fmt.Sprintf("/files/%s", object.FileName)
+
+	// 相同内容的对象已在本地存储中时共用其文件，清理时按引用计数删除
+	record.Result = ResultImported
+	existing, err := repo.GetObjectByEtag(im.db, h.etag)
+	if err == nil && existing.StorageClass == "" && existing.FileSize == h.size {
+		object.StoragePath = existing.FilePath()
+		record.Result = ResultLinked
+	} else {
+		object.StoragePath = filepath.Join("data", "files", "versions", object.VersionID, object.FileName)
+	}
+	detectType(&object, item.Source)
+	if im.cfg.DryRun {
+		return record
+	}
+
+	if record.Result == ResultImported {
+		if err := copyFile(ctx, item.Source, object.StoragePath, h.etag); err != nil {
+			return fail(err)
+		}
+	}
+	if err := repo.CreateObject(im.db, &object); err != nil {
+		if record.Result == ResultImported {
+			os.RemoveAll(filepath.Dir(object.StoragePath))
+		}
+		return fail(err)
+	}
+	record.ObjectID = object.ID
+	if err := repo.CreateObjectMetadata(im.db, object.ID, item.Metadata); err != nil {
+		return fail(err)
+	}
+	superseded, err := repo.SetCurrentVersion(im.db, &object, now)
+	if err != nil {
+		return fail(err)
+	}
+	if !versioned && im.trash != nil {
+		for _, previous := range superseded {
+			if previous.DeleteMarker {
+				continue
+			}
+			if err := im.trash.Delete(&previous); err != nil {
+				log.Printf("Failed to move overwritten object %d to trash: %v\n", previous.ID, err)
+			}
+		}
+	}
+	return record
+}
+
+// copyFile 把源文件复制到 dst，复制的内容与计算校验和时不一致时放弃
+func copyFile(ctx context.Context, src, dst, etag string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	written, _, err := storage.WriteFile(dst, func(w io.Writer) error {
+		_, err := io.Copy(w, storage.ContextReader(ctx, f))
+		return err
+	})
+	if err == nil && written != etag {
+		os.Remove(dst)
+		err = ErrChanged
+	}
+	if err != nil {
+		// 删除空的版本目录
+		os.Remove(filepath.Dir(dst))
+		return err
+	}
+	return nil
+}
+
+// detectType 探测媒体文件的类型与编码信息，非媒体文件按扩展名或内容推断 MIME 类型
+func detectType(object *model.OssObject, source string) {
+	if info, err := probe.File(source); err == nil {
+		object.FileType = info.Kind
+		object.MimeType = info.MimeType
+		object.Width = info.Width
+		object.Height = info.Height
+		object.Duration = int(math.Round(info.Duration))
+		object.VideoCodec = info.VideoCodec
+		object.AudioCodec = info.AudioCodec
+		object.Bitrate = info.Bitrate
+		object.FrameRate = info.FrameRate
+		return
+	}
+	mimeType := mime.TypeByExtension(filepath.Ext(source))
+	if mimeType == "" {
+		if f, err := os.Open(source); err == nil {
+			buf := make([]byte, 512)
+			n, _ := io.ReadFull(f, buf)
+			f.Close()
+			mimeType = http.DetectContentType(buf[:n])
+		}
+	}
+	// 与上传接口一致，非媒体文件的 FileType 为 MIME 类型
+	object.FileType = mimeType
+	object.MimeType = mimeType
+}
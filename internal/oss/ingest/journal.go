@@ -0,0 +1,68 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// Journal 以 JSON Lines 追加记录每个源文件的导入结果，中断后重新运行时跳过已完成的源文件
+type Journal struct {
+	mu   sync.Mutex
+	f    *os.File
+	enc  *json.Encoder
+	done map[string]bool
+}
+
+// OpenJournal 读取已有的记录并以追加方式打开日志，失败的源文件在下次运行时重试
+func OpenJournal(path string) (*Journal, error) {
+	done := make(map[string]bool)
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var r Record
+			// 进程中断时最后一行可能不完整，忽略无法解析的行
+			if json.Unmarshal(scanner.Bytes(), &r) != nil {
+				continue
+			}
+			done[r.Source] = r.Result != ResultFailed
+		}
+		err := scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{f: f, enc: json.NewEncoder(f), done: done}, nil
+}
+
+// Done 返回源文件是否已在之前的运行中完成
+func (j *Journal) Done(source string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.done[source]
+}
+
+// Append 追加一条记录
+func (j *Journal) Append(r Record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.enc.Encode(r); err != nil {
+		return err
+	}
+	j.done[r.Source] = r.Result != ResultFailed
+	return nil
+}
+
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
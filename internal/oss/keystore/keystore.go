@@ -0,0 +1,140 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"gorm.io/gorm"
+)
+
+// KeySize 是 HLS AES-128 内容密钥的长度
+const KeySize = 16
+
+var ErrInvalidMasterKey = errors.New("keystore: master key must be 32 bytes")
+
+// Key 是解密后的内容密钥
+type Key struct {
+	ID       string
+	Sequence int
+	Value    []byte
+}
+
+// Keystore 生成并保存 HLS 内容密钥，密钥用主密钥（AES-256-GCM）加密后入库
+type Keystore struct {
+	db   *gorm.DB
+	aead cipher.AEAD
+}
+
+// New 创建 Keystore，masterKey 为 32 字节的主密钥
+func New(db *gorm.DB, masterKey []byte) (*Keystore, error) {
+	if len(masterKey) != 32 {
+		return nil, ErrInvalidMasterKey
+	}
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Keystore{db: db, aead: aead}, nil
+}
+
+// ParseMasterKey 解析十六进制编码的主密钥
+func ParseMasterKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil || len(key) != 32 {
+		return nil, ErrInvalidMasterKey
+	}
+	return key, nil
+}
+
+// Generate 为对象生成 n 个随机内容密钥并保存
+func (k *Keystore) Generate(objectID uint, n int) ([]Key, error) {
+	keys := make([]Key, n)
+	records := make([]model.HLSKey, n)
+	for i := range keys {
+		value := make([]byte, KeySize)
+		id := make([]byte, 16)
+		if _, err := rand.Read(value); err != nil {
+			return nil, err
+		}
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		keys[i] = Key{ID: hex.EncodeToString(id), Sequence: i, Value: value}
+		sealed, err := k.seal(objectID, keys[i].ID, value)
+		if err != nil {
+			return nil, err
+		}
+		records[i] = model.HLSKey{ObjectID: objectID, KeyID: keys[i].ID, Sequence: i, EncryptedKey: sealed}
+	}
+	if err := repo.CreateHLSKeys(k.db, records); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Get 读取并解密对象的内容密钥
+func (k *Keystore) Get(objectID uint, keyID string) (*Key, error) {
+	record, err := repo.GetHLSKey(k.db, objectID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	value, err := k.open(objectID, keyID, record.EncryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{ID: record.KeyID, Sequence: record.Sequence, Value: value}, nil
+}
+
+// Delete 删除对象的指定密钥，用于打包失败后清理新生成的密钥
+func (k *Keystore) Delete(objectID uint, keys []Key) error {
+	return repo.DeleteHLSKeys(k.db, objectID, keyIDs(keys))
+}
+
+// DeleteExcept 删除对象除 keep 以外的所有密钥，用于重新打包后清理旧密钥
+func (k *Keystore) DeleteExcept(objectID uint, keep []Key) error {
+	return repo.DeleteHLSKeysExcept(k.db, objectID, keyIDs(keep))
+}
+
+func keyIDs(keys []Key) []string {
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = key.ID
+	}
+	return ids
+}
+
+// seal 加密内容密钥，附加数据绑定对象ID与密钥ID，防止密文被挪用到其他对象
+func (k *Keystore) seal(objectID uint, keyID string, value []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, value, associatedData(objectID, keyID)), nil
+}
+
+func (k *Keystore) open(objectID uint, keyID string, sealed []byte) ([]byte, error) {
+	n := k.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, fmt.Errorf("keystore: key %s is corrupted", keyID)
+	}
+	value, err := k.aead.Open(nil, sealed[:n], sealed[n:], associatedData(objectID, keyID))
+	if err != nil {
+		return nil, fmt.Errorf("keystore: decrypt key %s: %w", keyID, err)
+	}
+	return value, nil
+}
+
+func associatedData(objectID uint, keyID string) []byte {
+	return []byte(strconv.FormatUint(uint64(objectID), 10) + ":" + keyID)
+}
@@ -0,0 +1,262 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+	"github.com/ormasia/swiftstream/internal/oss/trash"
+	"gorm.io/gorm"
+)
+
+// 生命周期动作
+const (
+	ActionExpire      = "expire"       // 对象移入回收站
+	ActionTransition  = "transition"   // 对象文件转移到冷存储
+	ActionAbortUpload = "abort_upload" // 取消未完成的上传并删除已上传的分片
+)
+
+var ErrColdStorageDisabled = errors.New("lifecycle: cold storage is not configured")
+
+// Config 生命周期任务配置
+type Config struct {
+	Interval  time.Duration // 执行规则的间隔
+	BatchSize int           // 每条规则每个动作每轮最多处理的数量
+	DryRun    bool          // 定时任务只生成报告，不执行动作
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		Interval:  time.Hour,
+		BatchSize: 500,
+	}
+}
+
+// Action 是报告中的一项
+type Action struct {
+	RuleID   uint   `json:"rule_id"`
+	Action   string `json:"action"`
+	Bucket   string `json:"bucket"`
+	Key      string `json:"key,omitempty"`
+	ObjectID uint   `json:"object_id,omitempty"`
+	UploadID string `json:"upload_id,omitempty"`
+	Size     int64  `json:"size"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report 是一轮规则执行的结果，DryRun 时只列出将要执行的动作
+type Report struct {
+	DryRun     bool      `json:"dry_run"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Actions    []Action  `json:"actions"`
+}
+
+// Service 定时执行存储桶的生命周期规则
+type Service struct {
+	db    *gorm.DB
+	cfg   Config
+	trash *trash.Service
+	cold  storage.Driver // 冷存储，为 nil 时跳过转移动作
+
+	mu sync.Mutex // 同一时间只执行一轮
+}
+
+func NewService(db *gorm.DB, cfg Config, trash *trash.Service, cold storage.Driver) *Service {
+	return &Service{db: db, cfg: cfg, trash: trash, cold: cold}
+}
+
+// Start 启动定时任务
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := s.Run(ctx, "", s.cfg.DryRun)
+				if err != nil {
+					log.Printf("Failed to run lifecycle rules: %v\n", err)
+					continue
+				}
+				if len(report.Actions) > 0 {
+					log.Printf("Lifecycle run finished with %d actions (dry run: %v)\n", len(report.Actions), report.DryRun)
+				}
+			}
+		}
+	}()
+}
+
+// Run 执行已启用的生命周期规则，bucket 为空时执行所有存储桶的规则
+// dryRun 为 true 时不修改任何对象，只返回将要执行的动作
+func (s *Service) Run(ctx context.Context, bucket string, dryRun bool) (*Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules, err := repo.ListEnabledLifecycleRules(s.db)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{DryRun: dryRun, StartedAt: time.Now(), Actions: []Action{}}
+	// 同一对象只执行一个动作，过期优先于转移
+	done := make(map[uint]bool)
+	for i := range rules {
+		rule := &rules[i]
+		if bucket != "" && rule.Bucket != bucket {
+			continue
+		}
+		if rule.ExpirationDays > 0 {
+			if err := s.expire(rule, report, done); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for i := range rules {
+		rule := &rules[i]
+		if bucket != "" && rule.Bucket != bucket {
+			continue
+		}
+		if rule.TransitionDays > 0 && s.cold != nil {
+			if err := s.transition(ctx, rule, report, done); err != nil {
+				return nil, err
+			}
+		}
+		if rule.AbortIncompleteUploadDays > 0 {
+			if err := s.abortUploads(rule, report); err != nil {
+				return nil, err
+			}
+		}
+	}
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+func daysAgo(now time.Time, days int) time.Time {
+	return now.Add(-time.Duration(days) * 24 * time.Hour)
+}
+
+// expire 把匹配规则的对象移入回收站，保留期内仍可恢复；锁定的对象跳过并在报告中记录原因
+func (s *Service) expire(rule *model.LifecycleRule, report *Report, done map[uint]bool) error {
+	objects, err := repo.ListLifecycleObjects(s.db, rule, daysAgo(report.StartedAt, rule.ExpirationDays), false, s.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if done[object.ID] {
+			continue
+		}
+		done[object.ID] = true
+		action := Action{RuleID: rule.ID, Action: ActionExpire, Bucket: object.Bucket, Key: object.ObjectKey, ObjectID: object.ID, Size: object.FileSize}
+		if object.Locked(report.StartedAt) {
+			action.Error = trash.ErrLocked.Error()
+		} else if !report.DryRun {
+			if s.trash == nil {
+				action.Error = "deletion is disabled"
+			} else if err := s.trash.Delete(&object); err != nil {
+				action.Error = err.Error()
+			}
+		}
+		report.Actions = append(report.Actions, action)
+	}
+	return nil
+}
+
+// transition 把匹配规则的对象文件复制到冷存储，没有其他对象引用本地文件时删除本地文件
+// 衍生对象（缩略图、HLS 等）保留在本地，播放不受影响；下载源对象时从冷存储读取
+// 锁定的对象作为证据保留在原位置，不做转移
+func (s *Service) transition(ctx context.Context, rule *model.LifecycleRule, report *Report, done map[uint]bool) error {
+	objects, err := repo.ListLifecycleObjects(s.db, rule, daysAgo(report.StartedAt, rule.TransitionDays), true, s.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if done[object.ID] {
+			continue
+		}
+		done[object.ID] = true
+		action := Action{RuleID: rule.ID, Action: ActionTransition, Bucket: object.Bucket, Key: object.ObjectKey, ObjectID: object.ID, Size: object.FileSize}
+		if object.Locked(report.StartedAt) {
+			action.Error = trash.ErrLocked.Error()
+		} else if !report.DryRun {
+			if err := s.moveToCold(ctx, &object); err != nil {
+				action.Error = err.Error()
+			}
+		}
+		report.Actions = append(report.Actions, action)
+	}
+	return nil
+}
+
+func (s *Service) moveToCold(ctx context.Context, object *model.OssObject) error {
+	if s.cold == nil {
+		return ErrColdStorageDisabled
+	}
+	path := object.FilePath()
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = s.cold.Put(ctx, object.ColdKey(), f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if err := repo.UpdateObjectStorageClass(s.db, object.ID, model.StorageClassCold); err != nil {
+		s.cold.Delete(ctx, object.ColdKey())
+		return err
+	}
+	n, err := repo.CountStorageReferences(s.db, path, []uint{object.ID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	log.Printf("Object %d moved to cold storage %s\n", object.ID, s.cold.Name())
+	return nil
+}
+
+// abortUploads 取消长时间未完成的上传，删除分片记录与已上传的分片
+func (s *Service) abortUploads(rule *model.LifecycleRule, report *Report) error {
+	tasks, err := repo.ListStaleUploadTasks(s.db, rule, daysAgo(report.StartedAt, rule.AbortIncompleteUploadDays), s.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		action := Action{RuleID: rule.ID, Action: ActionAbortUpload, Bucket: rule.Bucket, Key: task.ObjectKey, UploadID: task.UploadID, Size: task.FileSize}
+		if !report.DryRun {
+			if err := s.abortUpload(&task); err != nil {
+				action.Error = err.Error()
+			}
+		}
+		report.Actions = append(report.Actions, action)
+	}
+	return nil
+}
+
+func (s *Service) abortUpload(task *model.UploadTask) error {
+	task.Status = "cancelled"
+	if err := repo.SaveUploadTask(s.db, task); err != nil {
+		return err
+	}
+	if err := repo.DeleteChunkRecords(s.db, task.UploadID); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join("data", "uploads", task.UploadID)); err != nil {
+		return err
+	}
+	log.Printf("Upload %s aborted by lifecycle rule\n", task.UploadID)
+	return nil
+}
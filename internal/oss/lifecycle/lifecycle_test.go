@@ -0,0 +1,141 @@
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+	"github.com/ormasia/swiftstream/internal/oss/trash"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := repo.CreateTable(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// createObject 创建 10 天前写入的对象
+func createObject(t *testing.T, db *gorm.DB, key string, object model.OssObject) *model.OssObject {
+	t.Helper()
+	object.FileName = filepath.Base(key)
+	object.Bucket = model.DefaultBucket
+	object.ObjectKey = key
+	object.StoragePath = filepath.Join(t.TempDir(), object.FileName)
+	object.Status = model.StatusActive
+	object.CreatedAt = time.Now().Add(-10 * 24 * time.Hour)
+	if err := os.WriteFile(object.StoragePath, []byte(key), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateObject(db, &object); err != nil {
+		t.Fatal(err)
+	}
+	return &object
+}
+
+func getObject(t *testing.T, db *gorm.DB, id uint) *model.OssObject {
+	t.Helper()
+	var object model.OssObject
+	if err := db.Unscoped().First(&object, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &object
+}
+
+func TestRunSkipsLockedObjects(t *testing.T) {
+	db := openTestDB(t)
+	cold := storage.NewLocalDriver(t.TempDir())
+	s := NewService(db, DefaultConfig(), trash.NewService(db, trash.DefaultConfig()), cold)
+	if err := repo.ReplaceLifecycleRules(db, model.DefaultBucket, []model.LifecycleRule{
+		{Name: "expire logs", Status: model.RuleEnabled, Prefix: "logs/", ExpirationDays: 7},
+		{Name: "archive media", Status: model.RuleEnabled, Prefix: "media/", TransitionDays: 7},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	future := time.Now().Add(24 * time.Hour)
+	past := time.Now().Add(-24 * time.Hour)
+	tests := []struct {
+		key    string
+		object model.OssObject
+		status string
+		class  string
+		locked bool
+	}{
+		{"logs/plain.log", model.OssObject{}, model.StatusDeleted, "", false},
+		{"logs/held.log", model.OssObject{LegalHold: true}, model.StatusActive, "", true},
+		{"logs/retained.log", model.OssObject{RetainUntil: &future}, model.StatusActive, "", true},
+		{"logs/retention-expired.log", model.OssObject{RetainUntil: &past}, model.StatusDeleted, "", false},
+		{"media/plain.mp4", model.OssObject{}, model.StatusActive, model.StorageClassCold, false},
+		{"media/held.mp4", model.OssObject{LegalHold: true}, model.StatusActive, "", true},
+		{"other/plain.txt", model.OssObject{}, model.StatusActive, "", false},
+	}
+	objects := make([]*model.OssObject, len(tests))
+	for i, tt := range tests {
+		objects[i] = createObject(t, db, tt.key, tt.object)
+	}
+
+	report, err := s.Run(context.Background(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failures := make(map[uint]string)
+	for _, action := range report.Actions {
+		failures[action.ObjectID] = action.Error
+	}
+	for i, tt := range tests {
+		got := getObject(t, db, objects[i].ID)
+		if got.Status != tt.status || got.StorageClass != tt.class {
+			t.Errorf("%s: status %s class %q, want %s %q", tt.key, got.Status, got.StorageClass, tt.status, tt.class)
+		}
+		if tt.locked && failures[got.ID] != trash.ErrLocked.Error() {
+			t.Errorf("%s: report error %q, want %q", tt.key, failures[got.ID], trash.ErrLocked)
+		}
+		// 锁定的对象文件保留在原位置
+		if _, err := os.Stat(got.StoragePath); tt.locked && err != nil {
+			t.Errorf("%s: locked file was moved: %v", tt.key, err)
+		}
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	db := openTestDB(t)
+	s := NewService(db, DefaultConfig(), trash.NewService(db, trash.DefaultConfig()), nil)
+	if err := repo.ReplaceLifecycleRules(db, model.DefaultBucket, []model.LifecycleRule{
+		{Name: "expire", Status: model.RuleEnabled, ExpirationDays: 7},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	object := createObject(t, db, "a.txt", model.OssObject{})
+	report, err := s.Run(context.Background(), "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Actions) != 1 || report.Actions[0].Action != ActionExpire || report.Actions[0].ObjectID != object.ID {
+		t.Errorf("report actions = %+v, want one expiration", report.Actions)
+	}
+	if got := getObject(t, db, object.ID); got.Status != model.StatusActive {
+		t.Errorf("dry run changed the object status to %s", got.Status)
+	}
+}
@@ -0,0 +1,256 @@
+package live
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ormasia/swiftstream/internal/oss/hls"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/mp4"
+	"github.com/ormasia/swiftstream/internal/oss/probe"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+	"github.com/ormasia/swiftstream/internal/oss/transcode"
+)
+
+// vodStream 是转点播后 HLS 输出中存放分片的子目录
+const vodStream = "live"
+
+// convert 把已结束的会话转为点播对象：分片拼接为完整文件，并把原分片整理为 VOD 播放列表
+func (s *Service) convert(sessionID uint) {
+	session, err := repo.GetLiveSession(s.db, sessionID)
+	if err != nil {
+		log.Printf("Failed to load live session %d for conversion: %v\n", sessionID, err)
+		return
+	}
+	object, err := s.toVOD(session)
+	if err != nil {
+		log.Printf("Failed to convert live session %d to VOD: %v\n", sessionID, err)
+		session.Status = StatusFailed
+		session.Error = err.Error()
+	} else {
+		session.Status = StatusEnded
+		session.Error = ""
+		if object != nil {
+			session.ObjectID = &object.ID
+		}
+	}
+	if err := repo.SaveLiveSession(s.db, session); err != nil {
+		log.Printf("Failed to save live session %d: %v\n", sessionID, err)
+	}
+	s.broadcast(sessionID)
+}
+
+// toVOD 创建点播对象，会话没有任何分片时返回 nil
+func (s *Service) toVOD(session *model.LiveSession) (*model.OssObject, error) {
+	segments, err := repo.ListLiveSegments(s.db, session.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, nil
+	}
+
+	// 拼接录像文件：fMP4 为初始化分片加所有媒体分片，TS 直接拼接
+	ext, mimeType := "ts", "video/mp2t"
+	if session.Format == model.LiveFormatFMP4 {
+		ext, mimeType = "mp4", "video/mp4"
+	}
+	recordingPath := filepath.Join(s.sessionDir(session.ID), "recording."+ext)
+	etag, size, err := storage.WriteFile(recordingPath, func(w io.Writer) error {
+		if session.Format == model.LiveFormatFMP4 {
+			if err := appendFile(w, s.InitPath(session)); err != nil {
+				return err
+			}
+		}
+		for _, seg := range segments {
+			if err := appendFile(w, seg.StoragePath); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var duration float64
+	for _, seg := range segments {
+		duration += seg.Duration
+	}
+	object := &model.OssObject{
+		FileName:    fmt.Sprintf("live_%d.%s", session.ID, ext),
+		FileSize:    size,
+		FileType:    probe.KindVideo,
+		MimeType:    mimeType,
+		Bucket:      "default",
+		ObjectKey:   fmt.Sprintf("live/%s/%d/recording.%s", session.CreatedAt.Format("2006/01/02"), session.ID, ext),
+		ETag:        etag,
+		StoragePath: recordingPath,
+		Duration:    int(math.Round(duration)),
+		UserID:      session.UserID,
+		BusinessID:  session.BusinessID,
+		Status:      "active",
+	}
+	if info, err := probe.File(recordingPath); err == nil {
+		object.Width, object.Height = info.Width, info.Height
+		object.VideoCodec, object.AudioCodec = info.VideoCodec, info.AudioCodec
+		object.FrameRate = info.FrameRate
+	}
+	if duration > 0 {
+		object.Bitrate = int64(float64(size*8) / duration)
+	}
+
+	// 服务重启后重新转换时复用已创建的对象
+	if session.ObjectID != nil {
+		if existing, err := repo.GetObject(s.db, *session.ObjectID); err == nil {
+			object.ID, object.CreatedAt = existing.ID, existing.CreatedAt
+		}
+	}
+	if err := repo.SaveObject(s.db, object); err != nil {
+		return nil, err
+	}
+	object.URL = fmt.Sprintf("/api/oss/objects/%d/download", object.ID)
+	if err := repo.SaveObject(s.db, object); err != nil {
+		return nil, err
+	}
+	// 立即记录对象ID，转换中断后重试不会重复创建对象
+	session.ObjectID = &object.ID
+	if err := repo.SaveLiveSession(s.db, session); err != nil {
+		return nil, err
+	}
+
+	if err := s.packageVOD(session, object, segments); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// packageVOD 把直播分片整理为点播 HLS，输出目录与转码打包一致，可通过 objects/:id/hls/ 访问
+func (s *Service) packageVOD(session *model.LiveSession, object *model.OssObject, segments []model.LiveSegment) error {
+	dir := filepath.Join(s.cfg.PackageDir, strconv.FormatUint(uint64(object.ID), 10))
+	tmpDir := fmt.Sprintf("%s.tmp-%d", dir, time.Now().UnixNano())
+	streamDir := filepath.Join(tmpDir, vodStream)
+	if err := os.MkdirAll(streamDir, 0755); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pl := &hls.Playlist{
+		Version:        6,
+		TargetDuration: session.TargetDuration,
+		MediaSequence:  segments[0].Sequence,
+		Type:           "VOD",
+		Ended:          true,
+	}
+	variant := hls.Variant{URI: vodStream + "/" + hls.MediaPlaylist, Width: object.Width, Height: object.Height}
+	if session.Format == model.LiveFormatFMP4 {
+		pl.Version = 7
+		pl.InitURI = InitName
+		if err := linkFile(s.InitPath(session), filepath.Join(streamDir, InitName)); err != nil {
+			return err
+		}
+		if codecs, err := initCodecs(s.InitPath(session)); err == nil {
+			variant.Codecs = strings.Join(codecs, ",")
+		}
+	}
+	for _, seg := range segments {
+		name := SegmentName(session, seg.Sequence)
+		if err := linkFile(seg.StoragePath, filepath.Join(streamDir, name)); err != nil {
+			return err
+		}
+		pl.Segments = append(pl.Segments, hls.PlaylistSegment{URI: name, Duration: seg.Duration})
+		variant.Bandwidth = max(variant.Bandwidth, int64(float64(seg.Size*8)/seg.Duration))
+	}
+
+	if _, _, err := storage.WriteFile(filepath.Join(streamDir, hls.MediaPlaylist), func(w io.Writer) error {
+		return hls.WritePlaylist(w, pl)
+	}); err != nil {
+		return err
+	}
+	masterPath := filepath.Join(tmpDir, hls.MasterPlaylist)
+	if _, _, err := storage.WriteFile(masterPath, func(w io.Writer) error {
+		return hls.WriteVariantPlaylist(w, variant)
+	}); err != nil {
+		return err
+	}
+	if err := storage.ReplaceDir(tmpDir, dir); err != nil {
+		return err
+	}
+	return s.registerPlaylist(object, filepath.Join(dir, hls.MasterPlaylist))
+}
+
+// registerPlaylist 把主播放列表登记为点播对象的 hls 衍生对象
+func (s *Service) registerPlaylist(object *model.OssObject, masterPath string) error {
+	etag, size, err := storage.Checksum(masterPath)
+	if err != nil {
+		return err
+	}
+	parentID := object.ID
+	variant := &model.OssObject{
+		ParentID:  &parentID,
+		Variant:   transcode.VariantHLS,
+		Bucket:    object.Bucket,
+		ObjectKey: fmt.Sprintf("%s@%s/%s", object.ObjectKey, transcode.VariantHLS, hls.MasterPlaylist),
+		VersionID: object.VersionID,
+		URL:       fmt.Sprintf("/api/oss/objects/%d/%s/%s", object.ID, transcode.VariantHLS, hls.MasterPlaylist),
+	}
+	if existing, err := repo.GetVariant(s.db, object.ID, transcode.VariantHLS); err == nil {
+		variant = existing
+	}
+	variant.FileName = hls.MasterPlaylist
+	variant.FileSize = size
+	variant.FileType = object.FileType
+	variant.MimeType = "application/vnd.apple.mpegurl"
+	variant.ETag = etag
+	variant.StoragePath = masterPath
+	variant.Width, variant.Height = object.Width, object.Height
+	variant.Duration = object.Duration
+	variant.UserID = object.UserID
+	variant.BusinessID = object.BusinessID
+	variant.Status = "active"
+	return repo.SaveObject(s.db, variant)
+}
+
+// initCodecs 读取 fMP4 初始化分片中各轨道的编码字符串
+func initCodecs(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return mp4.MovieCodecs(f, stat.Size())
+}
+
+func appendFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// linkFile 优先使用硬链接，跨文件系统时退回复制
+func linkFile(src, dst string) error {
+	if err := os.Link(src, dst); err == nil || errors.Is(err, os.ErrExist) {
+		return nil
+	}
+	_, _, err := storage.WriteFile(dst, func(w io.Writer) error {
+		return appendFile(w, src)
+	})
+	return err
+}
@@ -0,0 +1,373 @@
+package live
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+	"gorm.io/gorm"
+)
+
+// 会话状态
+const (
+	StatusIdle       = "idle"       // 已创建，尚未推送分片
+	StatusLive       = "live"       // 直播中
+	StatusConverting = "converting" // 已结束，正在转为点播
+	StatusEnded      = "ended"      // 已结束
+	StatusFailed     = "failed"     // 转点播失败
+)
+
+var (
+	ErrInvalidStreamKey = errors.New("live: invalid stream key")
+	ErrSessionEnded     = errors.New("live: session has ended")
+	ErrInvalidFormat    = errors.New("live: format must be fmp4 or ts")
+	ErrInitRequired     = errors.New("live: init segment must be pushed first")
+	ErrInitNotAllowed   = errors.New("live: ts sessions have no init segment")
+	ErrOutOfOrder       = errors.New("live: segment sequence must follow the last segment")
+	ErrInvalidDuration  = errors.New("live: segment duration must be positive")
+)
+
+// Config 直播配置
+type Config struct {
+	Dir                   string        // 直播分片存储目录
+	PackageDir            string        // 转点播后 HLS 输出目录，与转码打包共用
+	DefaultTargetDuration int           // 未指定时的分片目标时长(秒)
+	MaxTargetDuration     int           // 分片目标时长上限(秒)
+	IdleTimeout           time.Duration // 超过该时间没有推送分片的会话自动结束
+	CheckInterval         time.Duration // 检查空闲会话的间隔
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		Dir:                   filepath.Join("data", "files", "live"),
+		PackageDir:            filepath.Join("data", "files", "hls"),
+		DefaultTargetDuration: 6,
+		MaxTargetDuration:     20,
+		IdleTimeout:           2 * time.Minute,
+		CheckInterval:         30 * time.Second,
+	}
+}
+
+// CreateRequest 创建直播会话的参数
+type CreateRequest struct {
+	Title          string
+	Format         string
+	TargetDuration int
+	UserID         uint
+	BusinessID     string
+}
+
+// Service 管理直播会话：接收推流分片、通知等待中的播放列表请求、结束后转为点播
+type Service struct {
+	db  *gorm.DB
+	cfg Config
+
+	mu      sync.Mutex
+	notify  map[uint]chan struct{} // 会话有新分片或结束时关闭并替换
+	locks   map[uint]*sync.Mutex   // 同一会话的推送串行执行
+	running sync.WaitGroup
+}
+
+func NewService(db *gorm.DB, cfg Config) *Service {
+	return &Service{
+		db:     db,
+		cfg:    cfg,
+		notify: make(map[uint]chan struct{}),
+		locks:  make(map[uint]*sync.Mutex),
+	}
+}
+
+// Start 继续服务重启前未完成的转点播，并定期结束空闲会话
+func (s *Service) Start(ctx context.Context) error {
+	sessions, err := repo.ListLiveSessionsByStatus(s.db, StatusConverting)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		s.startConvert(session.ID)
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.cfg.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.endIdle()
+			}
+		}
+	}()
+	return nil
+}
+
+// Wait 等待后台的转点播任务结束
+func (s *Service) Wait() {
+	s.running.Wait()
+}
+
+// Create 创建直播会话并生成推流密钥
+func (s *Service) Create(req CreateRequest) (*model.LiveSession, error) {
+	if req.Format == "" {
+		req.Format = model.LiveFormatFMP4
+	}
+	if req.Format != model.LiveFormatFMP4 && req.Format != model.LiveFormatTS {
+		return nil, ErrInvalidFormat
+	}
+	if req.TargetDuration <= 0 {
+		req.TargetDuration = s.cfg.DefaultTargetDuration
+	}
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	session := &model.LiveSession{
+		StreamKey:      hex.EncodeToString(key),
+		Title:          req.Title,
+		Format:         req.Format,
+		TargetDuration: min(req.TargetDuration, s.cfg.MaxTargetDuration),
+		Status:         StatusIdle,
+		LastSequence:   -1,
+		UserID:         req.UserID,
+		BusinessID:     req.BusinessID,
+	}
+	if err := repo.CreateLiveSession(s.db, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Authorize 校验推流密钥，返回会话
+func (s *Service) Authorize(sessionID uint, streamKey string) (*model.LiveSession, error) {
+	session, err := repo.GetLiveSession(s.db, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(streamKey), []byte(session.StreamKey)) != 1 {
+		return nil, ErrInvalidStreamKey
+	}
+	return session, nil
+}
+
+// PutInit 保存 fMP4 初始化分片，重复推送时覆盖
+func (s *Service) PutInit(sessionID uint, data []byte) error {
+	unlock := s.lock(sessionID)
+	defer unlock()
+
+	session, err := s.pushable(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.Format != model.LiveFormatFMP4 {
+		return ErrInitNotAllowed
+	}
+	if _, _, err := storage.WriteFile(s.InitPath(session), writeBytes(data)); err != nil {
+		return err
+	}
+	session.HasInit = true
+	return repo.SaveLiveSession(s.db, session)
+}
+
+// PutSegment 保存一个媒体分片，sequence 必须紧接上一个分片；重复推送最后一个分片时覆盖
+func (s *Service) PutSegment(sessionID uint, sequence int64, duration float64, data []byte) (*model.LiveSegment, error) {
+	if duration <= 0 {
+		return nil, ErrInvalidDuration
+	}
+	unlock := s.lock(sessionID)
+	defer unlock()
+
+	session, err := s.pushable(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Format == model.LiveFormatFMP4 && !session.HasInit {
+		return nil, ErrInitRequired
+	}
+	if sequence < 0 || (session.LastSequence >= 0 && sequence != session.LastSequence && sequence != session.LastSequence+1) {
+		return nil, ErrOutOfOrder
+	}
+
+	path := filepath.Join(s.sessionDir(session.ID), SegmentName(session, sequence))
+	_, size, err := storage.WriteFile(path, writeBytes(data))
+	if err != nil {
+		return nil, err
+	}
+	segment := &model.LiveSegment{
+		SessionID:   session.ID,
+		Sequence:    sequence,
+		Duration:    duration,
+		Size:        size,
+		StoragePath: path,
+	}
+	if err := repo.SaveLiveSegment(s.db, segment); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session.Status = StatusLive
+	session.LastSequence = sequence
+	session.LastSegmentAt = &now
+	if err := repo.SaveLiveSession(s.db, session); err != nil {
+		return nil, err
+	}
+	s.broadcast(session.ID)
+	return segment, nil
+}
+
+// End 结束直播并在后台转为点播对象
+func (s *Service) End(sessionID uint) (*model.LiveSession, error) {
+	unlock := s.lock(sessionID)
+	defer unlock()
+
+	session, err := s.pushable(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session.Status = StatusConverting
+	session.EndedAt = &now
+	if err := repo.SaveLiveSession(s.db, session); err != nil {
+		return nil, err
+	}
+	s.broadcast(session.ID)
+	s.startConvert(session.ID)
+	return session, nil
+}
+
+// WaitSegment 阻塞到会话出现序号不小于 sequence 的分片、会话结束、超时或 ctx 取消，返回最新的会话状态
+func (s *Service) WaitSegment(ctx context.Context, sessionID uint, sequence int64, timeout time.Duration) (*model.LiveSession, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		// 先取通知通道再读状态，避免错过两者之间到达的分片
+		ch := s.channel(sessionID)
+		session, err := repo.GetLiveSession(s.db, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if session.LastSequence >= sequence || !IsActive(session) {
+			return session, nil
+		}
+		select {
+		case <-ch:
+		case <-timer.C:
+			return session, nil
+		case <-ctx.Done():
+			return session, ctx.Err()
+		}
+	}
+}
+
+// IsActive 判断会话是否仍在接收推流
+func IsActive(session *model.LiveSession) bool {
+	return session.Status == StatusIdle || session.Status == StatusLive
+}
+
+// SegmentName 返回分片文件名，如 seg_12.m4s
+func SegmentName(session *model.LiveSession, sequence int64) string {
+	ext := ".m4s"
+	if session.Format == model.LiveFormatTS {
+		ext = ".ts"
+	}
+	return "seg_" + strconv.FormatInt(sequence, 10) + ext
+}
+
+// InitName 是 fMP4 初始化分片的文件名
+const InitName = "init.mp4"
+
+// InitPath 返回会话初始化分片的存储路径
+func (s *Service) InitPath(session *model.LiveSession) string {
+	return filepath.Join(s.sessionDir(session.ID), InitName)
+}
+
+func (s *Service) sessionDir(sessionID uint) string {
+	return filepath.Join(s.cfg.Dir, strconv.FormatUint(uint64(sessionID), 10))
+}
+
+// pushable 读取会话并确认仍可推流
+func (s *Service) pushable(sessionID uint) (*model.LiveSession, error) {
+	session, err := repo.GetLiveSession(s.db, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !IsActive(session) {
+		return nil, ErrSessionEnded
+	}
+	return session, nil
+}
+
+func (s *Service) lock(sessionID uint) func() {
+	s.mu.Lock()
+	l, ok := s.locks[sessionID]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[sessionID] = l
+	}
+	s.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+func (s *Service) channel(sessionID uint) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.notify[sessionID]
+	if !ok {
+		ch = make(chan struct{})
+		s.notify[sessionID] = ch
+	}
+	return ch
+}
+
+// broadcast 唤醒所有等待该会话的请求
+func (s *Service) broadcast(sessionID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch, ok := s.notify[sessionID]; ok {
+		close(ch)
+		delete(s.notify, sessionID)
+	}
+}
+
+// endIdle 结束长时间没有推流的会话，编码器异常退出时不会调用 End
+func (s *Service) endIdle() {
+	sessions, err := repo.ListIdleLiveSessions(s.db, time.Now().Add(-s.cfg.IdleTimeout))
+	if err != nil {
+		log.Printf("Failed to list idle live sessions: %v\n", err)
+		return
+	}
+	for _, session := range sessions {
+		log.Printf("Live session %d has been idle for %s, ending it\n", session.ID, s.cfg.IdleTimeout)
+		if _, err := s.End(session.ID); err != nil && !errors.Is(err, ErrSessionEnded) {
+			log.Printf("Failed to end idle live session %d: %v\n", session.ID, err)
+		}
+	}
+}
+
+func (s *Service) startConvert(sessionID uint) {
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.convert(sessionID)
+	}()
+}
+
+func writeBytes(data []byte) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}
+}
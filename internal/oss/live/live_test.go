@@ -0,0 +1,239 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := repo.CreateTable(db); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	dir := t.TempDir()
+	cfg.Dir = filepath.Join(dir, "live")
+	cfg.PackageDir = filepath.Join(dir, "hls")
+	return NewService(db, cfg)
+}
+
+func TestCreate(t *testing.T) {
+	s := newTestService(t)
+	tests := []struct {
+		name     string
+		req      CreateRequest
+		format   string
+		duration int
+		err      error
+	}{
+		{"defaults", CreateRequest{UserID: 1}, model.LiveFormatFMP4, 6, nil},
+		{"ts", CreateRequest{Format: model.LiveFormatTS, TargetDuration: 4}, model.LiveFormatTS, 4, nil},
+		{"capped duration", CreateRequest{TargetDuration: 60}, model.LiveFormatFMP4, 20, nil},
+		{"invalid format", CreateRequest{Format: "flv"}, "", 0, ErrInvalidFormat},
+	}
+	for _, tt := range tests {
+		session, err := s.Create(tt.req)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if session.Format != tt.format || session.TargetDuration != tt.duration || session.Status != StatusIdle ||
+			session.LastSequence != -1 || session.UserID != tt.req.UserID || len(session.StreamKey) != 32 {
+			t.Errorf("%s: session = %+v", tt.name, session)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	s := newTestService(t)
+	session, err := s.Create(CreateRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		id   uint
+		key  string
+		err  error
+	}{
+		{"valid", session.ID, session.StreamKey, nil},
+		{"wrong key", session.ID, "0123456789abcdef0123456789abcdef", ErrInvalidStreamKey},
+		{"empty key", session.ID, "", ErrInvalidStreamKey},
+		{"unknown session", session.ID + 1, session.StreamKey, gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := s.Authorize(tt.id, tt.key); !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestPush(t *testing.T) {
+	s := newTestService(t)
+	fmp4, err := s.Create(CreateRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := s.Create(CreateRequest{Format: model.LiveFormatTS})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		session  uint
+		init     bool
+		sequence int64
+		duration float64
+		err      error
+	}{
+		{"segment before init", fmp4.ID, false, 0, 2, ErrInitRequired},
+		{"init", fmp4.ID, true, 0, 0, nil},
+		{"first segment", fmp4.ID, false, 5, 2, nil},
+		{"repeat last segment", fmp4.ID, false, 5, 2, nil},
+		{"next segment", fmp4.ID, false, 6, 2, nil},
+		{"gap", fmp4.ID, false, 8, 2, ErrOutOfOrder},
+		{"backwards", fmp4.ID, false, 4, 2, ErrOutOfOrder},
+		{"zero duration", fmp4.ID, false, 7, 0, ErrInvalidDuration},
+		{"ts init", ts.ID, true, 0, 0, ErrInitNotAllowed},
+		{"ts segment", ts.ID, false, 0, 2, nil},
+		{"negative sequence", ts.ID, false, -1, 2, ErrOutOfOrder},
+	}
+	for _, tt := range tests {
+		if tt.init {
+			err = s.PutInit(tt.session, []byte("init"))
+		} else {
+			_, err = s.PutSegment(tt.session, tt.sequence, tt.duration, []byte("segment"))
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	session, err := repo.GetLiveSession(s.db, fmp4.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Status != StatusLive || session.LastSequence != 6 || !session.HasInit {
+		t.Errorf("session after push = %+v", session)
+	}
+	segments, err := repo.ListLiveSegments(s.db, fmp4.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 2 {
+		t.Errorf("%d segments, want 2", len(segments))
+	}
+}
+
+func TestWaitSegment(t *testing.T) {
+	s := newTestService(t)
+	session, err := s.Create(CreateRequest{Format: model.LiveFormatTS})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 分片到达时唤醒等待中的请求
+	done := make(chan *model.LiveSession, 1)
+	go func() {
+		got, err := s.WaitSegment(context.Background(), session.ID, 0, 5*time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- got
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if _, err := s.PutSegment(session.ID, 0, 2, []byte("segment")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-done:
+		if got.LastSequence != 0 {
+			t.Errorf("woke with last sequence %d, want 0", got.LastSequence)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WaitSegment was not woken by the new segment")
+	}
+
+	// 超时返回当前状态
+	got, err := s.WaitSegment(context.Background(), session.ID, 1, 10*time.Millisecond)
+	if err != nil || got.LastSequence != 0 {
+		t.Errorf("WaitSegment after timeout = %+v, %v", got, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.WaitSegment(ctx, session.ID, 1, time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitSegment with canceled context: err = %v", err)
+	}
+}
+
+func TestEndConvertsToVOD(t *testing.T) {
+	s := newTestService(t)
+	session, err := s.Create(CreateRequest{Format: model.LiveFormatTS, UserID: 7, BusinessID: "course-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, data := range []string{"first ", "second"} {
+		if _, err := s.PutSegment(session.ID, int64(i), 3, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.End(session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PutSegment(session.ID, 2, 3, []byte("late")); !errors.Is(err, ErrSessionEnded) {
+		t.Errorf("push after end: err = %v, want %v", err, ErrSessionEnded)
+	}
+	if _, err := s.End(session.ID); !errors.Is(err, ErrSessionEnded) {
+		t.Errorf("end twice: err = %v, want %v", err, ErrSessionEnded)
+	}
+	s.Wait()
+
+	ended, err := repo.GetLiveSession(s.db, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ended.Status != StatusEnded || ended.ObjectID == nil {
+		t.Fatalf("session after conversion = %+v", ended)
+	}
+	// 录像属于创建会话的用户
+	object, err := repo.GetObject(s.db, *ended.ObjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object.UserID != 7 || object.BusinessID != "course-1" || object.Duration != 6 || object.FileSize != 12 {
+		t.Errorf("recording = %+v", object)
+	}
+	data, err := os.ReadFile(object.StoragePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first second" {
+		t.Errorf("recording content = %q", data)
+	}
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"fmt"
+	"path/filepath"
 	"time"
 
 	"gorm.io/gorm"
@@ -20,9 +22,22 @@ type OssObject struct {
 	MimeType string `json:"mime_type" gorm:"not null"`
 
 	// OSS 存储信息
-	Bucket    string `json:"bucket" gorm:"not null"`
-	ObjectKey string `json:"object_key" gorm:"not null;uniqueIndex"`
+	Bucket    string `json:"bucket" gorm:"not null;uniqueIndex:idx_object_version"`
+	ObjectKey string `json:"object_key" gorm:"not null;uniqueIndex:idx_object_version"`
 	ETag      string `json:"etag"`
+	// 开启版本控制的存储桶中，同一 ObjectKey 的每次写入都是一个版本；衍生对象沿用源对象的版本
+	VersionID    string     `json:"version_id,omitempty" gorm:"not null;default:'';uniqueIndex:idx_object_version"`
+	NoncurrentAt *time.Time `json:"noncurrent_at,omitempty"`                               // 被新版本取代的时间，为空表示当前版本
+	DeleteMarker bool       `json:"delete_marker,omitempty" gorm:"not null;default:false"` // 删除标记，没有文件，为当前版本时按 ObjectKey 读取返回不存在
+	// 文件在本地存储中的路径
+	StoragePath string `json:"-"`
+	// 存储类型，空表示本地标准存储；cold 表示文件已由生命周期规则转移到冷存储
+	StorageClass string `json:"storage_class,omitempty"`
+
+	// 衍生对象（缩略图、转码结果等）关联到源对象
+	ParentID *uint       `json:"parent_id,omitempty" gorm:"index"`
+	Variant  string      `json:"variant,omitempty"`                             // 衍生规格名，如 360
+	Variants []OssObject `json:"variants,omitempty" gorm:"foreignKey:ParentID"` // 源对象的衍生对象列表
 
 	// 访问信息
 	URL    string `json:"url"`     // 文件访问URL
@@ -33,10 +48,44 @@ type OssObject struct {
 	Height   int `json:"height"`   // 图片/视频高度
 	Duration int `json:"duration"` // 视频/音频时长(秒)
 
+	// 媒体编码信息，完成上传后从容器头部解析
+	VideoCodec string  `json:"video_codec"` // 视频编码，如 avc1/hvc1/V_VP9
+	AudioCodec string  `json:"audio_codec"` // 音频编码，如 mp4a/mp3/pcm_s16le
+	Bitrate    int64   `json:"bitrate"`     // 总码率(bit/s)
+	FrameRate  float64 `json:"frame_rate"`  // 视频帧率
+
+	// 图片与视频封面的占位信息，供客户端在加载前渲染模糊预览与背景色
+	BlurHash      string   `json:"blur_hash,omitempty"`
+	DominantColor string   `json:"dominant_color,omitempty"`                 // #rrggbb
+	Palette       []string `json:"palette,omitempty" gorm:"serializer:json"` // 主色列表，按占比降序
+
+	// 照片拍摄信息，取自清理后保留的 EXIF 子集
+	CameraMake  string     `json:"camera_make,omitempty"`
+	CameraModel string     `json:"camera_model,omitempty"`
+	LensModel   string     `json:"lens_model,omitempty"`
+	CapturedAt  *time.Time `json:"captured_at,omitempty"`
+
+	// 与已有内容的感知哈希接近时，指向被判定为重复的源对象
+	DuplicateOf *uint `json:"duplicate_of,omitempty" gorm:"index"`
+
+	// HLS 分片加密方式，空表示不加密
+	Encryption string `json:"encryption,omitempty"` // aes-128
+
 	// 业务信息
 	UserID     uint   `json:"user_id" gorm:"index"`
 	BusinessID string `json:"business_id" gorm:"index"`       // 业务关联ID
-	Status     string `json:"status" gorm:"default:'active'"` // active/deleted
+	Status     string `json:"status" gorm:"default:'active'"` // active/pending_review/quarantined/rejected/deleted
+
+	// 访问控制：public-read 任何人可读，private 只有所有者、被授权的用户与分享链接可读，授权记录在 object_grants 表中
+	ACL string `json:"acl" gorm:"not null;default:'public-read'"`
+
+	// 合规锁定：法律保留或保留期内的对象不能删除、覆盖或被生命周期规则处理，变更记录在 object_lock_audits 表中
+	LegalHold   bool       `json:"legal_hold,omitempty" gorm:"not null;default:false"`
+	RetainUntil *time.Time `json:"retain_until,omitempty"`
+
+	// 自定义元数据与标签保存在 object_metadata、object_tags 表中，查询单个对象时填充
+	Metadata map[string]string `json:"metadata,omitempty" gorm:"-"`
+	Tags     map[string]string `json:"tags,omitempty" gorm:"-"`
 }
 
 // TableName 指定表名
@@ -44,6 +93,99 @@ func (OssObject) TableName() string {
 	return "oss_objects"
 }
 
+// 对象状态，只有 active 的对象可以下载与分发
+const (
+	StatusActive        = "active"
+	StatusPendingReview = "pending_review" // 等待扫描完成或人工审核
+	StatusQuarantined   = "quarantined"    // 扫描命中，等待管理员确认
+	StatusRejected      = "rejected"       // 管理员确认违规，文件已删除
+	StatusDeleted       = "deleted"        // 用户删除，保留期内可从回收站恢复
+)
+
+// FilePath 返回对象文件的本地存储路径
+// 早期记录没有 StoragePath，按 Complete 的合并规则回退到 data/files/<FileName>
+func (o *OssObject) FilePath() string {
+	if o.StoragePath != "" {
+		return o.StoragePath
+	}
+	return filepath.Join("data", "files", o.FileName)
+}
+
+// Locked 返回对象在 now 时是否处于法律保留或保留期内
+func (o *OssObject) Locked(now time.Time) bool {
+	return o.LegalHold || o.RetainUntil != nil && now.Before(*o.RetainUntil)
+}
+
+// 对象的访问控制
+const (
+	ACLPublicRead = "public-read"
+	ACLPrivate    = "private"
+)
+
+// DefaultBucket 上传未指定存储桶时使用的存储桶
+const DefaultBucket = "default"
+
+// Bucket 存储桶配置，没有记录的存储桶不开启版本控制
+type Bucket struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name       string `json:"name" gorm:"not null;uniqueIndex"`
+	Versioning string `json:"versioning"` // 空/enabled/suspended
+}
+
+// TableName 指定表名
+func (Bucket) TableName() string {
+	return "buckets"
+}
+
+// 存储桶的版本控制状态
+const (
+	VersioningEnabled   = "enabled"   // 覆盖写入与删除保留历史版本
+	VersioningSuspended = "suspended" // 暂停后覆盖写入与删除不再保留版本，已有的历史版本保留
+)
+
+// 对象的存储类型
+const StorageClassCold = "cold"
+
+// ColdKey 返回对象文件在冷存储中的键
+func (o *OssObject) ColdKey() string {
+	return fmt.Sprintf("objects/%d/%s", o.ID, filepath.Base(o.FileName))
+}
+
+// LifecycleRule 存储桶的生命周期规则，按对象键前缀、标签、BusinessID 与对象年龄匹配
+// 各动作的天数为 0 表示不执行该动作
+type LifecycleRule struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Bucket string `json:"bucket" gorm:"not null;index"`
+	Name   string `json:"name"`
+	Status string `json:"status" gorm:"not null"` // enabled/disabled
+
+	// 匹配条件，为空表示不限制；Tags 中的标签需全部匹配
+	Prefix     string            `json:"prefix,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty" gorm:"serializer:json"`
+	BusinessID string            `json:"business_id,omitempty"`
+
+	ExpirationDays            int `json:"expiration_days,omitempty"`              // 创建超过 N 天的对象移入回收站
+	TransitionDays            int `json:"transition_days,omitempty"`              // 创建超过 N 天的对象转移到冷存储
+	AbortIncompleteUploadDays int `json:"abort_incomplete_upload_days,omitempty"` // 开始超过 N 天仍未完成的上传被取消
+}
+
+// TableName 指定表名
+func (LifecycleRule) TableName() string {
+	return "lifecycle_rules"
+}
+
+// 生命周期规则状态
+const (
+	RuleEnabled  = "enabled"
+	RuleDisabled = "disabled"
+)
+
 // UploadTask 分片上传任务
 type UploadTask struct {
 	ID        uint           `json:"id" gorm:"primarykey"`
@@ -60,18 +202,34 @@ type UploadTask struct {
 	ChunkCount int    `json:"chunk_count" gorm:"not null" comment:"分片总数"`
 
 	// 上传状态
-	Status         string `json:"status" gorm:"default:'uploading'" comment:"上传状态"`   // uploading/completed/failed/cancelled
+	Status         string `json:"status" gorm:"default:'uploading'" comment:"上传状态"`   // uploading/fetching/completed/failed/cancelled
 	UploadedChunks int    `json:"uploaded_chunks" gorm:"default:0" comment:"已上传分片数量"` // 已上传分片数量
 	Progress       int    `json:"progress" gorm:"default:0" comment:"上传进度百分比"`        // 上传进度百分比
 
+	// 目标存储桶与对象键，Init 时指定对象键的上传写入该键的新版本
+	Bucket string `json:"bucket"`
+
 	// 完成后的文件信息
 	ObjectKey string `json:"object_key"`
 	URL       string `json:"url"` // 文件访问URL
 	ETag      string `json:"etag"`
 
+	// 完成后对象的 HLS 加密方式，空表示不加密
+	Encryption string `json:"encryption,omitempty" comment:"HLS加密方式"`
+
 	// 业务信息
 	UserID     uint   `json:"user_id" gorm:"index"`
 	BusinessID string `json:"business_id" gorm:"index"`
+
+	// Init 时指定的自定义元数据，完成上传时写入 object_metadata
+	Metadata map[string]string `json:"metadata,omitempty" gorm:"serializer:json"`
+
+	// 完成后对象的访问控制，空表示 public-read
+	ACL string `json:"acl,omitempty"`
+
+	// 从 URL 下载的上传任务的源地址，以及下载失败的原因
+	SourceURL string `json:"source_url,omitempty"`
+	Error     string `json:"error,omitempty"`
 }
 
 // ChunkRecord 分片记录
@@ -115,3 +273,286 @@ func (ut *UploadTask) UpdateProgress() {
 		ut.Progress = (ut.UploadedChunks * 100) / ut.ChunkCount
 	}
 }
+
+// TranscodeJob 视频处理任务：转码任务对应码率阶梯中的一档，打包任务对应整个源视频
+type TranscodeJob struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 源对象与目标规格，同一对象的同类任务每个档位只有一个
+	ObjectID  uint   `json:"object_id" gorm:"not null;uniqueIndex:idx_transcode_job_target"`                // 源视频对象ID
+	Kind      string `json:"kind" gorm:"not null;default:'transcode';uniqueIndex:idx_transcode_job_target"` // transcode/hls
+	Rendition string `json:"rendition" gorm:"not null;default:'';uniqueIndex:idx_transcode_job_target"`     // 码率阶梯档位名，如 720p；打包任务为空
+
+	// 执行状态
+	Status      string    `json:"status" gorm:"index;default:'pending'"` // pending/running/completed/failed
+	Progress    int       `json:"progress" gorm:"default:0"`             // 转码进度百分比
+	Attempts    int       `json:"attempts" gorm:"default:0"`             // 已执行次数
+	MaxAttempts int       `json:"max_attempts"`                          // 最大执行次数
+	NextRunAt   time.Time `json:"next_run_at" gorm:"index"`              // 重试时间
+	Error       string    `json:"error"`                                 // 最近一次失败原因
+
+	// 输出对象
+	OutputObjectID *uint `json:"output_object_id,omitempty"`
+}
+
+// TableName 指定表名
+func (TranscodeJob) TableName() string {
+	return "transcode_jobs"
+}
+
+// 加密方式
+const (
+	EncryptionAES128 = "aes-128"
+)
+
+// HLSKey HLS 内容密钥，密钥本身用主密钥加密后保存
+type HLSKey struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ObjectID     uint   `json:"object_id" gorm:"not null;index"`    // 源视频对象ID
+	KeyID        string `json:"key_id" gorm:"not null;uniqueIndex"` // 播放列表中引用的密钥ID
+	Sequence     int    `json:"sequence" gorm:"not null"`           // 轮换序号，从 0 开始
+	EncryptedKey []byte `json:"-" gorm:"not null"`                  // nonce + AES-GCM 密文
+}
+
+// TableName 指定表名
+func (HLSKey) TableName() string {
+	return "hls_keys"
+}
+
+// 直播分片格式
+const (
+	LiveFormatFMP4 = "fmp4"
+	LiveFormatTS   = "ts"
+)
+
+// LiveSession 直播会话：编码器通过 HTTP PUT 推送分片，结束后转为点播对象
+type LiveSession struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	StreamKey      string `json:"-" gorm:"not null;uniqueIndex"`   // 推流密钥
+	Title          string `json:"title"`                           // 直播标题
+	Format         string `json:"format" gorm:"not null"`          // fmp4/ts
+	TargetDuration int    `json:"target_duration" gorm:"not null"` // 分片目标时长(秒)
+	HasInit        bool   `json:"has_init"`                        // fmp4 初始化分片是否已上传
+
+	// 状态
+	Status        string     `json:"status" gorm:"index;default:'idle'"` // idle/live/converting/ended/failed
+	LastSequence  int64      `json:"last_sequence" gorm:"default:-1"`    // 最新分片序号，-1 表示还没有分片
+	LastSegmentAt *time.Time `json:"last_segment_at,omitempty"`          // 最近一次推送分片的时间
+	EndedAt       *time.Time `json:"ended_at,omitempty"`
+	Error         string     `json:"error,omitempty"` // 转点播失败原因
+
+	// 转点播后的对象
+	ObjectID *uint `json:"object_id,omitempty"`
+
+	// 业务信息
+	UserID     uint   `json:"user_id" gorm:"index"`
+	BusinessID string `json:"business_id" gorm:"index"`
+}
+
+// TableName 指定表名
+func (LiveSession) TableName() string {
+	return "live_sessions"
+}
+
+// LiveSegment 直播分片，序号在会话内连续递增
+type LiveSegment struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	SessionID   uint    `json:"session_id" gorm:"not null;uniqueIndex:idx_live_segment_seq"`
+	Sequence    int64   `json:"sequence" gorm:"not null;uniqueIndex:idx_live_segment_seq"` // 媒体序号
+	Duration    float64 `json:"duration" gorm:"not null"`                                  // 时长(秒)
+	Size        int64   `json:"size"`
+	StoragePath string  `json:"-"`
+}
+
+// TableName 指定表名
+func (LiveSegment) TableName() string {
+	return "live_segments"
+}
+
+// SubtitleTrack 视频的字幕轨道，每个视频每种语言一条，统一保存为 WebVTT
+type SubtitleTrack struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ObjectID     uint    `json:"object_id" gorm:"not null;uniqueIndex:idx_subtitle_lang"`
+	Language     string  `json:"language" gorm:"not null;uniqueIndex:idx_subtitle_lang"` // BCP 47 语言标签，如 en、zh-Hans
+	Label        string  `json:"label"`                                                  // 播放器中显示的名称
+	IsDefault    bool    `json:"is_default"`                                             // 是否为默认字幕
+	SourceFormat string  `json:"source_format"`                                          // 上传的格式：srt/vtt
+	CueCount     int     `json:"cue_count"`
+	Duration     float64 `json:"duration"` // 最后一条字幕的结束时间(秒)
+
+	FileSize    int64  `json:"file_size"`
+	ETag        string `json:"etag"`
+	StoragePath string `json:"-"` // 规范化后的 WebVTT 文件
+}
+
+// TableName 指定表名
+func (SubtitleTrack) TableName() string {
+	return "subtitle_tracks"
+}
+
+// SubtitleCue 字幕条目的纯文本，用于字幕检索
+type SubtitleCue struct {
+	ID       uint    `json:"-" gorm:"primarykey"`
+	TrackID  uint    `json:"-" gorm:"not null;index"`
+	ObjectID uint    `json:"object_id" gorm:"not null;index"`
+	Language string  `json:"language" gorm:"not null;index"`
+	Sequence int     `json:"sequence"` // 在字幕轨道中的序号，从 1 开始
+	Start    float64 `json:"start"`    // 开始时间(秒)
+	End      float64 `json:"end"`      // 结束时间(秒)
+	Text     string  `json:"text"`     // 去掉标签后的文本
+}
+
+// TableName 指定表名
+func (SubtitleCue) TableName() string {
+	return "subtitle_cues"
+}
+
+// 感知哈希的来源
+const (
+	HashSourceImage = "image" // 图片对象本身
+	HashSourceCover = "cover" // 视频封面
+)
+
+// ImageHash 图片与视频封面的感知哈希，用于查找近似重复的内容
+// 哈希按位存为有符号整数，SQLite 不支持最高位为 1 的无符号整数
+type ImageHash struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ObjectID uint   `json:"object_id" gorm:"not null;uniqueIndex"`
+	Source   string `json:"source" gorm:"not null"` // image/cover
+	PHash    int64  `json:"-" gorm:"not null"`
+	DHash    int64  `json:"-" gorm:"not null"`
+}
+
+// TableName 指定表名
+func (ImageHash) TableName() string {
+	return "image_hashes"
+}
+
+// ScanResult 对象的一次扫描中单个扫描器的结果
+type ScanResult struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ObjectID uint   `json:"object_id" gorm:"not null;index"`
+	Scanner  string `json:"scanner" gorm:"not null"` // clamav/blocklist
+	Verdict  string `json:"verdict" gorm:"not null"` // clean/review/infected
+	Reason   string `json:"reason,omitempty"`        // 命中的病毒签名、黑名单标签等
+	Error    string `json:"error,omitempty"`         // 扫描器出错的原因
+}
+
+// TableName 指定表名
+func (ScanResult) TableName() string {
+	return "scan_results"
+}
+
+// ObjectMetadata 上传时由客户端指定的自定义元数据，对象创建后不可修改
+type ObjectMetadata struct {
+	ID       uint   `json:"-" gorm:"primarykey"`
+	ObjectID uint   `json:"object_id" gorm:"not null;uniqueIndex:idx_object_metadata_key"`
+	Key      string `json:"key" gorm:"not null;uniqueIndex:idx_object_metadata_key"` // 小写，不含 x-oss-meta- 前缀
+	Value    string `json:"value"`
+}
+
+// TableName 指定表名
+func (ObjectMetadata) TableName() string {
+	return "object_metadata"
+}
+
+// ObjectTag 对象的标签，可随时修改，列表接口可按标签过滤
+type ObjectTag struct {
+	ID       uint   `json:"-" gorm:"primarykey"`
+	ObjectID uint   `json:"object_id" gorm:"not null;uniqueIndex:idx_object_tag_key"`
+	Key      string `json:"key" gorm:"not null;uniqueIndex:idx_object_tag_key;index:idx_object_tag_value"`
+	Value    string `json:"value" gorm:"index:idx_object_tag_value"`
+}
+
+// TableName 指定表名
+func (ObjectTag) TableName() string {
+	return "object_tags"
+}
+
+// LockAudit 对象法律保留与保留期的变更记录，对象被物理删除后仍然保留
+type LockAudit struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ObjectID            uint       `json:"object_id" gorm:"not null;index"`
+	Action              string     `json:"action" gorm:"not null"` // legal_hold_on/legal_hold_off/retention_set/retention_cleared
+	UserID              uint       `json:"user_id"`                // 操作者，来自 X-User-ID
+	Admin               bool       `json:"admin"`                  // 是否以管理员令牌操作
+	Reason              string     `json:"reason,omitempty"`
+	RetainUntil         *time.Time `json:"retain_until,omitempty"`          // 变更后的保留期
+	PreviousRetainUntil *time.Time `json:"previous_retain_until,omitempty"` // 变更前的保留期
+}
+
+// TableName 指定表名
+func (LockAudit) TableName() string {
+	return "object_lock_audits"
+}
+
+// 锁定变更的动作
+const (
+	LockLegalHoldOn      = "legal_hold_on"
+	LockLegalHoldOff     = "legal_hold_off"
+	LockRetentionSet     = "retention_set"
+	LockRetentionCleared = "retention_cleared"
+)
+
+// ObjectGrant 授权其他用户读取对象，对 private 对象生效
+type ObjectGrant struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ObjectID  uint `json:"object_id" gorm:"not null;uniqueIndex:idx_object_grant"`
+	UserID    uint `json:"user_id" gorm:"not null;uniqueIndex:idx_object_grant"` // 被授权的用户
+	GrantedBy uint `json:"granted_by"`                                           // 授权者
+}
+
+// TableName 指定表名
+func (ObjectGrant) TableName() string {
+	return "object_grants"
+}
+
+// ObjectShare 对象的分享链接，持有令牌即可下载，不受对象 ACL 限制
+type ObjectShare struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Token    string `json:"token" gorm:"not null;uniqueIndex"`
+	ObjectID uint   `json:"object_id" gorm:"not null;index"`
+	UserID   uint   `json:"user_id"` // 创建者
+
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`    // 为空表示不过期
+	PasswordHash string     `json:"-"`                       // 为空表示不需要密码
+	MaxDownloads int        `json:"max_downloads,omitempty"` // 0 表示不限制次数
+	Downloads    int        `json:"downloads" gorm:"not null;default:0"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName 指定表名
+func (ObjectShare) TableName() string {
+	return "object_shares"
+}
+
+// Usable 返回分享链接在 now 时是否未撤销、未过期且未达到下载次数上限
+func (s *ObjectShare) Usable(now time.Time) bool {
+	if s.RevokedAt != nil || s.ExpiresAt != nil && !now.Before(*s.ExpiresAt) {
+		return false
+	}
+	return s.MaxDownloads == 0 || s.Downloads < s.MaxDownloads
+}
@@ -0,0 +1,132 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrBoxNotFound 在指定路径下找不到目标 box
+var ErrBoxNotFound = errors.New("mp4: box not found")
+
+// Box 描述 ISO BMFF 文件中的一个 box（atom）
+type Box struct {
+	Type       string // 四字符类型，如 moov/trak
+	Offset     int64  // box 在文件中的起始偏移（包含头部）
+	Size       int64  // box 总大小（包含头部）
+	HeaderSize int64  // 头部大小，8 或 16（largesize）
+}
+
+// DataOffset 返回 box 负载数据的起始偏移
+func (b Box) DataOffset() int64 {
+	return b.Offset + b.HeaderSize
+}
+
+// DataSize 返回 box 负载数据的大小
+func (b Box) DataSize() int64 {
+	return b.Size - b.HeaderSize
+}
+
+// End 返回 box 结束位置（下一个 box 的起始偏移）
+func (b Box) End() int64 {
+	return b.Offset + b.Size
+}
+
+// ReadBox 读取 off 位置的 box 头部，end 为父容器的结束位置
+func ReadBox(r io.ReaderAt, off, end int64) (Box, error) {
+	var hdr [16]byte
+	if end-off < 8 {
+		return Box{}, io.ErrUnexpectedEOF
+	}
+	if _, err := r.ReadAt(hdr[:8], off); err != nil {
+		return Box{}, err
+	}
+	box := Box{
+		Type:       string(hdr[4:8]),
+		Offset:     off,
+		Size:       int64(binary.BigEndian.Uint32(hdr[:4])),
+		HeaderSize: 8,
+	}
+	switch box.Size {
+	case 0:
+		// size 为 0 表示 box 一直延伸到容器末尾
+		box.Size = end - off
+	case 1:
+		// largesize，使用 64 位长度
+		if _, err := r.ReadAt(hdr[8:16], off+8); err != nil {
+			return Box{}, err
+		}
+		box.Size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+		box.HeaderSize = 16
+	}
+	if box.Size < box.HeaderSize || box.End() > end {
+		return Box{}, fmt.Errorf("mp4: invalid size %d for box %q at %d", box.Size, box.Type, off)
+	}
+	return box, nil
+}
+
+// Children 列出 [start, end) 范围内的所有直接子 box
+func Children(r io.ReaderAt, start, end int64) ([]Box, error) {
+	var boxes []Box
+	for off := start; off+8 <= end; {
+		box, err := ReadBox(r, off, end)
+		if err != nil {
+			return boxes, err
+		}
+		boxes = append(boxes, box)
+		off = box.End()
+	}
+	return boxes, nil
+}
+
+// Find 在 parent 内按路径逐级查找第一个匹配的 box，例如 Find(r, moov, "trak", "mdia")
+func Find(r io.ReaderAt, parent Box, path ...string) (Box, error) {
+	cur := parent
+	for _, typ := range path {
+		children, err := Children(r, cur.DataOffset(), cur.End())
+		if err != nil {
+			return Box{}, err
+		}
+		found := false
+		for _, child := range children {
+			if child.Type == typ {
+				cur, found = child, true
+				break
+			}
+		}
+		if !found {
+			return Box{}, ErrBoxNotFound
+		}
+	}
+	return cur, nil
+}
+
+// FindAll 返回 parent 内所有类型为 typ 的直接子 box
+func FindAll(r io.ReaderAt, parent Box, typ string) ([]Box, error) {
+	children, err := Children(r, parent.DataOffset(), parent.End())
+	if err != nil {
+		return nil, err
+	}
+	var out []Box
+	for _, child := range children {
+		if child.Type == typ {
+			out = append(out, child)
+		}
+	}
+	return out, nil
+}
+
+// TopLevel 列出文件的顶层 box，size 为文件大小
+func TopLevel(r io.ReaderAt, size int64) ([]Box, error) {
+	return Children(r, 0, size)
+}
+
+// ReadData 读取 box 的全部负载数据
+func ReadData(r io.ReaderAt, b Box) ([]byte, error) {
+	buf := make([]byte, b.DataSize())
+	if _, err := r.ReadAt(buf, b.DataOffset()); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,156 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// 样本描述中 box 之前固定字段的长度
+const (
+	visualSampleEntrySize = 78 // reserved(6) data_ref(2) ... depth(2) pre_defined(2)
+	audioSampleEntrySize  = 28 // reserved(6) data_ref(2) ... sample_rate(4)
+)
+
+// CodecString 根据 stsd box 返回 RFC 6381 编码字符串，如 avc1.64001f、mp4a.40.2
+// 无法解析编码参数时返回样本描述的四字符类型
+func CodecString(stsd []byte) string {
+	// box 头(8) + version/flags(4) + entry_count(4) + 第一个样本描述
+	if len(stsd) < 24 {
+		return ""
+	}
+	entry := stsd[16:]
+	entrySize := int(binary.BigEndian.Uint32(entry[0:4]))
+	if entrySize < 8 || entrySize > len(entry) {
+		return ""
+	}
+	entry = entry[:entrySize]
+	fourcc := string(entry[4:8])
+	body := entry[8:]
+
+	switch fourcc {
+	case "avc1", "avc3":
+		if len(body) < visualSampleEntrySize {
+			return fourcc
+		}
+		avcC := childBox(body[visualSampleEntrySize:], "avcC")
+		if len(avcC) < 4 {
+			return fourcc
+		}
+		// AVCDecoderConfigurationRecord: version, profile, compatibility, level
+		return fmt.Sprintf("%s.%02x%02x%02x", fourcc, avcC[1], avcC[2], avcC[3])
+	case "mp4a":
+		if len(body) < audioSampleEntrySize {
+			return fourcc
+		}
+		esds := childBox(body[audioSampleEntrySize:], "esds")
+		if len(esds) < 4 {
+			return fourcc
+		}
+		if oti, aot, ok := parseESDS(esds[4:]); ok {
+			if aot > 0 {
+				return fmt.Sprintf("mp4a.%02x.%d", oti, aot)
+			}
+			return fmt.Sprintf("mp4a.%02x", oti)
+		}
+	}
+	return fourcc
+}
+
+// childBox 在一段连续的 box 中查找类型为 typ 的 box，返回其负载
+func childBox(data []byte, typ string) []byte {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data[0:4]))
+		if size < 8 || size > len(data) {
+			return nil
+		}
+		if string(data[4:8]) == typ {
+			return data[8:size]
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// parseESDS 解析 ES_Descriptor，返回 objectTypeIndication 和 AudioObjectType
+func parseESDS(data []byte) (oti byte, aot int, ok bool) {
+	readDescriptor := func(b []byte) (tag byte, payload []byte, rest []byte, ok bool) {
+		if len(b) < 2 {
+			return 0, nil, nil, false
+		}
+		tag = b[0]
+		size, i := 0, 1
+		// 长度字段为 1~4 字节的 7 位变长编码
+		for ; i < len(b) && i <= 4; i++ {
+			size = size<<7 | int(b[i]&0x7f)
+			if b[i]&0x80 == 0 {
+				break
+			}
+		}
+		i++
+		if i+size > len(b) {
+			return 0, nil, nil, false
+		}
+		return tag, b[i : i+size], b[i+size:], true
+	}
+
+	tag, es, _, ok := readDescriptor(data)
+	if !ok || tag != 0x03 || len(es) < 3 {
+		return 0, 0, false
+	}
+	// ES_ID(2) + flags(1)，按标志跳过可选字段
+	flags := es[2]
+	es = es[3:]
+	if flags&0x80 != 0 && len(es) >= 2 {
+		es = es[2:]
+	}
+	if flags&0x40 != 0 && len(es) >= 1 {
+		es = es[1+int(es[0]):]
+	}
+	if flags&0x20 != 0 && len(es) >= 2 {
+		es = es[2:]
+	}
+	for len(es) > 0 {
+		var payload []byte
+		tag, payload, es, ok = readDescriptor(es)
+		if !ok {
+			return 0, 0, false
+		}
+		if tag != 0x04 || len(payload) < 13 {
+			continue
+		}
+		oti = payload[0]
+		// DecoderConfigDescriptor 固定 13 字节之后是 DecoderSpecificInfo
+		if dtag, dsi, _, ok := readDescriptor(payload[13:]); ok && dtag == 0x05 && len(dsi) > 0 {
+			aot = int(dsi[0] >> 3)
+			if aot == 31 && len(dsi) > 1 {
+				aot = 32 + int(dsi[0]&0x07)<<3 | int(dsi[1]>>5)
+			}
+		}
+		return oti, aot, true
+	}
+	return 0, 0, false
+}
+
+// MovieCodecs 返回文件中每条轨道的编码字符串，按轨道顺序排列，常用于 fMP4 初始化分片
+func MovieCodecs(r io.ReaderAt, size int64) ([]string, error) {
+	movie, err := ReadMovie(r, size)
+	if err != nil {
+		return nil, err
+	}
+	var codecs []string
+	for _, track := range movie.Tracks {
+		box, err := Find(r, track.Trak, "mdia", "minf", "stbl", "stsd")
+		if err != nil {
+			continue
+		}
+		stsd, err := copyBox(r, box)
+		if err != nil {
+			return nil, err
+		}
+		if codec := CodecString(stsd); codec != "" {
+			codecs = append(codecs, codec)
+		}
+	}
+	return codecs, nil
+}
@@ -0,0 +1,276 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrCompressedMovie moov 被压缩（cmov），无法改写 chunk 偏移
+var ErrCompressedMovie = errors.New("mp4: compressed moov is not supported")
+
+// 需要递归改写的容器 box，stco/co64 位于 moov/trak/mdia/minf/stbl 下
+var faststartContainers = map[string]bool{
+	"moov": true, "trak": true, "mdia": true, "minf": true, "stbl": true,
+}
+
+// Layout 描述 faststart 改写后的文件布局
+type Layout struct {
+	boxes   []Box // 原文件的顶层 box，按输出顺序排列
+	moov    Box
+	offsets map[int64]int64 // 原偏移 -> 新偏移，仅含非 moov 的顶层 box
+	co64    bool            // 是否需要把 stco 升级为 co64
+}
+
+// NeedsFaststart 判断文件是否是 moov 位于 mdat 之后的普通 MP4
+// 文件不以 ftyp 开头、是 fragmented MP4 或 moov 已在前面时返回 false
+func NeedsFaststart(r io.ReaderAt, size int64) bool {
+	boxes, err := TopLevel(r, size)
+	if err != nil || len(boxes) == 0 || boxes[0].Type != "ftyp" {
+		return false
+	}
+	mdatSeen := false
+	for _, b := range boxes {
+		switch b.Type {
+		case "moof":
+			return false
+		case "mdat":
+			mdatSeen = true
+		case "moov":
+			return mdatSeen
+		}
+	}
+	return false
+}
+
+// PlanFaststart 计算 moov 前移后的布局：moov 放在第一个 mdat 之前，其余 box 保持原有顺序
+func PlanFaststart(r io.ReaderAt, size int64) (*Layout, error) {
+	top, err := TopLevel(r, size)
+	if err != nil {
+		return nil, err
+	}
+	layout := &Layout{offsets: make(map[int64]int64)}
+	moovIndex := -1
+	for i, b := range top {
+		if b.Type == "moov" {
+			if moovIndex >= 0 {
+				return nil, errors.New("mp4: multiple moov boxes")
+			}
+			moovIndex = i
+		}
+	}
+	if moovIndex < 0 {
+		return nil, ErrBoxNotFound
+	}
+	layout.moov = top[moovIndex]
+	if _, err := Find(r, layout.moov, "cmov"); err == nil {
+		return nil, ErrCompressedMovie
+	}
+
+	inserted := false
+	for i, b := range top {
+		if i == moovIndex {
+			continue
+		}
+		if b.Type == "mdat" && !inserted {
+			layout.boxes = append(layout.boxes, layout.moov)
+			inserted = true
+		}
+		layout.boxes = append(layout.boxes, b)
+	}
+	if !inserted {
+		layout.boxes = append(layout.boxes, layout.moov)
+	}
+
+	// 先按 stco 不变计算偏移，若有偏移超过 32 位再升级为 co64 重新计算
+	layout.place(layout.moov.Size)
+	stcoEntries, maxOffset, err := chunkOffsetStats(r, layout.moov)
+	if err != nil {
+		return nil, err
+	}
+	if layout.mapOffset(maxOffset) > math.MaxUint32 && stcoEntries > 0 {
+		layout.co64 = true
+		layout.place(layout.moov.Size + 4*stcoEntries)
+	}
+	return layout, nil
+}
+
+// place 在 moov 大小为 moovSize 时计算各顶层 box 的新偏移
+func (l *Layout) place(moovSize int64) {
+	var off int64
+	for _, b := range l.boxes {
+		if b.Offset == l.moov.Offset {
+			off += moovSize
+			continue
+		}
+		l.offsets[b.Offset] = off
+		off += b.Size
+	}
+}
+
+// mapOffset 把原文件中的偏移映射到改写后的偏移
+func (l *Layout) mapOffset(off int64) int64 {
+	for _, b := range l.boxes {
+		if b.Offset != l.moov.Offset && off >= b.Offset && off < b.End() {
+			return off - b.Offset + l.offsets[b.Offset]
+		}
+	}
+	return off
+}
+
+// chunkOffsetStats 统计 stco 表项总数和所有 chunk 偏移的最大值
+func chunkOffsetStats(r io.ReaderAt, moov Box) (int64, int64, error) {
+	var entries, maxOffset int64
+	err := walkChunkOffsets(r, moov, func(typ string, offsets []uint64) {
+		if typ == "stco" {
+			entries += int64(len(offsets))
+		}
+		for _, o := range offsets {
+			maxOffset = max(maxOffset, int64(o))
+		}
+	})
+	return entries, maxOffset, err
+}
+
+func walkChunkOffsets(r io.ReaderAt, parent Box, fn func(typ string, offsets []uint64)) error {
+	children, err := Children(r, parent.DataOffset(), parent.End())
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		switch {
+		case faststartContainers[child.Type]:
+			if err := walkChunkOffsets(r, child, fn); err != nil {
+				return err
+			}
+		case child.Type == "stco" || child.Type == "co64":
+			data, err := ReadData(r, child)
+			if err != nil {
+				return err
+			}
+			offsets, err := parseChunkOffsets(child.Type, data)
+			if err != nil {
+				return err
+			}
+			fn(child.Type, offsets)
+		}
+	}
+	return nil
+}
+
+func parseChunkOffsets(typ string, data []byte) ([]uint64, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("mp4: %s too short", typ)
+	}
+	count := int(binary.BigEndian.Uint32(data[4:8]))
+	width := 4
+	if typ == "co64" {
+		width = 8
+	}
+	if len(data) < 8+count*width {
+		return nil, fmt.Errorf("mp4: %s truncated", typ)
+	}
+	offsets := make([]uint64, count)
+	for i := range offsets {
+		p := data[8+i*width:]
+		if width == 8 {
+			offsets[i] = binary.BigEndian.Uint64(p)
+		} else {
+			offsets[i] = uint64(binary.BigEndian.Uint32(p))
+		}
+	}
+	return offsets, nil
+}
+
+// Faststart 按布局写出改写后的文件：moov 中的 chunk 偏移重新计算，其他 box 原样流式复制
+func Faststart(w io.Writer, r io.ReaderAt, layout *Layout) (int64, error) {
+	moov, err := layout.rewrite(r, layout.moov)
+	if err != nil {
+		return 0, err
+	}
+	var written int64
+	for _, b := range layout.boxes {
+		var n int64
+		var err error
+		if b.Offset == layout.moov.Offset {
+			var m int
+			m, err = w.Write(moov)
+			n = int64(m)
+		} else {
+			n, err = io.Copy(w, io.NewSectionReader(r, b.Offset, b.Size))
+			if err == nil && n != b.Size {
+				err = io.ErrUnexpectedEOF
+			}
+		}
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// rewrite 递归重建 box：容器重新计算大小，stco/co64 改写偏移，其他 box 原样复制
+func (l *Layout) rewrite(r io.ReaderAt, box Box) ([]byte, error) {
+	switch {
+	case faststartContainers[box.Type]:
+		children, err := Children(r, box.DataOffset(), box.End())
+		if err != nil {
+			return nil, err
+		}
+		var payload []byte
+		for _, child := range children {
+			data, err := l.rewrite(r, child)
+			if err != nil {
+				return nil, err
+			}
+			payload = append(payload, data...)
+		}
+		return boxBytes(box.Type, box.HeaderSize, payload), nil
+
+	case box.Type == "stco" || box.Type == "co64":
+		data, err := ReadData(r, box)
+		if err != nil {
+			return nil, err
+		}
+		offsets, err := parseChunkOffsets(box.Type, data)
+		if err != nil {
+			return nil, err
+		}
+		typ, width := box.Type, 4
+		if box.Type == "co64" || l.co64 {
+			typ, width = "co64", 8
+		}
+		payload := make([]byte, 8, 8+len(offsets)*width)
+		copy(payload, data[:8])
+		for _, o := range offsets {
+			mapped := uint64(l.mapOffset(int64(o)))
+			if width == 8 {
+				payload = binary.BigEndian.AppendUint64(payload, mapped)
+			} else {
+				payload = binary.BigEndian.AppendUint32(payload, uint32(mapped))
+			}
+		}
+		return boxBytes(typ, box.HeaderSize, payload), nil
+
+	default:
+		return copyBox(r, box)
+	}
+}
+
+// boxBytes 按原头部长度（8 或 16 字节）拼接 box
+func boxBytes(typ string, headerSize int64, payload []byte) []byte {
+	b := &builder{}
+	if headerSize == 16 {
+		b.u32(1)
+		b.bytes([]byte(typ))
+		b.u64(uint64(16 + len(payload)))
+	} else {
+		b.u32(uint32(8 + len(payload)))
+		b.bytes([]byte(typ))
+	}
+	b.bytes(payload)
+	return b.buf
+}
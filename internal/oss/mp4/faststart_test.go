@@ -0,0 +1,279 @@
+package mp4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+// movieFile 构造一个单轨道 MP4：samples 依次写入 mdat，stco 每个样本一个 chunk
+// moovFirst 为 false 时 moov 位于 mdat 之后
+func movieFile(samples []string, moovFirst bool) []byte {
+	ftyp := &builder{}
+	ftyp.box("ftyp", func() { ftyp.bytes([]byte("isom\x00\x00\x02\x00isomavc1")) })
+	free := &builder{}
+	free.box("free", func() { free.zeros(8) })
+
+	moov := func(mdatOffset int) []byte {
+		b := &builder{}
+		b.box("moov", func() {
+			b.fullBox("mvhd", 0, 0, func() { b.zeros(8); b.u32(1000); b.u32(1000); b.zeros(80) })
+			b.box("trak", func() {
+				b.box("mdia", func() {
+					b.box("minf", func() {
+						b.box("stbl", func() {
+							b.fullBox("stco", 0, 0, func() {
+								b.u32(uint32(len(samples)))
+								off := mdatOffset + 8
+								for _, s := range samples {
+									b.u32(uint32(off))
+									off += len(s)
+								}
+							})
+						})
+					})
+				})
+			})
+		})
+		return b.buf
+	}
+	mdat := &builder{}
+	mdat.box("mdat", func() {
+		for _, s := range samples {
+			mdat.bytes([]byte(s))
+		}
+	})
+
+	head := append(ftyp.buf, free.buf...)
+	if moovFirst {
+		m := moov(0)
+		return bytes.Join([][]byte{head, moov(len(head) + len(m)), mdat.buf}, nil)
+	}
+	return bytes.Join([][]byte{head, mdat.buf, moov(len(head))}, nil)
+}
+
+// chunkSamples 按 stco 读取每个 chunk 开头 size 个字节
+func chunkSamples(t *testing.T, data []byte, size int) []string {
+	t.Helper()
+	r := bytes.NewReader(data)
+	top, err := TopLevel(r, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range top {
+		if b.Type != "moov" {
+			continue
+		}
+		stco, err := Find(r, b, "trak", "mdia", "minf", "stbl", "stco")
+		if err != nil {
+			stco, err = Find(r, b, "trak", "mdia", "minf", "stbl", "co64")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := ReadData(r, stco)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets, err := parseChunkOffsets(stco.Type, raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var samples []string
+		for _, o := range offsets {
+			samples = append(samples, string(data[o:int(o)+size]))
+		}
+		return samples
+	}
+	t.Fatal("no moov box")
+	return nil
+}
+
+func boxTypes(t *testing.T, data []byte) []string {
+	t.Helper()
+	top, err := TopLevel(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, b := range top {
+		types = append(types, b.Type)
+	}
+	return types
+}
+
+func TestNeedsFaststart(t *testing.T) {
+	moof := &builder{}
+	moof.box("moof", func() { moof.zeros(8) })
+	// ftyp 与 free 共 40 字节，moof 插在 mdat 之前
+	plain := movieFile([]string{"AAAA"}, false)
+	fragmented := bytes.Join([][]byte{plain[:40], moof.buf, plain[40:]}, nil)
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"moov at end", movieFile([]string{"AAAA", "BBBB"}, false), true},
+		{"moov first", movieFile([]string{"AAAA", "BBBB"}, true), false},
+		{"fragmented", fragmented, false},
+		{"no ftyp", movieFile([]string{"AAAA"}, false)[24:], false},
+		{"truncated", []byte{0, 0, 0, 100, 'f', 't', 'y', 'p'}, false},
+	}
+	for _, tt := range tests {
+		if got := NeedsFaststart(bytes.NewReader(tt.data), int64(len(tt.data))); got != tt.want {
+			t.Errorf("%s: NeedsFaststart = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFaststart(t *testing.T) {
+	samples := []string{"AAAA", "BBBB", "CCCC"}
+	src := movieFile(samples, false)
+	layout, err := PlanFaststart(bytes.NewReader(src), int64(len(src)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	n, err := Faststart(&out, bytes.NewReader(src), layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// stco 大小不变，改写后文件长度相同，moov 移到 mdat 之前
+	if n != int64(len(src)) || out.Len() != len(src) {
+		t.Errorf("wrote %d bytes, want %d", n, len(src))
+	}
+	if got, want := boxTypes(t, out.Bytes()), []string{"ftyp", "free", "moov", "mdat"}; !slices.Equal(got, want) {
+		t.Errorf("boxes = %v, want %v", got, want)
+	}
+	if got := chunkSamples(t, out.Bytes(), 4); !slices.Equal(got, samples) {
+		t.Errorf("chunk offsets point to %v, want %v", got, samples)
+	}
+	if NeedsFaststart(bytes.NewReader(out.Bytes()), int64(out.Len())) {
+		t.Error("output still needs faststart")
+	}
+	// 与 moov 在前的文件逐字节一致
+	if want := movieFile(samples, true); !bytes.Equal(out.Bytes(), want) {
+		t.Error("output differs from the equivalent faststart file")
+	}
+}
+
+func TestPlanFaststartErrors(t *testing.T) {
+	noMoov := movieFile([]string{"AAAA"}, false)
+	noMoov = noMoov[:len(noMoov)-moovSize(noMoov)]
+	compressed := movieFile([]string{"AAAA"}, false)
+	compressed = bytes.Replace(compressed, []byte("trak"), []byte("cmov"), 1)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"no moov", noMoov, ErrBoxNotFound},
+		{"compressed moov", compressed, ErrCompressedMovie},
+	}
+	for _, tt := range tests {
+		if _, err := PlanFaststart(bytes.NewReader(tt.data), int64(len(tt.data))); !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+// sparseFile 是只有头尾有内容的大文件，中间读出全零
+type sparseFile struct {
+	head []byte
+	tail []byte
+	size int64
+}
+
+func (f *sparseFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) && off+int64(n) < f.size {
+		pos := off + int64(n)
+		switch {
+		case pos < int64(len(f.head)):
+			p[n] = f.head[pos]
+		case pos >= f.size-int64(len(f.tail)):
+			p[n] = f.tail[pos-(f.size-int64(len(f.tail)))]
+		default:
+			p[n] = 0
+		}
+		n++
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestFaststartUpgradesToCo64(t *testing.T) {
+	// mdat 接近 4GB，moov 前移后最后一个 chunk 的偏移超出 32 位
+	const mdatSize = 1<<32 - 64
+	head := &builder{}
+	head.box("ftyp", func() { head.bytes([]byte("isom\x00\x00\x02\x00isom")) })
+	mdatOffset := len(head.buf)
+	head.u32(mdatSize)
+	head.bytes([]byte("mdat"))
+	moov := &builder{}
+	moov.box("moov", func() {
+		moov.box("trak", func() {
+			moov.box("mdia", func() {
+				moov.box("minf", func() {
+					moov.box("stbl", func() {
+						moov.fullBox("stco", 0, 0, func() {
+							moov.u32(2)
+							moov.u32(uint32(mdatOffset + 8))
+							moov.u32(uint32(mdatOffset + mdatSize - 16))
+						})
+					})
+				})
+			})
+		})
+	})
+	f := &sparseFile{head: head.buf, tail: moov.buf, size: int64(mdatOffset) + mdatSize + int64(len(moov.buf))}
+
+	layout, err := PlanFaststart(f, f.size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !layout.co64 {
+		t.Fatal("layout does not upgrade stco to co64")
+	}
+	rewritten, err := layout.rewrite(f, layout.moov)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 升级后每个表项多 4 个字节，mdat 后移的距离为新 moov 的大小
+	if len(rewritten) != len(moov.buf)+8 {
+		t.Errorf("rewritten moov is %d bytes, want %d", len(rewritten), len(moov.buf)+8)
+	}
+	r := bytes.NewReader(rewritten)
+	co64, err := Find(r, Box{Type: "moov", Offset: 0, Size: int64(len(rewritten)), HeaderSize: 8}, "trak", "mdia", "minf", "stbl", "co64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ReadData(r, co64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets, err := parseChunkOffsets("co64", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shift := uint64(len(rewritten))
+	want := []uint64{uint64(mdatOffset+8) + shift, uint64(mdatOffset+mdatSize-16) + shift}
+	if len(offsets) != 2 || offsets[0] != want[0] || offsets[1] != want[1] {
+		t.Errorf("co64 offsets = %v, want %v", offsets, want)
+	}
+}
+
+// moovSize 返回文件末尾 moov box 的大小
+func moovSize(data []byte) int {
+	top, _ := TopLevel(bytes.NewReader(data), int64(len(data)))
+	return int(top[len(top)-1].Size)
+}
@@ -0,0 +1,272 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// fMP4 输出中统一使用的轨道 ID（CMAF 要求每个文件只有一条轨道）
+const fragmentTrackID = 1
+
+// trun 样本标志
+const (
+	sampleFlagsSync    = 0x02000000 // sample_depends_on=2：不依赖其他样本
+	sampleFlagsNonSync = 0x01010000 // sample_depends_on=1 且 is_non_sync_sample=1
+)
+
+// 4x3 单位矩阵（16.16 / 2.30 定点数）
+var identityMatrix = []uint32{0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x40000000}
+
+// builder 以大端序拼接 box 内容
+type builder struct {
+	buf []byte
+}
+
+func (b *builder) u8(v uint8)     { b.buf = append(b.buf, v) }
+func (b *builder) u16(v uint16)   { b.buf = binary.BigEndian.AppendUint16(b.buf, v) }
+func (b *builder) u32(v uint32)   { b.buf = binary.BigEndian.AppendUint32(b.buf, v) }
+func (b *builder) u64(v uint64)   { b.buf = binary.BigEndian.AppendUint64(b.buf, v) }
+func (b *builder) bytes(v []byte) { b.buf = append(b.buf, v...) }
+func (b *builder) zeros(n int)    { b.buf = append(b.buf, make([]byte, n)...) }
+
+// box 写入一个 box，fn 负责写入负载
+func (b *builder) box(typ string, fn func()) {
+	start := len(b.buf)
+	b.u32(0)
+	b.bytes([]byte(typ))
+	fn()
+	binary.BigEndian.PutUint32(b.buf[start:], uint32(len(b.buf)-start))
+}
+
+// fullBox 写入带 version/flags 的 box
+func (b *builder) fullBox(typ string, version uint8, flags uint32, fn func()) {
+	b.box(typ, func() {
+		b.u32(uint32(version)<<24 | flags&0xFFFFFF)
+		fn()
+	})
+}
+
+// copyBox 从源文件原样复制一个 box
+func copyBox(r io.ReaderAt, box Box) ([]byte, error) {
+	buf := make([]byte, box.Size)
+	if _, err := r.ReadAt(buf, box.Offset); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// InitSegment 为单条轨道构建 CMAF 初始化分片（ftyp + moov），样本描述从源文件复制
+func InitSegment(r io.ReaderAt, track *Track, table *SampleTable) ([]byte, error) {
+	hdlrBox, err := Find(r, track.Trak, "mdia", "hdlr")
+	if err != nil {
+		return nil, err
+	}
+	hdlr, err := copyBox(r, hdlrBox)
+	if err != nil {
+		return nil, err
+	}
+	var mediaHeader []byte
+	for _, typ := range []string{"vmhd", "smhd", "sthd", "nmhd"} {
+		if box, err := Find(r, track.Trak, "mdia", "minf", typ); err == nil {
+			if mediaHeader, err = copyBox(r, box); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	matrix := identityMatrix
+	if tkhd, err := Find(r, track.Trak, "tkhd"); err == nil {
+		if data, err := ReadData(r, tkhd); err == nil {
+			base := 0
+			if data[0] == 1 {
+				base = 12
+			}
+			if len(data) >= base+76 {
+				matrix = make([]uint32, 9)
+				for i := range matrix {
+					matrix[i] = binary.BigEndian.Uint32(data[base+40+i*4:])
+				}
+			}
+		}
+	}
+	language := uint16(0x55c4) // und
+	if mdhd, err := Find(r, track.Trak, "mdia", "mdhd"); err == nil {
+		if data, err := ReadData(r, mdhd); err == nil {
+			off := 20
+			if data[0] == 1 {
+				off = 32
+			}
+			if len(data) >= off+2 {
+				language = binary.BigEndian.Uint16(data[off:])
+			}
+		}
+	}
+
+	b := &builder{}
+	b.box("ftyp", func() {
+		b.bytes([]byte("iso6"))
+		b.u32(0)
+		b.bytes([]byte("iso6cmfcdashmsdh"))
+	})
+	b.box("moov", func() {
+		b.fullBox("mvhd", 0, 0, func() {
+			b.u32(0) // creation_time
+			b.u32(0) // modification_time
+			b.u32(track.Timescale)
+			b.u32(0) // duration，分片文件由 mvex 描述
+			b.u32(0x00010000)
+			b.u16(0x0100)
+			b.zeros(10)
+			for _, v := range identityMatrix {
+				b.u32(v)
+			}
+			b.zeros(24)
+			b.u32(fragmentTrackID + 1)
+		})
+		b.box("trak", func() {
+			b.fullBox("tkhd", 0, 3, func() {
+				b.u32(0)
+				b.u32(0)
+				b.u32(fragmentTrackID)
+				b.u32(0)
+				b.u32(0)
+				b.zeros(8)
+				b.u16(0) // layer
+				b.u16(0) // alternate_group
+				if track.Handler == HandlerAudio {
+					b.u16(0x0100)
+				} else {
+					b.u16(0)
+				}
+				b.u16(0)
+				for _, v := range matrix {
+					b.u32(v)
+				}
+				b.u32(uint32(track.Width) << 16)
+				b.u32(uint32(track.Height) << 16)
+			})
+			b.box("mdia", func() {
+				b.fullBox("mdhd", 0, 0, func() {
+					b.u32(0)
+					b.u32(0)
+					b.u32(track.Timescale)
+					b.u32(0)
+					b.u16(language)
+					b.u16(0)
+				})
+				b.bytes(hdlr)
+				b.box("minf", func() {
+					b.bytes(mediaHeader)
+					b.box("dinf", func() {
+						b.fullBox("dref", 0, 0, func() {
+							b.u32(1)
+							b.fullBox("url ", 0, 1, func() {})
+						})
+					})
+					b.box("stbl", func() {
+						b.bytes(table.SampleEntry)
+						b.fullBox("stts", 0, 0, func() { b.u32(0) })
+						b.fullBox("stsc", 0, 0, func() { b.u32(0) })
+						b.fullBox("stsz", 0, 0, func() { b.u32(0); b.u32(0) })
+						b.fullBox("stco", 0, 0, func() { b.u32(0) })
+					})
+				})
+			})
+		})
+		b.box("mvex", func() {
+			b.fullBox("trex", 0, 0, func() {
+				b.u32(fragmentTrackID)
+				b.u32(1) // default_sample_description_index
+				b.u32(0)
+				b.u32(0)
+				b.u32(0)
+			})
+		})
+	})
+	return b.buf, nil
+}
+
+// Fragment 描述一个媒体分片
+type Fragment struct {
+	Sequence         uint32   // moof 序号，从 1 开始
+	BaseDecodeTime   uint64   // 第一个样本的解码时间
+	Samples          []Sample // 分片包含的样本，按解码顺序排列
+	CompositionShift int64    // 从显示偏移中减去的量（通常为编辑列表的 media_time）
+}
+
+// Size 返回样本数据的总字节数
+func (f *Fragment) Size() int64 {
+	var n int64
+	for _, s := range f.Samples {
+		n += int64(s.Size)
+	}
+	return n
+}
+
+// WriteFragment 写出 styp + moof + mdat，样本数据从源文件 r 复制，返回写入的字节数
+func WriteFragment(w io.Writer, r io.ReaderAt, f Fragment) (int64, error) {
+	b := &builder{}
+	b.box("styp", func() {
+		b.bytes([]byte("msdh"))
+		b.u32(0)
+		b.bytes([]byte("msdhmsix"))
+	})
+	moofStart := len(b.buf)
+	var dataOffsetPos int
+	b.box("moof", func() {
+		b.fullBox("mfhd", 0, 0, func() { b.u32(f.Sequence) })
+		b.box("traf", func() {
+			// default-base-is-moof：trun 的 data_offset 相对 moof 起始位置
+			b.fullBox("tfhd", 0, 0x020000, func() { b.u32(fragmentTrackID) })
+			b.fullBox("tfdt", 1, 0, func() { b.u64(f.BaseDecodeTime) })
+			// data-offset | duration | size | flags | composition-time-offset，
+			// version 1 允许负的显示偏移
+			b.fullBox("trun", 1, 0x000001|0x000100|0x000200|0x000400|0x000800, func() {
+				b.u32(uint32(len(f.Samples)))
+				dataOffsetPos = len(b.buf)
+				b.u32(0)
+				for _, s := range f.Samples {
+					b.u32(s.Duration)
+					b.u32(s.Size)
+					if s.Sync {
+						b.u32(sampleFlagsSync)
+					} else {
+						b.u32(sampleFlagsNonSync)
+					}
+					b.u32(uint32(int32(int64(s.CompositionOffset) - f.CompositionShift)))
+				}
+			})
+		})
+	})
+	mdatSize := 8 + f.Size()
+	binary.BigEndian.PutUint32(b.buf[dataOffsetPos:], uint32(len(b.buf)-moofStart+8))
+	b.u32(uint32(mdatSize))
+	b.bytes([]byte("mdat"))
+
+	written := int64(0)
+	n, err := w.Write(b.buf)
+	written += int64(n)
+	if err != nil {
+		return written, err
+	}
+	// 按连续区间合并读取，减少小样本的系统调用
+	for i := 0; i < len(f.Samples); {
+		start := f.Samples[i].Offset
+		end := start + int64(f.Samples[i].Size)
+		j := i + 1
+		for j < len(f.Samples) && f.Samples[j].Offset == end && end-start < 4<<20 {
+			end += int64(f.Samples[j].Size)
+			j++
+		}
+		n, err := io.Copy(w, io.NewSectionReader(r, start, end-start))
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if n != end-start {
+			return written, io.ErrUnexpectedEOF
+		}
+		i = j
+	}
+	return written, nil
+}
@@ -0,0 +1,254 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// 轨道的 handler 类型
+const (
+	HandlerVideo = "vide"
+	HandlerAudio = "soun"
+)
+
+// Movie 是从 moov box 中解析出的影片级信息
+type Movie struct {
+	Moov      Box     // moov box 的位置
+	Timescale uint32  // mvhd 时间刻度
+	Duration  uint64  // mvhd 时长（以 Timescale 为单位）
+	Tracks    []Track // 所有轨道
+}
+
+// Seconds 返回影片时长（秒）
+func (m *Movie) Seconds() float64 {
+	if m.Timescale == 0 {
+		return 0
+	}
+	return float64(m.Duration) / float64(m.Timescale)
+}
+
+// Track 返回第一个指定 handler 类型的轨道，找不到时返回 nil
+func (m *Movie) Track(handler string) *Track {
+	for i := range m.Tracks {
+		if m.Tracks[i].Handler == handler {
+			return &m.Tracks[i]
+		}
+	}
+	return nil
+}
+
+// Track 是从 trak box 中解析出的轨道头信息
+type Track struct {
+	Trak        Box    // trak box 的位置
+	ID          uint32 // tkhd 轨道 ID
+	Handler     string // vide/soun/...
+	Codec       string // stsd 第一个样本描述的类型，如 avc1/hvc1/mp4a
+	Timescale   uint32 // mdhd 时间刻度
+	Duration    uint64 // mdhd 时长（以 Timescale 为单位）
+	Width       int    // 显示宽度（tkhd，未考虑旋转）
+	Height      int    // 显示高度（tkhd，未考虑旋转）
+	Rotation    int    // 由 tkhd 矩阵得出的旋转角度：0/90/180/270
+	SampleCount uint32 // stts 中的样本总数
+	Channels    int    // 音频声道数
+	SampleRate  int    // 音频采样率
+}
+
+// Seconds 返回轨道时长（秒）
+func (t *Track) Seconds() float64 {
+	if t.Timescale == 0 {
+		return 0
+	}
+	return float64(t.Duration) / float64(t.Timescale)
+}
+
+// FrameRate 根据样本数和时长估算帧率，仅对视频轨道有意义
+func (t *Track) FrameRate() float64 {
+	secs := t.Seconds()
+	if secs <= 0 || t.SampleCount == 0 {
+		return 0
+	}
+	return float64(t.SampleCount) / secs
+}
+
+var errShortBox = errors.New("mp4: box too short")
+
+// ReadMovie 定位并解析文件中的 moov box
+func ReadMovie(r io.ReaderAt, size int64) (*Movie, error) {
+	top, err := TopLevel(r, size)
+	if err != nil && len(top) == 0 {
+		return nil, err
+	}
+	for _, box := range top {
+		if box.Type == "moov" {
+			return parseMoov(r, box)
+		}
+	}
+	return nil, ErrBoxNotFound
+}
+
+func parseMoov(r io.ReaderAt, moov Box) (*Movie, error) {
+	movie := &Movie{Moov: moov}
+
+	mvhd, err := Find(r, moov, "mvhd")
+	if err != nil {
+		return nil, err
+	}
+	data, err := ReadData(r, mvhd)
+	if err != nil {
+		return nil, err
+	}
+	movie.Timescale, movie.Duration, err = parseTimes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	traks, err := FindAll(r, moov, "trak")
+	if err != nil {
+		return nil, err
+	}
+	for _, trak := range traks {
+		track, err := parseTrak(r, trak)
+		if err != nil {
+			return nil, err
+		}
+		movie.Tracks = append(movie.Tracks, *track)
+	}
+	return movie, nil
+}
+
+// parseTimes 解析 mvhd/mdhd 共有的 timescale 与 duration 字段
+func parseTimes(data []byte) (uint32, uint64, error) {
+	if len(data) < 20 {
+		return 0, 0, errShortBox
+	}
+	if data[0] == 1 {
+		if len(data) < 32 {
+			return 0, 0, errShortBox
+		}
+		return binary.BigEndian.Uint32(data[20:24]), binary.BigEndian.Uint64(data[24:32]), nil
+	}
+	return binary.BigEndian.Uint32(data[12:16]), uint64(binary.BigEndian.Uint32(data[16:20])), nil
+}
+
+func parseTrak(r io.ReaderAt, trak Box) (*Track, error) {
+	track := &Track{Trak: trak}
+
+	tkhd, err := Find(r, trak, "tkhd")
+	if err != nil {
+		return nil, err
+	}
+	data, err := ReadData(r, tkhd)
+	if err != nil {
+		return nil, err
+	}
+	if err := parseTkhd(track, data); err != nil {
+		return nil, err
+	}
+
+	if mdhd, err := Find(r, trak, "mdia", "mdhd"); err == nil {
+		data, err := ReadData(r, mdhd)
+		if err != nil {
+			return nil, err
+		}
+		if track.Timescale, track.Duration, err = parseTimes(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if hdlr, err := Find(r, trak, "mdia", "hdlr"); err == nil {
+		data, err := ReadData(r, hdlr)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) >= 12 {
+			track.Handler = string(data[8:12])
+		}
+	}
+
+	stbl, err := Find(r, trak, "mdia", "minf", "stbl")
+	if err != nil {
+		// 没有样本表的轨道（如部分 fragmented MP4）只保留头信息
+		return track, nil
+	}
+	if stsd, err := Find(r, stbl, "stsd"); err == nil {
+		data, err := ReadData(r, stsd)
+		if err != nil {
+			return nil, err
+		}
+		parseStsd(track, data)
+	}
+	if stts, err := Find(r, stbl, "stts"); err == nil {
+		data, err := ReadData(r, stts)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) >= 8 {
+			count := binary.BigEndian.Uint32(data[4:8])
+			for i := uint32(0); i < count && int(8+i*8+8) <= len(data); i++ {
+				track.SampleCount += binary.BigEndian.Uint32(data[8+i*8:])
+			}
+		}
+	}
+	return track, nil
+}
+
+func parseTkhd(track *Track, data []byte) error {
+	// 版本 0 与版本 1 的时间字段长度不同，其余字段相对偏移一致
+	base := 0
+	if len(data) > 0 && data[0] == 1 {
+		base = 12
+	}
+	if len(data) < base+84 {
+		return errShortBox
+	}
+	track.ID = binary.BigEndian.Uint32(data[base+12 : base+16])
+	matrix := data[base+40 : base+76]
+	track.Rotation = rotation(
+		int32(binary.BigEndian.Uint32(matrix[0:4])),
+		int32(binary.BigEndian.Uint32(matrix[4:8])),
+	)
+	// 宽高为 16.16 定点数
+	track.Width = int(binary.BigEndian.Uint32(data[base+76:base+80]) >> 16)
+	track.Height = int(binary.BigEndian.Uint32(data[base+80:base+84]) >> 16)
+	return nil
+}
+
+// rotation 根据变换矩阵的 a、b 两项判断旋转角度
+func rotation(a, b int32) int {
+	const one = 1 << 16
+	switch {
+	case a == 0 && b == one:
+		return 90
+	case a == -one && b == 0:
+		return 180
+	case a == 0 && b == -one:
+		return 270
+	default:
+		return 0
+	}
+}
+
+func parseStsd(track *Track, data []byte) {
+	// version/flags(4) + entry_count(4) + 第一个样本描述 box
+	if len(data) < 16 {
+		return
+	}
+	entry := data[8:]
+	track.Codec = string(entry[4:8])
+	body := entry[8:]
+	switch track.Handler {
+	case HandlerVideo:
+		// VisualSampleEntry: reserved(6) data_ref(2) pre_defined/reserved(16) width(2) height(2)
+		if len(body) >= 28 && (track.Width == 0 || track.Height == 0) {
+			track.Width = int(binary.BigEndian.Uint16(body[24:26]))
+			track.Height = int(binary.BigEndian.Uint16(body[26:28]))
+		}
+	case HandlerAudio:
+		// AudioSampleEntry: reserved(6) data_ref(2) reserved(8) channels(2) sample_size(2) reserved(4) sample_rate(4)
+		if len(body) >= 28 {
+			track.Channels = int(binary.BigEndian.Uint16(body[16:18]))
+			track.SampleRate = int(binary.BigEndian.Uint32(body[24:28]) >> 16)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNoSampleTable 轨道没有可用的样本表（如 fragmented MP4）
+var ErrNoSampleTable = errors.New("mp4: track has no sample table")
+
+// Sample 描述轨道中的一个样本（一帧视频或一个音频帧）
+type Sample struct {
+	Offset            int64  // 样本数据在文件中的偏移
+	Size              uint32 // 样本大小
+	DTS               uint64 // 解码时间（轨道时间刻度）
+	Duration          uint32 // 样本时长（轨道时间刻度）
+	CompositionOffset int32  // 显示时间相对解码时间的偏移
+	Sync              bool   // 是否为关键帧
+}
+
+// SampleTable 是轨道完整的样本表及解封装所需的描述信息
+type SampleTable struct {
+	Samples     []Sample
+	SampleEntry []byte // stsd 完整 box（包含头部），用于写入 fMP4 初始化分片
+	MediaTime   int64  // 编辑列表的起始媒体时间，常用于抵消 B 帧引入的显示延迟
+}
+
+// ReadSamples 解析轨道的 stbl，展开为逐样本列表，size 为文件大小
+func ReadSamples(r io.ReaderAt, size int64, track *Track) (*SampleTable, error) {
+	stbl, err := Find(r, track.Trak, "mdia", "minf", "stbl")
+	if err != nil {
+		return nil, ErrNoSampleTable
+	}
+	read := func(typ string, required bool) ([]byte, error) {
+		box, err := Find(r, stbl, typ)
+		if err != nil {
+			if !required && errors.Is(err, ErrBoxNotFound) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("mp4: read %s: %w", typ, err)
+		}
+		return ReadData(r, box)
+	}
+
+	table := &SampleTable{}
+	stsdBox, err := Find(r, stbl, "stsd")
+	if err != nil {
+		return nil, fmt.Errorf("mp4: read stsd: %w", err)
+	}
+	table.SampleEntry = make([]byte, stsdBox.Size)
+	if _, err := r.ReadAt(table.SampleEntry, stsdBox.Offset); err != nil {
+		return nil, err
+	}
+
+	stts, err := read("stts", true)
+	if err != nil {
+		return nil, err
+	}
+	stsz, err := read("stsz", true)
+	if err != nil {
+		return nil, err
+	}
+	sizes, err := parseStsz(stsz, sampleLimit(stts), size)
+	if err != nil {
+		return nil, err
+	}
+	if len(sizes) == 0 {
+		return nil, ErrNoSampleTable
+	}
+
+	offsets, err := chunkOffsets(r, stbl)
+	if err != nil {
+		return nil, err
+	}
+	stsc, err := read("stsc", true)
+	if err != nil {
+		return nil, err
+	}
+	ctts, err := read("ctts", false)
+	if err != nil {
+		return nil, err
+	}
+	stss, err := read("stss", false)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]Sample, len(sizes))
+	for i, size := range sizes {
+		samples[i].Size = size
+		// 没有 stss 时所有样本都是关键帧
+		samples[i].Sync = stss == nil
+	}
+	if err := applyChunks(samples, stsc, offsets); err != nil {
+		return nil, err
+	}
+	if err := applyTimes(samples, stts); err != nil {
+		return nil, err
+	}
+	if err := applyCompositionOffsets(samples, ctts); err != nil {
+		return nil, err
+	}
+	if err := applySyncSamples(samples, stss); err != nil {
+		return nil, err
+	}
+	table.Samples = samples
+	table.MediaTime = editMediaTime(r, track.Trak)
+	return table, nil
+}
+
+var errBadSampleTable = errors.New("mp4: malformed sample table")
+
+// entries 校验 full box 的 entry_count 并返回条目数据
+func entries(data []byte, headerLen, entryLen int) (int, []byte, error) {
+	if len(data) < headerLen {
+		return 0, nil, errBadSampleTable
+	}
+	count := int(binary.BigEndian.Uint32(data[headerLen-4 : headerLen]))
+	body := data[headerLen:]
+	if count < 0 || len(body)/entryLen < count {
+		return 0, nil, errBadSampleTable
+	}
+	return count, body, nil
+}
+
+// sampleLimit 返回 stsz 样本数的上限：stts 的样本总数，部分文件的 stts 少写最后一个样本，多留一个
+func sampleLimit(stts []byte) uint64 {
+	count, body, err := entries(stts, 8, 8)
+	if err != nil {
+		return 0
+	}
+	limit := uint64(1)
+	for i := 0; i < count; i++ {
+		limit += uint64(binary.BigEndian.Uint32(body[i*8:]))
+	}
+	return limit
+}
+
+// parseStsz 展开样本大小表，样本数超过 limit 或固定大小的样本超出文件大小时返回错误
+// 固定大小时样本数只占 4 个字节，不校验的话一个 20 字节的 stsz 就能申请数 GB 内存
+func parseStsz(data []byte, limit uint64, fileSize int64) ([]uint32, error) {
+	if len(data) < 12 {
+		return nil, errBadSampleTable
+	}
+	fixed := binary.BigEndian.Uint32(data[4:8])
+	count := int(binary.BigEndian.Uint32(data[8:12]))
+	if uint64(count) > limit {
+		return nil, errBadSampleTable
+	}
+	sizes := make([]uint32, 0, min(count, 1<<20))
+	if fixed != 0 {
+		if uint64(count)*uint64(fixed) > uint64(fileSize) {
+			return nil, errBadSampleTable
+		}
+		for range count {
+			sizes = append(sizes, fixed)
+		}
+		return sizes, nil
+	}
+	if (len(data)-12)/4 < count {
+		return nil, errBadSampleTable
+	}
+	for i := range count {
+		sizes = append(sizes, binary.BigEndian.Uint32(data[12+i*4:]))
+	}
+	return sizes, nil
+}
+
+func chunkOffsets(r io.ReaderAt, stbl Box) ([]int64, error) {
+	if box, err := Find(r, stbl, "stco"); err == nil {
+		data, err := ReadData(r, box)
+		if err != nil {
+			return nil, err
+		}
+		count, body, err := entries(data, 8, 4)
+		if err != nil {
+			return nil, err
+		}
+		offsets := make([]int64, count)
+		for i := range offsets {
+			offsets[i] = int64(binary.BigEndian.Uint32(body[i*4:]))
+		}
+		return offsets, nil
+	}
+	box, err := Find(r, stbl, "co64")
+	if err != nil {
+		return nil, fmt.Errorf("mp4: read stco/co64: %w", err)
+	}
+	data, err := ReadData(r, box)
+	if err != nil {
+		return nil, err
+	}
+	count, body, err := entries(data, 8, 8)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make([]int64, count)
+	for i := range offsets {
+		offsets[i] = int64(binary.BigEndian.Uint64(body[i*8:]))
+	}
+	return offsets, nil
+}
+
+// applyChunks 根据 stsc 的 chunk 分布和 chunk 偏移计算每个样本的文件偏移
+func applyChunks(samples []Sample, stsc []byte, offsets []int64) error {
+	count, body, err := entries(stsc, 8, 12)
+	if err != nil {
+		return err
+	}
+	next := 0
+	for i := 0; i < count && next < len(samples); i++ {
+		firstChunk := int(binary.BigEndian.Uint32(body[i*12:]))
+		perChunk := int(binary.BigEndian.Uint32(body[i*12+4:]))
+		lastChunk := len(offsets)
+		if i+1 < count {
+			lastChunk = int(binary.BigEndian.Uint32(body[(i+1)*12:])) - 1
+		}
+		if firstChunk < 1 || lastChunk > len(offsets) {
+			return errBadSampleTable
+		}
+		for chunk := firstChunk; chunk <= lastChunk && next < len(samples); chunk++ {
+			off := offsets[chunk-1]
+			for j := 0; j < perChunk && next < len(samples); j++ {
+				samples[next].Offset = off
+				off += int64(samples[next].Size)
+				next++
+			}
+		}
+	}
+	if next != len(samples) {
+		return errBadSampleTable
+	}
+	return nil
+}
+
+func applyTimes(samples []Sample, stts []byte) error {
+	count, body, err := entries(stts, 8, 8)
+	if err != nil {
+		return err
+	}
+	next := 0
+	var dts uint64
+	for i := 0; i < count; i++ {
+		n := int(binary.BigEndian.Uint32(body[i*8:]))
+		delta := binary.BigEndian.Uint32(body[i*8+4:])
+		for j := 0; j < n && next < len(samples); j++ {
+			samples[next].DTS = dts
+			samples[next].Duration = delta
+			dts += uint64(delta)
+			next++
+		}
+	}
+	// 部分文件的 stts 少写了最后一个样本，沿用前一个样本的时长
+	for ; next < len(samples) && next > 0; next++ {
+		samples[next].Duration = samples[next-1].Duration
+		samples[next].DTS = samples[next-1].DTS + uint64(samples[next-1].Duration)
+	}
+	return nil
+}
+
+func applyCompositionOffsets(samples []Sample, ctts []byte) error {
+	if ctts == nil {
+		return nil
+	}
+	count, body, err := entries(ctts, 8, 8)
+	if err != nil {
+		return err
+	}
+	next := 0
+	for i := 0; i < count; i++ {
+		n := int(binary.BigEndian.Uint32(body[i*8:]))
+		// version 0 定义为无符号，实际文件中按有符号解释也兼容
+		offset := int32(binary.BigEndian.Uint32(body[i*8+4:]))
+		for j := 0; j < n && next < len(samples); j++ {
+			samples[next].CompositionOffset = offset
+			next++
+		}
+	}
+	return nil
+}
+
+func applySyncSamples(samples []Sample, stss []byte) error {
+	if stss == nil {
+		return nil
+	}
+	count, body, err := entries(stss, 8, 4)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < count; i++ {
+		n := int(binary.BigEndian.Uint32(body[i*4:]))
+		if n >= 1 && n <= len(samples) {
+			samples[n-1].Sync = true
+		}
+	}
+	return nil
+}
+
+// editMediaTime 读取 edts/elst 第一个非空编辑的 media_time，不存在时返回 0
+func editMediaTime(r io.ReaderAt, trak Box) int64 {
+	elst, err := Find(r, trak, "edts", "elst")
+	if err != nil {
+		return 0
+	}
+	data, err := ReadData(r, elst)
+	if err != nil || len(data) < 8 {
+		return 0
+	}
+	count := int(binary.BigEndian.Uint32(data[4:8]))
+	entrySize := 12
+	if data[0] == 1 {
+		entrySize = 20
+	}
+	for i := 0; i < count && 8+(i+1)*entrySize <= len(data); i++ {
+		entry := data[8+i*entrySize:]
+		var mediaTime int64
+		if data[0] == 1 {
+			mediaTime = int64(binary.BigEndian.Uint64(entry[8:16]))
+		} else {
+			mediaTime = int64(int32(binary.BigEndian.Uint32(entry[4:8])))
+		}
+		// media_time 为 -1 表示空编辑（起始留白），跳过
+		if mediaTime >= 0 {
+			return mediaTime
+		}
+	}
+	return 0
+}
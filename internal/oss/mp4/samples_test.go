@@ -0,0 +1,63 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// stsz 编码 stsz 的负载，fixed 为 0 时写入 sizes
+func stsz(fixed, count uint32, sizes ...uint32) []byte {
+	b := make([]byte, 4)
+	b = binary.BigEndian.AppendUint32(b, fixed)
+	b = binary.BigEndian.AppendUint32(b, count)
+	for _, s := range sizes {
+		b = binary.BigEndian.AppendUint32(b, s)
+	}
+	return b
+}
+
+func TestParseStsz(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		limit uint64
+		size  int64
+		want  int
+		err   error
+	}{
+		{"table", stsz(0, 3, 10, 20, 30), 4, 100, 3, nil},
+		{"fixed", stsz(100, 5), 6, 1000, 5, nil},
+		{"short box", stsz(0, 0)[:8], 1, 100, 0, errBadSampleTable},
+		{"short table", stsz(0, 3, 10, 20), 4, 100, 0, errBadSampleTable},
+		// 样本数只占 4 个字节，必须受 stts 与文件大小约束
+		{"count over stts", stsz(0, 5, 1, 2, 3, 4, 5), 4, 100, 0, errBadSampleTable},
+		{"huge fixed count", stsz(1, 0xFFFFFFFF), 0xFFFFFFFF + 1, 1 << 20, 0, errBadSampleTable},
+		{"fixed beyond file", stsz(100, 11), 12, 1000, 0, errBadSampleTable},
+	}
+	for _, tt := range tests {
+		sizes, err := parseStsz(tt.data, tt.limit, tt.size)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if len(sizes) != tt.want {
+			t.Errorf("%s: %d samples, want %d", tt.name, len(sizes), tt.want)
+		}
+	}
+}
+
+func TestSampleLimit(t *testing.T) {
+	stts := make([]byte, 4)
+	stts = binary.BigEndian.AppendUint32(stts, 2)
+	for _, v := range []uint32{10, 512, 5, 1024} {
+		stts = binary.BigEndian.AppendUint32(stts, v)
+	}
+	// stts 少写最后一个样本时仍可解析
+	if got := sampleLimit(stts); got != 16 {
+		t.Errorf("sampleLimit = %d, want 16", got)
+	}
+	if got := sampleLimit(stts[:12]); got != 0 {
+		t.Errorf("sampleLimit of a truncated stts = %d, want 0", got)
+	}
+}
@@ -0,0 +1,209 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	errBadMP3 = errors.New("probe: no MPEG audio frame found")
+	errBadWAV = errors.New("probe: malformed WAV")
+)
+
+// MPEG 音频帧头中的码率表（kbps），按 [版本族][层] 索引
+var mp3Bitrates = [2][3][16]int{
+	{ // MPEG-1
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0}, // Layer I
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},    // Layer II
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},     // Layer III
+	},
+	{ // MPEG-2 / MPEG-2.5
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	},
+}
+
+// 采样率表，按版本位（00=2.5, 10=2, 11=1）索引
+var mp3SampleRates = map[uint32][3]int{
+	0: {11025, 12000, 8000},
+	2: {22050, 24000, 16000},
+	3: {44100, 48000, 32000},
+}
+
+// mp3Frame 是解析后的 MPEG 音频帧头
+type mp3Frame struct {
+	version    uint32 // 0=MPEG-2.5 2=MPEG-2 3=MPEG-1
+	layer      int    // 1/2/3
+	bitrate    int    // kbps
+	sampleRate int
+	mono       bool
+}
+
+func parseMP3Header(h uint32) (mp3Frame, bool) {
+	if h>>21 != 0x7FF {
+		return mp3Frame{}, false
+	}
+	version := (h >> 19) & 3
+	layerBits := (h >> 17) & 3
+	bitrateIdx := (h >> 12) & 0xF
+	rateIdx := (h >> 10) & 3
+	if version == 1 || layerBits == 0 || bitrateIdx == 0 || bitrateIdx == 15 || rateIdx == 3 {
+		return mp3Frame{}, false
+	}
+	f := mp3Frame{version: version, layer: int(4 - layerBits)}
+	family := 0
+	if version != 3 {
+		family = 1
+	}
+	f.bitrate = mp3Bitrates[family][f.layer-1][bitrateIdx]
+	f.sampleRate = mp3SampleRates[version][rateIdx]
+	f.mono = (h>>6)&3 == 3
+	return f, true
+}
+
+func (f mp3Frame) samplesPerFrame() int {
+	switch {
+	case f.layer == 1:
+		return 384
+	case f.layer == 3 && f.version != 3:
+		return 576
+	default:
+		return 1152
+	}
+}
+
+// probeMP3 跳过 ID3v2 标签，解析第一个帧头，优先使用 Xing/Info/VBRI 中的帧数计算时长
+func probeMP3(r io.ReaderAt, size int64) (*Info, error) {
+	var start int64
+	var id3 [10]byte
+	if _, err := r.ReadAt(id3[:], 0); err == nil && string(id3[:3]) == "ID3" {
+		// ID3v2 标签长度为 28 位 syncsafe 整数
+		tagSize := int64(id3[6])<<21 | int64(id3[7])<<14 | int64(id3[8])<<7 | int64(id3[9])
+		start = 10 + tagSize
+		if id3[5]&0x10 != 0 {
+			start += 10
+		}
+	}
+
+	// 在标签之后的一小段范围内寻找帧同步
+	const scanLimit = 64 * 1024
+	buf := make([]byte, scanLimit)
+	n, err := r.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		frame, ok := parseMP3Header(binary.BigEndian.Uint32(buf[i:]))
+		if !ok {
+			continue
+		}
+		info := &Info{Format: "mp3", MimeType: "audio/mpeg", Kind: KindAudio, AudioCodec: "mp3"}
+		if frame.layer != 3 {
+			info.Format, info.AudioCodec = "mp2", "mp2"
+		}
+		audioStart := start + int64(i)
+
+		if frames := vbrFrameCount(buf[i:], frame); frames > 0 {
+			info.Duration = float64(frames) * float64(frame.samplesPerFrame()) / float64(frame.sampleRate)
+			return info, nil
+		}
+		// CBR：按首帧码率估算
+		info.Bitrate = int64(frame.bitrate) * 1000
+		info.Duration = float64(size-audioStart) * 8 / float64(info.Bitrate)
+		return info, nil
+	}
+	return nil, errBadMP3
+}
+
+// vbrFrameCount 读取 Xing/Info 或 VBRI 头中的总帧数，不存在时返回 0
+func vbrFrameCount(frame []byte, f mp3Frame) uint32 {
+	// Xing 头位于侧信息之后，侧信息长度取决于版本与声道
+	sideInfo := 32
+	switch {
+	case f.version == 3 && f.mono:
+		sideInfo = 17
+	case f.version != 3 && !f.mono:
+		sideInfo = 17
+	case f.version != 3 && f.mono:
+		sideInfo = 9
+	}
+	off := 4 + sideInfo
+	if len(frame) >= off+12 {
+		tag := string(frame[off : off+4])
+		if (tag == "Xing" || tag == "Info") && binary.BigEndian.Uint32(frame[off+4:])&1 != 0 {
+			return binary.BigEndian.Uint32(frame[off+8:])
+		}
+	}
+	// VBRI 头固定位于帧头之后 32 字节
+	if len(frame) >= 4+32+18 && string(frame[36:40]) == "VBRI" {
+		return binary.BigEndian.Uint32(frame[36+14:])
+	}
+	return 0
+}
+
+// probeWAV 解析 RIFF/WAVE 的 fmt 与 data 块
+func probeWAV(r io.ReaderAt, size int64) (*Info, error) {
+	info := &Info{Format: "wav", MimeType: "audio/wav", Kind: KindAudio}
+	var byteRate uint32
+	var dataSize int64 = -1
+
+	var hdr [8]byte
+	for off := int64(12); off+8 <= size; {
+		if _, err := r.ReadAt(hdr[:], off); err != nil {
+			return nil, err
+		}
+		chunkSize := int64(binary.LittleEndian.Uint32(hdr[4:8]))
+		body := off + 8
+		switch string(hdr[:4]) {
+		case "fmt ":
+			var fmtChunk [16]byte
+			if chunkSize < 16 {
+				return nil, errBadWAV
+			}
+			if _, err := r.ReadAt(fmtChunk[:], body); err != nil {
+				return nil, err
+			}
+			format := binary.LittleEndian.Uint16(fmtChunk[0:2])
+			byteRate = binary.LittleEndian.Uint32(fmtChunk[8:12])
+			bits := binary.LittleEndian.Uint16(fmtChunk[14:16])
+			info.AudioCodec = wavCodec(format, bits)
+		case "data":
+			// 部分录音软件写入的 data 长度大于文件实际长度
+			dataSize = min(chunkSize, size-body)
+		}
+		if byteRate > 0 && dataSize >= 0 {
+			break
+		}
+		// RIFF 块按偶数字节对齐
+		off = body + chunkSize + chunkSize&1
+	}
+	if byteRate == 0 || dataSize < 0 {
+		return nil, errBadWAV
+	}
+	info.Bitrate = int64(byteRate) * 8
+	info.Duration = float64(dataSize) / float64(byteRate)
+	return info, nil
+}
+
+func wavCodec(format, bits uint16) string {
+	switch format {
+	case 1, 0xFFFE: // PCM / WAVE_FORMAT_EXTENSIBLE
+		if bits == 8 {
+			return "pcm_u8"
+		}
+		return fmt.Sprintf("pcm_s%dle", bits)
+	case 3:
+		return fmt.Sprintf("pcm_f%dle", bits)
+	case 6:
+		return "pcm_alaw"
+	case 7:
+		return "pcm_mulaw"
+	default:
+		return fmt.Sprintf("wav_0x%04x", format)
+	}
+}
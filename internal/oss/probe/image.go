@@ -0,0 +1,69 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif"  // 注册 GIF 解码器
+	_ "image/jpeg" // 注册 JPEG 解码器
+	_ "image/png"  // 注册 PNG 解码器
+	"io"
+)
+
+var errBadWebP = errors.New("probe: malformed WebP")
+
+// probeImage 通过 image.DecodeConfig 只解析 JPEG/PNG/GIF 的头部获取尺寸
+func probeImage(r io.ReaderAt, size int64) (*Info, error) {
+	cfg, format, err := image.DecodeConfig(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	return &Info{
+		Format:   format,
+		MimeType: "image/" + format,
+		Kind:     KindImage,
+		Width:    cfg.Width,
+		Height:   cfg.Height,
+	}, nil
+}
+
+// probeWebP 解析 RIFF/WEBP 中第一个 VP8/VP8L/VP8X 块的画布尺寸
+func probeWebP(r io.ReaderAt, size int64) (*Info, error) {
+	var chunk [30]byte
+	n, err := r.ReadAt(chunk[:], 12)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if n < 18 {
+		return nil, errBadWebP
+	}
+	info := &Info{Format: "webp", MimeType: "image/webp", Kind: KindImage}
+	data := chunk[8:n]
+	switch string(chunk[0:4]) {
+	case "VP8 ":
+		// 3 字节帧标签 + 3 字节起始码 9d 01 2a，之后是 14 位宽高
+		if len(data) < 10 || data[3] != 0x9d || data[4] != 0x01 || data[5] != 0x2a {
+			return nil, errBadWebP
+		}
+		info.Width = int(binary.LittleEndian.Uint16(data[6:8]) & 0x3fff)
+		info.Height = int(binary.LittleEndian.Uint16(data[8:10]) & 0x3fff)
+	case "VP8L":
+		// 1 字节签名 0x2f，之后 14 位 width-1 与 14 位 height-1
+		if len(data) < 5 || data[0] != 0x2f {
+			return nil, errBadWebP
+		}
+		bits := binary.LittleEndian.Uint32(data[1:5])
+		info.Width = int(bits&0x3fff) + 1
+		info.Height = int((bits>>14)&0x3fff) + 1
+	case "VP8X":
+		// 4 字节标志位，之后 24 位 canvas width-1 与 height-1
+		if len(data) < 10 {
+			return nil, errBadWebP
+		}
+		info.Width = int(uint32(data[4])|uint32(data[5])<<8|uint32(data[6])<<16) + 1
+		info.Height = int(uint32(data[7])|uint32(data[8])<<8|uint32(data[9])<<16) + 1
+	default:
+		return nil, errBadWebP
+	}
+	return info, nil
+}
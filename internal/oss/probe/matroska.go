@@ -0,0 +1,274 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// EBML / Matroska 元素 ID（保留长度标记位）
+const (
+	ebmlHeader          = 0x1A45DFA3
+	ebmlDocType         = 0x4282
+	mkvSegment          = 0x18538067
+	mkvInfo             = 0x1549A966
+	mkvTimestampScale   = 0x2AD7B1
+	mkvDuration         = 0x4489
+	mkvTracks           = 0x1654AE6B
+	mkvTrackEntry       = 0xAE
+	mkvTrackType        = 0x83
+	mkvCodecID          = 0x86
+	mkvDefaultDuration  = 0x23E383
+	mkvVideo            = 0xE0
+	mkvPixelWidth       = 0xB0
+	mkvPixelHeight      = 0xBA
+	mkvCluster          = 0x1F43B675
+	mkvTrackTypeVideo   = 1
+	mkvTrackTypeAudio   = 2
+	ebmlUnknownSize     = -1
+	maxEBMLElementBytes = 1 << 20 // 头部元素的合理上限，防止恶意长度
+)
+
+var errBadEBML = errors.New("probe: malformed EBML")
+
+type ebmlElement struct {
+	id      uint32
+	dataOff int64
+	size    int64 // ebmlUnknownSize 表示长度未知（直播流）
+}
+
+// readVint 读取 EBML 变长整数；keepMarker 为 true 时保留长度标记位（用于元素 ID）
+func readVint(r io.ReaderAt, off int64, keepMarker bool) (uint64, int, error) {
+	var first [1]byte
+	if _, err := r.ReadAt(first[:], off); err != nil {
+		return 0, 0, err
+	}
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, 0, errBadEBML
+	}
+	buf := make([]byte, length)
+	if _, err := r.ReadAt(buf, off); err != nil {
+		return 0, 0, err
+	}
+	if !keepMarker {
+		buf[0] &= byte(0xFF >> length)
+	}
+	var v uint64
+	for _, b := range buf {
+		v = v<<8 | uint64(b)
+	}
+	return v, length, nil
+}
+
+func readElement(r io.ReaderAt, off int64) (ebmlElement, error) {
+	id, idLen, err := readVint(r, off, true)
+	if err != nil {
+		return ebmlElement{}, err
+	}
+	size, sizeLen, err := readVint(r, off+int64(idLen), false)
+	if err != nil {
+		return ebmlElement{}, err
+	}
+	el := ebmlElement{id: uint32(id), dataOff: off + int64(idLen+sizeLen), size: int64(size)}
+	// 所有数据位为 1 表示未知长度
+	if size == (uint64(1)<<(7*sizeLen))-1 {
+		el.size = ebmlUnknownSize
+	}
+	return el, nil
+}
+
+// ebmlChildren 遍历 [start, end) 内的子元素，fn 返回 false 时停止
+func ebmlChildren(r io.ReaderAt, start, end int64, fn func(el ebmlElement) (bool, error)) error {
+	for off := start; off < end; {
+		el, err := readElement(r, off)
+		if err != nil {
+			return err
+		}
+		more, err := fn(el)
+		if err != nil || !more {
+			return err
+		}
+		if el.size == ebmlUnknownSize {
+			return nil
+		}
+		off = el.dataOff + el.size
+	}
+	return nil
+}
+
+func readEBMLBytes(r io.ReaderAt, el ebmlElement) ([]byte, error) {
+	if el.size < 0 || el.size > maxEBMLElementBytes {
+		return nil, errBadEBML
+	}
+	buf := make([]byte, el.size)
+	if _, err := r.ReadAt(buf, el.dataOff); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func readEBMLUint(r io.ReaderAt, el ebmlElement) (uint64, error) {
+	buf, err := readEBMLBytes(r, el)
+	if err != nil || len(buf) > 8 {
+		return 0, errBadEBML
+	}
+	var v uint64
+	for _, b := range buf {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+func readEBMLFloat(r io.ReaderAt, el ebmlElement) (float64, error) {
+	buf, err := readEBMLBytes(r, el)
+	if err != nil {
+		return 0, err
+	}
+	switch len(buf) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(buf))), nil
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(buf)), nil
+	default:
+		return 0, errBadEBML
+	}
+}
+
+// probeMatroska 解析 WebM/Matroska 的 EBML 头、Segment/Info 和 Tracks
+func probeMatroska(r io.ReaderAt, size int64) (*Info, error) {
+	info := &Info{Format: "mkv", MimeType: "video/x-matroska", Kind: KindVideo}
+
+	header, err := readElement(r, 0)
+	if err != nil || header.id != ebmlHeader {
+		return nil, errBadEBML
+	}
+	err = ebmlChildren(r, header.dataOff, header.dataOff+header.size, func(el ebmlElement) (bool, error) {
+		if el.id == ebmlDocType {
+			docType, err := readEBMLBytes(r, el)
+			if err != nil {
+				return false, err
+			}
+			if string(docType) == "webm" {
+				info.Format, info.MimeType = "webm", "video/webm"
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	segment, err := readElement(r, header.dataOff+header.size)
+	if err != nil || segment.id != mkvSegment {
+		return nil, errBadEBML
+	}
+	segEnd := size
+	if segment.size != ebmlUnknownSize && segment.dataOff+segment.size < size {
+		segEnd = segment.dataOff + segment.size
+	}
+
+	timestampScale := uint64(1000000) // 默认 1ms
+	var rawDuration float64
+	hasVideo, hasAudio := false, false
+	err = ebmlChildren(r, segment.dataOff, segEnd, func(el ebmlElement) (bool, error) {
+		switch el.id {
+		case mkvInfo:
+			return true, ebmlChildren(r, el.dataOff, el.dataOff+el.size, func(child ebmlElement) (bool, error) {
+				var err error
+				switch child.id {
+				case mkvTimestampScale:
+					timestampScale, err = readEBMLUint(r, child)
+				case mkvDuration:
+					rawDuration, err = readEBMLFloat(r, child)
+				}
+				return true, err
+			})
+		case mkvTracks:
+			return true, ebmlChildren(r, el.dataOff, el.dataOff+el.size, func(entry ebmlElement) (bool, error) {
+				if entry.id != mkvTrackEntry {
+					return true, nil
+				}
+				track, err := parseMatroskaTrack(r, entry)
+				if err != nil {
+					return false, err
+				}
+				switch {
+				case track.kind == mkvTrackTypeVideo && !hasVideo:
+					hasVideo = true
+					info.VideoCodec = track.codec
+					info.Width, info.Height = track.width, track.height
+					if track.defaultDuration > 0 {
+						info.FrameRate = 1e9 / float64(track.defaultDuration)
+					}
+				case track.kind == mkvTrackTypeAudio && !hasAudio:
+					hasAudio = true
+					info.AudioCodec = track.codec
+				}
+				return true, nil
+			})
+		case mkvCluster:
+			// 到达媒体数据，头部信息已读取完毕
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info.Duration = rawDuration * float64(timestampScale) / 1e9
+	if !hasVideo && hasAudio {
+		info.Kind = KindAudio
+		if info.Format == "webm" {
+			info.MimeType = "audio/webm"
+		} else {
+			info.MimeType = "audio/x-matroska"
+		}
+	}
+	return info, nil
+}
+
+type matroskaTrack struct {
+	kind            uint64
+	codec           string
+	width, height   int
+	defaultDuration uint64 // 每帧时长（纳秒）
+}
+
+func parseMatroskaTrack(r io.ReaderAt, entry ebmlElement) (*matroskaTrack, error) {
+	track := &matroskaTrack{}
+	err := ebmlChildren(r, entry.dataOff, entry.dataOff+entry.size, func(el ebmlElement) (bool, error) {
+		var err error
+		switch el.id {
+		case mkvTrackType:
+			track.kind, err = readEBMLUint(r, el)
+		case mkvCodecID:
+			var codec []byte
+			codec, err = readEBMLBytes(r, el)
+			track.codec = string(codec)
+		case mkvDefaultDuration:
+			track.defaultDuration, err = readEBMLUint(r, el)
+		case mkvVideo:
+			err = ebmlChildren(r, el.dataOff, el.dataOff+el.size, func(v ebmlElement) (bool, error) {
+				var n uint64
+				var err error
+				switch v.id {
+				case mkvPixelWidth:
+					n, err = readEBMLUint(r, v)
+					track.width = int(n)
+				case mkvPixelHeight:
+					n, err = readEBMLUint(r, v)
+					track.height = int(n)
+				}
+				return true, err
+			})
+		}
+		return true, err
+	})
+	return track, err
+}
@@ -0,0 +1,46 @@
+package probe
+
+import (
+	"io"
+
+	"github.com/ormasia/swiftstream/internal/oss/mp4"
+)
+
+// probeMP4 解析 MP4/MOV 的 moov/mvhd、trak/tkhd、mdia/mdhd 等头部信息
+func probeMP4(r io.ReaderAt, size int64, head []byte) (*Info, error) {
+	movie, err := mp4.ReadMovie(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{Format: "mp4", MimeType: "video/mp4", Kind: KindVideo}
+	if string(head[8:12]) == "qt  " {
+		info.Format, info.MimeType = "mov", "video/quicktime"
+	}
+	info.Duration = movie.Seconds()
+
+	video := movie.Track(mp4.HandlerVideo)
+	audio := movie.Track(mp4.HandlerAudio)
+	if video != nil {
+		info.VideoCodec = video.Codec
+		info.Width, info.Height = video.Width, video.Height
+		if video.Rotation == 90 || video.Rotation == 270 {
+			info.Width, info.Height = info.Height, info.Width
+		}
+		info.FrameRate = video.FrameRate()
+		if info.Duration == 0 {
+			info.Duration = video.Seconds()
+		}
+	}
+	if audio != nil {
+		info.AudioCodec = audio.Codec
+		if info.Duration == 0 {
+			info.Duration = audio.Seconds()
+		}
+	}
+	if video == nil && audio != nil {
+		// 只有音轨的 MP4（如 .m4a）
+		info.Kind, info.MimeType = KindAudio, "audio/mp4"
+	}
+	return info, nil
+}
@@ -0,0 +1,85 @@
+package probe
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrUnknownFormat 文件头不属于任何支持的容器格式
+var ErrUnknownFormat = errors.New("probe: unknown format")
+
+// 媒体大类，与 model.OssObject.FileType 的取值一致
+const (
+	KindImage = "image"
+	KindVideo = "video"
+	KindAudio = "audio"
+)
+
+// Info 是从容器头部解析出的媒体元数据
+type Info struct {
+	Format     string  // 容器格式：mp4/mov/webm/mkv/jpeg/png/gif/webp/mp3/wav
+	MimeType   string  // 根据容器格式推断的 MIME 类型
+	Kind       string  // image/video/audio
+	Width      int     // 显示宽度（已考虑旋转）
+	Height     int     // 显示高度（已考虑旋转）
+	Duration   float64 // 时长（秒）
+	VideoCodec string  // 视频编码，如 avc1/hvc1/V_VP9
+	AudioCodec string  // 音频编码，如 mp4a/A_OPUS/mp3/pcm
+	Bitrate    int64   // 总码率（bit/s）
+	FrameRate  float64 // 视频帧率
+}
+
+// File 打开 path 并探测其媒体元数据
+func File(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Probe(f, stat.Size())
+}
+
+// Probe 根据文件头的魔数选择解析器，只读取解析所需的头部数据
+func Probe(r io.ReaderAt, size int64) (*Info, error) {
+	head := make([]byte, 64)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	var info *Info
+	switch {
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		info, err = probeMP4(r, size, head)
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		info, err = probeMatroska(r, size)
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		info, err = probeWebP(r, size)
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		info, err = probeWAV(r, size)
+	case bytes.HasPrefix(head, []byte("\xFF\xD8\xFF")),
+		bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")),
+		bytes.HasPrefix(head, []byte("GIF8")):
+		info, err = probeImage(r, size)
+	case bytes.HasPrefix(head, []byte("ID3")),
+		len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		info, err = probeMP3(r, size)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Bitrate == 0 && info.Duration > 0 && info.Kind != KindImage {
+		info.Bitrate = int64(float64(size*8) / info.Duration)
+	}
+	return info, nil
+}
@@ -0,0 +1,158 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func be32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func le32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+func le16(v uint16) []byte { return binary.LittleEndian.AppendUint16(nil, v) }
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// box 编码一个 MP4 box
+func box(typ string, parts ...[]byte) []byte {
+	body := join(parts...)
+	return join(be32(uint32(8+len(body))), []byte(typ), body)
+}
+
+// fullBox 编码带版本与标志位的 MP4 box
+func fullBox(typ string, parts ...[]byte) []byte {
+	return box(typ, append([][]byte{make([]byte, 4)}, parts...)...)
+}
+
+// matrix 返回 tkhd 中旋转 rotation 度的变换矩阵
+func matrix(rotation int) []byte {
+	const one = 1 << 16
+	a, b := uint32(one), uint32(0)
+	if rotation == 90 {
+		a, b = 0, one
+	}
+	return join(be32(a), be32(b), be32(0), be32(-b), be32(a), be32(0), be32(0), be32(0), be32(0x40000000))
+}
+
+// trak 编码一个轨道，samples 为 stts 中的样本数
+func trak(handler, codec string, width, height, rotation int, timescale, duration, samples uint32) []byte {
+	tkhd := fullBox("tkhd", make([]byte, 8), be32(1), make([]byte, 4), be32(0), make([]byte, 16), matrix(rotation),
+		be32(uint32(width)<<16), be32(uint32(height)<<16))
+	mdhd := fullBox("mdhd", make([]byte, 8), be32(timescale), be32(duration), make([]byte, 4))
+	hdlr := fullBox("hdlr", make([]byte, 4), []byte(handler), make([]byte, 12))
+	entry := box(codec, make([]byte, 28))
+	if handler == "soun" {
+		entry = box(codec, make([]byte, 16), []byte{0, 2, 0, 16}, make([]byte, 4), be32(44100<<16))
+	}
+	stsd := fullBox("stsd", be32(1), entry)
+	stts := fullBox("stts", be32(1), be32(samples), be32(duration/samples))
+	return box("trak", tkhd, box("mdia", mdhd, hdlr, box("minf", box("stbl", stsd, stts))))
+}
+
+func mp4File(brand string, traks ...[]byte) []byte {
+	mvhd := fullBox("mvhd", make([]byte, 8), be32(1000), be32(10000), make([]byte, 80))
+	ftyp := box("ftyp", []byte(brand), be32(0), []byte("isomavc1"))
+	return join(ftyp, box("moov", append([][]byte{mvhd}, traks...)...), box("mdat", make([]byte, 100)))
+}
+
+func riff(form string, chunks ...[]byte) []byte {
+	body := join(append([][]byte{[]byte(form)}, chunks...)...)
+	return join([]byte("RIFF"), le32(uint32(len(body))), body)
+}
+
+func chunk(id string, data []byte) []byte {
+	return join([]byte(id), le32(uint32(len(data))), data)
+}
+
+// mp3 返回以 MPEG-1 Layer III 128kbps 44.1kHz 帧开头的 size 字节数据，frames 不为 0 时在首帧写入 Xing 头的帧数
+func mp3(frames uint32, size int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	if frames > 0 {
+		copy(frame[4+32:], join([]byte("Xing"), be32(1), be32(frames)))
+	}
+	return join(frame, make([]byte, size-len(frame)))
+}
+
+func encode(t *testing.T, enc func(*bytes.Buffer, image.Image) error) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := enc(&buf, image.NewRGBA(image.Rect(0, 0, 64, 48))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProbe(t *testing.T) {
+	video := trak("vide", "avc1", 1920, 1080, 0, 12800, 128000, 250)
+	rotated := trak("vide", "hvc1", 1920, 1080, 90, 12800, 128000, 250)
+	audio := trak("soun", "mp4a", 0, 0, 0, 44100, 441000, 431)
+	pcm := join(le16(1), le16(2), le32(44100), le32(176400), le16(4), le16(16))
+	id3 := join([]byte("ID3\x04\x00\x00\x00\x00\x00\x0a"), make([]byte, 10))
+
+	tests := []struct {
+		name string
+		data []byte
+		want Info
+	}{
+		{"mp4", mp4File("isom", video, audio), Info{Format: "mp4", MimeType: "video/mp4", Kind: KindVideo,
+			Width: 1920, Height: 1080, Duration: 10, VideoCodec: "avc1", AudioCodec: "mp4a", FrameRate: 25}},
+		{"rotated mov", mp4File("qt  ", rotated), Info{Format: "mov", MimeType: "video/quicktime", Kind: KindVideo,
+			Width: 1080, Height: 1920, Duration: 10, VideoCodec: "hvc1", FrameRate: 25}},
+		{"m4a", mp4File("M4A ", audio), Info{Format: "mp4", MimeType: "audio/mp4", Kind: KindAudio, Duration: 10, AudioCodec: "mp4a"}},
+		{"wav", riff("WAVE", chunk("fmt ", pcm), chunk("data", make([]byte, 176400*2))), Info{Format: "wav", MimeType: "audio/wav", Kind: KindAudio,
+			Duration: 2, AudioCodec: "pcm_s16le", Bitrate: 1411200}},
+		// Xing 头中的帧数：1000 帧 × 1152 / 44100
+		{"vbr mp3", join(id3, mp3(1000, 4096)), Info{Format: "mp3", MimeType: "audio/mpeg", Kind: KindAudio, AudioCodec: "mp3", Duration: 1152000.0 / 44100}},
+		{"cbr mp3", mp3(0, 16000), Info{Format: "mp3", MimeType: "audio/mpeg", Kind: KindAudio, AudioCodec: "mp3", Duration: 1, Bitrate: 128000}},
+		{"png", encode(t, func(b *bytes.Buffer, m image.Image) error { return png.Encode(b, m) }), Info{Format: "png", MimeType: "image/png", Kind: KindImage, Width: 64, Height: 48}},
+		{"jpeg", encode(t, func(b *bytes.Buffer, m image.Image) error { return jpeg.Encode(b, m, nil) }), Info{Format: "jpeg", MimeType: "image/jpeg", Kind: KindImage, Width: 64, Height: 48}},
+		{"gif", encode(t, func(b *bytes.Buffer, m image.Image) error { return gif.Encode(b, m, nil) }), Info{Format: "gif", MimeType: "image/gif", Kind: KindImage, Width: 64, Height: 48}},
+		{"webp lossless", riff("WEBP", chunk("VP8L", join([]byte{0x2f}, le32(63|47<<14), make([]byte, 8)))), Info{Format: "webp", MimeType: "image/webp", Kind: KindImage, Width: 64, Height: 48}},
+		{"webp extended", riff("WEBP", chunk("VP8X", join(make([]byte, 4), []byte{63, 0, 0, 47, 0, 0}))), Info{Format: "webp", MimeType: "image/webp", Kind: KindImage, Width: 64, Height: 48}},
+	}
+	for _, tt := range tests {
+		info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		// 码率未在用例中给出时由文件大小与时长计算，不参与比较
+		if tt.want.Bitrate == 0 {
+			info.Bitrate = 0
+		}
+		if *info != tt.want {
+			t.Errorf("%s: info = %+v, want %+v", tt.name, *info, tt.want)
+		}
+	}
+}
+
+func TestProbeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, ErrUnknownFormat},
+		{"text", []byte("hello, world"), ErrUnknownFormat},
+		{"wav without data", riff("WAVE", chunk("fmt ", make([]byte, 16))), errBadWAV},
+		{"short fmt", riff("WAVE", chunk("fmt ", make([]byte, 8)), chunk("data", nil)), errBadWAV},
+		{"webp bad signature", riff("WEBP", chunk("VP8L", make([]byte, 10))), errBadWebP},
+		{"mp3 without frames", join([]byte("ID3\x04\x00\x00\x00\x00\x00\x0a"), make([]byte, 100)), errBadMP3},
+	}
+	for _, tt := range tests {
+		if _, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data))); !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	// mp4 没有 moov 时返回解析错误
+	if _, err := Probe(bytes.NewReader(box("ftyp", []byte("isom"))), 16); err == nil {
+		t.Error("mp4 without moov: err = nil")
+	}
+}
@@ -1,8 +1,15 @@
 package repo
 
 import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/ormasia/swiftstream/internal/oss/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ============================================================================
@@ -15,9 +22,30 @@ func CreateTable(db *gorm.DB) error {
 		&model.OssObject{},
 		&model.UploadTask{},
 		&model.ChunkRecord{},
+		&model.TranscodeJob{},
+		&model.HLSKey{},
+		&model.LiveSession{},
+		&model.LiveSegment{},
+		&model.SubtitleTrack{},
+		&model.SubtitleCue{},
+		&model.ImageHash{},
+		&model.ScanResult{},
+		&model.Bucket{},
+		&model.ObjectMetadata{},
+		&model.ObjectTag{},
+		&model.LifecycleRule{},
+		&model.LockAudit{},
+		&model.ObjectGrant{},
+		&model.ObjectShare{},
 	); err != nil {
 		return err
 	}
+	// 对象键的唯一索引改为 (bucket, object_key, version_id)，删除旧版本的单列唯一索引
+	if db.Migrator().HasIndex(&model.OssObject{}, "idx_oss_objects_object_key") {
+		if err := db.Migrator().DropIndex(&model.OssObject{}, "idx_oss_objects_object_key"); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -34,22 +62,443 @@ func CreateObject(db *gorm.DB, object *model.OssObject) error {
 }
 
 // GetObject 根据对象 ID 获取 OSS 对象记录
-func GetObject(db *gorm.DB, objectID string) (*model.OssObject, error) {
+func GetObject(db *gorm.DB, objectID uint) (*model.OssObject, error) {
+	var object model.OssObject
+	if err := db.First(&object, objectID).Error; err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+// GetObjectWithVariants 获取 OSS 对象记录，并加载其衍生对象列表
+func GetObjectWithVariants(db *gorm.DB, objectID uint) (*model.OssObject, error) {
+	var object model.OssObject
+	if err := db.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&object, objectID).Error; err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+// GetVariant 根据规格名获取源对象的衍生对象
+func GetVariant(db *gorm.DB, parentID uint, variant string) (*model.OssObject, error) {
 	var object model.OssObject
-	if err := db.Where("object_id = ?", objectID).First(&object).Error; err != nil {
+	if err := db.Where("parent_id = ? AND variant = ?", parentID, variant).First(&object).Error; err != nil {
 		return nil, err
 	}
 	return &object, nil
 }
 
+// DeleteVariant 删除源对象指定规格的衍生对象
+func DeleteVariant(db *gorm.DB, parentID uint, variant string) error {
+	if err := db.Where("parent_id = ? AND variant = ?", parentID, variant).
+		Delete(&model.OssObject{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListVariants 获取源对象的所有衍生对象
+func ListVariants(db *gorm.DB, parentID uint) ([]model.OssObject, error) {
+	var objects []model.OssObject
+	if err := db.Where("parent_id = ?", parentID).Order("id ASC").Find(&objects).Error; err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// SaveObject 保存 OSS 对象记录
+func SaveObject(db *gorm.DB, object *model.OssObject) error {
+	if err := db.Save(object).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateObjectPlaceholder 更新对象的 BlurHash 与主色，只写这几列，避免覆盖后台任务并发写入的其他字段
+func UpdateObjectPlaceholder(db *gorm.DB, objectID uint, blurHash, dominantColor string, palette []string) error {
+	if err := db.Model(&model.OssObject{ID: objectID}).
+		Select("blur_hash", "dominant_color", "palette").
+		Updates(&model.OssObject{BlurHash: blurHash, DominantColor: dominantColor, Palette: palette}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateObjectStatus 只有当前状态在 from 中时才更新对象状态，返回是否更新
+// 用条件更新避免扫描结果与管理员审核并发时互相覆盖
+func UpdateObjectStatus(db *gorm.DB, objectID uint, status string, from ...string) (bool, error) {
+	result := db.Model(&model.OssObject{}).
+		Where("id = ? AND status IN ?", objectID, from).
+		Update("status", status)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ListObjectsByStatus 按 ID 倒序分页列出指定状态的源对象
+func ListObjectsByStatus(db *gorm.DB, status string, beforeID uint, limit int) ([]model.OssObject, error) {
+	query := db.Where("parent_id IS NULL AND status = ?", status)
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	var objects []model.OssObject
+	if err := query.Order("id DESC").Limit(limit).Find(&objects).Error; err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// MarkDuplicate 标记对象为已有内容的重复上传
+func MarkDuplicate(db *gorm.DB, objectID, duplicateOf uint) error {
+	if err := db.Model(&model.OssObject{ID: objectID}).Update("duplicate_of", duplicateOf).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetObjects 按 ID 批量获取对象记录，结果顺序不保证与 ids 一致
+func GetObjects(db *gorm.DB, ids []uint) ([]model.OssObject, error) {
+	var objects []model.OssObject
+	if len(ids) == 0 {
+		return objects, nil
+	}
+	if err := db.Where("id IN ?", ids).Find(&objects).Error; err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// TagFilter 按标签过滤对象列表，HasValue 为 false 时只要求存在该标签
+type TagFilter struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+// ListObjects 按 ID 倒序分页列出源对象的当前版本（不含衍生对象与删除标记），并加载衍生对象列表
+// fileTypes 为空时不限类型，tags 中的条件需同时满足，beforeID 为 0 时从最新的对象开始
+func ListObjects(db *gorm.DB, fileTypes []string, tags []TagFilter, beforeID uint, limit int, scopes ...func(*gorm.DB) *gorm.DB) ([]model.OssObject, error) {
+	query := db.Scopes(scopes...).Where("parent_id IS NULL AND status = ? AND noncurrent_at IS NULL AND delete_marker = ?", "active", false)
+	if len(fileTypes) > 0 {
+		query = query.Where("file_type IN ?", fileTypes)
+	}
+	for _, tag := range tags {
+		sub := db.Model(&model.ObjectTag{}).Select("1").
+			Where("object_tags.object_id = oss_objects.id AND object_tags.key = ?", tag.Key)
+		if tag.HasValue {
+			sub = sub.Where("object_tags.value = ?", tag.Value)
+		}
+		query = query.Where("EXISTS (?)", sub)
+	}
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	var objects []model.OssObject
+	if err := query.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).Order("id DESC").Limit(limit).Find(&objects).Error; err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// GetObjectByEtag 根据 ETag 查找可用的对象，用于秒传；待审核与隔离的对象不参与秒传
 func GetObjectByEtag(db *gorm.DB, etag string) (*model.OssObject, error) {
 	var object model.OssObject
-	if err := db.Where("e_tag = ?", etag).First(&object).Error; err != nil {
+	if err := db.Where("e_tag = ? AND status = ?", etag, model.StatusActive).First(&object).Error; err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+// SoftDeleteObject 软删除源对象及其未删除的衍生对象，状态改为 deleted，返回源对象是否被删除
+// 已被单独删除的衍生对象保持原样，恢复时不会随源对象恢复
+func SoftDeleteObject(db *gorm.DB, objectID uint, now time.Time) (bool, error) {
+	var deleted bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.OssObject{}).
+			Where("id = ? AND status = ?", objectID, model.StatusActive).
+			Updates(map[string]any{"status": model.StatusDeleted, "deleted_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		deleted = true
+		if err := tx.Model(&model.OssObject{}).
+			Where("parent_id = ?", objectID).
+			Updates(map[string]any{"status": model.StatusDeleted, "deleted_at": now}).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// RestoreObject 恢复回收站中的源对象及随其删除的衍生对象，返回是否恢复
+func RestoreObject(db *gorm.DB, objectID uint) (bool, error) {
+	result := db.Unscoped().Model(&model.OssObject{}).
+		Where("(id = ? OR parent_id = ?) AND status = ?", objectID, objectID, model.StatusDeleted).
+		Updates(map[string]any{"status": model.StatusActive, "deleted_at": nil})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetDeletedObject 获取回收站中的源对象
+func GetDeletedObject(db *gorm.DB, objectID uint) (*model.OssObject, error) {
+	var object model.OssObject
+	if err := db.Unscoped().Where("id = ? AND parent_id IS NULL AND status = ?", objectID, model.StatusDeleted).
+		First(&object).Error; err != nil {
 		return nil, err
 	}
 	return &object, nil
 }
 
+// ListTrash 按删除时间倒序分页列出用户回收站中删除时间晚于 since 的源对象
+// before 为上一页最后一个对象的 ID，为 0 时从最近删除的对象开始
+func ListTrash(db *gorm.DB, userID uint, since time.Time, beforeID uint, limit int) ([]model.OssObject, error) {
+	query := db.Unscoped().Where("parent_id IS NULL AND status = ? AND user_id = ? AND deleted_at > ?",
+		model.StatusDeleted, userID, since)
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	var objects []model.OssObject
+	if err := query.Order("id DESC").Limit(limit).Find(&objects).Error; err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// ListExpiredObjects 列出删除时间早于 before 的源对象，供清理任务物理删除
+func ListExpiredObjects(db *gorm.DB, before time.Time, limit int) ([]model.OssObject, error) {
+	var objects []model.OssObject
+	if err := db.Unscoped().Where("parent_id IS NULL AND status = ? AND deleted_at < ?", model.StatusDeleted, before).
+		Order("deleted_at ASC").Limit(limit).Find(&objects).Error; err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// ListObjectFamily 获取源对象及其所有衍生对象（含已软删除的），源对象在最前
+func ListObjectFamily(db *gorm.DB, objectID uint) ([]model.OssObject, error) {
+	var objects []model.OssObject
+	if err := db.Unscoped().Where("id = ? OR parent_id = ?", objectID, objectID).
+		Order("parent_id IS NOT NULL, id ASC").Find(&objects).Error; err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// CountStorageReferences 统计 exclude 以外引用该文件的对象数，含回收站中的对象
+// 早期记录没有 StoragePath，按 data/files/<FileName> 一并统计
+func CountStorageReferences(db *gorm.DB, path string, exclude []uint) (int64, error) {
+	query := whereStoragePath(db.Unscoped().Model(&model.OssObject{}).Where("id NOT IN ?", exclude), path)
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// whereStoragePath 匹配文件位于 path 的对象，data/files 下的文件同时匹配没有 StoragePath 的早期记录
+func whereStoragePath(query *gorm.DB, path string) *gorm.DB {
+	if filepath.Dir(path) == filepath.Join("data", "files") {
+		return query.Where("storage_path = ? OR (storage_path = '' AND file_name = ?)", path, filepath.Base(path))
+	}
+	return query.Where("storage_path = ?", path)
+}
+
+// CountStorageReferencesUnder 统计 exclude 以外文件位于目录 dir 下的对象数，含回收站中的对象
+func CountStorageReferencesUnder(db *gorm.DB, dir string, exclude []uint) (int64, error) {
+	var count int64
+	if err := db.Unscoped().Model(&model.OssObject{}).
+		Where("id NOT IN ? AND storage_path LIKE ?", exclude, dir+string(filepath.Separator)+"%").
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// PurgeObjects 物理删除源对象及其衍生对象的记录，以及字幕、感知哈希、内容密钥、转码任务、扫描结果、元数据与标签
+func PurgeObjects(db *gorm.DB, objectID uint, ids []uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, m := range []any{
+			&model.SubtitleCue{}, &model.SubtitleTrack{}, &model.HLSKey{}, &model.TranscodeJob{}, &model.ScanResult{},
+		} {
+			if err := tx.Unscoped().Where("object_id = ?", objectID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		for _, m := range []any{&model.ImageHash{}, &model.ObjectMetadata{}, &model.ObjectTag{}, &model.ObjectGrant{}, &model.ObjectShare{}} {
+			if err := tx.Where("object_id IN ?", ids).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("id IN ?", ids).Delete(&model.OssObject{}).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// ============================================================================
+// Bucket 与对象版本操作
+// ============================================================================
+
+// GetBucket 获取存储桶配置
+func GetBucket(db *gorm.DB, name string) (*model.Bucket, error) {
+	var bucket model.Bucket
+	if err := db.Where("name = ?", name).First(&bucket).Error; err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+// SaveBucketVersioning 设置存储桶的版本控制状态，存储桶不存在时创建
+func SaveBucketVersioning(db *gorm.DB, name, versioning string) (*model.Bucket, error) {
+	bucket := model.Bucket{Name: name, Versioning: versioning}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"versioning", "updated_at"}),
+	}).Create(&bucket).Error; err != nil {
+		return nil, err
+	}
+	return GetBucket(db, name)
+}
+
+// GetCurrentVersion 获取对象键的当前版本，可能是删除标记
+func GetCurrentVersion(db *gorm.DB, bucket, key string) (*model.OssObject, error) {
+	var object model.OssObject
+	if err := db.Where("bucket = ? AND object_key = ? AND parent_id IS NULL AND noncurrent_at IS NULL", bucket, key).
+		Order("id DESC").First(&object).Error; err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+// GetVersion 获取对象键的指定版本
+func GetVersion(db *gorm.DB, bucket, key, versionID string) (*model.OssObject, error) {
+	var object model.OssObject
+	if err := db.Where("bucket = ? AND object_key = ? AND version_id = ? AND parent_id IS NULL", bucket, key, versionID).
+		First(&object).Error; err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+// ListVersions 按 ID 倒序分页列出存储桶中对象键以 prefix 开头的所有版本与删除标记
+func ListVersions(db *gorm.DB, bucket, prefix string, beforeID uint, limit int, scopes ...func(*gorm.DB) *gorm.DB) ([]model.OssObject, error) {
+	query := db.Scopes(scopes...).Where("bucket = ? AND parent_id IS NULL", bucket)
+	if prefix != "" {
+		// 用 substr 比较前缀，避免对象键中的 % 与 _ 被当作 LIKE 通配符
+		query = query.Where("substr(object_key, 1, ?) = ?", len(prefix), prefix)
+	}
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	var objects []model.OssObject
+	if err := query.Order("id DESC").Limit(limit).Find(&objects).Error; err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// ListCurrentObjects 按对象键排序列出用户在存储桶中可下载的当前版本，keys 不为空时只列出这些键，否则按 prefix 过滤
+func ListCurrentObjects(db *gorm.DB, bucket string, userID uint, prefix string, keys []string, limit int) ([]model.OssObject, error) {
+	query := db.Where("bucket = ? AND user_id = ? AND parent_id IS NULL AND noncurrent_at IS NULL AND delete_marker = ? AND status = ?",
+		bucket, userID, false, model.StatusActive)
+	if len(keys) > 0 {
+		query = query.Where("object_key IN ?", keys)
+	} else if prefix != "" {
+		query = query.Where("substr(object_key, 1, ?) = ?", len(prefix), prefix)
+	}
+	var objects []model.OssObject
+	if err := query.Order("object_key ASC").Limit(limit).Find(&objects).Error; err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// SetCurrentVersion 把对象设为其对象键的当前版本，同一键的其他当前版本标记为已取代，返回被取代的版本
+func SetCurrentVersion(db *gorm.DB, object *model.OssObject, now time.Time) ([]model.OssObject, error) {
+	var superseded []model.OssObject
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("bucket = ? AND object_key = ? AND parent_id IS NULL AND id <> ? AND noncurrent_at IS NULL",
+			object.Bucket, object.ObjectKey, object.ID)
+		if err := query.Find(&superseded).Error; err != nil {
+			return err
+		}
+		for i := range superseded {
+			if err := tx.Model(&superseded[i]).Update("noncurrent_at", now).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&model.OssObject{ID: object.ID}).Update("noncurrent_at", nil).Error; err != nil {
+			return err
+		}
+		object.NoncurrentAt = nil
+		return nil
+	})
+	return superseded, err
+}
+
+// PromoteNewestVersion 对象键没有当前版本时，把最新的版本（可能是删除标记）设为当前版本
+func PromoteNewestVersion(db *gorm.DB, bucket, key string) error {
+	if _, err := GetCurrentVersion(db, bucket, key); err == nil {
+		return nil
+	}
+	var object model.OssObject
+	err := db.Where("bucket = ? AND object_key = ? AND parent_id IS NULL", bucket, key).Order("id DESC").First(&object).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := db.Model(&object).Update("noncurrent_at", nil).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// DemoteIfSuperseded 对象仍标记为当前版本但同一键已有其他当前版本时，把它标记为已取代
+// 用于从回收站恢复的版本，恢复不改变对象键的当前版本
+func DemoteIfSuperseded(db *gorm.DB, object *model.OssObject, now time.Time) error {
+	if object.NoncurrentAt != nil {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&model.OssObject{}).
+		Where("bucket = ? AND object_key = ? AND parent_id IS NULL AND id <> ? AND noncurrent_at IS NULL",
+			object.Bucket, object.ObjectKey, object.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	if err := db.Model(&model.OssObject{ID: object.ID}).Update("noncurrent_at", now).Error; err != nil {
+		return err
+	}
+	object.NoncurrentAt = &now
+	return nil
+}
+
+// DeleteMarkerVersion 物理删除删除标记
+func DeleteMarkerVersion(db *gorm.DB, objectID uint) error {
+	if err := db.Unscoped().Where("id = ? AND delete_marker = ?", objectID, true).
+		Delete(&model.OssObject{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
 // ============================================================================
 // UploadTask 操作
 // ============================================================================
@@ -79,6 +528,15 @@ func SaveUploadTask(db *gorm.DB, uploadTask *model.UploadTask) error {
 	return nil
 }
 
+// ListUploadTasksByStatus 列出指定状态的上传任务
+func ListUploadTasksByStatus(db *gorm.DB, status string) ([]model.UploadTask, error) {
+	var tasks []model.UploadTask
+	if err := db.Where("status = ?", status).Order("id ASC").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
 // ============================================================================
 // ChunkRecord 操作
 // ============================================================================
@@ -136,3 +594,683 @@ func CountUploadedChunks(db *gorm.DB, uploadID string) (int64, error) {
 	}
 	return count, nil
 }
+
+// ============================================================================
+// TranscodeJob 操作
+// ============================================================================
+
+// CreateTranscodeJobs 批量创建转码任务
+func CreateTranscodeJobs(db *gorm.DB, jobs []model.TranscodeJob) error {
+	if err := db.Create(&jobs).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateTranscodeJobIfAbsent 创建任务，同一目标的任务已存在时忽略，返回是否创建
+func CreateTranscodeJobIfAbsent(db *gorm.DB, job *model.TranscodeJob) (bool, error) {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(job)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ClaimTranscodeJob 领取一个到期的待执行任务并标记为 running，没有任务时返回 nil
+// 通过带状态条件的更新保证同一任务只会被一个 worker 领取
+func ClaimTranscodeJob(db *gorm.DB, now time.Time) (*model.TranscodeJob, error) {
+	for {
+		var jobs []model.TranscodeJob
+		if err := db.Where("status = ? AND next_run_at <= ?", "pending", now).
+			Order("id ASC").Limit(1).Find(&jobs).Error; err != nil {
+			return nil, err
+		}
+		if len(jobs) == 0 {
+			return nil, nil
+		}
+		job := jobs[0]
+		result := db.Model(&model.TranscodeJob{}).
+			Where("id = ? AND status = ?", job.ID, "pending").
+			Updates(map[string]any{"status": "running", "attempts": job.Attempts + 1})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.Status = "running"
+			job.Attempts++
+			return &job, nil
+		}
+		// 已被其他 worker 领取，继续查找下一个
+	}
+}
+
+// SaveTranscodeJob 保存转码任务
+func SaveTranscodeJob(db *gorm.DB, job *model.TranscodeJob) error {
+	if err := db.Save(job).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateTranscodeProgress 只更新任务进度，避免覆盖其他字段
+func UpdateTranscodeProgress(db *gorm.DB, jobID uint, progress int) error {
+	if err := db.Model(&model.TranscodeJob{}).Where("id = ?", jobID).
+		Update("progress", progress).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// CountUnfinishedTranscodeJobs 统计源对象尚未结束（pending/running）的指定类型任务数
+func CountUnfinishedTranscodeJobs(db *gorm.DB, objectID uint, kind string) (int64, error) {
+	var count int64
+	if err := db.Model(&model.TranscodeJob{}).
+		Where("object_id = ? AND kind = ? AND status IN ?", objectID, kind, []string{"pending", "running"}).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ListTranscodeJobs 获取源对象的所有转码任务
+func ListTranscodeJobs(db *gorm.DB, objectID uint) ([]model.TranscodeJob, error) {
+	var jobs []model.TranscodeJob
+	if err := db.Where("object_id = ?", objectID).Order("id ASC").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ResetRunningTranscodeJobs 把异常退出时遗留的 running 任务重置为 pending
+func ResetRunningTranscodeJobs(db *gorm.DB) error {
+	if err := db.Model(&model.TranscodeJob{}).Where("status = ?", "running").
+		Update("status", "pending").Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ============================================================================
+// HLSKey 操作
+// ============================================================================
+
+// CreateHLSKeys 批量保存内容密钥
+func CreateHLSKeys(db *gorm.DB, keys []model.HLSKey) error {
+	if err := db.Create(&keys).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetHLSKey 获取源对象的指定密钥
+func GetHLSKey(db *gorm.DB, objectID uint, keyID string) (*model.HLSKey, error) {
+	var key model.HLSKey
+	if err := db.Where("object_id = ? AND key_id = ?", objectID, keyID).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// DeleteHLSKeys 删除源对象的指定密钥
+func DeleteHLSKeys(db *gorm.DB, objectID uint, keyIDs []string) error {
+	if len(keyIDs) == 0 {
+		return nil
+	}
+	if err := db.Where("object_id = ? AND key_id IN ?", objectID, keyIDs).
+		Delete(&model.HLSKey{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteHLSKeysExcept 删除源对象除 keep 以外的所有密钥
+func DeleteHLSKeysExcept(db *gorm.DB, objectID uint, keep []string) error {
+	query := db.Where("object_id = ?", objectID)
+	if len(keep) > 0 {
+		query = query.Where("key_id NOT IN ?", keep)
+	}
+	if err := query.Delete(&model.HLSKey{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ============================================================================
+// LiveSession 操作
+// ============================================================================
+
+// CreateLiveSession 创建直播会话
+func CreateLiveSession(db *gorm.DB, session *model.LiveSession) error {
+	if err := db.Create(session).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetLiveSession 根据 ID 获取直播会话
+func GetLiveSession(db *gorm.DB, sessionID uint) (*model.LiveSession, error) {
+	var session model.LiveSession
+	if err := db.First(&session, sessionID).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// SaveLiveSession 保存直播会话
+func SaveLiveSession(db *gorm.DB, session *model.LiveSession) error {
+	if err := db.Save(session).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListLiveSessionsByStatus 获取指定状态的直播会话
+func ListLiveSessionsByStatus(db *gorm.DB, status string) ([]model.LiveSession, error) {
+	var sessions []model.LiveSession
+	if err := db.Where("status = ?", status).Order("id ASC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// ListIdleLiveSessions 获取在 before 之后没有推送过分片的直播中会话
+func ListIdleLiveSessions(db *gorm.DB, before time.Time) ([]model.LiveSession, error) {
+	var sessions []model.LiveSession
+	if err := db.Where("status = ? AND last_segment_at < ?", "live", before).
+		Order("id ASC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// SaveLiveSegment 保存直播分片，同一序号重复推送时覆盖
+func SaveLiveSegment(db *gorm.DB, segment *model.LiveSegment) error {
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "session_id"}, {Name: "sequence"}},
+		DoUpdates: clause.AssignmentColumns([]string{"duration", "size", "storage_path"}),
+	}).Create(segment).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetLiveSegment 获取直播会话的指定分片
+func GetLiveSegment(db *gorm.DB, sessionID uint, sequence int64) (*model.LiveSegment, error) {
+	var segment model.LiveSegment
+	if err := db.Where("session_id = ? AND sequence = ?", sessionID, sequence).First(&segment).Error; err != nil {
+		return nil, err
+	}
+	return &segment, nil
+}
+
+// ListLiveSegments 获取直播会话最新的 limit 个分片，按序号升序排列；limit <= 0 时返回全部
+func ListLiveSegments(db *gorm.DB, sessionID uint, limit int) ([]model.LiveSegment, error) {
+	var segments []model.LiveSegment
+	query := db.Where("session_id = ?", sessionID).Order("sequence DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&segments).Error; err != nil {
+		return nil, err
+	}
+	slices.Reverse(segments)
+	return segments, nil
+}
+
+// ============================================================================
+// SubtitleTrack 操作
+// ============================================================================
+
+// SaveSubtitleTrack 保存字幕轨道并替换其检索条目；设为默认字幕时取消同一视频其他轨道的默认标记
+func SaveSubtitleTrack(db *gorm.DB, track *model.SubtitleTrack, cues []model.SubtitleCue) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if track.IsDefault {
+			if err := tx.Model(&model.SubtitleTrack{}).
+				Where("object_id = ? AND id <> ?", track.ObjectID, track.ID).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(track).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("track_id = ?", track.ID).Delete(&model.SubtitleCue{}).Error; err != nil {
+			return err
+		}
+		for i := range cues {
+			cues[i].TrackID = track.ID
+		}
+		if len(cues) > 0 {
+			if err := tx.CreateInBatches(cues, 500).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetSubtitleTrack 获取视频指定语言的字幕轨道
+func GetSubtitleTrack(db *gorm.DB, objectID uint, language string) (*model.SubtitleTrack, error) {
+	var track model.SubtitleTrack
+	if err := db.Where("object_id = ? AND language = ?", objectID, language).First(&track).Error; err != nil {
+		return nil, err
+	}
+	return &track, nil
+}
+
+// ListSubtitleTracks 获取视频的所有字幕轨道，按语言排序
+func ListSubtitleTracks(db *gorm.DB, objectID uint) ([]model.SubtitleTrack, error) {
+	var tracks []model.SubtitleTrack
+	if err := db.Where("object_id = ?", objectID).Order("language ASC").Find(&tracks).Error; err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// DeleteSubtitleTrack 删除字幕轨道及其检索条目
+func DeleteSubtitleTrack(db *gorm.DB, track *model.SubtitleTrack) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("track_id = ?", track.ID).Delete(&model.SubtitleCue{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(track).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// SearchSubtitleCues 按文本检索字幕条目，language 和 objectID 为空时不限制
+// scopes 限定视频对象的范围，例如 ReadableBy；已删除视频的字幕不返回
+func SearchSubtitleCues(db *gorm.DB, text, language string, objectID uint, limit int, scopes ...func(*gorm.DB) *gorm.DB) ([]model.SubtitleCue, error) {
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text) + "%"
+	objects := db.Session(&gorm.Session{NewDB: true}).Model(&model.OssObject{}).Select("id").Scopes(scopes...)
+	query := db.Where(`text LIKE ? ESCAPE '\'`, pattern).Where("object_id IN (?)", objects)
+	if language != "" {
+		query = query.Where("language = ?", language)
+	}
+	if objectID > 0 {
+		query = query.Where("object_id = ?", objectID)
+	}
+	var cues []model.SubtitleCue
+	if err := query.Order("object_id ASC, language ASC, start ASC").
+		Limit(limit).Find(&cues).Error; err != nil {
+		return nil, err
+	}
+	return cues, nil
+}
+
+// ============================================================================
+// ImageHash 操作
+// ============================================================================
+
+// SaveImageHash 保存对象的感知哈希，已存在时覆盖
+func SaveImageHash(db *gorm.DB, hash *model.ImageHash) error {
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "object_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "source", "p_hash", "d_hash"}),
+	}).Create(hash).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetImageHash 获取对象的感知哈希
+func GetImageHash(db *gorm.DB, objectID uint) (*model.ImageHash, error) {
+	var hash model.ImageHash
+	if err := db.Where("object_id = ?", objectID).First(&hash).Error; err != nil {
+		return nil, err
+	}
+	return &hash, nil
+}
+
+// GetImageHashes 批量获取对象的感知哈希
+func GetImageHashes(db *gorm.DB, objectIDs []uint) ([]model.ImageHash, error) {
+	var hashes []model.ImageHash
+	if len(objectIDs) == 0 {
+		return hashes, nil
+	}
+	if err := db.Where("object_id IN ?", objectIDs).Find(&hashes).Error; err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// ListImageHashes 获取所有感知哈希，用于启动时重建索引
+func ListImageHashes(db *gorm.DB) ([]model.ImageHash, error) {
+	var hashes []model.ImageHash
+	if err := db.Order("id ASC").Find(&hashes).Error; err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// ============================================================================
+// ScanResult 操作
+// ============================================================================
+
+// CreateScanResults 保存一次扫描的结果
+func CreateScanResults(db *gorm.DB, results []model.ScanResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	if err := db.Create(&results).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListScanResults 获取对象的所有扫描结果，最新的在前
+func ListScanResults(db *gorm.DB, objectID uint) ([]model.ScanResult, error) {
+	var results []model.ScanResult
+	if err := db.Where("object_id = ?", objectID).Order("id DESC").Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ============================================================================
+// ObjectMetadata 与 ObjectTag 操作
+// ============================================================================
+
+// CreateObjectMetadata 保存对象的自定义元数据
+func CreateObjectMetadata(db *gorm.DB, objectID uint, metadata map[string]string) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	rows := make([]model.ObjectMetadata, 0, len(metadata))
+	for k, v := range metadata {
+		rows = append(rows, model.ObjectMetadata{ObjectID: objectID, Key: k, Value: v})
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListObjectMetadata 获取对象的自定义元数据，按键排序
+func ListObjectMetadata(db *gorm.DB, objectID uint) ([]model.ObjectMetadata, error) {
+	var rows []model.ObjectMetadata
+	if err := db.Where("object_id = ?", objectID).Order("key ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ListObjectTags 获取对象的标签，按键排序
+func ListObjectTags(db *gorm.DB, objectID uint) ([]model.ObjectTag, error) {
+	var tags []model.ObjectTag
+	if err := db.Where("object_id = ?", objectID).Order("key ASC").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// ReplaceObjectTags 用 tags 替换对象的全部标签，tags 为空时清空
+func ReplaceObjectTags(db *gorm.DB, objectID uint, tags map[string]string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("object_id = ?", objectID).Delete(&model.ObjectTag{}).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		rows := make([]model.ObjectTag, 0, len(tags))
+		for k, v := range tags {
+			rows = append(rows, model.ObjectTag{ObjectID: objectID, Key: k, Value: v})
+		}
+		if err := tx.Create(&rows).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// ============================================================================
+// 对象锁定操作
+// ============================================================================
+
+// UpdateObjectLock 修改对象的法律保留与保留期，并写入变更记录
+func UpdateObjectLock(db *gorm.DB, objectID uint, legalHold bool, retainUntil *time.Time, audit *model.LockAudit) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.OssObject{}).Where("id = ?", objectID).Updates(map[string]any{
+			"legal_hold":   legalHold,
+			"retain_until": retainUntil,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(audit).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// ListLockAudits 按时间倒序获取对象的锁定变更记录
+func ListLockAudits(db *gorm.DB, objectID uint) ([]model.LockAudit, error) {
+	var audits []model.LockAudit
+	if err := db.Where("object_id = ?", objectID).Order("id DESC").Find(&audits).Error; err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
+
+// GetLockedObjectByPath 获取文件位于 path 且在 now 时处于锁定状态的对象，用于拒绝覆盖同名文件的上传
+func GetLockedObjectByPath(db *gorm.DB, path string, now time.Time) (*model.OssObject, error) {
+	var object model.OssObject
+	query := whereStoragePath(db.Model(&model.OssObject{}), path).
+		Where("legal_hold = ? OR retain_until > ?", true, now)
+	if err := query.First(&object).Error; err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+// ============================================================================
+// LifecycleRule 操作
+// ============================================================================
+
+// ListLifecycleRules 获取存储桶的生命周期规则
+func ListLifecycleRules(db *gorm.DB, bucket string) ([]model.LifecycleRule, error) {
+	var rules []model.LifecycleRule
+	if err := db.Where("bucket = ?", bucket).Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ListEnabledLifecycleRules 获取所有存储桶已启用的生命周期规则
+func ListEnabledLifecycleRules(db *gorm.DB) ([]model.LifecycleRule, error) {
+	var rules []model.LifecycleRule
+	if err := db.Where("status = ?", model.RuleEnabled).Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ReplaceLifecycleRules 用 rules 替换存储桶的全部生命周期规则
+func ReplaceLifecycleRules(db *gorm.DB, bucket string, rules []model.LifecycleRule) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bucket = ?", bucket).Delete(&model.LifecycleRule{}).Error; err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+		for i := range rules {
+			rules[i].ID = 0
+			rules[i].Bucket = bucket
+		}
+		if err := tx.Create(&rules).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// ListLifecycleObjects 列出匹配生命周期规则且创建时间早于 before 的可用源对象，不含删除标记
+// standardOnly 为 true 时只返回仍在本地标准存储中的对象
+func ListLifecycleObjects(db *gorm.DB, rule *model.LifecycleRule, before time.Time, standardOnly bool, limit int) ([]model.OssObject, error) {
+	query := db.Where("bucket = ? AND parent_id IS NULL AND status = ? AND delete_marker = ? AND created_at < ?",
+		rule.Bucket, model.StatusActive, false, before)
+	if rule.Prefix != "" {
+		query = query.Where("substr(object_key, 1, ?) = ?", len(rule.Prefix), rule.Prefix)
+	}
+	if rule.BusinessID != "" {
+		query = query.Where("business_id = ?", rule.BusinessID)
+	}
+	for k, v := range rule.Tags {
+		query = query.Where("EXISTS (?)", db.Model(&model.ObjectTag{}).Select("1").
+			Where("object_tags.object_id = oss_objects.id AND object_tags.key = ? AND object_tags.value = ?", k, v))
+	}
+	if standardOnly {
+		query = query.Where("storage_class = ? OR storage_class IS NULL", "")
+	}
+	var objects []model.OssObject
+	if err := query.Order("id ASC").Limit(limit).Find(&objects).Error; err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// ListStaleUploadTasks 列出匹配生命周期规则且开始时间早于 before 仍未完成的上传任务
+// 未指定对象键的上传只匹配没有前缀条件的规则；上传任务没有标签，带标签条件的规则不匹配上传任务
+func ListStaleUploadTasks(db *gorm.DB, rule *model.LifecycleRule, before time.Time, limit int) ([]model.UploadTask, error) {
+	var tasks []model.UploadTask
+	if len(rule.Tags) > 0 {
+		return tasks, nil
+	}
+	buckets := []string{rule.Bucket}
+	if rule.Bucket == model.DefaultBucket {
+		buckets = append(buckets, "")
+	}
+	query := db.Where("status = ? AND created_at < ? AND COALESCE(bucket, '') IN ?", "uploading", before, buckets)
+	if rule.Prefix != "" {
+		query = query.Where("substr(object_key, 1, ?) = ?", len(rule.Prefix), rule.Prefix)
+	}
+	if rule.BusinessID != "" {
+		query = query.Where("business_id = ?", rule.BusinessID)
+	}
+	if err := query.Order("id ASC").Limit(limit).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// UpdateObjectStorageClass 更新对象的存储类型
+func UpdateObjectStorageClass(db *gorm.DB, objectID uint, storageClass string) error {
+	if err := db.Model(&model.OssObject{ID: objectID}).Update("storage_class", storageClass).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ============================================================================
+// 访问控制与分享链接操作
+// ============================================================================
+
+// ReadableBy 限定查询用户可读的源对象：public-read、用户自己的对象与被授权的对象，userID 为 0 时只有 public-read
+func ReadableBy(userID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if userID == 0 {
+			return db.Where("oss_objects.acl = ?", model.ACLPublicRead)
+		}
+		grants := db.Session(&gorm.Session{NewDB: true}).Model(&model.ObjectGrant{}).Select("1").
+			Where("object_grants.object_id = oss_objects.id AND object_grants.user_id = ?", userID)
+		return db.Where("oss_objects.acl = ? OR oss_objects.user_id = ? OR EXISTS (?)", model.ACLPublicRead, userID, grants)
+	}
+}
+
+// UpdateObjectACL 修改对象的访问控制与公开访问地址
+func UpdateObjectACL(db *gorm.DB, objectID uint, acl, url string) error {
+	if err := db.Model(&model.OssObject{ID: objectID}).Updates(map[string]any{"acl": acl, "url": url}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// HasGrant 返回用户是否被授权读取对象
+func HasGrant(db *gorm.DB, objectID, userID uint) (bool, error) {
+	var count int64
+	if err := db.Model(&model.ObjectGrant{}).Where("object_id = ? AND user_id = ?", objectID, userID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ListGrants 列出对象的授权，按授权时间排序
+func ListGrants(db *gorm.DB, objectID uint) ([]model.ObjectGrant, error) {
+	var grants []model.ObjectGrant
+	if err := db.Where("object_id = ?", objectID).Order("id ASC").Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// CreateGrant 授权用户读取对象，已授权时不做修改
+func CreateGrant(db *gorm.DB, grant *model.ObjectGrant) error {
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(grant).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteGrant 撤销用户对对象的授权
+func DeleteGrant(db *gorm.DB, objectID, userID uint) error {
+	if err := db.Where("object_id = ? AND user_id = ?", objectID, userID).Delete(&model.ObjectGrant{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateShare 创建分享链接
+func CreateShare(db *gorm.DB, share *model.ObjectShare) error {
+	if err := db.Create(share).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetShareByToken 根据令牌获取分享链接，包括已撤销与已过期的链接
+func GetShareByToken(db *gorm.DB, token string) (*model.ObjectShare, error) {
+	var share model.ObjectShare
+	if err := db.Where("token = ?", token).First(&share).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// ListShares 列出对象的分享链接，按创建时间倒序
+func ListShares(db *gorm.DB, objectID uint) ([]model.ObjectShare, error) {
+	var shares []model.ObjectShare
+	if err := db.Where("object_id = ?", objectID).Order("id DESC").Find(&shares).Error; err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// RevokeShare 撤销对象的分享链接，链接不存在或已撤销时返回 gorm.ErrRecordNotFound
+func RevokeShare(db *gorm.DB, objectID, shareID uint, now time.Time) error {
+	result := db.Model(&model.ObjectShare{}).
+		Where("id = ? AND object_id = ? AND revoked_at IS NULL", shareID, objectID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ConsumeShareDownload 在分享链接仍可用时把下载次数加一，返回是否成功
+// 条件更新保证并发下载不会超过次数上限
+func ConsumeShareDownload(db *gorm.DB, shareID uint, now time.Time) (bool, error) {
+	result := db.Model(&model.ObjectShare{}).
+		Where("id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?) AND (max_downloads = 0 OR downloads < max_downloads)", shareID, now).
+		Update("downloads", gorm.Expr("downloads + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
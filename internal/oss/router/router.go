@@ -16,9 +16,133 @@ func RegisterRoutes(app *fiber.App, handlers handlers.Handlers) {
 	// 上传分片
 	oss.Post("/upload/:uploadid/chunk/:chunkIndex", handlers.Chunk)
 
+	// 从 URL 下载创建对象，异步执行，通过上传状态接口查询结果
+	oss.Post("/upload/fetch", handlers.Fetch)
+
 	// 完成上传
 	oss.Post("/upload/:uploadid/complete", handlers.Complete)
 
 	// 查询上传状态
 	oss.Get("/upload/:uploadid/status", handlers.Status)
+
+	// 按上传时间倒序列出对象，?type=&tag=&before=&limit=
+	oss.Get("/objects", handlers.ListObjects)
+
+	// 按顺序拼接多个对象生成新对象
+	oss.Post("/objects/compose", handlers.ComposeObject)
+
+	// 查询对象元数据（含衍生对象），读取类接口按对象的 ACL 校验
+	oss.Get("/objects/:id", handlers.RequireRead, handlers.GetObject)
+
+	// 下载对象，?variant= 指定衍生规格
+	oss.Get("/objects/:id/download", handlers.RequireRead, handlers.Download)
+
+	// 对象标签：查询、整体替换、清空
+	oss.Get("/objects/:id/tags", handlers.RequireRead, handlers.GetTags)
+	oss.Put("/objects/:id/tags", handlers.PutTags)
+	oss.Delete("/objects/:id/tags", handlers.DeleteTags)
+	// 保留期：所有者可以设置或延长，缩短或清除需要管理员令牌
+	oss.Put("/objects/:id/retention", handlers.PutRetention)
+
+	// 访问控制：public-read 或 private，private 对象只有所有者、被授权的用户与管理员可读
+	oss.Get("/objects/:id/acl", handlers.GetACL)
+	oss.Put("/objects/:id/acl", handlers.PutACL)
+	oss.Put("/objects/:id/grants/:user", handlers.PutGrant)
+	oss.Delete("/objects/:id/grants/:user", handlers.DeleteGrant)
+
+	// 分享链接：创建（有效期、密码、下载次数上限）、列出、撤销
+	oss.Post("/objects/:id/shares", handlers.CreateShare)
+	oss.Get("/objects/:id/shares", handlers.ListShares)
+	oss.Delete("/objects/:id/shares/:share", handlers.RevokeShare)
+
+	// 删除对象，默认移入回收站，?permanent=true 立即物理删除
+	oss.Delete("/objects/:id", handlers.DeleteObject)
+
+	// 服务端复制与移动到新的对象键
+	oss.Post("/objects/:id/copy", handlers.CopyObject)
+	oss.Post("/objects/:id/move", handlers.MoveObject)
+
+	// 从回收站恢复对象
+	oss.Post("/objects/:id/restore", handlers.RestoreObject)
+
+	// 通过分享链接查询文件信息与下载，受密码保护时携带 X-Share-Password
+	oss.Get("/shares/:token", handlers.GetShare)
+	oss.Get("/shares/:token/download", handlers.DownloadShare)
+
+	// 当前用户的回收站，?before=&limit=
+	oss.Get("/trash", handlers.ListTrash)
+
+	// 存储桶版本控制：查询与设置版本控制状态（需要管理员令牌），列出版本
+	oss.Get("/buckets/:bucket", handlers.GetBucket)
+	oss.Put("/buckets/:bucket/versioning", handlers.RequireAdmin, handlers.PutVersioning)
+	oss.Get("/buckets/:bucket/versions", handlers.ListVersions)
+
+	// 打包下载当前用户的对象，?key= 可重复或 ?prefix=，支持 Range 续传
+	oss.Get("/buckets/:bucket/archive", handlers.Archive)
+
+	// 存储桶的生命周期规则：过期、转移到冷存储、取消未完成的上传
+	oss.Get("/buckets/:bucket/lifecycle", handlers.GetLifecycle)
+	oss.Put("/buckets/:bucket/lifecycle", handlers.PutLifecycle)
+
+	// 按对象键访问，?key= 必填，?version_id= 指定版本：查询、下载、删除（写入删除标记）、设为当前版本
+	oss.Get("/buckets/:bucket/object", handlers.GetKeyedObject)
+	oss.Get("/buckets/:bucket/object/download", handlers.DownloadKeyedObject)
+	oss.Delete("/buckets/:bucket/object", handlers.DeleteKeyedObject)
+	oss.Post("/buckets/:bucket/object/promote", handlers.PromoteVersion)
+
+	// 使用已上传的图片作为视频封面
+	oss.Put("/objects/:id/cover", handlers.SetCover)
+
+	// 查找感知哈希接近的图片与视频封面，?distance=&limit=
+	oss.Get("/objects/:id/similar", handlers.RequireRead, handlers.Similar)
+
+	// 查询转码任务进度
+	oss.Get("/objects/:id/transcodes", handlers.RequireRead, handlers.Transcodes)
+
+	// HLS 播放列表与分片
+	oss.Get("/objects/:id/hls/*", handlers.RequireRead, handlers.HLS)
+
+	// DASH MPD 与分片
+	oss.Get("/objects/:id/dash/*", handlers.RequireRead, handlers.DASH)
+
+	// HLS 内容密钥，仅供播放边缘调用
+	oss.Get("/objects/:id/keys/:kid", handlers.Key)
+
+	// 音频波形峰值，?samples_per_pixel= 或 ?pixels= 选择分辨率，?format=binary 返回二进制
+	oss.Get("/objects/:id/waveform", handlers.RequireRead, handlers.Waveform)
+
+	// 字幕：上传 SRT/WebVTT（统一转为 WebVTT）、查询、下载、删除，HLS 主播放列表自动加入字幕渲染
+	oss.Get("/objects/:id/subtitles", handlers.RequireRead, handlers.ListSubtitles)
+	oss.Put("/objects/:id/subtitles/:lang", handlers.PutSubtitle)
+	oss.Get("/objects/:id/subtitles/:lang", handlers.RequireRead, handlers.GetSubtitle)
+	oss.Delete("/objects/:id/subtitles/:lang", handlers.DeleteSubtitle)
+
+	// 按字幕文本检索视频片段
+	oss.Get("/subtitles/search", handlers.SearchSubtitles)
+
+	// 内容审核：列出待审核/已隔离的对象，审核通过、拒绝或重新扫描，需携带管理员令牌
+	admin := oss.Group("/admin", handlers.RequireAdmin)
+	admin.Get("/review", handlers.ReviewList)
+	admin.Post("/objects/:id/approve", handlers.Approve)
+	admin.Post("/objects/:id/reject", handlers.Reject)
+	admin.Post("/objects/:id/rescan", handlers.Rescan)
+
+	// 生命周期规则：试运行报告与立即执行，?bucket= 指定存储桶
+	admin.Get("/lifecycle/report", handlers.LifecycleReport)
+	admin.Post("/lifecycle/run", handlers.LifecycleRun)
+
+	// 法律保留与锁定变更记录
+	admin.Put("/objects/:id/legal-hold", handlers.PutLegalHold)
+	admin.Get("/objects/:id/lock-audits", handlers.ListLockAudits)
+
+	// 直播：创建会话、编码器推送分片、结束后转为点播
+	oss.Post("/live", handlers.LiveCreate)
+	oss.Get("/live/:id", handlers.LiveGet)
+	oss.Put("/live/:id/init.mp4", handlers.LivePutInit)
+	oss.Put("/live/:id/segments/:seq", handlers.LivePutSegment)
+	oss.Post("/live/:id/end", handlers.LiveEnd)
+
+	// 直播分片列表（支持阻塞等待新分片）与分片文件，供播放边缘调用
+	oss.Get("/live/:id/segments", handlers.LiveSegments)
+	oss.Get("/live/:id/files/:name", handlers.LiveFile)
 }
@@ -0,0 +1,100 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+)
+
+// Blocklist 按文件哈希拦截已知的违规内容，支持 MD5 与 SHA-256
+type Blocklist struct {
+	mu     sync.RWMutex
+	hashes map[string]string // 小写十六进制哈希 -> 标签
+}
+
+func NewBlocklist() *Blocklist {
+	return &Blocklist{hashes: make(map[string]string)}
+}
+
+// LoadBlocklist 从文件加载黑名单，每行为 "<哈希> [标签]"，# 开头的行为注释
+func LoadBlocklist(path string) (*Blocklist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b := NewBlocklist()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		hash, label, _ := strings.Cut(text, " ")
+		if err := b.Add(hash, strings.TrimSpace(label)); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Add 加入一个哈希
+func (b *Blocklist) Add(hash, label string) error {
+	hash = strings.ToLower(hash)
+	if _, err := hex.DecodeString(hash); err != nil || (len(hash) != 2*md5.Size && len(hash) != 2*sha256.Size) {
+		return fmt.Errorf("scan: invalid hash %q", hash)
+	}
+	b.mu.Lock()
+	b.hashes[hash] = label
+	b.mu.Unlock()
+	return nil
+}
+
+// Len 返回黑名单中的哈希数
+func (b *Blocklist) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.hashes)
+}
+
+func (b *Blocklist) Name() string {
+	return "blocklist"
+}
+
+// Scan 一次读取同时计算 MD5 与 SHA-256
+func (b *Blocklist) Scan(ctx context.Context, path string) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), storage.ContextReader(ctx, f)); err != nil {
+		return Result{}, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sum := range []string{hex.EncodeToString(md5Hash.Sum(nil)), hex.EncodeToString(sha256Hash.Sum(nil))} {
+		if label, ok := b.hashes[sum]; ok {
+			reason := "blocklisted hash " + sum
+			if label != "" {
+				reason += " (" + label + ")"
+			}
+			return Result{Verdict: VerdictInfected, Reason: reason}, nil
+		}
+	}
+	return Result{Verdict: VerdictClean}, nil
+}
@@ -0,0 +1,167 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// clamd 每个 INSTREAM 数据块的大小，需小于 clamd 的 StreamMaxLength
+const clamdChunkSize = 64 * 1024
+
+// Clamd 通过 clamd 的 INSTREAM 命令把文件内容发送给 ClamAV 扫描
+// 文件以流的方式发送，clamd 不需要访问 OSS 的存储目录
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration // 连接与单次读写的超时
+}
+
+// NewClamd 创建 clamd 客户端，address 形如 tcp://127.0.0.1:3310、unix:///run/clamd.sock 或 host:port
+func NewClamd(address string) *Clamd {
+	c := &Clamd{network: "tcp", address: address, timeout: 30 * time.Second}
+	if rest, ok := strings.CutPrefix(address, "unix://"); ok {
+		c.network, c.address = "unix", rest
+	} else if rest, ok := strings.CutPrefix(address, "tcp://"); ok {
+		c.address = rest
+	}
+	return c
+}
+
+func (c *Clamd) Name() string {
+	return "clamav"
+}
+
+func (c *Clamd) dial(ctx context.Context) (net.Conn, error) {
+	d := net.Dialer{Timeout: c.timeout}
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, err
+	}
+	// ctx 取消时关闭连接，让阻塞的读写返回
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	return &ctxConn{Conn: conn, stop: stop}, nil
+}
+
+type ctxConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *ctxConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// Ping 检查 clamd 是否可用
+func (c *Clamd) Ping(ctx context.Context) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("clamd: unexpected reply %q", reply)
+	}
+	return nil
+}
+
+// Scan 用 INSTREAM 扫描文件：每个数据块前加 4 字节大端长度，长度为 0 的块表示结束
+func (c *Clamd) Scan(ctx context.Context, path string) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriterSize(conn, clamdChunkSize+4)
+	deadline := func() { conn.SetDeadline(time.Now().Add(c.timeout)) }
+	deadline()
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return Result{}, err
+	}
+	buf := make([]byte, clamdChunkSize)
+	var size [4]byte
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			deadline()
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			w.Write(size[:])
+			if _, err := w.Write(buf[:n]); err != nil {
+				return Result{}, c.streamError(conn, err)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	w.Write(size[:])
+	if err := w.Flush(); err != nil {
+		return Result{}, c.streamError(conn, err)
+	}
+
+	deadline()
+	reply, err := readReply(conn)
+	if err != nil {
+		return Result{}, err
+	}
+	return parseReply(reply)
+}
+
+// streamError 超出 StreamMaxLength 时 clamd 会回复错误并关闭连接，尽量读出该错误
+func (c *Clamd) streamError(conn net.Conn, err error) error {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if reply, rerr := readReply(conn); rerr == nil && reply != "" {
+		return fmt.Errorf("clamd: %s", reply)
+	}
+	return err
+}
+
+// readReply 读取以 \0 结尾的回复
+func readReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadBytes(0)
+	if err != nil && !(err == io.EOF && len(reply) > 0) {
+		return "", err
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+// parseReply 解析 "stream: OK"、"stream: <签名> FOUND" 与 "... ERROR"
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return Result{Verdict: VerdictClean}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Verdict: VerdictInfected, Reason: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.HasSuffix(reply, " ERROR"):
+		return Result{}, errors.New("clamd: " + strings.TrimSuffix(reply, " ERROR"))
+	}
+	return Result{}, fmt.Errorf("clamd: unexpected reply %q", reply)
+}
@@ -0,0 +1,190 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd 在 unix socket 上模拟 clamd 的 PING 与 INSTREAM 命令
+// 内容包含 EICAR 时回复 FOUND，超过 maxStream 字节时按 clamd 的行为回复错误并断开
+type fakeClamd struct {
+	maxStream int
+	hang      bool        // 收到命令后不回复
+	received  chan []byte // 每次 INSTREAM 收到的完整内容
+}
+
+func startFakeClamd(t *testing.T, fake *fakeClamd) *Clamd {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "clamd.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	fake.received = make(chan []byte, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fake.serve(conn)
+		}
+	}()
+	return NewClamd("unix://" + path)
+}
+
+func (f *fakeClamd) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	if f.hang {
+		io.Copy(io.Discard, r)
+		return
+	}
+	switch command {
+	case "zPING\x00":
+		conn.Write([]byte("PONG\x00"))
+	case "zINSTREAM\x00":
+		var data []byte
+		var size [4]byte
+		for {
+			if _, err := io.ReadFull(r, size[:]); err != nil {
+				return
+			}
+			n := binary.BigEndian.Uint32(size[:])
+			if n == 0 {
+				break
+			}
+			chunk := make([]byte, n)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return
+			}
+			data = append(data, chunk...)
+			if f.maxStream > 0 && len(data) > f.maxStream {
+				conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+				return
+			}
+		}
+		f.received <- data
+		if bytes.Contains(data, []byte("EICAR")) {
+			conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		} else {
+			conn.Write([]byte("stream: OK\x00"))
+		}
+	default:
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+	}
+}
+
+func writeTemp(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "upload")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewClamdAddress(t *testing.T) {
+	tests := []struct{ in, network, address string }{
+		{"tcp://127.0.0.1:3310", "tcp", "127.0.0.1:3310"},
+		{"unix:///run/clamd.sock", "unix", "/run/clamd.sock"},
+		{"clamav:3310", "tcp", "clamav:3310"},
+	}
+	for _, tt := range tests {
+		c := NewClamd(tt.in)
+		if c.network != tt.network || c.address != tt.address {
+			t.Errorf("NewClamd(%q) = %s %s", tt.in, c.network, c.address)
+		}
+	}
+}
+
+func TestClamdPing(t *testing.T) {
+	c := startFakeClamd(t, &fakeClamd{})
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClamdScanClean(t *testing.T) {
+	fake := &fakeClamd{}
+	c := startFakeClamd(t, fake)
+	// 超过一个数据块，检查分块与结束块
+	content := bytes.Repeat([]byte("0123456789abcdef"), clamdChunkSize/16*2+5)
+	result, err := c.Scan(context.Background(), writeTemp(t, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != VerdictClean {
+		t.Errorf("verdict = %s, want clean", result.Verdict)
+	}
+	if got := <-fake.received; !bytes.Equal(got, content) {
+		t.Errorf("clamd received %d bytes, want %d", len(got), len(content))
+	}
+}
+
+func TestClamdScanInfected(t *testing.T) {
+	c := startFakeClamd(t, &fakeClamd{})
+	result, err := c.Scan(context.Background(), writeTemp(t, []byte("X5O!P%@AP EICAR test file")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != VerdictInfected || result.Reason != "Eicar-Test-Signature" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestClamdScanEmptyFile(t *testing.T) {
+	fake := &fakeClamd{}
+	c := startFakeClamd(t, fake)
+	result, err := c.Scan(context.Background(), writeTemp(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != VerdictClean || len(<-fake.received) != 0 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestClamdScanSizeLimit(t *testing.T) {
+	c := startFakeClamd(t, &fakeClamd{maxStream: clamdChunkSize})
+	_, err := c.Scan(context.Background(), writeTemp(t, make([]byte, 4*clamdChunkSize)))
+	if err == nil || !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Errorf("Scan() error = %v, want size limit error", err)
+	}
+}
+
+func TestClamdScanCanceled(t *testing.T) {
+	c := startFakeClamd(t, &fakeClamd{hang: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.Scan(ctx, writeTemp(t, []byte("data"))); err == nil {
+		t.Fatal("Scan() succeeded without a reply")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Scan() returned after %v, want it to stop when ctx is done", elapsed)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	if _, err := parseReply("stream: Can't allocate memory ERROR"); err == nil || !strings.Contains(err.Error(), "allocate memory") {
+		t.Errorf("parseReply(ERROR) = %v", err)
+	}
+	if _, err := parseReply("garbage"); err == nil {
+		t.Error("parseReply accepted an unexpected reply")
+	}
+}
@@ -0,0 +1,101 @@
+package scan
+
+import (
+	"context"
+	"time"
+)
+
+// 扫描结论，严重程度依次升高
+const (
+	VerdictClean    = "clean"    // 未发现问题
+	VerdictReview   = "review"   // 需要人工审核
+	VerdictInfected = "infected" // 命中恶意软件或黑名单，应隔离
+)
+
+// Result 是单个扫描器的结论
+type Result struct {
+	Verdict string
+	Reason  string // 命中的病毒签名、黑名单标签等
+}
+
+// Scanner 是可插拔的文件扫描器
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, path string) (Result, error)
+}
+
+// Finding 是一个扫描器在一次扫描中的结果
+type Finding struct {
+	Scanner string `json:"scanner"`
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report 是一次扫描的汇总，Verdict 取所有扫描器中最严重的结论
+type Report struct {
+	Verdict  string    `json:"verdict"`
+	Findings []Finding `json:"findings"`
+}
+
+// Config 是扫描流水线的配置
+type Config struct {
+	Timeout  time.Duration // 单个文件所有扫描器的总超时
+	FailOpen bool          // 扫描器出错时视为通过；默认转人工审核
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{Timeout: 5 * time.Minute}
+}
+
+// Pipeline 依次执行所有扫描器，命中隔离结论后不再执行后续扫描器
+type Pipeline struct {
+	cfg      Config
+	scanners []Scanner
+}
+
+func NewPipeline(cfg Config, scanners ...Scanner) *Pipeline {
+	return &Pipeline{cfg: cfg, scanners: scanners}
+}
+
+// Scan 扫描文件
+func (p *Pipeline) Scan(ctx context.Context, path string) Report {
+	if p.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.Timeout)
+		defer cancel()
+	}
+	report := Report{Verdict: VerdictClean}
+	for _, s := range p.scanners {
+		finding := Finding{Scanner: s.Name()}
+		result, err := s.Scan(ctx, path)
+		if err != nil {
+			finding.Error = err.Error()
+			finding.Verdict = VerdictReview
+			if p.cfg.FailOpen {
+				finding.Verdict = VerdictClean
+			}
+		} else {
+			finding.Verdict, finding.Reason = result.Verdict, result.Reason
+		}
+		report.Findings = append(report.Findings, finding)
+		if severity(finding.Verdict) > severity(report.Verdict) {
+			report.Verdict = finding.Verdict
+		}
+		if report.Verdict == VerdictInfected {
+			break
+		}
+	}
+	return report
+}
+
+func severity(verdict string) int {
+	switch verdict {
+	case VerdictClean:
+		return 0
+	case VerdictReview:
+		return 1
+	}
+	return 2
+}
@@ -0,0 +1,132 @@
+package scan
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubScanner 返回固定结论的扫描器
+type stubScanner struct {
+	name   string
+	result Result
+	err    error
+	calls  int
+}
+
+func (s *stubScanner) Name() string {
+	return s.name
+}
+
+func (s *stubScanner) Scan(ctx context.Context, path string) (Result, error) {
+	s.calls++
+	return s.result, s.err
+}
+
+func TestPipelineTakesMostSevereVerdict(t *testing.T) {
+	clean := &stubScanner{name: "a", result: Result{Verdict: VerdictClean}}
+	review := &stubScanner{name: "b", result: Result{Verdict: VerdictReview, Reason: "nsfw"}}
+	report := NewPipeline(DefaultConfig(), clean, review).Scan(context.Background(), "unused")
+	if report.Verdict != VerdictReview || len(report.Findings) != 2 || report.Findings[1].Reason != "nsfw" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestPipelineStopsAtInfected(t *testing.T) {
+	infected := &stubScanner{name: "a", result: Result{Verdict: VerdictInfected, Reason: "Eicar"}}
+	next := &stubScanner{name: "b", result: Result{Verdict: VerdictClean}}
+	report := NewPipeline(DefaultConfig(), infected, next).Scan(context.Background(), "unused")
+	if report.Verdict != VerdictInfected || next.calls != 0 {
+		t.Errorf("report = %+v, later scanner called %d times", report, next.calls)
+	}
+}
+
+func TestPipelineScannerError(t *testing.T) {
+	failing := &stubScanner{name: "clamav", err: errors.New("connection refused")}
+
+	report := NewPipeline(DefaultConfig(), failing).Scan(context.Background(), "unused")
+	if report.Verdict != VerdictReview || report.Findings[0].Error != "connection refused" {
+		t.Errorf("fail closed: report = %+v", report)
+	}
+
+	cfg := DefaultConfig()
+	cfg.FailOpen = true
+	report = NewPipeline(cfg, failing).Scan(context.Background(), "unused")
+	if report.Verdict != VerdictClean || report.Findings[0].Error == "" {
+		t.Errorf("fail open: report = %+v", report)
+	}
+}
+
+func TestPipelineWithFakeClamd(t *testing.T) {
+	clamd := startFakeClamd(t, &fakeClamd{})
+	report := NewPipeline(DefaultConfig(), clamd).Scan(context.Background(), writeTemp(t, []byte("EICAR")))
+	if report.Verdict != VerdictInfected || report.Findings[0].Scanner != "clamav" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	content := []byte("known bad content")
+	md5Sum := md5.Sum(content)
+	sha256Sum := sha256.Sum256(content)
+	path := writeTemp(t, content)
+
+	for name, hash := range map[string]string{
+		"md5":    strings.ToUpper(hex.EncodeToString(md5Sum[:])),
+		"sha256": hex.EncodeToString(sha256Sum[:]),
+	} {
+		b := NewBlocklist()
+		if err := b.Add(hash, "known-bad"); err != nil {
+			t.Fatal(err)
+		}
+		result, err := b.Scan(context.Background(), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Verdict != VerdictInfected || !strings.Contains(result.Reason, "known-bad") {
+			t.Errorf("%s: result = %+v", name, result)
+		}
+	}
+
+	result, err := NewBlocklist().Scan(context.Background(), path)
+	if err != nil || result.Verdict != VerdictClean {
+		t.Errorf("empty blocklist: %+v, %v", result, err)
+	}
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	data := "# known hashes\n\nd41d8cd98f00b204e9800998ecf8427e empty file\n" +
+		"E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadBlocklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", b.Len())
+	}
+
+	if err := os.WriteFile(path, []byte("d41d8cd98f00b204e9800998ecf8427e\nnot-a-hash\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBlocklist(path); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("LoadBlocklist() error = %v, want error on line 2", err)
+	}
+}
+
+func TestBlocklistScanCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewBlocklist().Scan(ctx, writeTemp(t, []byte("data"))); !errors.Is(err, context.Canceled) {
+		t.Errorf("Scan() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,78 @@
+package similar
+
+// BKTree 以汉明距离为度量的 BK 树，按距离剪枝，查询不必遍历所有哈希
+// 不支持删除，调用方需要自行过滤已失效的结果
+type BKTree struct {
+	root *bkNode
+	size int
+}
+
+type bkNode struct {
+	hash     uint64
+	ids      []uint // 哈希完全相同的对象共用一个节点
+	children map[int]*bkNode
+}
+
+// Match 是一次查询命中的对象
+type Match struct {
+	ID       uint
+	Hash     uint64
+	Distance int
+}
+
+// Len 返回已加入的对象数
+func (t *BKTree) Len() int {
+	return t.size
+}
+
+// Add 加入一个对象的哈希
+func (t *BKTree) Add(hash uint64, id uint) {
+	t.size++
+	if t.root == nil {
+		t.root = &bkNode{hash: hash, ids: []uint{id}}
+		return
+	}
+	node := t.root
+	for {
+		d := Distance(node.hash, hash)
+		if d == 0 {
+			node.ids = append(node.ids, id)
+			return
+		}
+		child, ok := node.children[d]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[d] = &bkNode{hash: hash, ids: []uint{id}}
+			return
+		}
+		node = child
+	}
+}
+
+// Search 返回与 hash 距离不超过 maxDistance 的所有对象
+func (t *BKTree) Search(hash uint64, maxDistance int) []Match {
+	if t.root == nil {
+		return nil
+	}
+	var matches []Match
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := Distance(node.hash, hash)
+		if d <= maxDistance {
+			for _, id := range node.ids {
+				matches = append(matches, Match{ID: id, Hash: node.hash, Distance: d})
+			}
+		}
+		// 三角不等式：只有与当前节点距离在 [d-max, d+max] 内的子树可能命中
+		for childDistance, child := range node.children {
+			if childDistance >= d-maxDistance && childDistance <= d+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+	return matches
+}
@@ -0,0 +1,100 @@
+package similar
+
+import (
+	"image"
+	"math"
+	"math/bits"
+	"slices"
+
+	"golang.org/x/image/draw"
+)
+
+// Hashes 是一张图片的感知哈希
+// pHash 基于低频 DCT 系数，对重新压缩、缩放、调色更稳定，用于建立索引；dHash 基于相邻像素梯度，用于辅助确认
+type Hashes struct {
+	PHash uint64
+	DHash uint64
+}
+
+// Compute 计算图片的 pHash 与 dHash
+func Compute(img image.Image) Hashes {
+	return Hashes{PHash: PHash(img), DHash: DHash(img)}
+}
+
+// Distance 返回两个哈希的汉明距离
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// gray 把图片拉伸缩放到 w x h 的灰度图
+func gray(img image.Image, w, h int) *image.Gray {
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// DHash 缩放到 9x8 灰度图，每行相邻像素左亮于右记为 1
+func DHash(img image.Image) uint64 {
+	g := gray(img, 9, 8)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if g.GrayAt(x, y).Y > g.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// PHash 缩放到 32x32 灰度图做二维 DCT，取左上角 8x8 低频系数，大于中位数记为 1
+// 中位数不含直流分量，避免整体亮度主导结果
+func PHash(img image.Image) uint64 {
+	const size, low = 32, 8
+	g := gray(img, size, size)
+	pixels := make([]float64, size*size)
+	for i, v := range g.Pix {
+		pixels[i] = float64(v)
+	}
+
+	// 先对每行、再对每列做一维 DCT，只需要前 8 个系数
+	rows := make([]float64, size*low)
+	for y := 0; y < size; y++ {
+		for u := 0; u < low; u++ {
+			rows[y*low+u] = dct(pixels[y*size:(y+1)*size], u)
+		}
+	}
+	coeffs := make([]float64, low*low)
+	column := make([]float64, size)
+	for u := 0; u < low; u++ {
+		for y := 0; y < size; y++ {
+			column[y] = rows[y*low+u]
+		}
+		for v := 0; v < low; v++ {
+			coeffs[v*low+u] = dct(column, v)
+		}
+	}
+
+	sorted := slices.Clone(coeffs[1:])
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+	var hash uint64
+	for _, c := range coeffs {
+		hash <<= 1
+		if c > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// dct 计算一维 DCT-II 的第 k 个系数（未归一化，比较大小时不影响结果）
+func dct(values []float64, k int) float64 {
+	n := len(values)
+	sum := 0.0
+	for i, value := range values {
+		sum += value * math.Cos(math.Pi*float64(k)*(2*float64(i)+1)/float64(2*n))
+	}
+	return sum
+}
@@ -0,0 +1,102 @@
+package similar
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"gorm.io/gorm"
+)
+
+// Result 是一个近似对象，距离越小越相似
+type Result struct {
+	ObjectID      uint   `json:"object_id"`
+	Source        string `json:"source"`         // image/cover
+	Distance      int    `json:"distance"`       // pHash 汉明距离
+	DHashDistance int    `json:"dhash_distance"` // dHash 汉明距离
+}
+
+// Index 是内存中的 pHash BK 树，哈希持久化在 image_hashes 表，启动时重建
+// 对象的哈希更新后旧节点仍留在树中，查询结果会与数据库中的当前哈希核对
+type Index struct {
+	db *gorm.DB
+
+	mu   sync.RWMutex
+	tree BKTree
+}
+
+// NewIndex 从数据库加载所有哈希建立索引
+func NewIndex(db *gorm.DB) (*Index, error) {
+	hashes, err := repo.ListImageHashes(db)
+	if err != nil {
+		return nil, err
+	}
+	idx := &Index{db: db}
+	for _, h := range hashes {
+		idx.tree.Add(uint64(h.PHash), h.ObjectID)
+	}
+	return idx, nil
+}
+
+// Add 保存对象的哈希并加入索引
+func (i *Index) Add(objectID uint, source string, h Hashes) error {
+	if err := repo.SaveImageHash(i.db, &model.ImageHash{
+		ObjectID: objectID,
+		Source:   source,
+		PHash:    int64(h.PHash),
+		DHash:    int64(h.DHash),
+	}); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	i.tree.Add(h.PHash, objectID)
+	i.mu.Unlock()
+	return nil
+}
+
+// Search 查找 pHash 距离不超过 maxDistance 的对象，按 pHash、dHash 距离升序排列，exclude 为查询对象自身
+func (i *Index) Search(h Hashes, maxDistance int, exclude uint, limit int) ([]Result, error) {
+	i.mu.RLock()
+	matches := i.tree.Search(h.PHash, maxDistance)
+	i.mu.RUnlock()
+
+	ids := make([]uint, 0, len(matches))
+	for _, m := range matches {
+		if m.ID != exclude {
+			ids = append(ids, m.ID)
+		}
+	}
+	current, err := repo.GetImageHashes(i.db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(current))
+	for _, c := range current {
+		d := Distance(uint64(c.PHash), h.PHash)
+		if d > maxDistance {
+			continue
+		}
+		results = append(results, Result{
+			ObjectID:      c.ObjectID,
+			Source:        c.Source,
+			Distance:      d,
+			DHashDistance: Distance(uint64(c.DHash), h.DHash),
+		})
+	}
+	sort.Slice(results, func(a, b int) bool {
+		ra, rb := results[a], results[b]
+		if ra.Distance != rb.Distance {
+			return ra.Distance < rb.Distance
+		}
+		if ra.DHashDistance != rb.DHashDistance {
+			return ra.DHashDistance < rb.DHashDistance
+		}
+		return ra.ObjectID < rb.ObjectID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Driver 是对象文件的存储后端，目前用于冷存储：生命周期规则把长期不访问的对象转移到 Driver 中
+type Driver interface {
+	Name() string
+	Put(ctx context.Context, key string, r io.Reader) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// LocalDriver 把文件保存在本地目录中，目录可以挂载到低成本的磁盘或网络存储
+type LocalDriver struct {
+	dir string
+}
+
+func NewLocalDriver(dir string) *LocalDriver {
+	return &LocalDriver{dir: dir}
+}
+
+func (d *LocalDriver) Name() string {
+	return "local:" + d.dir
+}
+
+// path 把以 / 分隔的键映射到目录下的文件，拒绝跳出目录的键
+func (d *LocalDriver) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(d.dir, clean), nil
+}
+
+func (d *LocalDriver) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	_, _, err = WriteFile(path, func(w io.Writer) error {
+		_, err := io.Copy(w, &ctxReader{ctx: ctx, r: r})
+		return err
+	})
+	return err
+}
+
+func (d *LocalDriver) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Delete 删除文件，文件不存在时不报错
+func (d *LocalDriver) Delete(ctx context.Context, key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// 删除空的上级目录，忽略非空目录的错误
+	os.Remove(filepath.Dir(path))
+	return nil
+}
+
+// ContextReader 返回在 ctx 取消后读取失败的 Reader，复制大文件时响应 ctx 取消
+func ContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &ctxReader{ctx: ctx, r: r}
+}
+
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WriteFile 通过 write 回调写入 path，返回写入内容的 MD5(ETag) 与大小
+// 先写临时文件再重命名，避免读取方看到写了一半的文件
+func WriteFile(path string, write func(w io.Writer) error) (string, int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	counter := &countingWriter{}
+	if err := write(io.MultiWriter(tmp, hash, counter)); err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), counter.n, nil
+}
+
+// ReplaceDir 用 tmpDir 替换 dir：旧目录先改名移开，新目录改名到位后再删除旧目录
+// 改名失败时旧目录恢复原位；进程在两次改名之间退出时，旧内容保留在 <dir>.old-* 中
+func ReplaceDir(tmpDir, dir string) error {
+	oldDir := fmt.Sprintf("%s.old-%d", dir, time.Now().UnixNano())
+	if err := os.Rename(dir, oldDir); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		oldDir = ""
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		if oldDir != "" {
+			os.Rename(oldDir, dir)
+		}
+		return err
+	}
+	if oldDir != "" {
+		os.RemoveAll(oldDir)
+	}
+	return nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// Checksum 计算文件的 MD5(ETag) 与大小
+func Checksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), n, nil
+}
@@ -0,0 +1,277 @@
+package subtitle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 字幕源文件格式
+const (
+	FormatSRT = "srt"
+	FormatVTT = "vtt"
+)
+
+var (
+	ErrEmpty       = errors.New("subtitle: no cues found")
+	ErrInvalidUTF8 = errors.New("subtitle: file is not valid UTF-8")
+)
+
+// Cue 是一条字幕
+type Cue struct {
+	ID       string        // WebVTT 的 cue 标识，SRT 的序号不保留
+	Start    time.Duration // 开始时间
+	End      time.Duration // 结束时间
+	Settings string        // WebVTT 的 cue 设置，如 line:0 align:start
+	Text     string        // WebVTT 格式的文本，可包含 <i>/<b>/<u> 等标签，多行以 \n 分隔
+}
+
+// Parse 解析 SRT 或 WebVTT 字幕，以 WEBVTT 开头的按 WebVTT 解析，否则按 SRT 解析
+// 返回的字幕按开始时间排序，SRT 文本中 WebVTT 不支持的标签会被去掉
+func Parse(data []byte) ([]Cue, string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return nil, "", ErrInvalidUTF8
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	format, parse := FormatSRT, parseSRTBlock
+	if isVTT(text) {
+		format, parse = FormatVTT, parseVTTBlock
+	}
+
+	var cues []Cue
+	for i, block := range splitBlocks(text) {
+		if format == FormatVTT && i == 0 {
+			continue // WEBVTT 文件头
+		}
+		cue, ok, err := parse(block.lines)
+		if err != nil {
+			return nil, "", fmt.Errorf("subtitle: line %d: %w", block.line, err)
+		}
+		if ok {
+			cues = append(cues, cue)
+		}
+	}
+	if len(cues) == 0 {
+		return nil, "", ErrEmpty
+	}
+	// WebVTT 要求字幕按开始时间排列，SRT 文件不一定满足
+	sort.SliceStable(cues, func(i, j int) bool {
+		return cues[i].Start < cues[j].Start
+	})
+	return cues, format, nil
+}
+
+func isVTT(text string) bool {
+	header, _, _ := strings.Cut(text, "\n")
+	rest, ok := strings.CutPrefix(header, "WEBVTT")
+	return ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t')
+}
+
+type block struct {
+	line  int // 第一行的行号，从 1 开始
+	lines []string
+}
+
+// splitBlocks 按空行切分文本块
+func splitBlocks(text string) []block {
+	var blocks []block
+	var cur *block
+	for i, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			cur = nil
+			continue
+		}
+		if cur == nil {
+			blocks = append(blocks, block{line: i + 1})
+			cur = &blocks[len(blocks)-1]
+		}
+		cur.lines = append(cur.lines, line)
+	}
+	return blocks
+}
+
+// parseVTTBlock 解析 WebVTT 的一个块，NOTE/STYLE/REGION 块返回 ok=false
+func parseVTTBlock(lines []string) (Cue, bool, error) {
+	first := lines[0]
+	if first == "NOTE" || strings.HasPrefix(first, "NOTE ") || strings.HasPrefix(first, "NOTE\t") ||
+		first == "STYLE" || first == "REGION" {
+		return Cue{}, false, nil
+	}
+	var cue Cue
+	if !strings.Contains(first, "-->") {
+		cue.ID = first
+		lines = lines[1:]
+		if len(lines) == 0 {
+			return Cue{}, false, errors.New("cue has no timing line")
+		}
+	}
+	if err := parseTiming(lines[0], &cue, true); err != nil {
+		return Cue{}, false, err
+	}
+	for _, line := range lines[1:] {
+		if strings.Contains(line, "-->") {
+			return Cue{}, false, errors.New("cue text must not contain -->")
+		}
+	}
+	cue.Text = strings.Join(lines[1:], "\n")
+	return cue, true, nil
+}
+
+// parseSRTBlock 解析 SRT 的一个块，序号行可省略
+func parseSRTBlock(lines []string) (Cue, bool, error) {
+	if _, err := strconv.Atoi(strings.TrimSpace(lines[0])); err == nil && len(lines) > 1 {
+		lines = lines[1:]
+	}
+	var cue Cue
+	// SRT 的时间行可能带 X1:..Y2: 坐标，WebVTT 中没有对应设置，直接丢弃
+	if err := parseTiming(lines[0], &cue, false); err != nil {
+		return Cue{}, false, err
+	}
+	text := make([]string, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		text = append(text, srtText(line))
+	}
+	cue.Text = strings.Join(text, "\n")
+	return cue, true, nil
+}
+
+// parseTiming 解析 "开始 --> 结束 [设置]" 时间行
+func parseTiming(line string, cue *Cue, keepSettings bool) error {
+	start, rest, ok := strings.Cut(line, "-->")
+	if !ok {
+		return errors.New("missing -->")
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return errors.New("missing end time")
+	}
+	var err error
+	if cue.Start, err = parseTimestamp(strings.TrimSpace(start)); err != nil {
+		return err
+	}
+	if cue.End, err = parseTimestamp(fields[0]); err != nil {
+		return err
+	}
+	if cue.End <= cue.Start {
+		return fmt.Errorf("end time %s is not after start time %s", formatTimestamp(cue.End), formatTimestamp(cue.Start))
+	}
+	if keepSettings {
+		cue.Settings = strings.Join(fields[1:], " ")
+	}
+	return nil
+}
+
+var timestampPattern = regexp.MustCompile(`^(?:(\d+):)?([0-5]\d):([0-5]\d)[.,](\d{3})$`)
+
+// parseTimestamp 解析 hh:mm:ss.ttt 或 mm:ss.ttt，毫秒分隔符接受 SRT 的逗号
+func parseTimestamp(s string) (time.Duration, error) {
+	m := timestampPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	var hours int64
+	if m[1] != "" {
+		hours, _ = strconv.ParseInt(m[1], 10, 64)
+	}
+	minutes, _ := strconv.ParseInt(m[2], 10, 64)
+	seconds, _ := strconv.ParseInt(m[3], 10, 64)
+	millis, _ := strconv.ParseInt(m[4], 10, 64)
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second + time.Duration(millis)*time.Millisecond, nil
+}
+
+func formatTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+var (
+	// SRT 中 WebVTT 支持的标签原样保留
+	srtKeepTag = regexp.MustCompile(`^</?[ibu]>$`)
+	srtTag     = regexp.MustCompile(`</?[A-Za-z][^<>]*>`)
+	// ASS 风格的 {\an8} 等覆盖标记
+	srtOverride = regexp.MustCompile(`\{\\[^{}]*\}`)
+)
+
+// srtText 把 SRT 文本转为 WebVTT 文本：保留 i/b/u 标签，去掉 font 等其他标签并转义 & 和 <
+func srtText(line string) string {
+	line = srtOverride.ReplaceAllString(line, "")
+	var b strings.Builder
+	last := 0
+	for _, loc := range srtTag.FindAllStringIndex(line, -1) {
+		b.WriteString(escapeText(line[last:loc[0]]))
+		if tag := strings.ToLower(line[loc[0]:loc[1]]); srtKeepTag.MatchString(tag) {
+			b.WriteString(tag)
+		}
+		last = loc[1]
+	}
+	b.WriteString(escapeText(line[last:]))
+	return b.String()
+}
+
+func escapeText(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+var vttTag = regexp.MustCompile(`<[^>]*>`)
+
+// PlainText 去掉标签并还原转义字符，多行合并为一行，用于全文检索
+func PlainText(cue Cue) string {
+	text := vttTag.ReplaceAllString(cue.Text, "")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// WriteVTT 写出 WebVTT 文件
+func WriteVTT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, "WEBVTT\n")
+	for _, cue := range cues {
+		fmt.Fprint(bw, "\n")
+		if cue.ID != "" {
+			fmt.Fprintln(bw, cue.ID)
+		}
+		fmt.Fprintf(bw, "%s --> %s", formatTimestamp(cue.Start), formatTimestamp(cue.End))
+		if cue.Settings != "" {
+			fmt.Fprint(bw, " "+cue.Settings)
+		}
+		fmt.Fprint(bw, "\n")
+		if cue.Text != "" {
+			fmt.Fprintln(bw, cue.Text)
+		}
+	}
+	return bw.Flush()
+}
+
+var languagePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// NormalizeLanguage 校验 BCP 47 语言标签并规范大小写，如 zh-hans-cn -> zh-Hans-CN
+func NormalizeLanguage(tag string) (string, bool) {
+	if !languagePattern.MatchString(tag) {
+		return "", false
+	}
+	parts := strings.Split(tag, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		switch len(parts[i]) {
+		case 2:
+			parts[i] = strings.ToUpper(parts[i])
+		case 4:
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:])
+		default:
+			parts[i] = strings.ToLower(parts[i])
+		}
+	}
+	return strings.Join(parts, "-"), true
+}
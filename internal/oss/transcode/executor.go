@@ -0,0 +1,143 @@
+package transcode
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Request 描述一次转码调用
+type Request struct {
+	Input     string    // 源文件路径
+	Output    string    // 输出文件路径
+	Rendition Rendition // 输出规格
+	Duration  float64   // 源视频时长（秒），用于计算进度，未知时为 0
+}
+
+// Executor 执行单个转码请求，progress 回调的取值范围为 [0, 1]
+type Executor interface {
+	Transcode(ctx context.Context, req Request, progress func(float64)) error
+}
+
+// FFmpegExecutor 调用 ffmpeg 命令行转码为 H.264/AAC MP4
+type FFmpegExecutor struct {
+	Path   string // ffmpeg 可执行文件路径
+	Preset string // x264 preset
+}
+
+func NewFFmpegExecutor(path string) *FFmpegExecutor {
+	return &FFmpegExecutor{Path: path, Preset: "veryfast"}
+}
+
+// Args 返回转码命令的参数列表
+func (e *FFmpegExecutor) Args(req Request) []string {
+	r := req.Rendition
+	return []string{
+		"-y", "-nostdin", "-nostats", "-loglevel", "error",
+		"-i", req.Input,
+		"-vf", fmt.Sprintf("scale=-2:%d", r.Height),
+		"-c:v", "libx264", "-preset", e.Preset, "-profile:v", "high",
+		// 固定间隔强制关键帧，保证各档位的 HLS 分片边界对齐
+		"-force_key_frames", "expr:gte(t,n_forced*2)", "-sc_threshold", "0",
+		"-b:v", fmt.Sprintf("%dk", r.VideoBitrate),
+		"-maxrate", fmt.Sprintf("%dk", r.VideoBitrate*3/2),
+		"-bufsize", fmt.Sprintf("%dk", r.VideoBitrate*2),
+		"-c:a", "aac", "-b:a", fmt.Sprintf("%dk", r.AudioBitrate),
+		"-movflags", "+faststart",
+		"-progress", "pipe:1",
+		req.Output,
+	}
+}
+
+func (e *FFmpegExecutor) Transcode(ctx context.Context, req Request, progress func(float64)) error {
+	cmd := exec.CommandContext(ctx, e.Path, e.Args(req)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// -progress 输出 key=value 行，out_time_us 为已处理的时长（微秒）
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || req.Duration <= 0 || (key != "out_time_us" && key != "out_time_ms") {
+			continue
+		}
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil && us > 0 {
+			progress(min(1, float64(us)/1e6/req.Duration))
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("ffmpeg: %w: %s", err, lastLine(msg))
+		}
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+	progress(1)
+	return nil
+}
+
+func lastLine(s string) string {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// FakeExecutor 是测试用的执行器，把输入原样复制到输出
+type FakeExecutor struct {
+	mu       sync.Mutex
+	FailNext int       // 接下来的 FailNext 次调用返回错误，用于测试重试
+	Calls    []Request // 已收到的请求
+}
+
+var ErrFakeFailure = errors.New("transcode: fake executor failure")
+
+func (f *FakeExecutor) Transcode(ctx context.Context, req Request, progress func(float64)) error {
+	f.mu.Lock()
+	f.Calls = append(f.Calls, req)
+	fail := f.FailNext > 0
+	if fail {
+		f.FailNext--
+	}
+	f.mu.Unlock()
+	if fail {
+		return ErrFakeFailure
+	}
+
+	in, err := os.Open(req.Input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(req.Output)
+	if err != nil {
+		return err
+	}
+	progress(0.5)
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	progress(1)
+	return nil
+}
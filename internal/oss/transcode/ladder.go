@@ -0,0 +1,40 @@
+package transcode
+
+// Rendition 是码率阶梯中的一档输出规格
+type Rendition struct {
+	Name         string `json:"name"`          // 档位名，同时作为衍生对象的 Variant，如 720p
+	Height       int    `json:"height"`        // 输出高度，宽度按原视频比例计算
+	VideoBitrate int    `json:"video_bitrate"` // 视频码率(kbps)
+	AudioBitrate int    `json:"audio_bitrate"` // 音频码率(kbps)
+}
+
+// DefaultLadder 是默认的 H.264/AAC 码率阶梯
+var DefaultLadder = []Rendition{
+	{Name: "360p", Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+	{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+	{Name: "1080p", Height: 1080, VideoBitrate: 5000, AudioBitrate: 128},
+}
+
+// Select 选出不高于源视频高度的档位，避免放大；源高度未知时返回全部档位
+func Select(ladder []Rendition, sourceHeight int) []Rendition {
+	if sourceHeight <= 0 {
+		return ladder
+	}
+	var out []Rendition
+	for _, r := range ladder {
+		if r.Height <= sourceHeight {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Find 按名称查找档位
+func Find(ladder []Rendition, name string) (Rendition, bool) {
+	for _, r := range ladder {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rendition{}, false
+}
@@ -0,0 +1,221 @@
+package transcode
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ormasia/swiftstream/internal/oss/cmaf"
+	"github.com/ormasia/swiftstream/internal/oss/dash"
+	"github.com/ormasia/swiftstream/internal/oss/hls"
+	"github.com/ormasia/swiftstream/internal/oss/keystore"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+	"gorm.io/gorm"
+)
+
+// 打包结果作为衍生对象时的规格名，分别指向 HLS 主播放列表和 DASH MPD
+const (
+	VariantHLS  = "hls"
+	VariantDASH = "dash"
+)
+
+// packageStreams 把源视频及其各档转码输出打包为 CMAF 分片，并生成 HLS 播放列表和 DASH MPD
+// 输出先写入临时目录，完成后整体替换旧目录，播放中的客户端不会读到半成品
+func (p *Pool) packageStreams(job *model.TranscodeJob) (*model.OssObject, error) {
+	source, err := repo.GetObject(p.db, job.ObjectID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			job.Attempts = job.MaxAttempts
+		}
+		return nil, err
+	}
+	if err := p.checkEncryption(source); err != nil {
+		job.Attempts = job.MaxAttempts
+		return nil, err
+	}
+	inputs, err := p.packageInputs(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(inputs) == 0 {
+		job.Attempts = job.MaxAttempts
+		return nil, cmaf.ErrNothingToPackage
+	}
+
+	dir := filepath.Join(p.cfg.PackageDir, strconv.FormatUint(uint64(source.ID), 10))
+	tmpDir := fmt.Sprintf("%s.tmp-%d", dir, time.Now().UnixNano())
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pres, err := cmaf.Package(tmpDir, inputs, p.cfg.Packaging)
+	if err != nil {
+		if errors.Is(err, cmaf.ErrNothingToPackage) {
+			job.Attempts = job.MaxAttempts
+		}
+		return nil, err
+	}
+
+	// 加密的视频只生成 HLS：DASH 播放器需要 CENC，整段 AES-128 加密的分片无法播放
+	var keys []keystore.Key
+	committed := false
+	if source.Encryption != "" {
+		var enc *hls.Encryption
+		enc, keys, err = p.encrypt(source, tmpDir, pres)
+		if keys != nil {
+			defer func() {
+				if !committed {
+					p.cfg.Keys.Delete(source.ID, keys)
+				}
+			}()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := hls.WritePlaylists(tmpDir, pres, enc); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := hls.WritePlaylists(tmpDir, pres, nil); err != nil {
+			return nil, err
+		}
+		if err := dash.WriteManifest(tmpDir, pres); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := storage.ReplaceDir(tmpDir, dir); err != nil {
+		return nil, err
+	}
+	committed = true
+	// 新分片已经替换旧分片，旧密钥不再需要
+	if p.cfg.Keys != nil {
+		if err := p.cfg.Keys.DeleteExcept(source.ID, keys); err != nil {
+			log.Printf("Failed to delete stale keys of object %d: %v\n", source.ID, err)
+		}
+	}
+
+	if source.Encryption != "" {
+		if err := repo.DeleteVariant(p.db, source.ID, VariantDASH); err != nil {
+			return nil, err
+		}
+	} else if _, err := p.registerManifest(source, VariantDASH, filepath.Join(dir, dash.Manifest), "application/dash+xml", pres); err != nil {
+		return nil, err
+	}
+	return p.registerManifest(source, VariantHLS, filepath.Join(dir, hls.MasterPlaylist), "application/vnd.apple.mpegurl", pres)
+}
+
+// checkEncryption 检查对象要求的加密方式是否可用，不可用时重试也无法成功
+func (p *Pool) checkEncryption(source *model.OssObject) error {
+	switch {
+	case source.Encryption == "":
+		return nil
+	case source.Encryption != model.EncryptionAES128:
+		return fmt.Errorf("unsupported encryption %q", source.Encryption)
+	case p.cfg.Keys == nil:
+		return errors.New("encryption requested but no master key is configured")
+	}
+	return nil
+}
+
+// encrypt 生成内容密钥并加密所有媒体分片，返回写入播放列表所需的密钥信息
+// 密钥一经生成即返回，调用方负责在打包失败时删除
+func (p *Pool) encrypt(source *model.OssObject, dir string, pres *cmaf.Presentation) (*hls.Encryption, []keystore.Key, error) {
+	segments := 0
+	for _, s := range pres.Streams {
+		segments = max(segments, len(s.Segments))
+	}
+	keys, err := p.cfg.Keys.Generate(source.ID, hls.KeyCount(segments, p.cfg.KeyRotation))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	enc := &hls.Encryption{Rotation: p.cfg.KeyRotation}
+	for _, key := range keys {
+		// 媒体播放列表位于 <流>/index.m3u8，密钥地址为 objects/:id/keys/:kid
+		enc.Keys = append(enc.Keys, hls.Key{URI: "../../keys/" + key.ID, Value: key.Value})
+	}
+	if err := hls.EncryptSegments(dir, pres, enc); err != nil {
+		return nil, keys, err
+	}
+	return enc, keys, nil
+}
+
+// packageInputs 选出可打包的输入：H.264/AAC 的 MP4 源文件和已完成的各档转码输出
+// 源文件的 AAC 音轨优先作为音频，否则使用第一个转码输出的音轨
+func (p *Pool) packageInputs(source *model.OssObject) ([]cmaf.Input, error) {
+	var inputs []cmaf.Input
+	isMP4 := source.MimeType == "video/mp4" || source.MimeType == "video/quicktime"
+	if isMP4 && (source.VideoCodec == "avc1" || source.VideoCodec == "avc3") {
+		inputs = append(inputs, cmaf.Input{Name: "source", Path: source.FilePath(), Audio: source.AudioCodec == "mp4a"})
+	}
+
+	variants, err := repo.ListVariants(p.db, source.ID)
+	if err != nil {
+		return nil, err
+	}
+	var renditions []model.OssObject
+	for _, v := range variants {
+		if _, ok := Find(p.cfg.Ladder, v.Variant); ok && v.Status == "active" {
+			renditions = append(renditions, v)
+		}
+	}
+	sort.Slice(renditions, func(i, j int) bool {
+		return renditions[i].Height < renditions[j].Height
+	})
+	hasAudio := len(inputs) > 0 && inputs[0].Audio
+	for _, r := range renditions {
+		inputs = append(inputs, cmaf.Input{Name: r.Variant, Path: r.FilePath(), Audio: !hasAudio})
+		hasAudio = true
+	}
+	return inputs, nil
+}
+
+// registerManifest 把清单文件注册为源对象的衍生对象，重新打包时更新已有记录
+func (p *Pool) registerManifest(source *model.OssObject, variant, manifestPath, mimeType string, pres *cmaf.Presentation) (*model.OssObject, error) {
+	etag, size, err := storage.Checksum(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(manifestPath)
+	output, err := repo.GetVariant(p.db, source.ID, variant)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		parentID := source.ID
+		output = &model.OssObject{
+			ParentID:  &parentID,
+			Variant:   variant,
+			Bucket:    source.Bucket,
+			ObjectKey: fmt.Sprintf("%s@%s/%s", source.ObjectKey, variant, name),
+			VersionID: source.VersionID,
+			URL:       fmt.Sprintf("/api/oss/objects/%d/%s/%s", source.ID, variant, name),
+		}
+	}
+	output.FileName = name
+	output.FileSize = size
+	output.FileType = source.FileType
+	output.MimeType = mimeType
+	output.ETag = etag
+	output.StoragePath = manifestPath
+	output.Width, output.Height = source.Width, source.Height
+	output.Duration = int(math.Round(pres.Duration))
+	output.UserID = source.UserID
+	output.BusinessID = source.BusinessID
+	output.Status = "active"
+	if err := repo.SaveObject(p.db, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
@@ -0,0 +1,142 @@
+package transcode
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ormasia/swiftstream/internal/oss/cmaf"
+	"github.com/ormasia/swiftstream/internal/oss/dash"
+	"github.com/ormasia/swiftstream/internal/oss/hls"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+)
+
+func TestPackageStreams(t *testing.T) {
+	db := openTestDB(t)
+	source := createVideo(t, db, 240)
+	cfg := testConfig(t)
+	pool := NewPool(db, cfg, &FakeExecutor{})
+
+	if _, err := pool.Enqueue(source); err != nil {
+		t.Fatal(err)
+	}
+	runPool(t, pool, jobsFinished(t, db, source.ID))
+
+	dir := filepath.Join(cfg.PackageDir, strconv.FormatUint(uint64(source.ID), 10))
+	pres, err := cmaf.ReadPresentation(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 源文件与 240p 输出各一条视频流，音轨取自源文件
+	var ids []string
+	for _, s := range pres.Streams {
+		ids = append(ids, s.ID)
+		if len(s.Segments) == 0 {
+			t.Errorf("stream %s has no segments", s.ID)
+		}
+		for n := range s.Segments {
+			if _, err := os.Stat(filepath.Join(dir, s.ID, s.SegmentName(n+1))); err != nil {
+				t.Errorf("stream %s: %v", s.ID, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, s.ID, hls.MediaPlaylist)); err != nil {
+			t.Errorf("stream %s: %v", s.ID, err)
+		}
+	}
+	if got := strings.Join(ids, ","); got != "video_source,video_240p,audio" {
+		t.Errorf("streams = %s", got)
+	}
+
+	master, err := os.ReadFile(filepath.Join(dir, hls.MasterPlaylist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(master), "#EXT-X-STREAM-INF:"); n != 2 {
+		t.Errorf("master playlist has %d variant streams, want 2:\n%s", n, master)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, dash.Manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mpd dash.MPD
+	if err := xml.Unmarshal(data, &mpd); err != nil {
+		t.Fatal(err)
+	}
+	if err := dash.Validate(&mpd); err != nil {
+		t.Errorf("generated MPD is invalid: %v", err)
+	}
+
+	for variant, name := range map[string]string{VariantHLS: hls.MasterPlaylist, VariantDASH: dash.Manifest} {
+		output, err := repo.GetVariant(db, source.ID, variant)
+		if err != nil {
+			t.Fatalf("variant %s: %v", variant, err)
+		}
+		if output.StoragePath != filepath.Join(dir, name) || output.ETag == "" || output.Duration != 4 {
+			t.Errorf("variant %s: %+v", variant, output)
+		}
+	}
+}
+
+func TestRepackageReplacesOutput(t *testing.T) {
+	db := openTestDB(t)
+	source := createVideo(t, db, 0)
+	cfg := testConfig(t)
+	cfg.Ladder = nil
+	pool := NewPool(db, cfg, nil)
+
+	job := &model.TranscodeJob{ObjectID: source.ID, Kind: JobHLS}
+	if _, err := pool.packageStreams(job); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(cfg.PackageDir, strconv.FormatUint(uint64(source.ID), 10))
+	stale := filepath.Join(dir, "stale.m4s")
+	if err := os.WriteFile(stale, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := pool.packageStreams(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("repackaging kept a file from the previous output: %v", err)
+	}
+	if _, err := os.Stat(output.StoragePath); err != nil {
+		t.Errorf("master playlist: %v", err)
+	}
+	// 替换后不留下临时目录与旧目录
+	entries, err := os.ReadDir(cfg.PackageDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("package dir contains %v, want only %s", names, filepath.Base(dir))
+	}
+}
+
+func TestPackageWithoutInputs(t *testing.T) {
+	db := openTestDB(t)
+	source := createVideo(t, db, 0)
+	source.VideoCodec = "hvc1"
+	if err := repo.SaveObject(db, source); err != nil {
+		t.Fatal(err)
+	}
+	pool := NewPool(db, testConfig(t), nil)
+
+	job := &model.TranscodeJob{ObjectID: source.ID, Kind: JobHLS, MaxAttempts: 3}
+	if _, err := pool.packageStreams(job); err != cmaf.ErrNothingToPackage {
+		t.Fatalf("packageStreams() error = %v, want %v", err, cmaf.ErrNothingToPackage)
+	}
+	// 没有可打包的输入时重试也无法成功
+	if job.Attempts != job.MaxAttempts {
+		t.Errorf("attempts = %d, want %d", job.Attempts, job.MaxAttempts)
+	}
+}
@@ -0,0 +1,324 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ormasia/swiftstream/internal/oss/cmaf"
+	"github.com/ormasia/swiftstream/internal/oss/keystore"
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/probe"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+	"gorm.io/gorm"
+)
+
+// 任务类型
+const (
+	JobTranscode = "transcode" // 按码率阶梯转码
+	JobHLS       = "hls"       // 打包为 CMAF 分片，生成 HLS 播放列表与 DASH MPD
+)
+
+// Config 转码任务池配置
+type Config struct {
+	Workers      int                // 并发 worker 数
+	MaxAttempts  int                // 每个任务的最大执行次数
+	RetryDelay   time.Duration      // 第 n 次失败后等待 n*RetryDelay 再重试
+	PollInterval time.Duration      // 轮询到期重试任务的间隔
+	JobTimeout   time.Duration      // 单个任务的超时时间
+	OutputDir    string             // 转码输出目录
+	PackageDir   string             // HLS 打包输出目录
+	Ladder       []Rendition        // 码率阶梯
+	Packaging    cmaf.Options       // 分片参数
+	Keys         *keystore.Keystore // HLS 内容密钥存储，为 nil 时不支持加密
+	KeyRotation  int                // 加密时每多少个分片轮换一次密钥，0 表示每个视频一个密钥
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		Workers:      2,
+		MaxAttempts:  3,
+		RetryDelay:   30 * time.Second,
+		PollInterval: 30 * time.Second,
+		JobTimeout:   2 * time.Hour,
+		OutputDir:    filepath.Join("data", "files", "renditions"),
+		PackageDir:   filepath.Join("data", "files", "hls"),
+		Ladder:       DefaultLadder,
+		Packaging:    cmaf.DefaultOptions(),
+	}
+}
+
+// Pool 从数据库领取转码任务并交给 Executor 执行
+// 任务持久化在 transcode_jobs 表中，服务重启后未完成的任务会继续执行
+// exec 为 nil 时只执行打包任务，Ladder 应同时置空
+type Pool struct {
+	db     *gorm.DB
+	cfg    Config
+	exec   Executor
+	notify chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewPool(db *gorm.DB, cfg Config, exec Executor) *Pool {
+	return &Pool{
+		db:     db,
+		cfg:    cfg,
+		exec:   exec,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// Start 启动 worker，ctx 取消后 worker 在当前任务结束后退出
+func (p *Pool) Start(ctx context.Context) error {
+	if err := repo.ResetRunningTranscodeJobs(p.db); err != nil {
+		return err
+	}
+	for range max(1, p.cfg.Workers) {
+		p.wg.Add(1)
+		go p.worker(ctx)
+	}
+	p.wake()
+	return nil
+}
+
+// Wait 等待所有 worker 退出
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+// Enqueue 为视频对象按码率阶梯创建转码任务；没有可用档位时直接创建打包任务
+func (p *Pool) Enqueue(source *model.OssObject) ([]model.TranscodeJob, error) {
+	if source.FileType != probe.KindVideo {
+		return nil, nil
+	}
+	renditions := Select(p.cfg.Ladder, source.Height)
+	if len(renditions) == 0 {
+		job, err := p.enqueuePackage(source.ID)
+		if err != nil || job == nil {
+			return nil, err
+		}
+		return []model.TranscodeJob{*job}, nil
+	}
+
+	jobs := make([]model.TranscodeJob, 0, len(renditions))
+	now := time.Now()
+	for _, r := range renditions {
+		jobs = append(jobs, p.newJob(source.ID, JobTranscode, r.Name, now))
+	}
+	if err := repo.CreateTranscodeJobs(p.db, jobs); err != nil {
+		return nil, err
+	}
+	p.wake()
+	return jobs, nil
+}
+
+// enqueuePackage 创建打包任务；任务已存在时返回 nil
+func (p *Pool) enqueuePackage(objectID uint) (*model.TranscodeJob, error) {
+	job := p.newJob(objectID, JobHLS, "", time.Now())
+	created, err := repo.CreateTranscodeJobIfAbsent(p.db, &job)
+	if err != nil || !created {
+		return nil, err
+	}
+	p.wake()
+	return &job, nil
+}
+
+func (p *Pool) newJob(objectID uint, kind, rendition string, now time.Time) model.TranscodeJob {
+	return model.TranscodeJob{
+		ObjectID:    objectID,
+		Kind:        kind,
+		Rendition:   rendition,
+		Status:      "pending",
+		MaxAttempts: max(1, p.cfg.MaxAttempts),
+		NextRunAt:   now,
+	}
+}
+
+func (p *Pool) wake() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Pool) worker(ctx context.Context) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// 连续处理所有到期任务，队列为空后再等待通知
+		for ctx.Err() == nil {
+			job, err := repo.ClaimTranscodeJob(p.db, time.Now())
+			if err != nil {
+				log.Printf("Failed to claim transcode job: %v\n", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			// 还有剩余任务时唤醒其他空闲 worker
+			p.wake()
+			p.run(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) run(ctx context.Context, job *model.TranscodeJob) {
+	var output *model.OssObject
+	var err error
+	switch job.Kind {
+	case JobHLS:
+		output, err = p.packageStreams(job)
+	default:
+		output, err = p.transcode(ctx, job)
+	}
+	if err == nil {
+		job.Status = "completed"
+		job.Progress = 100
+		job.Error = ""
+		job.OutputObjectID = &output.ID
+	} else if job.Attempts < job.MaxAttempts && ctx.Err() == nil {
+		log.Printf("Transcode job %d (%s %s) failed, will retry: %v\n", job.ID, job.Kind, job.Rendition, err)
+		job.Status = "pending"
+		job.Error = err.Error()
+		job.NextRunAt = time.Now().Add(time.Duration(job.Attempts) * p.cfg.RetryDelay)
+	} else if ctx.Err() != nil {
+		// 服务退出导致的中断不计入重试次数
+		job.Status = "pending"
+		job.Attempts--
+	} else {
+		log.Printf("Transcode job %d (%s %s) failed: %v\n", job.ID, job.Kind, job.Rendition, err)
+		job.Status = "failed"
+		job.Error = err.Error()
+	}
+	if err := repo.SaveTranscodeJob(p.db, job); err != nil {
+		log.Printf("Failed to save transcode job %d: %v\n", job.ID, err)
+		return
+	}
+
+	// 所有转码任务结束后，把源视频和各档输出一起打包
+	if job.Kind == JobTranscode && job.Status != "pending" {
+		remaining, err := repo.CountUnfinishedTranscodeJobs(p.db, job.ObjectID, JobTranscode)
+		if err != nil {
+			log.Printf("Failed to count transcode jobs of object %d: %v\n", job.ObjectID, err)
+			return
+		}
+		if remaining == 0 {
+			if _, err := p.enqueuePackage(job.ObjectID); err != nil {
+				log.Printf("Failed to enqueue packaging of object %d: %v\n", job.ObjectID, err)
+			}
+		}
+	}
+}
+
+// transcode 执行转码并把输出注册为源对象的衍生对象
+func (p *Pool) transcode(ctx context.Context, job *model.TranscodeJob) (*model.OssObject, error) {
+	rendition, ok := Find(p.cfg.Ladder, job.Rendition)
+	if !ok {
+		// 配置中已删除该档位，重试也无法成功
+		job.Attempts = job.MaxAttempts
+		return nil, fmt.Errorf("unknown rendition %q", job.Rendition)
+	}
+	source, err := repo.GetObject(p.db, job.ObjectID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			job.Attempts = job.MaxAttempts
+		}
+		return nil, err
+	}
+
+	outputPath := filepath.Join(p.cfg.OutputDir, strconv.FormatUint(uint64(source.ID), 10), rendition.Name+".mp4")
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.JobTimeout)
+	defer cancel()
+	lastProgress := -1
+	req := Request{
+		Input:     source.FilePath(),
+		Output:    outputPath,
+		Rendition: rendition,
+		Duration:  float64(source.Duration),
+	}
+	err = p.exec.Transcode(ctx, req, func(f float64) {
+		// 进度只在百分比变化时写库；100% 在任务完成时写入
+		percent := int(math.Floor(f * 100))
+		if percent > lastProgress && percent < 100 {
+			lastProgress = percent
+			if err := repo.UpdateTranscodeProgress(p.db, job.ID, percent); err != nil {
+				log.Printf("Failed to update transcode progress of job %d: %v\n", job.ID, err)
+			}
+		}
+	})
+	if err != nil {
+		os.Remove(outputPath)
+		return nil, err
+	}
+	return p.register(source, rendition, outputPath)
+}
+
+// register 把转码输出保存为源对象的衍生对象，重试时覆盖已有记录
+func (p *Pool) register(source *model.OssObject, rendition Rendition, outputPath string) (*model.OssObject, error) {
+	etag, size, err := storage.Checksum(outputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := repo.GetVariant(p.db, source.ID, rendition.Name)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		parentID := source.ID
+		output = &model.OssObject{
+			ParentID:  &parentID,
+			Variant:   rendition.Name,
+			Bucket:    source.Bucket,
+			ObjectKey: fmt.Sprintf("%s@%s.mp4", source.ObjectKey, rendition.Name),
+			VersionID: source.VersionID,
+			URL:       fmt.Sprintf("/api/oss/objects/%d/download?variant=%s", source.ID, rendition.Name),
+		}
+	}
+	output.FileName = fmt.Sprintf("%s_%s.mp4", trimExt(source.FileName), rendition.Name)
+	output.FileSize = size
+	output.FileType = probe.KindVideo
+	output.MimeType = "video/mp4"
+	output.ETag = etag
+	output.StoragePath = outputPath
+	output.UserID = source.UserID
+	output.BusinessID = source.BusinessID
+	output.Status = "active"
+
+	if info, err := probe.File(outputPath); err == nil {
+		output.Width, output.Height = info.Width, info.Height
+		output.Duration = int(math.Round(info.Duration))
+		output.VideoCodec, output.AudioCodec = info.VideoCodec, info.AudioCodec
+		output.Bitrate, output.FrameRate = info.Bitrate, info.FrameRate
+	}
+	if err := repo.SaveObject(p.db, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func trimExt(name string) string {
+	return name[:len(name)-len(filepath.Ext(name))]
+}
@@ -0,0 +1,259 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/probe"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const sampleVideo = "testdata/sample.mp4"
+
+var testLadder = []Rendition{
+	{Name: "240p", Height: 240, VideoBitrate: 400, AudioBitrate: 64},
+	{Name: "360p", Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+	{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 单连接避免 worker 并发写入时 SQLite 返回 database is locked
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := repo.CreateTable(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func createVideo(t *testing.T, db *gorm.DB, height int) *model.OssObject {
+	t.Helper()
+	object := &model.OssObject{
+		FileName:    "sample.mp4",
+		FileType:    probe.KindVideo,
+		MimeType:    "video/mp4",
+		Bucket:      model.DefaultBucket,
+		ObjectKey:   "videos/sample.mp4",
+		StoragePath: sampleVideo,
+		Width:       height * 16 / 9,
+		Height:      height,
+		Duration:    4,
+		VideoCodec:  "avc1",
+		AudioCodec:  "mp4a",
+		Status:      model.StatusActive,
+	}
+	if err := repo.CreateObject(db, object); err != nil {
+		t.Fatal(err)
+	}
+	return object
+}
+
+func testConfig(t *testing.T) Config {
+	cfg := DefaultConfig()
+	dir := t.TempDir()
+	cfg.Workers = 2
+	cfg.RetryDelay = 0
+	cfg.PollInterval = 10 * time.Millisecond
+	cfg.JobTimeout = time.Minute
+	cfg.OutputDir = filepath.Join(dir, "renditions")
+	cfg.PackageDir = filepath.Join(dir, "hls")
+	cfg.Ladder = testLadder
+	return cfg
+}
+
+// runPool 启动任务池，直到 done 返回 true 或超时
+func runPool(t *testing.T, pool *Pool, done func() bool) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := pool.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cancel()
+		pool.Wait()
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for transcode jobs")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// jobsFinished 返回对象的任务是否全部结束，包括所有转码任务结束后创建的打包任务
+func jobsFinished(t *testing.T, db *gorm.DB, objectID uint) func() bool {
+	return func() bool {
+		jobs, err := repo.ListTranscodeJobs(db, objectID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packaged := false
+		for _, job := range jobs {
+			if job.Status == "pending" || job.Status == "running" {
+				return false
+			}
+			packaged = packaged || job.Kind == JobHLS
+		}
+		return packaged
+	}
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		height int
+		want   []string
+	}{
+		{0, []string{"240p", "360p", "720p"}},
+		{200, nil},
+		{360, []string{"240p", "360p"}},
+		{1080, []string{"240p", "360p", "720p"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range Select(testLadder, tt.height) {
+			got = append(got, r.Name)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("Select(%d) = %v, want %v", tt.height, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Select(%d) = %v, want %v", tt.height, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestEnqueueSkipsNonVideo(t *testing.T) {
+	db := openTestDB(t)
+	pool := NewPool(db, testConfig(t), &FakeExecutor{})
+	jobs, err := pool.Enqueue(&model.OssObject{ID: 1, FileType: probe.KindImage, Height: 720})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 0 {
+		t.Fatalf("Enqueue created %d jobs for an image", len(jobs))
+	}
+}
+
+func TestPoolTranscodesLadder(t *testing.T) {
+	db := openTestDB(t)
+	source := createVideo(t, db, 360)
+	exec := &FakeExecutor{}
+	pool := NewPool(db, testConfig(t), exec)
+
+	jobs, err := pool.Enqueue(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("Enqueue created %d jobs, want 2 (no upscaling to 720p)", len(jobs))
+	}
+	runPool(t, pool, jobsFinished(t, db, source.ID))
+
+	if len(exec.Calls) != 2 {
+		t.Fatalf("executor called %d times, want 2", len(exec.Calls))
+	}
+	for _, call := range exec.Calls {
+		if call.Input != sampleVideo || call.Duration != 4 {
+			t.Errorf("unexpected request %+v", call)
+		}
+	}
+	jobList, err := repo.ListTranscodeJobs(db, source.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range jobList {
+		if job.Status != "completed" || job.Progress != 100 || job.OutputObjectID == nil {
+			t.Errorf("job %s %s: status %s progress %d output %v", job.Kind, job.Rendition, job.Status, job.Progress, job.OutputObjectID)
+		}
+	}
+	for _, name := range []string{"240p", "360p"} {
+		variant, err := repo.GetVariant(db, source.ID, name)
+		if err != nil {
+			t.Fatalf("variant %s: %v", name, err)
+		}
+		if variant.FileName != "sample_"+name+".mp4" || variant.MimeType != "video/mp4" || variant.ETag == "" || variant.FileSize == 0 {
+			t.Errorf("variant %s: %+v", name, variant)
+		}
+	}
+}
+
+func TestPoolRetriesFailedJob(t *testing.T) {
+	db := openTestDB(t)
+	source := createVideo(t, db, 240)
+	exec := &FakeExecutor{FailNext: 1}
+	pool := NewPool(db, testConfig(t), exec)
+
+	if _, err := pool.Enqueue(source); err != nil {
+		t.Fatal(err)
+	}
+	runPool(t, pool, jobsFinished(t, db, source.ID))
+
+	jobs, err := repo.ListTranscodeJobs(db, source.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range jobs {
+		if job.Kind != JobTranscode {
+			continue
+		}
+		if job.Status != "completed" || job.Attempts != 2 {
+			t.Errorf("job %s: status %s after %d attempts, want completed after 2", job.Rendition, job.Status, job.Attempts)
+		}
+	}
+	if len(exec.Calls) != 2 {
+		t.Errorf("executor called %d times, want 2", len(exec.Calls))
+	}
+}
+
+func TestPoolGivesUpAfterMaxAttempts(t *testing.T) {
+	db := openTestDB(t)
+	source := createVideo(t, db, 240)
+	exec := &FakeExecutor{FailNext: 10}
+	cfg := testConfig(t)
+	cfg.MaxAttempts = 2
+	pool := NewPool(db, cfg, exec)
+
+	if _, err := pool.Enqueue(source); err != nil {
+		t.Fatal(err)
+	}
+	runPool(t, pool, jobsFinished(t, db, source.ID))
+
+	jobs, err := repo.ListTranscodeJobs(db, source.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range jobs {
+		if job.Kind != JobTranscode {
+			continue
+		}
+		if job.Status != "failed" || job.Attempts != 2 || job.Error != ErrFakeFailure.Error() {
+			t.Errorf("job %s: status %s attempts %d error %q", job.Rendition, job.Status, job.Attempts, job.Error)
+		}
+	}
+	if _, err := repo.GetVariant(db, source.ID, "240p"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("failed job registered a variant: %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"github.com/ormasia/swiftstream/internal/oss/storage"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotDeletable = errors.New("trash: only active objects can be deleted")
+	ErrNotInTrash   = errors.New("trash: object is not in trash")
+	ErrExpired      = errors.New("trash: retention period has expired")
+	ErrLocked       = errors.New("trash: object is under legal hold or retention")
+)
+
+// Config 回收站配置
+type Config struct {
+	Retention     time.Duration // 删除后可恢复的时长，超过后由清理任务物理删除
+	PurgeInterval time.Duration // 检查过期对象的间隔
+	BatchSize     int           // 每轮最多清理的对象数
+	// 冷存储，清理已转移到冷存储的对象时删除其中的文件
+	Cold storage.Driver
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		Retention:     30 * 24 * time.Hour,
+		PurgeInterval: time.Hour,
+		BatchSize:     100,
+	}
+}
+
+// Service 管理对象的软删除、恢复与过期清理
+// 软删除只修改记录状态，文件保留到保留期结束；清理时只删除没有其他对象引用的文件
+type Service struct {
+	db  *gorm.DB
+	cfg Config
+}
+
+func NewService(db *gorm.DB, cfg Config) *Service {
+	return &Service{db: db, cfg: cfg}
+}
+
+// Retention 返回回收站的保留时长
+func (s *Service) Retention() time.Duration {
+	return s.cfg.Retention
+}
+
+// PurgeAt 返回已删除对象被物理删除的时间
+func (s *Service) PurgeAt(object *model.OssObject) time.Time {
+	return object.DeletedAt.Time.Add(s.cfg.Retention)
+}
+
+// Start 启动过期对象的清理任务，启动时先清理一轮
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.cfg.PurgeInterval)
+		defer ticker.Stop()
+		for {
+			s.purgeExpired()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Delete 软删除源对象及其衍生对象，对象进入回收站
+func (s *Service) Delete(object *model.OssObject) error {
+	if object.ParentID != nil || object.DeleteMarker || object.Status != model.StatusActive {
+		return ErrNotDeletable
+	}
+	if object.Locked(time.Now()) {
+		return ErrLocked
+	}
+	ok, err := repo.SoftDeleteObject(s.db, object.ID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotDeletable
+	}
+	log.Printf("Object %d moved to trash\n", object.ID)
+	// 删除的是对象键的当前版本时，剩余最新的版本成为当前版本
+	if object.VersionID != "" && object.NoncurrentAt == nil {
+		if err := repo.PromoteNewestVersion(s.db, object.Bucket, object.ObjectKey); err != nil {
+			log.Printf("Failed to promote newest version of %s/%s: %v\n", object.Bucket, object.ObjectKey, err)
+		}
+	}
+	return nil
+}
+
+// Restore 在保留期内恢复回收站中的对象，衍生对象随之恢复
+func (s *Service) Restore(object *model.OssObject) error {
+	if object.ParentID != nil || object.Status != model.StatusDeleted {
+		return ErrNotInTrash
+	}
+	if !time.Now().Before(s.PurgeAt(object)) {
+		return ErrExpired
+	}
+	ok, err := repo.RestoreObject(s.db, object.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotInTrash
+	}
+	log.Printf("Object %d restored from trash\n", object.ID)
+	// 恢复的版本不取代对象键已有的当前版本
+	if object.VersionID != "" {
+		if err := repo.DemoteIfSuperseded(s.db, object, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeExpired 物理删除超过保留期的对象
+func (s *Service) purgeExpired() {
+	objects, err := repo.ListExpiredObjects(s.db, time.Now().Add(-s.cfg.Retention), s.cfg.BatchSize)
+	if err != nil {
+		log.Printf("Failed to list expired objects: %v\n", err)
+		return
+	}
+	for _, object := range objects {
+		if err := s.Purge(object.ID); err != nil {
+			log.Printf("Failed to purge object %d: %v\n", object.ID, err)
+		}
+	}
+}
+
+// Purge 物理删除回收站中的对象：删除没有其他对象引用的文件与该对象独占的目录，再删除数据库记录
+func (s *Service) Purge(objectID uint) error {
+	family, err := repo.ListObjectFamily(s.db, objectID)
+	if err != nil {
+		return err
+	}
+	if len(family) == 0 || family[0].Status != model.StatusDeleted {
+		return ErrNotInTrash
+	}
+	if family[0].Locked(time.Now()) {
+		return ErrLocked
+	}
+	ids := make([]uint, 0, len(family))
+	for _, o := range family {
+		ids = append(ids, o.ID)
+	}
+
+	// 同名上传共用 data/files/<FileName>，视频封面与图片共用文件，删除前确认没有其他记录引用
+	dirs := make(map[string]bool)
+	for _, o := range family {
+		// 删除标记没有文件
+		if o.DeleteMarker {
+			continue
+		}
+		if o.StorageClass == model.StorageClassCold {
+			if s.cfg.Cold == nil {
+				return fmt.Errorf("trash: object %d is in cold storage but cold storage is not configured", o.ID)
+			}
+			if err := s.cfg.Cold.Delete(context.Background(), o.ColdKey()); err != nil {
+				return err
+			}
+		}
+		path := o.FilePath()
+		n, err := repo.CountStorageReferences(s.db, path, ids)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// HLS 分片、衍生图、波形与转码输出位于以对象 ID 命名的目录中，指定对象键的上传位于以版本 ID 命名的目录中
+		dir := filepath.Dir(path)
+		if base := filepath.Base(dir); base == strconv.FormatUint(uint64(objectID), 10) || (o.VersionID != "" && base == o.VersionID) {
+			dirs[dir] = true
+		}
+	}
+	tracks, err := repo.ListSubtitleTracks(s.db, objectID)
+	if err != nil {
+		return err
+	}
+	for _, track := range tracks {
+		if err := os.Remove(track.StoragePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		dirs[filepath.Dir(track.StoragePath)] = true
+	}
+	for dir := range dirs {
+		n, err := repo.CountStorageReferencesUnder(s.db, dir, ids)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+
+	if err := repo.PurgeObjects(s.db, objectID, ids); err != nil {
+		return err
+	}
+	log.Printf("Object %d purged\n", objectID)
+	return nil
+}
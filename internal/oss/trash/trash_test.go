@@ -0,0 +1,202 @@
+package trash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ormasia/swiftstream/internal/oss/model"
+	"github.com/ormasia/swiftstream/internal/oss/repo"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := repo.CreateTable(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// createObject 创建对象并写入其文件，StoragePath 为空时在临时目录中创建文件
+func createObject(t *testing.T, db *gorm.DB, object model.OssObject) *model.OssObject {
+	t.Helper()
+	if object.StoragePath == "" {
+		object.StoragePath = filepath.Join(t.TempDir(), object.FileName)
+	}
+	if err := os.WriteFile(object.StoragePath, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if object.Status == "" {
+		object.Status = model.StatusActive
+	}
+	if object.Bucket == "" {
+		object.Bucket = model.DefaultBucket
+	}
+	if object.ObjectKey == "" {
+		object.ObjectKey = "uploads" + object.StoragePath
+	}
+	if err := repo.CreateObject(db, &object); err != nil {
+		t.Fatal(err)
+	}
+	return &object
+}
+
+func getObject(t *testing.T, db *gorm.DB, id uint) (*model.OssObject, bool) {
+	t.Helper()
+	var object model.OssObject
+	err := db.Unscoped().First(&object, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &object, true
+}
+
+func TestDeleteAndRestore(t *testing.T) {
+	db := openTestDB(t)
+	s := NewService(db, DefaultConfig())
+	source := createObject(t, db, model.OssObject{FileName: "a.jpg", UserID: 1})
+	child := createObject(t, db, model.OssObject{FileName: "a_thumb.jpg", ParentID: &source.ID, Variant: "thumb"})
+
+	if err := s.Delete(source); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint{source.ID, child.ID} {
+		if o, _ := getObject(t, db, id); o.Status != model.StatusDeleted || !o.DeletedAt.Valid {
+			t.Errorf("object %d after delete: status %s, deleted_at %v", id, o.Status, o.DeletedAt)
+		}
+	}
+
+	deleted, _ := getObject(t, db, source.ID)
+	if err := s.Restore(deleted); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint{source.ID, child.ID} {
+		if o, _ := getObject(t, db, id); o.Status != model.StatusActive || o.DeletedAt.Valid {
+			t.Errorf("object %d after restore: status %s, deleted_at %v", id, o.Status, o.DeletedAt)
+		}
+	}
+}
+
+func TestDeleteRejected(t *testing.T) {
+	db := openTestDB(t)
+	s := NewService(db, DefaultConfig())
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	parent := uint(1)
+	tests := []struct {
+		name   string
+		object model.OssObject
+		want   error
+	}{
+		{"variant", model.OssObject{FileName: "v.jpg", ParentID: &parent}, ErrNotDeletable},
+		{"delete marker", model.OssObject{FileName: "m", DeleteMarker: true}, ErrNotDeletable},
+		{"pending review", model.OssObject{FileName: "p.jpg", Status: model.StatusPendingReview}, ErrNotDeletable},
+		{"legal hold", model.OssObject{FileName: "h.jpg", LegalHold: true}, ErrLocked},
+		{"retention", model.OssObject{FileName: "r.jpg", RetainUntil: &future}, ErrLocked},
+		{"retention expired", model.OssObject{FileName: "e.jpg", RetainUntil: &past}, nil},
+	}
+	for _, tt := range tests {
+		object := createObject(t, db, tt.object)
+		if err := s.Delete(object); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Delete() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRestoreAfterRetention(t *testing.T) {
+	db := openTestDB(t)
+	cfg := DefaultConfig()
+	cfg.Retention = time.Hour
+	s := NewService(db, cfg)
+	object := createObject(t, db, model.OssObject{FileName: "a.jpg"})
+	if _, err := repo.SoftDeleteObject(db, object.ID, time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	deleted, _ := getObject(t, db, object.ID)
+	if err := s.Restore(deleted); !errors.Is(err, ErrExpired) {
+		t.Errorf("Restore() error = %v, want ErrExpired", err)
+	}
+	active := createObject(t, db, model.OssObject{FileName: "b.jpg"})
+	if err := s.Restore(active); !errors.Is(err, ErrNotInTrash) {
+		t.Errorf("Restore() of an active object: error = %v, want ErrNotInTrash", err)
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	db := openTestDB(t)
+	cfg := DefaultConfig()
+	cfg.Retention = time.Hour
+	s := NewService(db, cfg)
+
+	expired := createObject(t, db, model.OssObject{FileName: "expired.jpg"})
+	dir := filepath.Join(t.TempDir(), "variants", "1")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	variant := createObject(t, db, model.OssObject{FileName: "thumb.jpg", StoragePath: filepath.Join(dir, "thumb.jpg"), ParentID: &expired.ID, Variant: "thumb"})
+	// 与其他对象共用文件的对象只删除记录
+	shared := createObject(t, db, model.OssObject{FileName: "shared.jpg"})
+	copied := createObject(t, db, model.OssObject{FileName: "shared.jpg", ObjectKey: "copies/shared.jpg", StoragePath: shared.StoragePath})
+	recent := createObject(t, db, model.OssObject{FileName: "recent.jpg"})
+	held := createObject(t, db, model.OssObject{FileName: "held.jpg"})
+
+	for _, object := range []*model.OssObject{expired, shared, held} {
+		if _, err := repo.SoftDeleteObject(db, object.ID, time.Now().Add(-2*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.SoftDeleteObject(db, recent.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// 删除后施加的法律保留同样阻止清理
+	if err := db.Unscoped().Model(&model.OssObject{}).Where("id = ?", held.ID).Update("legal_hold", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	s.purgeExpired()
+
+	tests := []struct {
+		name     string
+		object   *model.OssObject
+		exists   bool
+		keepFile bool
+	}{
+		{"expired", expired, false, false},
+		{"expired variant", variant, false, false},
+		{"expired with shared file", shared, false, true},
+		{"copy sharing the file", copied, true, true},
+		{"within retention", recent, true, true},
+		{"legal hold", held, true, true},
+	}
+	for _, tt := range tests {
+		if _, ok := getObject(t, db, tt.object.ID); ok != tt.exists {
+			t.Errorf("%s: record exists = %v, want %v", tt.name, ok, tt.exists)
+		}
+		if _, err := os.Stat(tt.object.StoragePath); (err == nil) != tt.keepFile {
+			t.Errorf("%s: file exists = %v, want %v", tt.name, err == nil, tt.keepFile)
+		}
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("variant directory was not removed: %v", err)
+	}
+}
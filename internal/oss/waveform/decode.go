@@ -0,0 +1,229 @@
+package waveform
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// ErrUnsupported 音频格式或采样格式无法解码
+var ErrUnsupported = errors.New("waveform: unsupported audio format")
+
+// Decoder 逐块输出交错排列的 16 位采样
+type Decoder interface {
+	SampleRate() int
+	Channels() int
+	// Read 读取采样到 buf，返回读取的采样数（所有声道合计），结束时返回 io.EOF
+	Read(buf []int16) (int, error)
+}
+
+// OpenFile 按格式打开音频文件的解码器，format 为 probe 解析出的容器格式 wav/mp3
+func OpenFile(path, format string) (Decoder, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var dec Decoder
+	switch format {
+	case "wav":
+		dec, err = NewWAVDecoder(f)
+	case "mp3":
+		dec, err = NewMP3Decoder(f)
+	default:
+		err = ErrUnsupported
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return dec, f, nil
+}
+
+// ============================================================================
+// WAV
+// ============================================================================
+
+// maxFmtChunk 是 fmt 块大小的上限，WAVE_FORMAT_EXTENSIBLE 的 fmt 块也只有 40 个字节
+const maxFmtChunk = 1024
+
+type wavDecoder struct {
+	r          *bufio.Reader
+	remaining  int64 // data 块剩余字节数
+	sampleRate int
+	channels   int
+	format     uint16 // 1=整数 PCM 3=浮点
+	width      int    // 每个采样的字节数
+	buf        []byte
+}
+
+// NewWAVDecoder 解析 RIFF/WAVE 头部，支持 8/16/24/32 位整数 PCM 与 32/64 位浮点
+func NewWAVDecoder(r io.Reader) (Decoder, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	var riff [12]byte
+	if _, err := io.ReadFull(br, riff[:]); err != nil {
+		return nil, err
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, ErrUnsupported
+	}
+
+	d := &wavDecoder{r: br}
+	var hdr [8]byte
+	for {
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return nil, fmt.Errorf("waveform: no data chunk: %w", err)
+		}
+		size := int64(binary.LittleEndian.Uint32(hdr[4:8]))
+		switch string(hdr[:4]) {
+		case "fmt ":
+			// 块大小来自文件，只读取用到的前 40 个字节，其余跳过
+			if size < 16 || size > maxFmtChunk {
+				return nil, ErrUnsupported
+			}
+			chunk := make([]byte, min(size, 40))
+			if _, err := io.ReadFull(br, chunk); err != nil {
+				return nil, err
+			}
+			if _, err := br.Discard(int(size - int64(len(chunk)))); err != nil {
+				return nil, err
+			}
+			d.format = binary.LittleEndian.Uint16(chunk[0:2])
+			d.channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+			d.sampleRate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			d.width = int(binary.LittleEndian.Uint16(chunk[14:16])) / 8
+			// WAVE_FORMAT_EXTENSIBLE 的实际格式在子格式 GUID 的前两个字节
+			if d.format == 0xFFFE && size >= 26 {
+				d.format = binary.LittleEndian.Uint16(chunk[24:26])
+			}
+			if size&1 == 1 {
+				br.Discard(1)
+			}
+		case "data":
+			if d.channels == 0 {
+				return nil, errors.New("waveform: data chunk before fmt chunk")
+			}
+			if !d.supported() {
+				return nil, ErrUnsupported
+			}
+			d.remaining = size
+			return d, nil
+		default:
+			if _, err := br.Discard(int(size + size&1)); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (d *wavDecoder) supported() bool {
+	if d.channels <= 0 || d.sampleRate <= 0 {
+		return false
+	}
+	switch d.format {
+	case 1:
+		return d.width >= 1 && d.width <= 4
+	case 3:
+		return d.width == 4 || d.width == 8
+	}
+	return false
+}
+
+func (d *wavDecoder) SampleRate() int { return d.sampleRate }
+func (d *wavDecoder) Channels() int   { return d.channels }
+
+func (d *wavDecoder) Read(buf []int16) (int, error) {
+	if d.remaining < int64(d.width) {
+		return 0, io.EOF
+	}
+	n := min(int64(len(buf)*d.width), d.remaining)
+	n -= n % int64(d.width)
+	if cap(d.buf) < int(n) {
+		d.buf = make([]byte, n)
+	}
+	raw := d.buf[:n]
+	// 部分录音软件写入的 data 长度大于实际长度，读到文件末尾即结束
+	read, err := io.ReadFull(d.r, raw)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		d.remaining = 0
+		err = nil
+	} else {
+		d.remaining -= int64(read)
+	}
+	count := read / d.width
+	for i := 0; i < count; i++ {
+		buf[i] = d.sample(raw[i*d.width:])
+	}
+	if count == 0 && err == nil {
+		return 0, io.EOF
+	}
+	return count, err
+}
+
+// sample 把一个采样转为 16 位有符号整数
+func (d *wavDecoder) sample(b []byte) int16 {
+	if d.format == 3 {
+		var v float64
+		if d.width == 4 {
+			v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		} else {
+			v = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+		return int16(math.Max(-1, math.Min(1, v)) * math.MaxInt16)
+	}
+	switch d.width {
+	case 1:
+		return int16(int(b[0])-128) << 8
+	case 2:
+		return int16(binary.LittleEndian.Uint16(b))
+	case 3:
+		return int16(uint16(b[1]) | uint16(b[2])<<8)
+	default:
+		return int16(binary.LittleEndian.Uint32(b) >> 16)
+	}
+}
+
+// ============================================================================
+// MP3
+// ============================================================================
+
+type mp3Decoder struct {
+	d   *mp3.Decoder
+	buf []byte
+}
+
+// NewMP3Decoder 解码 MPEG-1/2/2.5 Layer III，输出固定为双声道
+func NewMP3Decoder(r io.Reader) (Decoder, error) {
+	d, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, fmt.Errorf("waveform: %w", err)
+	}
+	return &mp3Decoder{d: d}, nil
+}
+
+func (d *mp3Decoder) SampleRate() int { return d.d.SampleRate() }
+func (d *mp3Decoder) Channels() int   { return 2 }
+
+func (d *mp3Decoder) Read(buf []int16) (int, error) {
+	if cap(d.buf) < len(buf)*2 {
+		d.buf = make([]byte, len(buf)*2)
+	}
+	raw := d.buf[:len(buf)*2]
+	n, err := io.ReadFull(d.d, raw)
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	count := n / 2
+	for i := 0; i < count; i++ {
+		buf[i] = int16(binary.LittleEndian.Uint16(raw[i*2:]))
+	}
+	if count > 0 && err == io.EOF {
+		err = nil
+	}
+	return count, err
+}
@@ -0,0 +1,172 @@
+package waveform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+)
+
+// chunk 编码一个 RIFF 块，奇数长度补一个字节
+func chunk(id string, data []byte) []byte {
+	b := binary.LittleEndian.AppendUint32([]byte(id), uint32(len(data)))
+	b = append(b, data...)
+	if len(data)&1 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// fmtChunk 编码 16 字节的 fmt 块内容
+func fmtChunk(format, channels uint16, sampleRate uint32, bits uint16) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, format)
+	b = binary.LittleEndian.AppendUint16(b, channels)
+	b = binary.LittleEndian.AppendUint32(b, sampleRate)
+	b = binary.LittleEndian.AppendUint32(b, sampleRate*uint32(channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, channels*bits/8)
+	return binary.LittleEndian.AppendUint16(b, bits)
+}
+
+// extensible 编码 WAVE_FORMAT_EXTENSIBLE 的 40 字节 fmt 块
+func extensible(format, channels uint16, sampleRate uint32, bits uint16) []byte {
+	b := fmtChunk(0xFFFE, channels, sampleRate, bits)
+	b = binary.LittleEndian.AppendUint16(b, 22)
+	b = binary.LittleEndian.AppendUint16(b, bits)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint16(b, format)
+	return append(b, make([]byte, 14)...)
+}
+
+func wav(chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	return chunk("RIFF", body)
+}
+
+func decodeAll(t *testing.T, dec Decoder) []int16 {
+	t.Helper()
+	var out []int16
+	buf := make([]int16, 3)
+	for {
+		n, err := dec.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWAVDecoder(t *testing.T) {
+	pcm16 := binary.LittleEndian.AppendUint16(nil, 0x1234)
+	pcm16 = binary.LittleEndian.AppendUint16(pcm16, 0x8000)
+	float32le := binary.LittleEndian.AppendUint32(nil, math.Float32bits(0.5))
+	float32le = binary.LittleEndian.AppendUint32(float32le, math.Float32bits(-2))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		rate     int
+		channels int
+		samples  []int16
+	}{
+		{"pcm 16", wav(chunk("fmt ", fmtChunk(1, 2, 44100, 16)), chunk("data", pcm16)), 44100, 2, []int16{0x1234, math.MinInt16}},
+		{"pcm 8", wav(chunk("fmt ", fmtChunk(1, 1, 8000, 8)), chunk("data", []byte{128, 255, 0})), 8000, 1, []int16{0, 127 << 8, math.MinInt16}},
+		{"pcm 24", wav(chunk("fmt ", fmtChunk(1, 1, 48000, 24)), chunk("data", []byte{0xff, 0x34, 0x12})), 48000, 1, []int16{0x1234}},
+		{"float 32 clipped", wav(chunk("fmt ", fmtChunk(3, 1, 48000, 32)), chunk("data", float32le)), 48000, 1, []int16{math.MaxInt16 / 2, -math.MaxInt16}},
+		{"extensible", wav(chunk("fmt ", extensible(1, 2, 22050, 16)), chunk("data", pcm16)), 22050, 2, []int16{0x1234, math.MinInt16}},
+		// fmt 块大于 40 个字节时跳过多余部分
+		{"long fmt", wav(chunk("fmt ", append(fmtChunk(1, 1, 8000, 16), make([]byte, 101)...)), chunk("data", pcm16)), 8000, 1, []int16{0x1234, math.MinInt16}},
+		{"skipped chunks", wav(chunk("LIST", []byte("odd")), chunk("fmt ", fmtChunk(1, 1, 8000, 16)), chunk("data", pcm16)), 8000, 1, []int16{0x1234, math.MinInt16}},
+		// data 长度大于实际内容时读到文件末尾为止
+		{"truncated data", append(wav(chunk("fmt ", fmtChunk(1, 1, 8000, 16))), append([]byte("data\xff\xff\x00\x00"), pcm16...)...), 8000, 1, []int16{0x1234, math.MinInt16}},
+	}
+	for _, tt := range tests {
+		dec, err := NewWAVDecoder(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if dec.SampleRate() != tt.rate || dec.Channels() != tt.channels {
+			t.Errorf("%s: %d Hz %d channels, want %d Hz %d channels", tt.name, dec.SampleRate(), dec.Channels(), tt.rate, tt.channels)
+		}
+		if samples := decodeAll(t, dec); !slices.Equal(samples, tt.samples) {
+			t.Errorf("%s: samples %v, want %v", tt.name, samples, tt.samples)
+		}
+	}
+}
+
+func TestWAVDecoderInvalid(t *testing.T) {
+	huge := binary.LittleEndian.AppendUint32([]byte("fmt "), math.MaxUint32)
+	huge = append(huge, fmtChunk(1, 1, 8000, 16)...)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"not riff", []byte("RIFX\x04\x00\x00\x00WAVE"), ErrUnsupported},
+		{"not wave", chunk("RIFF", []byte("AVI ")), ErrUnsupported},
+		// 文件中的块大小不可信，超出上限时不分配内存
+		{"huge fmt chunk", wav(huge), ErrUnsupported},
+		{"oversized fmt chunk", wav(chunk("fmt ", append(fmtChunk(1, 1, 8000, 16), make([]byte, maxFmtChunk)...))), ErrUnsupported},
+		{"short fmt chunk", wav(chunk("fmt ", fmtChunk(1, 1, 8000, 16)[:12])), ErrUnsupported},
+		{"compressed", wav(chunk("fmt ", fmtChunk(2, 1, 8000, 4)), chunk("data", []byte{0})), ErrUnsupported},
+		{"float 16", wav(chunk("fmt ", fmtChunk(3, 1, 8000, 16)), chunk("data", []byte{0, 0})), ErrUnsupported},
+		{"no channels", wav(chunk("fmt ", fmtChunk(1, 0, 8000, 16)), chunk("data", []byte{0, 0})), nil},
+		{"no data chunk", wav(chunk("fmt ", fmtChunk(1, 1, 8000, 16))), io.EOF},
+	}
+	for _, tt := range tests {
+		_, err := NewWAVDecoder(bytes.NewReader(tt.data))
+		if err == nil || tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	pcm := make([]byte, 0, 2*10)
+	for _, s := range []int16{1, -1, 5, -5, 2, -2, 9, -9, 3, -3} {
+		pcm = binary.LittleEndian.AppendUint16(pcm, uint16(s))
+	}
+	dec, err := NewWAVDecoder(bytes.NewReader(wav(chunk("fmt ", fmtChunk(1, 2, 8000, 16)), chunk("data", pcm))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waveforms, err := Generate(dec, []int{2, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 双声道每个像素 2 帧共 4 个采样，最后不足一个像素的采样单独成为一个像素
+	if want := []int16{-5, 5, -9, 9, -3, 3}; !slices.Equal(waveforms[0].Data, want) {
+		t.Errorf("level 2 = %v, want %v", waveforms[0].Data, want)
+	}
+	if want := []int16{-9, 9, -3, 3}; waveforms[1].SamplesPerPixel != 4 || !slices.Equal(waveforms[1].Data, want) {
+		t.Errorf("level 4 = %+v, want %v", waveforms[1], want)
+	}
+
+	var buf bytes.Buffer
+	if err := waveforms[0].WriteBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadBinary(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SampleRate != 8000 || got.SamplesPerPixel != 2 || !slices.Equal(got.Data, waveforms[0].Data) {
+		t.Errorf("binary round trip = %+v", got)
+	}
+
+	for _, levels := range [][]int{nil, {0}, {4, 2}, {2, 3}} {
+		if err := ValidateLevels(levels); !errors.Is(err, ErrInvalidLevels) {
+			t.Errorf("levels %v: err = %v", levels, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package waveform
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// DefaultLevels 默认生成的各级分辨率（每像素采样数），后一级为前一级的整数倍
+var DefaultLevels = []int{256, 512, 1024, 2048, 4096, 8192}
+
+// 输出格式与 BBC audiowaveform 的 .dat/.json 一致，可直接交给 waveform-data.js、peaks.js 等前端库
+const formatVersion = 2
+
+var (
+	ErrInvalidLevels = errors.New("waveform: levels must be ascending multiples of the first level")
+	ErrEmpty         = errors.New("waveform: audio has no samples")
+)
+
+// Waveform 是一个分辨率下的峰值数据，各声道合并为一路
+type Waveform struct {
+	SampleRate      int
+	SamplesPerPixel int
+	Data            []int16 // 每个像素依次为 min、max
+}
+
+// Length 返回像素数
+func (w *Waveform) Length() int {
+	return len(w.Data) / 2
+}
+
+// ValidateLevels 检查分辨率是否升序且都是第一级的整数倍
+func ValidateLevels(levels []int) error {
+	if len(levels) == 0 || levels[0] <= 0 {
+		return ErrInvalidLevels
+	}
+	for i := 1; i < len(levels); i++ {
+		if levels[i] <= levels[i-1] || levels[i]%levels[0] != 0 {
+			return ErrInvalidLevels
+		}
+	}
+	return nil
+}
+
+// Generate 一次解码生成所有分辨率：先按第一级计算峰值，其余级别由第一级合并得到
+func Generate(dec Decoder, levels []int) ([]*Waveform, error) {
+	if err := ValidateLevels(levels); err != nil {
+		return nil, err
+	}
+	base, err := peaks(dec, levels[0])
+	if err != nil {
+		return nil, err
+	}
+	waveforms := []*Waveform{base}
+	for _, spp := range levels[1:] {
+		waveforms = append(waveforms, base.Downsample(spp/base.SamplesPerPixel))
+	}
+	return waveforms, nil
+}
+
+// peaks 计算每 samplesPerPixel 个采样帧的最小值与最大值
+func peaks(dec Decoder, samplesPerPixel int) (*Waveform, error) {
+	channels := dec.Channels()
+	w := &Waveform{SampleRate: dec.SampleRate(), SamplesPerPixel: samplesPerPixel}
+	buf := make([]int16, 64*1024-64*1024%channels)
+
+	var lo, hi int16 = math.MaxInt16, math.MinInt16
+	count := 0 // 当前像素已累计的采样数（所有声道合计）
+	for {
+		n, err := dec.Read(buf)
+		for _, s := range buf[:n] {
+			lo, hi = min(lo, s), max(hi, s)
+			count++
+			if count == samplesPerPixel*channels {
+				w.Data = append(w.Data, lo, hi)
+				lo, hi, count = math.MaxInt16, math.MinInt16, 0
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if count > 0 {
+		w.Data = append(w.Data, lo, hi)
+	}
+	if len(w.Data) == 0 {
+		return nil, ErrEmpty
+	}
+	return w, nil
+}
+
+// Downsample 每 factor 个像素合并为一个
+func (w *Waveform) Downsample(factor int) *Waveform {
+	out := &Waveform{SampleRate: w.SampleRate, SamplesPerPixel: w.SamplesPerPixel * factor}
+	for i := 0; i < w.Length(); i += factor {
+		lo, hi := int16(math.MaxInt16), int16(math.MinInt16)
+		for j := i; j < min(i+factor, w.Length()); j++ {
+			lo, hi = min(lo, w.Data[2*j]), max(hi, w.Data[2*j+1])
+		}
+		out.Data = append(out.Data, lo, hi)
+	}
+	return out
+}
+
+// WriteBinary 写出 audiowaveform 第 2 版二进制格式：24 字节小端头部后接 16 位的 min/max 对
+func (w *Waveform) WriteBinary(out io.Writer) error {
+	bw := bufio.NewWriter(out)
+	header := []int32{formatVersion, 0, int32(w.SampleRate), int32(w.SamplesPerPixel), int32(w.Length()), 1}
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, w.Data); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Header 是二进制数据的头部
+type Header struct {
+	SampleRate      int
+	SamplesPerPixel int
+	Length          int // 像素数
+}
+
+// ReadHeader 读取 WriteBinary 写出的头部，只支持单声道 16 位
+func ReadHeader(r io.Reader) (Header, error) {
+	var header [6]int32
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return Header{}, err
+	}
+	version, flags, length, channels := header[0], header[1], header[4], header[5]
+	if version != formatVersion || flags != 0 || channels != 1 || length < 0 {
+		return Header{}, fmt.Errorf("waveform: unsupported data (version %d, flags %d, channels %d)", version, flags, channels)
+	}
+	return Header{SampleRate: int(header[2]), SamplesPerPixel: int(header[3]), Length: int(length)}, nil
+}
+
+// ReadBinary 读取 WriteBinary 写出的数据
+func ReadBinary(r io.Reader) (*Waveform, error) {
+	h, err := ReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	w := &Waveform{SampleRate: h.SampleRate, SamplesPerPixel: h.SamplesPerPixel, Data: make([]int16, 2*h.Length)}
+	if err := binary.Read(r, binary.LittleEndian, w.Data); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// MarshalJSON 输出 audiowaveform 的 JSON 格式
+func (w *Waveform) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Version         int     `json:"version"`
+		Channels        int     `json:"channels"`
+		SampleRate      int     `json:"sample_rate"`
+		SamplesPerPixel int     `json:"samples_per_pixel"`
+		Bits            int     `json:"bits"`
+		Length          int     `json:"length"`
+		Data            []int16 `json:"data"`
+	}{formatVersion, 1, w.SampleRate, w.SamplesPerPixel, 16, w.Length(), w.Data})
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/ormasia/swiftstream/internal/handlers"
 )
 
-func RegFeedRoutes(app fiber.Router) {
-	app.Get("/feed", handlers.GetFeed)
+func RegFeedRoutes(app fiber.Router, feed *handlers.FeedHandler) {
+	// 按上传时间倒序的视频与图片，?cursor=&limit=
+	app.Get("/feed", feed.GetFeed)
 }
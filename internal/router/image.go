@@ -0,0 +1,11 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/handlers"
+)
+
+func RegImageRoutes(router fiber.Router, images *handlers.ImageHandler) {
+	// 实时缩放图片 ?w=&h=&fit=&q=&fmt=&sig=
+	router.Get("/images/:id", images.Transform)
+}
@@ -1,8 +1,14 @@
 package router
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/handlers"
+)
 
 type Deps struct {
+	Feed    *handlers.FeedHandler
+	Images  *handlers.ImageHandler
+	Streams *handlers.StreamHandler
 }
 
 func RegisterRoutes(app *fiber.App, deps Deps) {
@@ -12,7 +18,11 @@ func RegisterRoutes(app *fiber.App, deps Deps) {
 
 	v1 := app.Group("/v1")
 	// 注册 Feed 路由
-	RegFeedRoutes(v1)
+	RegFeedRoutes(v1, deps.Feed)
 	// 注册 Media 路由
 	RegMediaRoutes(app)
+	// 注册图片处理路由
+	RegImageRoutes(v1, deps.Images)
+	// 注册流媒体分发路由
+	RegStreamRoutes(v1, deps.Streams)
 }
@@ -0,0 +1,18 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/ormasia/swiftstream/internal/handlers"
+)
+
+func RegStreamRoutes(router fiber.Router, streams *handlers.StreamHandler) {
+	// HLS 播放列表与分片，入口为 master.m3u8
+	router.Get("/media/:id/hls/*", streams.HLS)
+	// DASH MPD 与分片，入口为 manifest.mpd
+	router.Get("/media/:id/dash/*", streams.DASH)
+	// HLS 内容密钥，需携带有效的播放令牌
+	router.Get("/media/:id/keys/:kid", streams.Key)
+	// 直播滑动窗口播放列表（支持 _HLS_msn 阻塞刷新）与分片
+	router.Get("/live/:id/index.m3u8", streams.LivePlaylist)
+	router.Get("/live/:id/:file", streams.LiveFile)
+}